@@ -7,9 +7,19 @@ import (
 	"github.com/USACE/go-consequences/consequences"
 	"github.com/USACE/go-consequences/geography"
 	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
 	"github.com/USACE/go-consequences/resultswriters"
+	"github.com/USACE/go-consequences/structures"
 )
 
+// computeRealization computes one realization of a receptor, stochastic structures are sampled from a seed derived from their own seed and the iteration.
+func computeRealization(f consequences.Receptor, d hazards.HazardEvent, iteration int64) (consequences.Result, error) {
+	if ss, ok := f.(structures.StructureStochastic); ok {
+		return ss.SampleStructure(structures.DeriveSeed(ss.SampleSeed(), iteration)).Compute(d)
+	}
+	return f.Compute(d)
+}
+
 func Aggregated_StageDamage(hps []hazardproviders.HazardProvider, sp consequences.StreamProvider, indexlocation geography.Location, terrainelevation float64, coutputfilepath string, soutputfilepath string) {
 
 	//need to allow provision of the user specified occupancy types
@@ -41,16 +51,16 @@ func Aggregated_StageDamage(hps []hazardproviders.HazardProvider, sp consequence
 		i := 0
 		maxiter := 100
 		for i < maxiter {
-			//modify structure random seed. this is probably an inefficent way to do this, but it should work.
+			//each iteration samples every structure from a seed derived from the structure's seed and the iteration.
 			cm := make(map[string]float64)
 			sm := make(map[string]float64)
 			sp.ByBbox(bbox, func(f consequences.Receptor) {
 
 				//ProvideHazard works off of a geography.Location
-				d, err2 := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
+				d, err2 := hp.Hazard(f.Location())
 				//compute damages based on hazard being able to provide depth
 				if err2 == nil {
-					r, err3 := f.Compute(d)
+					r, err3 := computeRealization(f, d, int64(i))
 					if err3 == nil {
 						damcati, err := r.Fetch("damage category")
 						if err != nil {
@@ -64,7 +74,7 @@ func Aggregated_StageDamage(hps []hazardproviders.HazardProvider, sp consequence
 							log.Fatal("error fetching structure damage")
 						}
 						smtd += sd.(float64)
-						cm[damcat] = smtd
+						sm[damcat] = smtd
 						cd, err := r.Fetch("content damage")
 						if err != nil {
 							log.Fatal("error fetching content damage")
@@ -0,0 +1,195 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// Alternative is a hazard, an inventory, or both, compared against the baseline hazard and inventory.
+type Alternative struct {
+	Name               string                              `json:"name"`
+	HazardProviderInfo *hazardproviders.HazardProviderInfo `json:"hazard_provider_info,omitempty"` //the baseline hazard is used if not set
+	InventoryOverrides []structures.InventoryOverride      `json:"inventory_overrides,omitempty"`  //applied to the baseline inventory in order
+}
+
+// AlternativeSettings describes a comparison of alternatives against the baseline described by hazard_provider_info and structure_provider_info.
+type AlternativeSettings struct {
+	Alternatives            []Alternative `json:"alternatives"`
+	AggregateOutputFilePath string        `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total benefits
+}
+
+// Validate checks every alternative has a unique name and changes the hazard or the inventory.
+func (s AlternativeSettings) Validate() error {
+	if len(s.Alternatives) == 0 {
+		return errors.New("compute: an alternative comparison requires at least one alternative")
+	}
+	names := make(map[string]bool)
+	for _, a := range s.Alternatives {
+		if a.Name == "" || a.Name == "baseline" {
+			return errors.New("compute: every alternative requires a name other than baseline")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("compute: alternative %v is listed more than once", a.Name)
+		}
+		names[a.Name] = true
+		if a.HazardProviderInfo == nil && len(a.InventoryOverrides) == 0 {
+			return fmt.Errorf("compute: alternative %v does not change the hazard or the inventory", a.Name)
+		}
+		for _, o := range a.InventoryOverrides {
+			err := o.Validate()
+			if err != nil {
+				return fmt.Errorf("alternative %v: %w", a.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AlternativeHazard is an alternative with its hazard provider opened, a nil HazardProvider uses the baseline hazard.
+type AlternativeHazard struct {
+	Name               string
+	HazardProvider     hazardproviders.HazardProvider
+	InventoryOverrides []structures.InventoryOverride
+}
+
+// CreateAlternativeHazards opens the hazard provider of each alternative that has one, every provider opened is closed if one fails.
+func CreateAlternativeHazards(alternatives []Alternative) ([]AlternativeHazard, error) {
+	out := make([]AlternativeHazard, 0, len(alternatives))
+	for _, a := range alternatives {
+		ah := AlternativeHazard{Name: a.Name, InventoryOverrides: a.InventoryOverrides}
+		if a.HazardProviderInfo != nil {
+			hp, err := a.HazardProviderInfo.CreateHazardProvider()
+			if err != nil {
+				closeAlternativeHazards(out)
+				return nil, err
+			}
+			ah.HazardProvider = hp
+		}
+		out = append(out, ah)
+	}
+	return out, nil
+}
+func closeAlternativeHazards(alternatives []AlternativeHazard) {
+	for _, a := range alternatives {
+		if a.HazardProvider != nil {
+			a.HazardProvider.Close()
+		}
+	}
+}
+
+// alternativeBoundary is the union of the baseline boundary and the boundary of every alternative hazard.
+func alternativeBoundary(baseline hazardproviders.HazardProvider, alternatives []AlternativeHazard) (geography.BBox, error) {
+	hps := []hazardproviders.HazardProvider{baseline}
+	for _, a := range alternatives {
+		if a.HazardProvider != nil {
+			hps = append(hps, a.HazardProvider)
+		}
+	}
+	return frequencyBoundary(hps)
+}
+
+// alternativeHeaders names the consequence columns of the baseline, or of an alternative along with its reductions from the baseline.
+func alternativeHeaders(name string, lle *lifeloss.LifeLossEngine) []string {
+	h := make([]string, 0)
+	for _, m := range eadMeasures(lle) {
+		h = append(h, name+" "+m)
+	}
+	if name == "baseline" {
+		return h
+	}
+	h = append(h, name+" damage reduction")
+	if lle != nil {
+		h = append(h, name+" life loss reduction")
+	}
+	return h
+}
+
+// CompareAlternatives computes every structure in stream under the baseline hazard and inventory and under each alternative. Each structure is sampled once from its seed, an alternative's inventory overrides are applied to that structure, so the alternatives differ from the baseline only by their hazards and overrides. Bought out structures have no consequences in that alternative, and it is an error if an override cannot be applied to a structure, such as raising one without a ground elevation to a first floor elevation. Per structure damages, life loss if lle is not nil, and the reductions from the baseline are written to w, and the same values summed by damage category, county and in total to aw if it is not nil.
+func CompareAlternatives(baseline hazardproviders.HazardProvider, alternatives []AlternativeHazard, stream func(sp consequences.StreamProcessor), seed int64, lle *lifeloss.LifeLossEngine, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	if len(alternatives) == 0 {
+		return errors.New("compute: an alternative comparison requires at least one alternative")
+	}
+	valueHeaders := alternativeHeaders("baseline", lle)
+	for _, a := range alternatives {
+		valueHeaders = append(valueHeaders, alternativeHeaders(a.Name, lle)...)
+	}
+	header := append(structureHeader(), valueHeaders...)
+	groups := make(eadGroups)
+	index := 0
+	var computeErr error
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		if computeErr != nil {
+			return
+		}
+		receptorSeed := receptorSeed(seed, f, index)
+		sd, ok := sampleReceptor(f, receptorSeed)
+		if !ok {
+			return
+		}
+		consequencesOf := func(r consequences.Receptor, rsd structures.StructureDeterministic, hp hazardproviders.HazardProvider) ([]float64, bool) {
+			curves, wet := damageFrequency(r, rsd, receptorSeed, []hazardproviders.HazardProvider{hp}, lle)
+			values := make([]float64, len(curves))
+			for i, c := range curves {
+				values[i] = c[0]
+			}
+			return values, wet
+		}
+		base, wet := consequencesOf(f, sd, baseline)
+		values := append([]float64{}, base...)
+		for _, a := range alternatives {
+			hp := a.HazardProvider
+			if hp == nil {
+				hp = baseline
+			}
+			altValues := make([]float64, len(base))
+			var modified consequences.Receptor
+			var msd structures.StructureDeterministic
+			standing := true
+			var err error
+			switch s := f.(type) {
+			case structures.StructureStochastic:
+				var ms structures.StructureStochastic
+				ms, standing, err = structures.ApplyOverrides(a.InventoryOverrides, s)
+				modified, msd = ms, ms.SampleStructure(receptorSeed)
+			default:
+				msd, standing, err = structures.ApplyOverridesDeterministic(a.InventoryOverrides, sd)
+				modified = msd
+			}
+			if err != nil {
+				computeErr = fmt.Errorf("alternative %v: %w", a.Name, err)
+				return
+			}
+			if standing {
+				var altWet bool
+				altValues, altWet = consequencesOf(modified, msd, hp)
+				wet = wet || altWet
+			}
+			values = append(values, altValues...)
+			values = append(values, (base[0]-altValues[0])+(base[1]-altValues[1]))
+			if lle != nil {
+				values = append(values, base[2]-altValues[2])
+			}
+		}
+		if !wet {
+			return
+		}
+		groups.add(sd, values)
+		result := structureAttributes(sd)
+		for _, v := range values {
+			result = append(result, v)
+		}
+		w.Write(consequences.Result{Headers: header, Result: result})
+	})
+	if computeErr != nil || aw == nil {
+		return computeErr
+	}
+	groups.write(valueHeaders, aw)
+	return nil
+}
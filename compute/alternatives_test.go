@@ -0,0 +1,118 @@
+package compute
+
+import (
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/structures"
+)
+
+func TestCompareAlternatives_Reductions(t *testing.T) {
+	sp := testStructureStream{count: 10, seed: 7}
+	stream := func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }
+	alternatives := []AlternativeHazard{
+		{Name: "levee", HazardProvider: constantDepthHazardProvider{depth: 1}},
+		{Name: "raise", InventoryOverrides: []structures.InventoryOverride{{RaiseFoundationBy: 2}}},
+		{Name: "buyout", InventoryOverrides: []structures.InventoryOverride{{FdIds: []string{"3"}, Buyout: true}}},
+	}
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := CompareAlternatives(constantDepthHazardProvider{depth: 5}, alternatives, stream, 7, nil, w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	for _, r := range w.results {
+		name, _ := r.Fetch("fd_id")
+		bs, _ := r.Fetch("baseline structure damage")
+		bc, _ := r.Fetch("baseline content damage")
+		baseline := bs.(float64) + bc.(float64)
+		for _, a := range alternatives {
+			reduction, _ := r.Fetch(a.Name + " damage reduction")
+			s, _ := r.Fetch(a.Name + " structure damage")
+			c, _ := r.Fetch(a.Name + " content damage")
+			if math.Abs(baseline-s.(float64)-c.(float64)-reduction.(float64)) > 1e-9 {
+				t.Errorf("%v reduction %v for structure %v was not baseline less alternative", a.Name, reduction, name)
+			}
+			if a.Name == "buyout" {
+				expected := 0.0
+				if name == "3" {
+					expected = baseline
+				}
+				if reduction.(float64) != expected {
+					t.Errorf("buyout reduced damage to structure %v by %v; expected %v", name, reduction, expected)
+				}
+			} else if reduction.(float64) <= 0 {
+				t.Errorf("%v did not reduce damage to structure %v", a.Name, name)
+			}
+		}
+	}
+	totals := false
+	for _, r := range aw.results {
+		if g, _ := r.Fetch("group"); g == "total" {
+			totals = true
+		}
+	}
+	if !totals {
+		t.Error("no total benefits were written")
+	}
+}
+func TestCompareAlternatives_DeterministicOverrides(t *testing.T) {
+	sp := testStructureStream{count: 4, seed: 7}
+	stream := func(p consequences.StreamProcessor) {
+		sp.ByBbox(geography.BBox{}, func(f consequences.Receptor) {
+			p(f.(structures.StructureStochastic).SampleStructure(1))
+		})
+	}
+	alternatives := []AlternativeHazard{
+		{Name: "raise", InventoryOverrides: []structures.InventoryOverride{{RaiseFoundationBy: 2}}},
+		{Name: "buyout", InventoryOverrides: []structures.InventoryOverride{{Buyout: true}}},
+	}
+	w := &collectingResultsWriter{}
+	err := CompareAlternatives(constantDepthHazardProvider{depth: 5}, alternatives, stream, 7, nil, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range w.results {
+		name, _ := r.Fetch("fd_id")
+		bs, _ := r.Fetch("baseline structure damage")
+		bc, _ := r.Fetch("baseline content damage")
+		if reduction, _ := r.Fetch("raise damage reduction"); reduction.(float64) <= 0 {
+			t.Errorf("raise did not reduce damage to deterministic structure %v", name)
+		}
+		if reduction, _ := r.Fetch("buyout damage reduction"); reduction.(float64) != bs.(float64)+bc.(float64) {
+			t.Errorf("buyout reduced damage to deterministic structure %v by %v; expected all of it", name, reduction)
+		}
+	}
+	ffe := 20.0
+	elevate := []AlternativeHazard{{Name: "elevate", InventoryOverrides: []structures.InventoryOverride{{FirstFloorElevation: &ffe}}}}
+	noGround := func(p consequences.StreamProcessor) {
+		sp.ByBbox(geography.BBox{}, func(f consequences.Receptor) {
+			sd := f.(structures.StructureStochastic).SampleStructure(1)
+			sd.HasGroundElevation = false
+			p(sd)
+		})
+	}
+	err = CompareAlternatives(constantDepthHazardProvider{depth: 5}, elevate, noGround, 7, nil, &collectingResultsWriter{}, nil)
+	if err == nil {
+		t.Error("expected raising structures without a ground elevation to a first floor elevation to be an error")
+	}
+}
+func TestAlternativeSettings_Validate(t *testing.T) {
+	s := AlternativeSettings{Alternatives: []Alternative{{Name: "nothing"}}}
+	if s.Validate() == nil {
+		t.Error("expected an error for an alternative that changes nothing")
+	}
+	s = AlternativeSettings{Alternatives: []Alternative{
+		{Name: "a", HazardProviderInfo: &hazardproviders.HazardProviderInfo{}},
+		{Name: "a", HazardProviderInfo: &hazardproviders.HazardProviderInfo{}},
+	}}
+	if s.Validate() == nil {
+		t.Error("expected an error for a repeated alternative name")
+	}
+}
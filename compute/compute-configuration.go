@@ -1,11 +1,11 @@
 package compute
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 
 	"github.com/USACE/go-consequences/consequences"
-	"github.com/USACE/go-consequences/geography"
 	"github.com/USACE/go-consequences/hazardproviders"
 	"github.com/USACE/go-consequences/hazards"
 	"github.com/USACE/go-consequences/lifeloss"
@@ -21,29 +21,257 @@ type Config struct {
 	structureprovider.StructureProviderInfo `json:"structure_provider_info"`
 	hazardproviders.HazardProviderInfo      `json:"hazard_provider_info"`
 	resultswriters.ResultsWriterInfo        `json:"results_writer_info"`
-	ComputeLifeloss                         bool    `json:"compute_lifeloss"`
-	LifelossSeed                            int64   `json:"lifeloss_seed,omitempty"`
-	ComplianceRate                          float64 `json:"warning_compliance_rate"`
-	ComputeByFips                           bool    `json:"compute_by_fips"`
-	FipsCode                                string  `json:"fips_code"`
+	ComputeLifeloss                         bool                      `json:"compute_lifeloss"`
+	Seed                                    int64                     `json:"seed,omitempty"`          //master seed, every structure's random stream is derived from it and the structure's fd_id
+	LifelossSeed                            int64                     `json:"lifeloss_seed,omitempty"` //deprecated, used as the master seed when seed is not set
+	ComplianceRate                          float64                   `json:"warning_compliance_rate"`
+	ComputeByFips                           bool                      `json:"compute_by_fips"`
+	FipsCode                                string                    `json:"fips_code"`
+	Workers                                 int                       `json:"workers,omitempty"`                  //zero computes sequentially
+	MonteCarlo                              *MonteCarloSettings       `json:"monte_carlo,omitempty"`              //when provided, realizations are drawn until convergence and statistics are written instead of a single realization
+	EAD                                     *EADSettings              `json:"ead,omitempty"`                      //when provided, expected annual damages are computed from the events and hazard_provider_info is not used
+	EquivalentAnnual                        *EquivalentAnnualSettings `json:"equivalent_annual_damage,omitempty"` //when provided, equivalent annual damages are computed over the period of analysis and hazard_provider_info is not used
+	Alternatives                            *AlternativeSettings      `json:"alternatives,omitempty"`             //when provided, each alternative is compared against the baseline hazard and inventory
+	Mitigation                              *MitigationSettings       `json:"mitigation,omitempty"`               //when provided, the benefits and costs of the mitigation measures are computed from the events and hazard_provider_info is not used
+	Lifecycle                               *LifecycleSettings        `json:"lifecycle,omitempty"`                //when provided, storm sequences are sampled from the storms and hazard_provider_info is not used
+	Exposure                                *ExposureSettings         `json:"exposure,omitempty"`                 //when provided, the structures in the wet area of the hazard are reported without computing damages
+	ComputeReconstruction                   bool                      `json:"compute_reconstruction,omitempty"`   //adds the days to reconstruct each structure, multi hazard events are rebuilt between events by rebuild_rules
+	RebuildRules                            *structures.RebuildRules  `json:"rebuild_rules,omitempty"`            //rebuild rules for compute_reconstruction and lifecycle, every event is fully rebuilt if not set
 }
 type Computeable struct {
 	structureprovider.StructureProvider
 	hazardproviders.HazardProvider
 	consequences.ResultsWriter
 	ComputeLifeloss bool
-	LifelossSeed    int64
+	Seed            int64
+	LifelossSeed    int64 //deprecated, used as the master seed when Seed is not set
 	ComplianceRate  float64
 	ComputeByFips   bool
 	FipsCode        string
+	Workers         int
+	MonteCarlo      *MonteCarloSettings
+	//ComputeReconstruction reports reconstruction days and rebuilds multi hazard events according to RebuildRules.
+	ComputeReconstruction bool
+	RebuildRules          structures.RebuildRules
+	//FrequencyHazardProviders and Frequencies are the ead events ordered from most to least frequent.
+	FrequencyHazardProviders []hazardproviders.HazardProvider
+	Frequencies              []float64
+	//PeriodOfAnalysis, WithoutProject and WithProject describe an equivalent annual damage compute, analysis years are in increasing order.
+	PeriodOfAnalysis *PeriodOfAnalysis
+	WithoutProject   []AnalysisYearHazards
+	WithProject      []AnalysisYearHazards
+	TailMethod       TailMethod
+	//Alternatives are compared against HazardProvider and the unmodified inventory.
+	Alternatives []AlternativeHazard
+	//Lifecycle samples storm sequences from the Storms and rebuilds between them according to RebuildRules.
+	Lifecycle *LifecycleSettings
+	Storms    []CatalogStorm
+	//MitigationMeasures are evaluated against the FrequencyHazardProviders, their costs are annualized over the PeriodOfAnalysis.
+	MitigationMeasures []structures.MitigationMeasure
+	//Exposure reports the structures in the wet area of HazardProvider without computing damages.
+	Exposure *ExposureSettings
+	//AggregateResultsWriter receives the monte carlo statistics, expected annual damages, exposure, or benefits by damage category, county and in total, it may be nil.
+	AggregateResultsWriter consequences.ResultsWriter
 }
 
+// MasterSeed is the seed structures are sampled from, falling back to the lifeloss seed so configurations written before seed existed reproduce their lifeloss draws.
+func (config Config) MasterSeed() int64 {
+	if config.Seed != 0 {
+		return config.Seed
+	}
+	return config.LifelossSeed
+}
+
+// validateMode checks that at most one of monte_carlo, ead, equivalent_annual_damage, alternatives, mitigation, lifecycle and exposure is requested and that its settings are valid, every problem found is reported.
+func (config Config) validateMode() error {
+	modes := 0
+	errs := make([]error, 0)
+	if config.MonteCarlo != nil {
+		modes++
+		errs = append(errs, config.MonteCarlo.Validate())
+	}
+	if config.EAD != nil {
+		modes++
+		errs = append(errs, config.EAD.Validate())
+	}
+	if config.EquivalentAnnual != nil {
+		modes++
+		errs = append(errs, config.EquivalentAnnual.Validate())
+	}
+	if config.Alternatives != nil {
+		modes++
+		errs = append(errs, config.Alternatives.Validate())
+	}
+	if config.Mitigation != nil {
+		modes++
+		errs = append(errs, config.Mitigation.Validate())
+	}
+	if config.Lifecycle != nil {
+		modes++
+		errs = append(errs, config.Lifecycle.Validate())
+	}
+	if config.Exposure != nil {
+		modes++
+		errs = append(errs, config.Exposure.Validate())
+	}
+	if config.RebuildRules != nil {
+		errs = append(errs, config.RebuildRules.Validate())
+	}
+	if config.ComputeReconstruction {
+		if modes > 0 || config.ComputeLifeloss {
+			errs = append(errs, errors.New("compute: compute_reconstruction can only be requested for a single event compute without lifeloss"))
+		}
+	}
+	if modes > 1 {
+		errs = append(errs, errors.New("compute: only one of monte_carlo, ead, equivalent_annual_damage, alternatives, mitigation, lifecycle and exposure can be requested"))
+	}
+	return errors.Join(errs...)
+}
+
+// HazardInput is a hazard the configuration reads, labeled by where it appears in the configuration.
+type HazardInput struct {
+	Label string
+	hazardproviders.HazardProviderInfo
+}
+
+// HazardInputs lists the hazards the requested compute reads, in the order they appear in the configuration.
+func (config Config) HazardInputs() []HazardInput {
+	inputs := make([]HazardInput, 0)
+	events := func(label string, events []FrequencyEvent) {
+		for _, e := range events {
+			inputs = append(inputs, HazardInput{Label: fmt.Sprintf("%v aep %v", label, e.AnnualExceedanceProbability), HazardProviderInfo: e.HazardProviderInfo})
+		}
+	}
+	switch {
+	case config.EAD != nil:
+		events("ead", config.EAD.Events)
+	case config.Mitigation != nil:
+		events("mitigation", config.Mitigation.Events)
+	case config.EquivalentAnnual != nil:
+		for _, y := range config.EquivalentAnnual.WithoutProject {
+			events(fmt.Sprintf("without project year %v", y.Year), y.Events)
+		}
+		for _, y := range config.EquivalentAnnual.WithProject {
+			events(fmt.Sprintf("with project year %v", y.Year), y.Events)
+		}
+	case config.Lifecycle != nil:
+		for _, s := range config.Lifecycle.Storms {
+			inputs = append(inputs, HazardInput{Label: "storm " + s.Name, HazardProviderInfo: s.HazardProviderInfo})
+		}
+	default:
+		inputs = append(inputs, HazardInput{Label: "hazard_provider_info", HazardProviderInfo: config.HazardProviderInfo})
+		if config.Alternatives != nil {
+			for _, a := range config.Alternatives.Alternatives {
+				if a.HazardProviderInfo != nil {
+					inputs = append(inputs, HazardInput{Label: "alternative " + a.Name, HazardProviderInfo: *a.HazardProviderInfo})
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+// Validate reports every problem with the configuration it can find before any dataset is opened: invalid mode settings, missing files, unknown hazard parameters and incomplete structure provider and results writer settings.
+func (config Config) Validate() error {
+	errs := []error{config.validateMode(), config.StructureProviderInfo.Validate(), config.ResultsWriterInfo.Validate()}
+	for _, h := range config.HazardInputs() {
+		err := h.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", h.Label, err))
+		}
+	}
+	if fp := config.AggregateOutputFilePath(); fp != "" {
+		errs = append(errs, resultswriters.CheckOutputFilePath("aggregate_output_file_path", fp))
+	}
+	return errors.Join(errs...)
+}
+
+// AggregateOutputFilePath is the aggregate output file of the requested mode, empty if there is none.
+func (config Config) AggregateOutputFilePath() string {
+	switch {
+	case config.MonteCarlo != nil:
+		return config.MonteCarlo.AggregateOutputFilePath
+	case config.EAD != nil:
+		return config.EAD.AggregateOutputFilePath
+	case config.EquivalentAnnual != nil:
+		return config.EquivalentAnnual.AggregateOutputFilePath
+	case config.Alternatives != nil:
+		return config.Alternatives.AggregateOutputFilePath
+	case config.Mitigation != nil:
+		return config.Mitigation.AggregateOutputFilePath
+	case config.Lifecycle != nil:
+		return config.Lifecycle.AggregateOutputFilePath
+	case config.Exposure != nil:
+		return config.Exposure.AggregateOutputFilePath
+	}
+	return ""
+}
+
+// createFrequencyHazards opens a hazard provider for each event, every provider opened is closed if one fails.
+func createFrequencyHazards(events []FrequencyEvent) ([]hazardproviders.HazardProvider, []float64, error) {
+	hps := make([]hazardproviders.HazardProvider, 0, len(events))
+	freqs := make([]float64, 0, len(events))
+	for _, e := range events {
+		hp, err := e.CreateHazardProvider()
+		if err != nil {
+			for _, opened := range hps {
+				opened.Close()
+			}
+			return nil, nil, err
+		}
+		hps = append(hps, hp)
+		freqs = append(freqs, e.AnnualExceedanceProbability)
+	}
+	return hps, freqs, nil
+}
+
+// CreateComputable validates the configuration and opens its providers and writers.
 func (config Config) CreateComputable() (Computeable, error) {
+	err := config.Validate()
+	if err != nil {
+		return Computeable{}, err
+	}
 	sp, err := config.CreateStructureProvider()
 	if err != nil {
 		return Computeable{}, err
 	}
-	hp, err := config.CreateHazardProvider()
+	if ssp, ok := sp.(structureprovider.SeedableStructureProvider); ok {
+		ssp.SetSeed(config.MasterSeed())
+	}
+	var hp hazardproviders.HazardProvider
+	var fhps []hazardproviders.HazardProvider
+	var freqs []float64
+	var without, with []AnalysisYearHazards
+	var alternatives []AlternativeHazard
+	var measures []structures.MitigationMeasure
+	var storms []CatalogStorm
+	switch {
+	case config.EAD != nil:
+		fhps, freqs, err = createFrequencyHazards(config.EAD.SortedEvents())
+	case config.Mitigation != nil:
+		measures, err = config.Mitigation.CreateMitigationMeasures()
+		if err == nil {
+			fhps, freqs, err = createFrequencyHazards(EADSettings{Events: config.Mitigation.Events}.SortedEvents())
+		}
+	case config.Lifecycle != nil:
+		storms, err = CreateStormCatalog(config.Lifecycle.Storms)
+	case config.EquivalentAnnual != nil:
+		without, err = CreateAnalysisYearHazards(config.EquivalentAnnual.WithoutProject)
+		if err == nil {
+			with, err = CreateAnalysisYearHazards(config.EquivalentAnnual.WithProject)
+			if err != nil {
+				closeAnalysisYearHazards(without)
+			}
+		}
+	default:
+		hp, err = config.CreateHazardProvider()
+		if err == nil && config.Alternatives != nil {
+			alternatives, err = CreateAlternativeHazards(config.Alternatives.Alternatives)
+			if err != nil {
+				hp.Close()
+			}
+		}
+	}
 	if err != nil {
 		return Computeable{}, err
 	}
@@ -51,18 +279,99 @@ func (config Config) CreateComputable() (Computeable, error) {
 	if err != nil {
 		return Computeable{}, err
 	}
+	var aw consequences.ResultsWriter
+	if fp := config.AggregateOutputFilePath(); fp != "" {
+		aw = resultswriters.InitJsonResultsWriterFromFile(fp)
+	}
+	tail := TrapezoidalTail
+	if config.EAD != nil && config.EAD.TailMethod != "" {
+		tail = config.EAD.TailMethod
+	}
+	if config.EquivalentAnnual != nil && config.EquivalentAnnual.TailMethod != "" {
+		tail = config.EquivalentAnnual.TailMethod
+	}
+	if config.Mitigation != nil && config.Mitigation.TailMethod != "" {
+		tail = config.Mitigation.TailMethod
+	}
+	rules := structures.DefaultRebuildRules()
+	if config.RebuildRules != nil {
+		rules = *config.RebuildRules
+	}
+	var poa *PeriodOfAnalysis
+	if config.EquivalentAnnual != nil {
+		poa = &config.EquivalentAnnual.PeriodOfAnalysis
+	}
+	if config.Mitigation != nil {
+		poa = &config.Mitigation.PeriodOfAnalysis
+	}
 	return Computeable{
-		StructureProvider: sp,
-		HazardProvider:    hp,
-		ResultsWriter:     rw,
-		ComputeLifeloss:   config.ComputeLifeloss,
-		LifelossSeed:      config.LifelossSeed,
-		ComplianceRate:    config.ComplianceRate,
-		ComputeByFips:     config.ComputeByFips,
-		FipsCode:          config.FipsCode,
+		StructureProvider:        sp,
+		HazardProvider:           hp,
+		ResultsWriter:            rw,
+		ComputeLifeloss:          config.ComputeLifeloss,
+		ComputeReconstruction:    config.ComputeReconstruction,
+		RebuildRules:             rules,
+		Seed:                     config.MasterSeed(),
+		LifelossSeed:             config.LifelossSeed,
+		ComplianceRate:           config.ComplianceRate,
+		ComputeByFips:            config.ComputeByFips,
+		FipsCode:                 config.FipsCode,
+		Workers:                  config.Workers,
+		MonteCarlo:               config.MonteCarlo,
+		FrequencyHazardProviders: fhps,
+		Frequencies:              freqs,
+		PeriodOfAnalysis:         poa,
+		WithoutProject:           without,
+		WithProject:              with,
+		TailMethod:               tail,
+		Alternatives:             alternatives,
+		MitigationMeasures:       measures,
+		Lifecycle:                config.Lifecycle,
+		Storms:                   storms,
+		Exposure:                 config.Exposure,
+		AggregateResultsWriter:   aw,
 	}, nil
 }
+func (computable Computeable) masterSeed() int64 {
+	if computable.Seed != 0 {
+		return computable.Seed
+	}
+	return computable.LifelossSeed
+}
+
+// Compute runs the compute the configuration selects, it is an error if the structure stream stopped early.
 func (computable Computeable) Compute() error {
+	err := computable.compute()
+	if err != nil {
+		return err
+	}
+	return structureprovider.StreamError(computable.StructureProvider)
+}
+func (computable Computeable) compute() error {
+	if computable.MonteCarlo != nil {
+		return computable.computeMonteCarlo()
+	}
+	if computable.Exposure != nil {
+		return computable.computeExposure()
+	}
+	if computable.Lifecycle != nil {
+		return computable.computeLifecycles()
+	}
+	if len(computable.MitigationMeasures) > 0 {
+		return computable.computeMitigation()
+	}
+	if len(computable.FrequencyHazardProviders) > 0 {
+		return computable.computeEAD()
+	}
+	if computable.PeriodOfAnalysis != nil {
+		return computable.computeEquivalentAnnual()
+	}
+	if len(computable.Alternatives) > 0 {
+		return computable.computeAlternatives()
+	}
+	if computable.ComputeReconstruction {
+		return computable.computeWithReconstruction()
+	}
 	if computable.ComputeLifeloss {
 		if computable.ComputeByFips {
 			return computable.computeWithLifelossByFips(computable.HazardProvider, computable.StructureProvider, computable.ResultsWriter)
@@ -70,20 +379,225 @@ func (computable Computeable) Compute() error {
 			return computable.computeWithLifelossByBbox(computable.HazardProvider, computable.StructureProvider, computable.ResultsWriter)
 		}
 	} else {
-		StreamAbstract(computable.HazardProvider, computable.StructureProvider, computable.ResultsWriter) //bybbox. need to add logic for fips.
+		if computable.Workers > 0 {
+			err := StreamAbstractConcurrent(computable.HazardProvider, computable.StructureProvider, computable.ResultsWriter, computable.Workers, computable.masterSeed())
+			if err != nil {
+				return err
+			}
+		} else {
+			StreamAbstract(computable.HazardProvider, computable.StructureProvider, computable.ResultsWriter) //bybbox. need to add logic for fips.
+		}
 		computable.ResultsWriter.Close()
 	}
 
 	return nil
 }
-func (computable Computeable) computeWithLifelossByFips(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, w consequences.ResultsWriter) error {
 
-	rng := rand.New(rand.NewSource(computable.LifelossSeed))
-	warningSystem := warning.InitComplianceBasedWarningSystem(rng.Int63(), computable.ComplianceRate)
-	lle := lifeloss.Init(rng.Int63(), warningSystem)
+// computeMonteCarlo streams by fips or by the hazard boundary and writes monte carlo statistics, life loss is included if requested.
+func (computable Computeable) computeMonteCarlo() error {
+	defer computable.closeWriters()
+	stream, err := computable.hazardStructureStream()
+	if err != nil {
+		return err
+	}
+	return MonteCarlo(computable.HazardProvider, stream, *computable.MonteCarlo, computable.masterSeed(), computable.lifelossEngine(), computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeExposure streams by fips or by the hazard boundary and writes the exposure of each structure, and by group to the aggregate writer.
+func (computable Computeable) computeExposure() error {
+	defer computable.closeWriters()
+	stream, err := computable.hazardStructureStream()
+	if err != nil {
+		return err
+	}
+	return Exposure(computable.HazardProvider, stream, *computable.Exposure, computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// closeWriters closes the aggregate results writer if there is one, then the results writer.
+func (computable Computeable) closeWriters() {
+	if computable.AggregateResultsWriter != nil {
+		computable.AggregateResultsWriter.Close()
+	}
+	computable.ResultsWriter.Close()
+}
+
+// hazardStructureStream streams by fips, or by the hazard boundary one tile at a time if the hazard is tiled.
+func (computable Computeable) hazardStructureStream() (func(consequences.StreamProcessor), error) {
+	if computable.ComputeByFips {
+		return func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByFips(computable.FipsCode, p)
+		}, nil
+	}
+	return hazardStream(computable.HazardProvider, computable.StructureProvider)
+}
+
+// computeEAD streams by fips or by the union of the event boundaries and writes expected annual damages, equivalent annual life loss is included if requested.
+func (computable Computeable) computeEAD() error {
+	defer computable.ResultsWriter.Close()
+	if computable.AggregateResultsWriter != nil {
+		defer computable.AggregateResultsWriter.Close()
+	}
+	for _, hp := range computable.FrequencyHazardProviders {
+		defer hp.Close()
+	}
+	seed := computable.masterSeed()
+	lle := computable.lifelossEngine()
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		bbox, err := frequencyBoundary(computable.FrequencyHazardProviders)
+		if err != nil {
+			return err
+		}
+		stream = func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByBbox(bbox, p)
+		}
+	}
+	return ExpectedAnnualDamages(computable.FrequencyHazardProviders, computable.Frequencies, computable.TailMethod, stream, seed, lle, computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeLifecycles streams by fips or by the union of the storm boundaries and writes lifecycle statistics.
+func (computable Computeable) computeLifecycles() error {
+	defer computable.ResultsWriter.Close()
+	if computable.AggregateResultsWriter != nil {
+		defer computable.AggregateResultsWriter.Close()
+	}
+	defer closeStormCatalog(computable.Storms)
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		bbox, err := stormCatalogBoundary(computable.Storms)
+		if err != nil {
+			return err
+		}
+		stream = func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByBbox(bbox, p)
+		}
+	}
+	settings := computable.Lifecycle
+	return LifecycleSimulation(computable.Storms, settings.PeriodOfAnalysis, settings.Lifecycles, settings.Percentiles, computable.RebuildRules, stream, computable.masterSeed(), computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeMitigation streams by fips or by the union of the event boundaries and writes the benefits and costs of the mitigation measures.
+func (computable Computeable) computeMitigation() error {
+	defer computable.ResultsWriter.Close()
+	if computable.AggregateResultsWriter != nil {
+		defer computable.AggregateResultsWriter.Close()
+	}
+	for _, hp := range computable.FrequencyHazardProviders {
+		defer hp.Close()
+	}
+	if computable.PeriodOfAnalysis == nil {
+		return errors.New("compute: mitigation requires a period of analysis")
+	}
+	seed := computable.masterSeed()
+	lle := computable.lifelossEngine()
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		bbox, err := frequencyBoundary(computable.FrequencyHazardProviders)
+		if err != nil {
+			return err
+		}
+		stream = func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByBbox(bbox, p)
+		}
+	}
+	return MitigationBenefitCost(computable.FrequencyHazardProviders, computable.Frequencies, computable.TailMethod, *computable.PeriodOfAnalysis, computable.MitigationMeasures, stream, seed, lle, computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeEquivalentAnnual streams by fips or by the union of every analysis year's event boundaries and writes equivalent annual damages and benefits.
+func (computable Computeable) computeEquivalentAnnual() error {
+	defer computable.ResultsWriter.Close()
+	if computable.AggregateResultsWriter != nil {
+		defer computable.AggregateResultsWriter.Close()
+	}
+	defer closeAnalysisYearHazards(computable.WithoutProject)
+	defer closeAnalysisYearHazards(computable.WithProject)
+	seed := computable.masterSeed()
+	lle := computable.lifelossEngine()
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		bbox, err := analysisYearBoundary(computable.WithoutProject, computable.WithProject)
+		if err != nil {
+			return err
+		}
+		stream = func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByBbox(bbox, p)
+		}
+	}
+	return EquivalentAnnualDamages(*computable.PeriodOfAnalysis, computable.WithoutProject, computable.WithProject, computable.TailMethod, stream, seed, lle, computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeAlternatives streams by fips or by the union of the baseline and alternative hazard boundaries and writes each alternative's consequences and reductions from the baseline.
+func (computable Computeable) computeAlternatives() error {
+	defer computable.ResultsWriter.Close()
+	if computable.AggregateResultsWriter != nil {
+		defer computable.AggregateResultsWriter.Close()
+	}
+	defer closeAlternativeHazards(computable.Alternatives)
+	seed := computable.masterSeed()
+	lle := computable.lifelossEngine()
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		bbox, err := alternativeBoundary(computable.HazardProvider, computable.Alternatives)
+		if err != nil {
+			return err
+		}
+		stream = func(p consequences.StreamProcessor) {
+			computable.StructureProvider.ByBbox(bbox, p)
+		}
+	}
+	return CompareAlternatives(computable.HazardProvider, computable.Alternatives, stream, seed, lle, computable.ResultsWriter, computable.AggregateResultsWriter)
+}
+
+// computeWithReconstruction streams by fips or by the hazard boundary and writes damages with reconstruction, sequentially unless workers are requested.
+func (computable Computeable) computeWithReconstruction() error {
+	defer computable.ResultsWriter.Close()
+	stream := func(p consequences.StreamProcessor) {
+		computable.StructureProvider.ByFips(computable.FipsCode, p)
+	}
+	if !computable.ComputeByFips {
+		var err error
+		stream, err = hazardStream(computable.HazardProvider, computable.StructureProvider)
+		if err != nil {
+			return err
+		}
+	}
+	workers := computable.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	return ComputeConcurrently(workers, computable.masterSeed(), computable.HazardProvider, stream, ReconstructionCompute(computable.RebuildRules), computable.ResultsWriter)
+}
+
+// lifelossEngine returns nil unless lifeloss was requested.
+func (computable Computeable) lifelossEngine() *lifeloss.LifeLossEngine {
+	if !computable.ComputeLifeloss {
+		return nil
+	}
+	seed := computable.masterSeed()
+	engine := lifeloss.Init(seed, warning.InitComplianceBasedWarningSystem(seed, computable.ComplianceRate))
+	return &engine
+}
+func (computable Computeable) computeWithLifelossByFips(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, w consequences.ResultsWriter) error {
+	if computable.Workers > 0 {
+		return computable.computeWithLifelossConcurrently(hp, func(p consequences.StreamProcessor) {
+			sp.ByFips(computable.FipsCode, p)
+		}, w)
+	}
+	seed := computable.masterSeed()
+	lle := lifeloss.Init(seed, warning.InitComplianceBasedWarningSystem(seed, computable.ComplianceRate))
 	//err := errors.New("error")
 	sp.ByFips(computable.FipsCode, func(f consequences.Receptor) {
-		err := computeLifelossPerStructure(hp, f, rng, lle, w)
+		err := computeLifelossPerStructure(hp, f, seed, lle, w)
 		if err != nil {
 			log.Println(err)
 		}
@@ -91,78 +605,106 @@ func (computable Computeable) computeWithLifelossByFips(hp hazardproviders.Hazar
 	return nil
 }
 func (computable Computeable) computeWithLifelossByBbox(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, w consequences.ResultsWriter) error {
-
-	rng := rand.New(rand.NewSource(computable.LifelossSeed))
-	warningSystem := warning.InitComplianceBasedWarningSystem(rng.Int63(), computable.ComplianceRate)
-	lle := lifeloss.Init(rng.Int63(), warningSystem)
-	bbox, err := hp.HazardBoundary()
+	stream, err := hazardStream(hp, sp)
 	if err != nil {
 		return err
 	}
-	sp.ByBbox(bbox, func(f consequences.Receptor) {
-		err := computeLifelossPerStructure(hp, f, rng, lle, w)
+	if computable.Workers > 0 {
+		return computable.computeWithLifelossConcurrently(hp, stream, w)
+	}
+	seed := computable.masterSeed()
+	lle := lifeloss.Init(seed, warning.InitComplianceBasedWarningSystem(seed, computable.ComplianceRate))
+	stream(func(f consequences.Receptor) {
+		err := computeLifelossPerStructure(hp, f, seed, lle, w)
 		if err != nil {
 			log.Println(err)
 		}
 	})
 	return nil
 }
-func computeLifelossPerStructure(hp hazardproviders.HazardProvider, f consequences.Receptor, rng *rand.Rand, lle lifeloss.LifeLossEngine, w consequences.ResultsWriter) error {
-	//ProvideHazard works off of a geography.Location
-	d, err := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
-	if err == nil {
-		r := consequences.Result{}
-		//compute damages based on hazard being able to provide depth
-		r, err = f.Compute(d)
+
+// computeWithLifelossConcurrently computes damages and lifeloss on a pool of workers. each receptor reseeds a copy of the engine from its own seed so results do not depend on which worker computed it.
+func (computable Computeable) computeWithLifelossConcurrently(hp hazardproviders.HazardProvider, stream func(sp consequences.StreamProcessor), w consequences.ResultsWriter) error {
+	//the lethality curves and stability criteria are read only and can be shared across workers.
+	seed := computable.masterSeed()
+	baseEngine := lifeloss.Init(seed, warning.InitComplianceBasedWarningSystem(seed, computable.ComplianceRate))
+	return ComputeConcurrently(computable.Workers, seed, hp, stream, func(whp hazardproviders.HazardProvider, f consequences.Receptor, receptorSeed int64) (consequences.Result, error) {
+		r, err := computeLifeloss(whp, f, receptorSeed, baseEngine)
 		if err != nil {
-			return err
+			log.Println(err)
 		}
+		return r, err
+	}, w)
+}
+func computeLifelossPerStructure(hp hazardproviders.HazardProvider, f consequences.Receptor, masterSeed int64, lle lifeloss.LifeLossEngine, w consequences.ResultsWriter) error {
+	r, err := computeLifeloss(hp, f, receptorSeed(masterSeed, f, 0), lle)
+	if err != nil {
+		return err
+	}
+	w.Write(r)
+	return nil
+}
+
+// computeLifeloss samples the structure from seed and draws lifeloss from a stream derived from seed, so damages and lifeloss are computed on the same sample.
+func computeLifeloss(hp hazardproviders.HazardProvider, f consequences.Receptor, seed int64, lle lifeloss.LifeLossEngine) (consequences.Result, error) {
+	//ProvideHazard works off of a geography.Location
+	d, err := hp.Hazard(f.Location())
+	if err != nil {
+		return consequences.Result{}, err
+	}
+	return computeLifelossForHazard(d, f, seed, lle)
+}
 
-		//cast f to structure deterministic
-		var sd structures.StructureDeterministic
-		ss, ssok := f.(structures.StructureStochastic)
-		if ssok {
-			sd = ss.SampleStructure(rng.Int63())
+// computeLifelossForHazard is computeLifeloss for a hazard that has already been provided.
+func computeLifelossForHazard(d hazards.HazardEvent, f consequences.Receptor, seed int64, lle lifeloss.LifeLossEngine) (consequences.Result, error) {
+	//cast f to structure deterministic, damages and lifeloss are computed on the same sample.
+	var sd structures.StructureDeterministic
+	ss, ssok := f.(structures.StructureStochastic)
+	if ssok {
+		sd = ss.SampleStructure(seed)
+	} else {
+		sdtemp, sdok := f.(structures.StructureDeterministic)
+		if sdok {
+			sd = sdtemp
 		} else {
-			sdtemp, sdok := f.(structures.StructureDeterministic)
-			if sdok {
-				sd = sdtemp
-			} else {
-				return err
-			}
+			return consequences.Result{}, errors.New("compute: lifeloss can only be computed for structures")
 		}
-		//if hazard provider does not have velocity or depth*velocity add it based on fema velocity zone
-		llevent := hazards.DepthandDVEvent{}
-		llevent.SetDepth(d.Depth())
-		llevent.SetDV(0.0)
-		if d.Has(hazards.Velocity) {
-			llevent.SetDV(d.Depth() * d.Velocity())
-		} else if d.Has(hazards.DV) {
-			llevent.SetDV(d.DV())
-		} else if d.Has(hazards.WaveHeight) {
-			//if waveheight>3 => VE zone
+	}
+	//compute damages based on hazard being able to provide depth
+	r, err := sd.Compute(d)
+	if err != nil {
+		return r, err
+	}
+	//if hazard provider does not have velocity or depth*velocity add it based on fema velocity zone
+	llevent := hazards.DepthandDVEvent{}
+	llevent.SetDepth(d.Depth())
+	llevent.SetDV(0.0)
+	if d.Has(hazards.Velocity) {
+		llevent.SetDV(d.Depth() * d.Velocity())
+	} else if d.Has(hazards.DV) {
+		llevent.SetDV(d.DV())
+	} else if d.Has(hazards.WaveHeight) {
+		//if waveheight>3 => VE zone
+		llevent.SetDV(d.Depth() * 6.5)
+	} else {
+		switch sd.FirmZone {
+		case "VE", "V1-30":
 			llevent.SetDV(d.Depth() * 6.5)
-		} else {
-			switch sd.FirmZone {
-			case "VE", "V1-30":
-				llevent.SetDV(d.Depth() * 6.5)
-			}
-		}
-		//
-		//compute lifeloss
-		stability, err := lle.EvaluateStabilityCriteria(llevent, sd)
-		if err != nil {
-			return err
-		}
-		llr, err := lle.ComputeLifeLoss(llevent, sd, stability)
-		if err != nil {
-			return err
 		}
-		//append results
-		r.Headers = append(r.Headers, llr.Headers...)
-		r.Result = append(r.Result, llr.Result...)
-		w.Write(r)
-		return nil
 	}
-	return err
+	//
+	//compute lifeloss
+	lle = lle.WithSeed(structures.DeriveSeed(seed, 1))
+	stability, err := lle.EvaluateStabilityCriteria(llevent, sd)
+	if err != nil {
+		return r, err
+	}
+	llr, err := lle.ComputeLifeLoss(llevent, sd, stability)
+	if err != nil {
+		return r, err
+	}
+	//append results
+	r.Headers = append(r.Headers, llr.Headers...)
+	r.Result = append(r.Result, llr.Result...)
+	return r, nil
 }
@@ -0,0 +1,197 @@
+package compute
+
+import (
+	"log"
+	"sync"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// receptorJob is a single receptor pulled from a stream along with its position in the stream and the seed dedicated to it.
+type receptorJob struct {
+	index    int
+	receptor consequences.Receptor
+	seed     int64
+}
+
+// receptorOutcome is the result of a receptorJob, an outcome with an error is not written.
+type receptorOutcome struct {
+	index  int
+	result consequences.Result
+	err    error
+}
+
+// ReceptorCompute computes consequences for one receptor using a hazard provider owned by the calling worker and a seed dedicated to that receptor.
+type ReceptorCompute func(hp hazardproviders.HazardProvider, f consequences.Receptor, seed int64) (consequences.Result, error)
+
+// lockedHazardProvider serializes access to a hazard provider that cannot be cloned for each worker.
+type lockedHazardProvider struct {
+	hp hazardproviders.HazardProvider
+	mu *sync.Mutex
+}
+
+func (l lockedHazardProvider) Hazard(location geography.Location) (hazards.HazardEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hp.Hazard(location)
+}
+func (l lockedHazardProvider) HazardBoundary() (geography.BBox, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hp.HazardBoundary()
+}
+
+// Close is a no-op, the wrapped provider is owned by the caller.
+func (l lockedHazardProvider) Close() {}
+
+// workerHazardProviders gives each worker a hazard provider that is safe to use on its own goroutine. the returned func closes any clones that were opened.
+func workerHazardProviders(hp hazardproviders.HazardProvider, workers int) ([]hazardproviders.HazardProvider, func(), error) {
+	hps := make([]hazardproviders.HazardProvider, workers)
+	chp, ok := hp.(hazardproviders.CloneableHazardProvider)
+	if !ok {
+		locked := lockedHazardProvider{hp: hp, mu: &sync.Mutex{}}
+		for i := range hps {
+			hps[i] = locked
+		}
+		return hps, func() {}, nil
+	}
+	//the first worker uses the original provider, the rest get their own handles.
+	hps[0] = hp
+	closer := func() {
+		for _, c := range hps[1:] {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}
+	for i := 1; i < workers; i++ {
+		c, err := chp.Clone()
+		if err != nil {
+			closer()
+			return nil, func() {}, err
+		}
+		hps[i] = c
+	}
+	return hps, closer, nil
+}
+
+// receptorSeed derives a receptor's seed from the master seed. structures are keyed by fd_id so their draws do not depend on stream order or filtering, other receptors fall back to their position in the stream.
+func receptorSeed(masterSeed int64, f consequences.Receptor, index int) int64 {
+	switch s := f.(type) {
+	case structures.StructureStochastic:
+		return structures.StructureSeed(masterSeed, s.Name)
+	case structures.StructureDeterministic:
+		return structures.StructureSeed(masterSeed, s.Name)
+	}
+	return structures.DeriveSeed(masterSeed, int64(index))
+}
+
+// ComputeConcurrently pulls receptors from stream and computes them on a pool of workers. a single goroutine writes to w in stream order, and each receptor's seed is derived from seed by receptorSeed, so the output does not depend on the number of workers.
+func ComputeConcurrently(workers int, seed int64, hp hazardproviders.HazardProvider, stream func(sp consequences.StreamProcessor), compute ReceptorCompute, w consequences.ResultsWriter) error {
+	if workers < 1 {
+		workers = 1
+	}
+	hps, closeClones, err := workerHazardProviders(hp, workers)
+	if err != nil {
+		return err
+	}
+	defer closeClones()
+	jobs := make(chan receptorJob, workers*4)
+	outcomes := make(chan receptorOutcome, workers*4)
+	go func() {
+		index := 0
+		stream(func(f consequences.Receptor) {
+			jobs <- receptorJob{index: index, receptor: f, seed: receptorSeed(seed, f, index)}
+			index++
+		})
+		close(jobs)
+	}()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(whp hazardproviders.HazardProvider) {
+			defer wg.Done()
+			for j := range jobs {
+				r, err := compute(whp, j.receptor, j.seed)
+				outcomes <- receptorOutcome{index: j.index, result: r, err: err}
+			}
+		}(hps[i])
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+	//hold results that finish early until every result before them has been written.
+	pending := make(map[int]receptorOutcome)
+	next := 0
+	for o := range outcomes {
+		pending[o.index] = o
+		for {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if p.err == nil {
+				w.Write(p.result)
+			}
+		}
+	}
+	return nil
+}
+
+// computeReceptorWithSeed samples stochastic structures with the provided seed so the draw does not depend on the global random source.
+func computeReceptorWithSeed(f consequences.Receptor, d hazards.HazardEvent, seed int64) (consequences.Result, error) {
+	ss, ok := f.(structures.StructureStochastic)
+	if ok {
+		return ss.SampleStructure(seed).Compute(d)
+	}
+	return f.Compute(d)
+}
+
+// StructureCompute is the ReceptorCompute used by StreamAbstract, receptors without a hazard are skipped.
+func StructureCompute() ReceptorCompute {
+	return func(hp hazardproviders.HazardProvider, f consequences.Receptor, seed int64) (consequences.Result, error) {
+		d, err := hp.Hazard(f.Location())
+		if err != nil {
+			return consequences.Result{}, err
+		}
+		return computeReceptorWithSeed(f, d, seed)
+	}
+}
+
+// StreamAbstractConcurrent is the concurrent version of StreamAbstract, it computes every receptor in the hazard boundary on the specified number of workers.
+func StreamAbstractConcurrent(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, w consequences.ResultsWriter, workers int, seed int64) error {
+	bbox, err := hp.HazardBoundary()
+	if err != nil {
+		return err
+	}
+	log.Printf("Computing %v on %v workers\n", bbox.ToString(), workers)
+	stream, err := hazardStream(hp, sp)
+	if err != nil {
+		return err
+	}
+	return ComputeConcurrently(workers, seed, hp, stream, StructureCompute(), w)
+}
+
+// ReconstructionCompute computes structures with the days needed to reconstruct them, multi hazard events are rebuilt between events according to rules. Other receptors are computed as usual, and receptors without a hazard are skipped.
+func ReconstructionCompute(rules structures.RebuildRules) ReceptorCompute {
+	return func(hp hazardproviders.HazardProvider, f consequences.Receptor, seed int64) (consequences.Result, error) {
+		d, err := hp.Hazard(f.Location())
+		if err != nil {
+			return consequences.Result{}, err
+		}
+		switch s := f.(type) {
+		case structures.StructureStochastic:
+			return s.SampleStructure(seed).ComputeWithReconstruction(d, rules)
+		case structures.StructureDeterministic:
+			return s.ComputeWithReconstruction(d, rules)
+		}
+		return f.Compute(d)
+	}
+}
@@ -0,0 +1,193 @@
+package compute
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/statistics"
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/structures"
+)
+
+type constantDepthHazardProvider struct {
+	depth float64
+}
+
+func (c constantDepthHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	d := hazards.DepthEvent{}
+	d.SetDepth(c.depth + l.X/1000)
+	return d, nil
+}
+func (c constantDepthHazardProvider) HazardBoundary() (geography.BBox, error) {
+	return geography.BBox{Bbox: []float64{0, 0, 1000, 1000}}, nil
+}
+func (c constantDepthHazardProvider) Close() {}
+
+type testStructureStream struct {
+	count   int
+	seed    int64
+	reverse bool
+}
+
+func (ts testStructureStream) ByFips(fipscode string, sp consequences.StreamProcessor) {
+	ts.ByBbox(geography.BBox{}, sp)
+}
+func (ts testStructureStream) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	otp := structures.JsonOccupancyTypeProvider{}
+	otp.InitDefault()
+	ot := otp.OccupancyTypeMap()["RES1-1SNB"]
+	for j := 0; j < ts.count; j++ {
+		i := j
+		if ts.reverse {
+			i = ts.count - 1 - j
+		}
+		s := structures.StructureStochastic{
+			BaseStructure:  structures.BaseStructure{Name: fmt.Sprintf("%v", i), DamCat: "RES", CBFips: "151530001001", X: float64(i), Y: float64(i), HasGroundElevation: true},
+			UseUncertainty: true,
+			OccType:        ot,
+			StructVal:      consequences.ParameterValue{Value: statistics.NormalDistribution{Mean: 100000, StandardDeviation: 10000}},
+			ContVal:        consequences.ParameterValue{Value: statistics.NormalDistribution{Mean: 50000, StandardDeviation: 5000}},
+			FoundHt:        consequences.ParameterValue{Value: statistics.NormalDistribution{Mean: 1, StandardDeviation: .5}},
+			NumStories:     1,
+			PopulationSet:  structures.PopulationSet{Pop2amu65: 4, Pop2amo65: 2, Pop2pmu65: 3, Pop2pmo65: 1},
+		}
+		s.Seed = structures.StructureSeed(ts.seed, s.Name)
+		sp(s)
+	}
+}
+
+type collectingResultsWriter struct {
+	results []consequences.Result
+}
+
+func (c *collectingResultsWriter) Write(r consequences.Result) {
+	c.results = append(c.results, r)
+}
+func (c *collectingResultsWriter) Close() {}
+
+func TestStreamAbstractConcurrent_ReproducibleAcrossWorkers(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 4}
+	sp := testStructureStream{count: 200}
+	var baseline []consequences.Result
+	for _, workers := range []int{1, 2, 8} {
+		w := &collectingResultsWriter{}
+		err := StreamAbstractConcurrent(hp, sp, w, workers, 1234)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(w.results) != sp.count {
+			t.Fatalf("%v workers wrote %v results; expected %v", workers, len(w.results), sp.count)
+		}
+		if baseline == nil {
+			baseline = w.results
+			continue
+		}
+		for i, r := range w.results {
+			name, _ := r.Fetch("fd_id")
+			expectedName, _ := baseline[i].Fetch("fd_id")
+			if name != expectedName {
+				t.Fatalf("%v workers wrote %v at position %v; expected %v", workers, name, i, expectedName)
+			}
+			sd, _ := r.Fetch("structure damage")
+			expected, _ := baseline[i].Fetch("structure damage")
+			if sd.(float64) != expected.(float64) {
+				t.Errorf("%v workers computed %f for structure %v; expected %f", workers, sd, name, expected)
+			}
+		}
+	}
+}
+func TestComputeWithLifelossConcurrent_ReproducibleAcrossWorkers(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 12}
+	sp := testStructureStream{count: 50}
+	var baseline []consequences.Result
+	for _, workers := range []int{1, 4} {
+		w := &collectingResultsWriter{}
+		computable := Computeable{ComputeLifeloss: true, LifelossSeed: 42, ComplianceRate: .5, Workers: workers}
+		err := computable.computeWithLifelossByBbox(hp, sp, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if baseline == nil {
+			baseline = w.results
+			continue
+		}
+		if len(w.results) != len(baseline) {
+			t.Fatalf("%v workers wrote %v results; expected %v", workers, len(w.results), len(baseline))
+		}
+		for i, r := range w.results {
+			ll, _ := r.Fetch("ll_tot")
+			expected, _ := baseline[i].Fetch("ll_tot")
+			if ll != expected {
+				t.Errorf("%v workers computed %v lifeloss at position %v; expected %v", workers, ll, i, expected)
+			}
+		}
+	}
+}
+func resultsByName(t *testing.T, results []consequences.Result, header string) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{})
+	for _, r := range results {
+		name, err := r.Fetch("fd_id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := r.Fetch(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[name] = v
+	}
+	return m
+}
+func TestStreamAbstractConcurrent_IndependentOfStreamOrder(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 4}
+	forward := &collectingResultsWriter{}
+	err := StreamAbstractConcurrent(hp, testStructureStream{count: 100}, forward, 4, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := &collectingResultsWriter{}
+	err = StreamAbstractConcurrent(hp, testStructureStream{count: 100, reverse: true}, reversed, 4, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := resultsByName(t, forward.results, "structure damage")
+	for name, sd := range resultsByName(t, reversed.results, "structure damage") {
+		if sd != expected[name] {
+			t.Errorf("structure %v computed %v when streamed in reverse; expected %v", name, sd, expected[name])
+		}
+	}
+}
+func TestComputeWithLifeloss_SequentialMatchesConcurrent(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 12}
+	//the stream stamps the same master seed the computable uses, as CreateComputable does for seedable providers.
+	sp := testStructureStream{count: 50, seed: 42}
+	sequential := &collectingResultsWriter{}
+	err := Computeable{ComputeLifeloss: true, Seed: 42, ComplianceRate: .5}.computeWithLifelossByBbox(hp, sp, sequential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	concurrent := &collectingResultsWriter{}
+	err = Computeable{ComputeLifeloss: true, Seed: 42, ComplianceRate: .5, Workers: 3}.computeWithLifelossByBbox(hp, sp, concurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"structure damage", "ll_tot"} {
+		expected := resultsByName(t, sequential.results, header)
+		for name, v := range resultsByName(t, concurrent.results, header) {
+			if v != expected[name] {
+				t.Errorf("structure %v computed %v %v concurrently; expected %v", name, v, header, expected[name])
+			}
+		}
+	}
+	//damages from StreamAbstract use the stamped seed and should agree with the lifeloss compute.
+	damages := &collectingResultsWriter{}
+	StreamAbstract(hp, sp, damages)
+	expected := resultsByName(t, sequential.results, "structure damage")
+	for name, v := range resultsByName(t, damages.results, "structure damage") {
+		if v != expected[name] {
+			t.Errorf("structure %v computed %v structure damage without lifeloss; expected %v", name, v, expected[name])
+		}
+	}
+}
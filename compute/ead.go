@@ -0,0 +1,345 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// TailMethod selects how a damage frequency curve is extended past its most and least frequent events.
+type TailMethod string
+
+const (
+	TrapezoidalTail TailMethod = "trapezoidal" //linear in probability, the default
+	LogLinearTail   TailMethod = "log_linear"  //linear in the log of probability
+)
+
+// FrequencyEvent is a hazard with the annual exceedance probability it represents.
+type FrequencyEvent struct {
+	AnnualExceedanceProbability        float64 `json:"annual_exceedance_probability"`
+	hazardproviders.HazardProviderInfo `json:"hazard_provider_info"`
+}
+
+// EADSettings describes an expected annual damage compute, the events may be listed in any order.
+type EADSettings struct {
+	Events                  []FrequencyEvent `json:"events"`
+	TailMethod              TailMethod       `json:"tail_method,omitempty"`                //trapezoidal if not set
+	AggregateOutputFilePath string           `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total expected annual damages
+}
+
+// Validate checks that every event has a unique annual exceedance probability between zero and one and a hazard.
+func (s EADSettings) Validate() error {
+	if len(s.Events) == 0 {
+		return errors.New("compute: ead requires at least one event")
+	}
+	switch s.TailMethod {
+	case "", TrapezoidalTail, LogLinearTail:
+	default:
+		return fmt.Errorf("compute: ead tail_method %v is not %v or %v", s.TailMethod, TrapezoidalTail, LogLinearTail)
+	}
+	seen := make(map[float64]bool)
+	for _, e := range s.Events {
+		p := e.AnnualExceedanceProbability
+		if p <= 0 || p >= 1 {
+			return fmt.Errorf("compute: ead annual_exceedance_probability %v must be between 0 and 1", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("compute: ead annual_exceedance_probability %v is listed more than once", p)
+		}
+		seen[p] = true
+		if len(e.Hazards) == 0 {
+			return fmt.Errorf("compute: ead event %v has no hazards", p)
+		}
+	}
+	return nil
+}
+
+// SortedEvents returns the events ordered from most to least frequent.
+func (s EADSettings) SortedEvents() []FrequencyEvent {
+	events := make([]FrequencyEvent, len(s.Events))
+	copy(events, s.Events)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].AnnualExceedanceProbability > events[j].AnnualExceedanceProbability
+	})
+	return events
+}
+
+// IntegrateDamageFrequency integrates damage over annual exceedance probability to give expected annual damage. aeps must be strictly decreasing (most frequent first) and between zero and one, damages are the damages of those events.
+//
+// Between events the curve is linear in probability, so each interval contributes the area of a trapezoid. Beyond the events the tail method applies:
+//
+//   - TrapezoidalTail: damage rises linearly from zero at an aep of one to the most frequent event, and the least frequent event's damage is held constant down to an aep of zero.
+//   - LogLinearTail: damage rises linearly in ln(aep) from zero at an aep of one to the most frequent event, and the slope in ln(aep) between the two least frequent events is extended to an aep of zero. A negative slope, or a single event, holds the least frequent damage constant instead.
+//
+// With TrapezoidalTail the result is linear in damages, so expected annual damages can be summed across structures and components. LogLinearTail is linear except where a negative slope is held constant, which depends on the damages, so the expected annual damage of a summed curve can differ from the sum of the curves' expected annual damages.
+func IntegrateDamageFrequency(aeps []float64, damages []float64, tail TailMethod) (float64, error) {
+	if len(aeps) != len(damages) {
+		return 0, errors.New("compute: the damage frequency curve has a different number of probabilities and damages")
+	}
+	err := checkFrequencies(aeps)
+	if err != nil {
+		return 0, err
+	}
+	n := len(aeps)
+	ead := 0.0
+	//frequent tail
+	p1, d1 := aeps[0], damages[0]
+	if tail == LogLinearTail {
+		ead += d1 * (p1 - 1 - p1*math.Log(p1)) / math.Log(p1)
+	} else {
+		ead += (1 - p1) * d1 / 2
+	}
+	//between events
+	for i := 1; i < n; i++ {
+		ead += (aeps[i-1] - aeps[i]) * (damages[i-1] + damages[i]) / 2
+	}
+	//rare tail
+	pn, dn := aeps[n-1], damages[n-1]
+	slope := 0.0
+	if tail == LogLinearTail && n > 1 {
+		slope = (dn - damages[n-2]) / (math.Log(aeps[n-2]) - math.Log(pn))
+		if slope < 0 {
+			slope = 0
+		}
+	}
+	ead += pn * (dn + slope)
+	return ead, nil
+}
+func checkFrequencies(aeps []float64) error {
+	if len(aeps) == 0 {
+		return errors.New("compute: the damage frequency curve has no events")
+	}
+	for i, p := range aeps {
+		if p <= 0 || p >= 1 {
+			return fmt.Errorf("compute: annual exceedance probability %v must be between 0 and 1", p)
+		}
+		if i > 0 && p >= aeps[i-1] {
+			return errors.New("compute: annual exceedance probabilities must be ordered from most to least frequent")
+		}
+	}
+	return nil
+}
+
+// eadGroup sums the values written for every structure in a damage category, a county, or the whole study area.
+type eadGroup struct {
+	aggregation string
+	name        string
+	values      []float64
+}
+
+// eadGroups collects eadGroups so they can be written in a stable order.
+type eadGroups map[string]*eadGroup
+
+func (gs eadGroups) add(sd structures.StructureDeterministic, values []float64) {
+	county := sd.CBFips
+	if len(county) >= 5 {
+		county = county[0:5]
+	}
+	for _, k := range [][]string{{"total", "total"}, {"damage category", sd.DamCat}, {"county", county}} {
+		key := k[0] + "|" + k[1]
+		g, ok := gs[key]
+		if !ok {
+			g = &eadGroup{aggregation: k[0], name: k[1], values: make([]float64, len(values))}
+			gs[key] = g
+		}
+		for i, v := range values {
+			g.values[i] += v
+		}
+	}
+}
+func (gs eadGroups) write(valueHeaders []string, aw consequences.ResultsWriter) {
+	keys := make([]string, 0, len(gs))
+	for k := range gs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	header := append([]string{"aggregation", "group"}, valueHeaders...)
+	for _, k := range keys {
+		g := gs[k]
+		result := []interface{}{g.aggregation, g.name}
+		for _, v := range g.values {
+			result = append(result, v)
+		}
+		aw.Write(consequences.Result{Headers: header, Result: result})
+	}
+}
+
+// structureHeader lists the structure attributes written ahead of computed values.
+func structureHeader() []string {
+	return []string{"fd_id", "x", "y", "damage category", "occupancy type", "cbfips"}
+}
+func structureAttributes(sd structures.StructureDeterministic) []interface{} {
+	return []interface{}{sd.Name, sd.X, sd.Y, sd.DamCat, sd.OccType.Name, sd.CBFips}
+}
+
+// sampleReceptor samples stochastic structures from seed, receptors that are not structures are not ok.
+func sampleReceptor(f consequences.Receptor, seed int64) (structures.StructureDeterministic, bool) {
+	switch s := f.(type) {
+	case structures.StructureStochastic:
+		return s.SampleStructure(seed), true
+	case structures.StructureDeterministic:
+		return s, true
+	}
+	return structures.StructureDeterministic{}, false
+}
+
+// eadMeasures are the curves built for each structure, life loss is only included with a lifeloss engine.
+func eadMeasures(lle *lifeloss.LifeLossEngine) []string {
+	if lle != nil {
+		return []string{"structure damage", "content damage", "life loss"}
+	}
+	return []string{"structure damage", "content damage"}
+}
+
+// eadHeaders are the values integrateCurves returns.
+func eadHeaders(lle *lifeloss.LifeLossEngine) []string {
+	if lle != nil {
+		return []string{"structure ead", "content ead", "total ead", "eall"}
+	}
+	return []string{"structure ead", "content ead", "total ead"}
+}
+
+// damageFrequency computes the damage frequency curves, indexed by measure then event, of a sampled structure. it is not wet if no event had a hazard at the structure.
+func damageFrequency(f consequences.Receptor, sd structures.StructureDeterministic, seed int64, hps []hazardproviders.HazardProvider, lle *lifeloss.LifeLossEngine) ([][]float64, bool) {
+	measures := eadMeasures(lle)
+	curves := make([][]float64, len(measures))
+	for i := range curves {
+		curves[i] = make([]float64, len(hps))
+	}
+	gotWet := false
+	for e, hp := range hps {
+		d, err := hp.Hazard(sd.Location())
+		if err != nil {
+			//no hazard for this event, the structure is dry.
+			continue
+		}
+		var r consequences.Result
+		if lle != nil {
+			//samples f from the same seed, so this is the same structure as sd.
+			r, err = computeLifelossForHazard(d, f, seed, *lle)
+		} else {
+			r, err = sd.Compute(d)
+		}
+		if err != nil {
+			continue
+		}
+		gotWet = true
+		for i, m := range []string{"structure damage", "content damage", "ll_tot"}[:len(measures)] {
+			v, err := r.Fetch(m)
+			if err != nil {
+				continue
+			}
+			switch tv := v.(type) {
+			case float64:
+				curves[i][e] = tv
+			case int32:
+				curves[i][e] = float64(tv)
+			}
+		}
+	}
+	return curves, gotWet
+}
+
+// integrateCurves integrates the curves from damageFrequency into the values named by eadHeaders.
+func integrateCurves(aeps []float64, curves [][]float64, tail TailMethod) ([]float64, error) {
+	total := make([]float64, len(aeps))
+	for e := range aeps {
+		total[e] = curves[0][e] + curves[1][e]
+	}
+	toIntegrate := [][]float64{curves[0], curves[1], total}
+	if len(curves) > 2 {
+		toIntegrate = append(toIntegrate, curves[2])
+	}
+	values := make([]float64, len(toIntegrate))
+	for i, c := range toIntegrate {
+		v, err := IntegrateDamageFrequency(aeps, c, tail)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// ExpectedAnnualDamages computes every structure in stream against each event and integrates the damage frequency curves with IntegrateDamageFrequency. hps and aeps must be ordered from most to least frequent. Each structure is sampled once from its seed so every event sees the same structure. Per structure event damages and expected annual structure, content and total damage are written to w, along with equivalent annual life loss if lle is not nil. The same values summed by damage category, county and in total are written to aw if it is not nil.
+func ExpectedAnnualDamages(hps []hazardproviders.HazardProvider, aeps []float64, tail TailMethod, stream func(sp consequences.StreamProcessor), seed int64, lle *lifeloss.LifeLossEngine, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	if len(hps) != len(aeps) {
+		return errors.New("compute: ead requires one hazard provider for each annual exceedance probability")
+	}
+	err := checkFrequencies(aeps)
+	if err != nil {
+		return err
+	}
+	measures := eadMeasures(lle)
+	valueHeaders := make([]string, 0)
+	for _, p := range aeps {
+		for _, m := range measures {
+			valueHeaders = append(valueHeaders, m+" "+strconv.FormatFloat(p, 'f', -1, 64))
+		}
+	}
+	valueHeaders = append(valueHeaders, eadHeaders(lle)...)
+	header := append(structureHeader(), valueHeaders...)
+	groups := make(eadGroups)
+	index := 0
+	var computeErr error
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		if computeErr != nil {
+			return
+		}
+		receptorSeed := receptorSeed(seed, f, index)
+		sd, ok := sampleReceptor(f, receptorSeed)
+		if !ok {
+			return
+		}
+		curves, gotWet := damageFrequency(f, sd, receptorSeed, hps, lle)
+		if !gotWet {
+			return
+		}
+		values := make([]float64, 0, len(valueHeaders))
+		for e := range aeps {
+			for i := range measures {
+				values = append(values, curves[i][e])
+			}
+		}
+		eads, err := integrateCurves(aeps, curves, tail)
+		if err != nil {
+			computeErr = err
+			return
+		}
+		values = append(values, eads...)
+		groups.add(sd, values)
+		result := structureAttributes(sd)
+		for _, v := range values {
+			result = append(result, v)
+		}
+		w.Write(consequences.Result{Headers: header, Result: result})
+	})
+	if computeErr != nil || aw == nil {
+		return computeErr
+	}
+	groups.write(valueHeaders, aw)
+	return nil
+}
+
+// frequencyBoundary is the union of the boundaries of every event, so structures only wet by one event are still computed. events can be in different reference systems, the union is in the first event's.
+func frequencyBoundary(hps []hazardproviders.HazardProvider) (geography.BBox, error) {
+	boxes := make([]geography.BBox, len(hps))
+	for i, hp := range hps {
+		b, err := hp.HazardBoundary()
+		if err != nil {
+			return geography.BBox{}, err
+		}
+		boxes[i] = b
+	}
+	return projection.UnionBBox(boxes)
+}
@@ -0,0 +1,105 @@
+package compute
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+)
+
+func TestIntegrateDamageFrequency_Trapezoidal(t *testing.T) {
+	d := []float64{1, 10, 30, 45, 59, 78, 89, 102, 140, 180, 240, 330, 350, 370}
+	f := []float64{.99, .95, .9, .8, .7, .6, .5, .4, .3, .2, .1, .01, .002, .001}
+	val, err := IntegrateDamageFrequency(f, d, TrapezoidalTail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//matches ComputeEAD, which uses the same tails.
+	if math.Abs(val-113.125) > 1e-9 {
+		t.Errorf("IntegrateDamageFrequency() yielded %f; expected %f", val, 113.125)
+	}
+}
+func TestIntegrateDamageFrequency_LogLinear(t *testing.T) {
+	f := []float64{.5, .1}
+	d := []float64{10, 20}
+	val, err := IntegrateDamageFrequency(f, d, LogLinearTail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frequent := 10 * (.5 - 1 - .5*math.Log(.5)) / math.Log(.5)
+	between := .4 * 15
+	rare := .1 * (20 + 10/(math.Log(.5)-math.Log(.1)))
+	expected := frequent + between + rare
+	if math.Abs(val-expected) > 1e-9 {
+		t.Errorf("IntegrateDamageFrequency() yielded %f; expected %f", val, expected)
+	}
+}
+func TestIntegrateDamageFrequency_RejectsUnorderedCurve(t *testing.T) {
+	_, err := IntegrateDamageFrequency([]float64{.1, .5}, []float64{1, 2}, TrapezoidalTail)
+	if err == nil {
+		t.Error("expected an error for probabilities ordered from least to most frequent")
+	}
+}
+func TestEADSettings_ValidateAndSort(t *testing.T) {
+	info := hazardproviders.HazardProviderInfo{Hazards: []hazardproviders.HazardProviderParameterAndPath{{}}}
+	s := EADSettings{Events: []FrequencyEvent{{.01, info}, {.5, info}, {.1, info}}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sorted := s.SortedEvents()
+	for i, expected := range []float64{.5, .1, .01} {
+		if sorted[i].AnnualExceedanceProbability != expected {
+			t.Errorf("event %v was %v; expected %v", i, sorted[i].AnnualExceedanceProbability, expected)
+		}
+	}
+	s.Events = append(s.Events, FrequencyEvent{.1, info})
+	if s.Validate() == nil {
+		t.Error("expected an error for a repeated annual exceedance probability")
+	}
+}
+func TestExpectedAnnualDamages_AggregatesMatchStructures(t *testing.T) {
+	hps := []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 2}, constantDepthHazardProvider{depth: 6}, constantDepthHazardProvider{depth: 10}}
+	aeps := []float64{.1, .02, .01}
+	sp := testStructureStream{count: 10, seed: 3}
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := ExpectedAnnualDamages(hps, aeps, LogLinearTail, func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }, 3, nil, w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	sum := 0.0
+	for _, r := range w.results {
+		s, _ := r.Fetch("structure ead")
+		c, _ := r.Fetch("content ead")
+		total, _ := r.Fetch("total ead")
+		if math.Abs(s.(float64)+c.(float64)-total.(float64)) > 1e-9 {
+			t.Errorf("total ead %v was not structure %v plus content %v", total, s, c)
+		}
+		sum += total.(float64)
+	}
+	for _, r := range aw.results {
+		total, _ := r.Fetch("total ead")
+		if math.Abs(total.(float64)-sum) > 1e-6 {
+			group, _ := r.Fetch("group")
+			t.Errorf("%v total ead was %v; expected %v", group, total, sum)
+		}
+	}
+}
+func TestStreamAbstractMultiFrequency_Columns(t *testing.T) {
+	hps := []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 6}, constantDepthHazardProvider{depth: 2}}
+	w := &collectingResultsWriter{}
+	StreamAbstractMultiFrequency(hps, []float64{.01, .1}, testStructureStream{count: 3, seed: 3}, w)
+	if len(w.results) != 3 {
+		t.Fatalf("wrote %v structures; expected 3", len(w.results))
+	}
+	expected := []string{"fd_id", "x", "y", "damage category", "occupancy type", "cbfips", "structure damage 0.1", "content damage 0.1", "structure damage 0.01", "content damage 0.01", "structure ead", "content ead", "total ead"}
+	if !reflect.DeepEqual(w.results[0].Headers, expected) {
+		t.Errorf("expected columns %v, got %v", expected, w.results[0].Headers)
+	}
+}
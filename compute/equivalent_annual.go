@@ -0,0 +1,305 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// PeriodOfAnalysis is the span of years over which annual values are discounted to the base year.
+type PeriodOfAnalysis struct {
+	BaseYear     int     `json:"base_year"`
+	Years        int     `json:"years"`         //length of the period of analysis, e.g. 50
+	DiscountRate float64 `json:"discount_rate"` //annual rate, e.g. .0275
+}
+
+// Validate checks the period has at least one year and a non negative discount rate.
+func (p PeriodOfAnalysis) Validate() error {
+	if p.Years < 1 {
+		return errors.New("compute: the period of analysis must be at least one year")
+	}
+	if p.DiscountRate < 0 {
+		return errors.New("compute: the discount rate must not be negative")
+	}
+	return nil
+}
+
+// LastYear is the final year of the period of analysis.
+func (p PeriodOfAnalysis) LastYear() int {
+	return p.BaseYear + p.Years - 1
+}
+
+// annualValues interpolates values at the analysis years linearly to every year in the period, values are held constant before the first and after the last analysis year.
+func (p PeriodOfAnalysis) annualValues(years []int, values []float64) ([]float64, error) {
+	if len(years) == 0 || len(years) != len(values) {
+		return nil, errors.New("compute: each analysis year requires one value")
+	}
+	for i, y := range years {
+		if y < p.BaseYear || y > p.LastYear() {
+			return nil, fmt.Errorf("compute: analysis year %v is outside the period of analysis %v to %v", y, p.BaseYear, p.LastYear())
+		}
+		if i > 0 && y <= years[i-1] {
+			return nil, errors.New("compute: analysis years must be in increasing order")
+		}
+	}
+	annual := make([]float64, p.Years)
+	j := 0
+	for k := range annual {
+		year := p.BaseYear + k
+		for j < len(years)-1 && years[j+1] <= year {
+			j++
+		}
+		switch {
+		case year <= years[0]:
+			annual[k] = values[0]
+		case j == len(years)-1:
+			annual[k] = values[j]
+		default:
+			fraction := float64(year-years[j]) / float64(years[j+1]-years[j])
+			annual[k] = values[j] + fraction*(values[j+1]-values[j])
+		}
+	}
+	return annual, nil
+}
+
+// PresentValue interpolates values at the analysis years to every year in the period and discounts them to the base year. values are treated as occurring at the end of each year, so the base year is discounted one year.
+func (p PeriodOfAnalysis) PresentValue(years []int, values []float64) (float64, error) {
+	annual, err := p.annualValues(years, values)
+	if err != nil {
+		return 0, err
+	}
+	pv := 0.0
+	for k, v := range annual {
+		pv += v / math.Pow(1+p.DiscountRate, float64(k+1))
+	}
+	return pv, nil
+}
+
+// CapitalRecoveryFactor converts a present value to a uniform annual value over the period of analysis.
+func (p PeriodOfAnalysis) CapitalRecoveryFactor() float64 {
+	n := float64(p.Years)
+	if p.DiscountRate == 0 {
+		return 1 / n
+	}
+	return p.DiscountRate / (1 - math.Pow(1+p.DiscountRate, -n))
+}
+
+// EquivalentAnnual is the uniform annual value with the same present value as values at the analysis years. a value that does not change over the period is its own equivalent annual value.
+func (p PeriodOfAnalysis) EquivalentAnnual(years []int, values []float64) (float64, error) {
+	pv, err := p.PresentValue(years, values)
+	if err != nil {
+		return 0, err
+	}
+	return pv * p.CapitalRecoveryFactor(), nil
+}
+
+// AnalysisYear is the hazard set describing conditions in one year of the period of analysis.
+type AnalysisYear struct {
+	Year   int              `json:"year"`
+	Events []FrequencyEvent `json:"events"`
+}
+
+// EquivalentAnnualSettings describes an equivalent annual damage compute over a period of analysis for a without project alternative and optionally a with project alternative.
+type EquivalentAnnualSettings struct {
+	PeriodOfAnalysis        `json:"period_of_analysis"`
+	WithoutProject          []AnalysisYear `json:"without_project"`
+	WithProject             []AnalysisYear `json:"with_project,omitempty"`
+	TailMethod              TailMethod     `json:"tail_method,omitempty"`                //trapezoidal if not set
+	AggregateOutputFilePath string         `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total equivalent annual damages
+}
+
+// Validate checks the period of analysis and the events of every analysis year.
+func (s EquivalentAnnualSettings) Validate() error {
+	err := s.PeriodOfAnalysis.Validate()
+	if err != nil {
+		return err
+	}
+	if len(s.WithoutProject) == 0 {
+		return errors.New("compute: equivalent annual damage requires at least one without project analysis year")
+	}
+	for _, alternative := range [][]AnalysisYear{s.WithoutProject, s.WithProject} {
+		seen := make(map[int]bool)
+		for _, y := range alternative {
+			if y.Year < s.BaseYear || y.Year > s.LastYear() {
+				return fmt.Errorf("compute: analysis year %v is outside the period of analysis %v to %v", y.Year, s.BaseYear, s.LastYear())
+			}
+			if seen[y.Year] {
+				return fmt.Errorf("compute: analysis year %v is listed more than once", y.Year)
+			}
+			seen[y.Year] = true
+			err = EADSettings{Events: y.Events, TailMethod: s.TailMethod}.Validate()
+			if err != nil {
+				return fmt.Errorf("analysis year %v: %w", y.Year, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AnalysisYearHazards are the hazard providers of one analysis year ordered from most to least frequent.
+type AnalysisYearHazards struct {
+	Year            int
+	HazardProviders []hazardproviders.HazardProvider
+	Frequencies     []float64
+}
+
+// CreateAnalysisYearHazards opens the hazard providers of each analysis year in year order, every provider opened is closed if one fails.
+func CreateAnalysisYearHazards(years []AnalysisYear) ([]AnalysisYearHazards, error) {
+	sorted := make([]AnalysisYear, len(years))
+	copy(sorted, years)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Year < sorted[j].Year })
+	out := make([]AnalysisYearHazards, 0, len(sorted))
+	for _, y := range sorted {
+		ayh := AnalysisYearHazards{Year: y.Year}
+		for _, e := range (EADSettings{Events: y.Events}).SortedEvents() {
+			hp, err := e.CreateHazardProvider()
+			if err != nil {
+				closeAnalysisYearHazards(append(out, ayh))
+				return nil, err
+			}
+			ayh.HazardProviders = append(ayh.HazardProviders, hp)
+			ayh.Frequencies = append(ayh.Frequencies, e.AnnualExceedanceProbability)
+		}
+		out = append(out, ayh)
+	}
+	return out, nil
+}
+func closeAnalysisYearHazards(years []AnalysisYearHazards) {
+	for _, y := range years {
+		for _, hp := range y.HazardProviders {
+			hp.Close()
+		}
+	}
+}
+
+// analysisYearBoundary is the union of the boundaries of every event in every analysis year.
+func analysisYearBoundary(alternatives ...[]AnalysisYearHazards) (geography.BBox, error) {
+	hps := make([]hazardproviders.HazardProvider, 0)
+	for _, alternative := range alternatives {
+		for _, y := range alternative {
+			hps = append(hps, y.HazardProviders...)
+		}
+	}
+	return frequencyBoundary(hps)
+}
+
+// equivalentAnnualValues computes the ead values of a sampled structure in each analysis year and converts each to an equivalent annual value over the period. it is not wet if no event in any year had a hazard at the structure.
+func equivalentAnnualValues(poa PeriodOfAnalysis, alternative []AnalysisYearHazards, f consequences.Receptor, sd structures.StructureDeterministic, seed int64, tail TailMethod, lle *lifeloss.LifeLossEngine) ([]float64, bool, error) {
+	years := make([]int, len(alternative))
+	byYear := make([][]float64, len(alternative))
+	wet := false
+	for i, y := range alternative {
+		years[i] = y.Year
+		curves, gotWet := damageFrequency(f, sd, seed, y.HazardProviders, lle)
+		wet = wet || gotWet
+		eads, err := integrateCurves(y.Frequencies, curves, tail)
+		if err != nil {
+			return nil, false, err
+		}
+		byYear[i] = eads
+	}
+	values := make([]float64, len(eadHeaders(lle)))
+	for m := range values {
+		series := make([]float64, len(years))
+		for i := range years {
+			series[i] = byYear[i][m]
+		}
+		v, err := poa.EquivalentAnnual(years, series)
+		if err != nil {
+			return nil, false, err
+		}
+		values[m] = v
+	}
+	return values, wet, nil
+}
+
+// equivalentAnnualHeaders names the equivalent annual values of an alternative, ead becomes eqad and eall becomes eqall.
+func equivalentAnnualHeaders(prefix string, lle *lifeloss.LifeLossEngine) []string {
+	names := []string{"structure eqad", "content eqad", "total eqad", "eqall"}[:len(eadHeaders(lle))]
+	h := make([]string, len(names))
+	for i, n := range names {
+		h[i] = prefix + " " + n
+	}
+	return h
+}
+
+// EquivalentAnnualDamages computes expected annual damages for each analysis year of each alternative, interpolates them across the period of analysis, discounts them to the base year and reports the equivalent annual damage. each structure is sampled once from its seed so the alternatives and years differ only by their hazards. If with is not empty the benefits, without project less with project, are reported too. Per structure values are written to w, and values summed by damage category, county and in total to aw if it is not nil.
+func EquivalentAnnualDamages(poa PeriodOfAnalysis, without []AnalysisYearHazards, with []AnalysisYearHazards, tail TailMethod, stream func(sp consequences.StreamProcessor), seed int64, lle *lifeloss.LifeLossEngine, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	err := poa.Validate()
+	if err != nil {
+		return err
+	}
+	if len(without) == 0 {
+		return errors.New("compute: equivalent annual damage requires at least one without project analysis year")
+	}
+	for _, alternative := range [][]AnalysisYearHazards{without, with} {
+		for _, y := range alternative {
+			if len(y.HazardProviders) != len(y.Frequencies) {
+				return fmt.Errorf("compute: analysis year %v requires one hazard provider for each annual exceedance probability", y.Year)
+			}
+			err = checkFrequencies(y.Frequencies)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	valueHeaders := equivalentAnnualHeaders("without", lle)
+	if len(with) > 0 {
+		valueHeaders = append(valueHeaders, equivalentAnnualHeaders("with", lle)...)
+		valueHeaders = append(valueHeaders, []string{"structure benefits", "content benefits", "total benefits", "eqall reduction"}[:len(eadHeaders(lle))]...)
+	}
+	header := append(structureHeader(), valueHeaders...)
+	groups := make(eadGroups)
+	index := 0
+	var computeErr error
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		if computeErr != nil {
+			return
+		}
+		receptorSeed := receptorSeed(seed, f, index)
+		sd, ok := sampleReceptor(f, receptorSeed)
+		if !ok {
+			return
+		}
+		values, wet, err := equivalentAnnualValues(poa, without, f, sd, receptorSeed, tail, lle)
+		if err != nil {
+			computeErr = err
+			return
+		}
+		if len(with) > 0 {
+			withValues, withWet, err := equivalentAnnualValues(poa, with, f, sd, receptorSeed, tail, lle)
+			if err != nil {
+				computeErr = err
+				return
+			}
+			wet = wet || withWet
+			n := len(withValues)
+			values = append(values, withValues...)
+			for i := 0; i < n; i++ {
+				values = append(values, values[i]-withValues[i])
+			}
+		}
+		if !wet {
+			return
+		}
+		groups.add(sd, values)
+		result := structureAttributes(sd)
+		for _, v := range values {
+			result = append(result, v)
+		}
+		w.Write(consequences.Result{Headers: header, Result: result})
+	})
+	if computeErr != nil || aw == nil {
+		return computeErr
+	}
+	groups.write(valueHeaders, aw)
+	return nil
+}
@@ -0,0 +1,85 @@
+package compute
+
+import (
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+)
+
+func TestPeriodOfAnalysis_ConstantValueIsItsOwnEquivalent(t *testing.T) {
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 50, DiscountRate: .0275}
+	v, err := poa.EquivalentAnnual([]int{2030}, []float64{1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(v-1000) > 1e-9 {
+		t.Errorf("EquivalentAnnual() yielded %f; expected %f", v, 1000.0)
+	}
+}
+func TestPeriodOfAnalysis_Interpolation(t *testing.T) {
+	//without discounting the equivalent annual value is the mean of the interpolated annual values.
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 4, DiscountRate: 0}
+	v, err := poa.EquivalentAnnual([]int{2030, 2032}, []float64{100, 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//100, 200, 300, 300
+	if math.Abs(v-225) > 1e-9 {
+		t.Errorf("EquivalentAnnual() yielded %f; expected %f", v, 225.0)
+	}
+	poa.DiscountRate = .1
+	pv, err := poa.PresentValue([]int{2030, 2032}, []float64{100, 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 100/1.1 + 200/math.Pow(1.1, 2) + 300/math.Pow(1.1, 3) + 300/math.Pow(1.1, 4)
+	if math.Abs(pv-expected) > 1e-9 {
+		t.Errorf("PresentValue() yielded %f; expected %f", pv, expected)
+	}
+	_, err = poa.PresentValue([]int{2040}, []float64{1})
+	if err == nil {
+		t.Error("expected an error for an analysis year outside the period of analysis")
+	}
+}
+func TestEquivalentAnnualDamages_Benefits(t *testing.T) {
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 50, DiscountRate: .0275}
+	aeps := []float64{.1, .01}
+	without := []AnalysisYearHazards{
+		{Year: 2030, HazardProviders: []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 2}, constantDepthHazardProvider{depth: 6}}, Frequencies: aeps},
+		{Year: 2079, HazardProviders: []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 4}, constantDepthHazardProvider{depth: 8}}, Frequencies: aeps},
+	}
+	with := []AnalysisYearHazards{
+		{Year: 2030, HazardProviders: []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 0}, constantDepthHazardProvider{depth: 3}}, Frequencies: aeps},
+	}
+	sp := testStructureStream{count: 5, seed: 9}
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := EquivalentAnnualDamages(poa, without, with, TrapezoidalTail, func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }, 9, nil, w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	for _, r := range w.results {
+		wo, _ := r.Fetch("without total eqad")
+		wi, _ := r.Fetch("with total eqad")
+		b, _ := r.Fetch("total benefits")
+		if math.Abs(wo.(float64)-wi.(float64)-b.(float64)) > 1e-9 {
+			t.Errorf("benefits %v were not without %v less with %v", b, wo, wi)
+		}
+		if b.(float64) <= 0 {
+			t.Errorf("expected positive benefits from lowering depths, got %v", b)
+		}
+	}
+	for _, r := range aw.results {
+		g, _ := r.Fetch("group")
+		if g == "total" {
+			return
+		}
+	}
+	t.Error("no total aggregate was written")
+}
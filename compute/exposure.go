@@ -0,0 +1,211 @@
+package compute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// ExposureSettings describe a pre-compute exposure report, the structures in the wet area of the hazard are counted and valued without computing any damages.
+type ExposureSettings struct {
+	DepthBins               []float64 `json:"depth_bins"`                           //lower edge of each depth bin in feet, the last bin has no upper edge
+	AggregateOutputFilePath string    `json:"aggregate_output_file_path,omitempty"` //json file for the depth grid and the exposure by damage category, occupancy type, county and in total
+}
+
+// DefaultExposureSettings returns the settings used when a configuration does not specify them.
+func DefaultExposureSettings() ExposureSettings {
+	return ExposureSettings{DepthBins: []float64{0, 1, 2, 3, 6, 10}}
+}
+
+// UnmarshalJSON starts from DefaultExposureSettings so a configuration only needs to specify the settings it changes.
+func (s *ExposureSettings) UnmarshalJSON(b []byte) error {
+	type settings ExposureSettings
+	d := settings(DefaultExposureSettings())
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return err
+	}
+	*s = ExposureSettings(d)
+	return nil
+}
+
+// Validate checks there is at least one depth bin and that the bins increase from zero or deeper.
+func (s ExposureSettings) Validate() error {
+	if len(s.DepthBins) == 0 {
+		return errors.New("compute: exposure requires at least one depth bin")
+	}
+	if s.DepthBins[0] < 0 {
+		return errors.New("compute: exposure depth_bins must not be negative")
+	}
+	for i := 1; i < len(s.DepthBins); i++ {
+		if s.DepthBins[i] <= s.DepthBins[i-1] {
+			return errors.New("compute: exposure depth_bins must increase")
+		}
+	}
+	return nil
+}
+
+// binLabels name each depth bin by its edges, e.g. "depth 1-2" and "depth 10+".
+func binLabels(bins []float64) []string {
+	labels := make([]string, len(bins))
+	for i, b := range bins {
+		if i == len(bins)-1 {
+			labels[i] = fmt.Sprintf("depth %v+", b)
+		} else {
+			labels[i] = fmt.Sprintf("depth %v-%v", b, bins[i+1])
+		}
+	}
+	return labels
+}
+
+// exposureGroup totals the structures exposed in a damage category, an occupancy type, a county, or the whole study area.
+type exposureGroup struct {
+	aggregation    string
+	name           string
+	structures     int32
+	structureValue float64
+	contentValue   float64
+	nightPop       int32
+	dayPop         int32
+	histogram      []int32 //structures in each depth bin
+}
+
+// Exposure writes every structure in the stream that is in the wet area of hp to w with its depth, depth bin, values and populations, without computing damages. A structure is in the wet area if hp has a hazard at it whose depth, if it has one, is above zero. If aw is not nil the exposure by damage category, occupancy type, county and in total is written to it, and when hp is a DepthGridHazardProvider so is the extent, wet cell count and depth histogram of its grid, otherwise its hazard boundary.
+func Exposure(hp hazardproviders.HazardProvider, stream func(sp consequences.StreamProcessor), settings ExposureSettings, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	err := settings.Validate()
+	if err != nil {
+		return err
+	}
+	labels := binLabels(settings.DepthBins)
+	groups := make(map[string]*exposureGroup)
+	group := func(aggregation string, name string) *exposureGroup {
+		key := aggregation + "|" + name
+		g, ok := groups[key]
+		if !ok {
+			g = &exposureGroup{aggregation: aggregation, name: name, histogram: make([]int32, len(labels))}
+			groups[key] = g
+		}
+		return g
+	}
+	header := []string{"fd_id", "x", "y", "damage category", "occupancy type", "cbfips", "depth", "depth bin", "structure value", "content value", "population night", "population day"}
+	stream(func(f consequences.Receptor) {
+		s, ok := f.(structures.StructureStochastic)
+		if !ok {
+			log.Printf("compute: exposure skipped %T, only stochastic structures are counted\n", f)
+			return
+		}
+		h, err := hp.Hazard(s.Location())
+		if err != nil || h == nil {
+			return
+		}
+		depth := 0.0
+		bin := -1
+		binLabel := ""
+		if h.Has(hazards.Depth) {
+			depth = h.Depth()
+			if depth <= 0 {
+				return
+			}
+			bin = hazardproviders.DepthBin(settings.DepthBins, depth)
+			if bin >= 0 {
+				binLabel = labels[bin]
+			}
+		}
+		structureValue := s.StructVal.CentralTendency()
+		contentValue := s.ContVal.CentralTendency()
+		night := s.Pop2amo65 + s.Pop2amu65
+		day := s.Pop2pmo65 + s.Pop2pmu65
+		w.Write(consequences.Result{Headers: header, Result: []interface{}{s.Name, s.X, s.Y, s.DamCat, s.OccType.Name, s.CBFips, depth, binLabel, structureValue, contentValue, night, day}})
+		county := s.CBFips
+		if len(county) >= 5 {
+			county = county[0:5]
+		}
+		for _, g := range []*exposureGroup{group("total", "total"), group("damage category", s.DamCat), group("occupancy type", s.OccType.Name), group("county", county)} {
+			g.structures++
+			g.structureValue += structureValue
+			g.contentValue += contentValue
+			g.nightPop += night
+			g.dayPop += day
+			if bin >= 0 {
+				g.histogram[bin]++
+			}
+		}
+	})
+	if aw == nil {
+		return nil
+	}
+	err = writeHazardExposure(hp, settings.DepthBins, labels, aw)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	aggregateHeader := append([]string{"aggregation", "group", "structures", "structure value", "content value", "population night", "population day"}, labels...)
+	for _, k := range keys {
+		g := groups[k]
+		r := []interface{}{g.aggregation, g.name, g.structures, g.structureValue, g.contentValue, g.nightPop, g.dayPop}
+		for _, c := range g.histogram {
+			r = append(r, c)
+		}
+		aw.Write(consequences.Result{Headers: aggregateHeader, Result: r})
+	}
+	return nil
+}
+
+// writeHazardExposure writes the extent of the hazard, with the wet cells and depth histogram of a DepthGridHazardProvider's grid.
+func writeHazardExposure(hp hazardproviders.HazardProvider, bins []float64, labels []string, aw consequences.ResultsWriter) error {
+	if dg, ok := hp.(hazardproviders.DepthGridHazardProvider); ok {
+		summary, err := dg.DepthGrid(bins)
+		if err == nil {
+			header := append(append([]string{"aggregation", "group"}, extentHeader...), "cells", "wet cells", "wet area", "max depth")
+			header = append(header, labels...)
+			r := append(append([]interface{}{"hazard", "depth grid"}, extentValues(summary.Extent)...), int32(summary.Cells), int32(summary.WetCells), float64(summary.WetCells)*summary.CellArea, summary.MaxDepth)
+			for _, c := range summary.Histogram {
+				r = append(r, int32(c))
+			}
+			aw.Write(consequences.Result{Headers: header, Result: r})
+			return nil
+		}
+		log.Printf("compute: exposure reports the hazard boundary, %v\n", err)
+	}
+	bbox, err := hp.HazardBoundary()
+	if err != nil {
+		return err
+	}
+	aw.Write(consequences.Result{Headers: append([]string{"aggregation", "group"}, extentHeader...), Result: append([]interface{}{"hazard", "boundary"}, extentValues(bbox)...)})
+	return nil
+}
+
+var extentHeader = []string{"upper left x", "upper left y", "lower right x", "lower right y"}
+
+// extentValues are the corners of a bounding box, zero if it is empty.
+func extentValues(b geography.BBox) []interface{} {
+	values := make([]interface{}, len(extentHeader))
+	for i := range values {
+		values[i] = 0.0
+		if i < len(b.Bbox) {
+			values[i] = b.Bbox[i]
+		}
+	}
+	return values
+}
+
+// StreamAbstractExposure writes the exposure of every structure in the hazard boundary.
+func StreamAbstractExposure(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, settings ExposureSettings, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	stream, err := hazardStream(hp, sp)
+	if err != nil {
+		return err
+	}
+	return Exposure(hp, stream, settings, w, aw)
+}
@@ -0,0 +1,75 @@
+package compute
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// slopingDepthHazardProvider is dry up to x of 5 and a foot deeper for every unit of x after that.
+type slopingDepthHazardProvider struct {
+	constantDepthHazardProvider
+}
+
+func (s slopingDepthHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	d := hazards.DepthEvent{}
+	d.SetDepth(l.X - 5)
+	return d, nil
+}
+func TestExposure_CountsWetStructures(t *testing.T) {
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := StreamAbstractExposure(slopingDepthHazardProvider{}, testStructureStream{count: 20}, DefaultExposureSettings(), w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != 14 {
+		t.Fatalf("wrote %v structures; expected the 14 deeper than zero", len(w.results))
+	}
+	bin, _ := w.results[0].Fetch("depth bin")
+	if bin != "depth 1-2" {
+		t.Errorf("expected the first wet structure in depth 1-2, got %v", bin)
+	}
+	//the hazard boundary, then county, damage category, occupancy type and total.
+	if len(aw.results) != 5 {
+		t.Fatalf("wrote %v aggregates; expected 5", len(aw.results))
+	}
+	ulx, _ := aw.results[0].Fetch("upper left x")
+	lrx, _ := aw.results[0].Fetch("lower right x")
+	if ulx != 0.0 || lrx != 1000.0 {
+		t.Errorf("expected the hazard boundary extent, got %v to %v", ulx, lrx)
+	}
+	for _, r := range aw.results[1:] {
+		group, _ := r.Fetch("group")
+		count, _ := r.Fetch("structures")
+		value, _ := r.Fetch("structure value")
+		night, _ := r.Fetch("population night")
+		day, _ := r.Fetch("population day")
+		if count != int32(14) || value != 14*100000.0 || night != int32(14*6) || day != int32(14*4) {
+			t.Errorf("%v counted %v structures worth %v with %v at night and %v by day", group, count, value, night, day)
+		}
+		histogram := make([]int32, 0)
+		for _, label := range binLabels(DefaultExposureSettings().DepthBins) {
+			c, _ := r.Fetch(label)
+			histogram = append(histogram, c.(int32))
+		}
+		if !reflect.DeepEqual(histogram, []int32{0, 1, 1, 3, 4, 5}) {
+			t.Errorf("%v depth histogram was %v", group, histogram)
+		}
+	}
+}
+func TestExposureSettings_Validate(t *testing.T) {
+	var s ExposureSettings
+	err := json.Unmarshal([]byte(`{"aggregate_output_file_path": "exposure.json"}`), &s)
+	if err != nil || !reflect.DeepEqual(s.DepthBins, DefaultExposureSettings().DepthBins) {
+		t.Errorf("expected the default depth bins, got %v %v", s.DepthBins, err)
+	}
+	for _, bins := range [][]float64{{}, {-1, 1}, {0, 2, 2}} {
+		if (ExposureSettings{DepthBins: bins}).Validate() == nil {
+			t.Errorf("expected depth bins %v to be invalid", bins)
+		}
+	}
+}
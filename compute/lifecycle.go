@@ -0,0 +1,399 @@
+package compute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// Storm is an event in a storm catalog, a hazard with how often it occurs and the months it occurs in.
+type Storm struct {
+	Name                               string    `json:"name"`
+	AnnualProbability                  float64   `json:"annual_probability"`    //expected occurrences per year
+	Seasonality                        []float64 `json:"seasonality,omitempty"` //relative likelihood of each month from january to december, every month is equally likely if empty
+	Duration                           float64   `json:"duration"`              //days the structure is inundated
+	hazardproviders.HazardProviderInfo `json:"hazard_provider_info"`
+}
+
+// LifecycleSettings describes a lifecycle compute, storm sequences are sampled from the catalog for each year of the period of analysis.
+type LifecycleSettings struct {
+	PeriodOfAnalysis        `json:"period_of_analysis"`
+	Storms                  []Storm   `json:"storms"`
+	Lifecycles              int       `json:"lifecycles"`                           //number of storm sequences sampled
+	Percentiles             []float64 `json:"percentiles"`                          //non exceedance probabilities reported for each measure, e.g. .05, .5, .95
+	AggregateOutputFilePath string    `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total statistics
+}
+
+// DefaultLifecycleSettings returns the settings used when a configuration does not specify them.
+func DefaultLifecycleSettings() LifecycleSettings {
+	return LifecycleSettings{
+		PeriodOfAnalysis: PeriodOfAnalysis{Years: 50},
+		Lifecycles:       100,
+		Percentiles:      []float64{.05, .5, .95},
+	}
+}
+
+// UnmarshalJSON starts from DefaultLifecycleSettings so a configuration only needs to specify the settings it changes.
+func (s *LifecycleSettings) UnmarshalJSON(b []byte) error {
+	type settings LifecycleSettings
+	d := settings(DefaultLifecycleSettings())
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return err
+	}
+	*s = LifecycleSettings(d)
+	return nil
+}
+
+// Validate checks the period of analysis, the number of lifecycles and the storm catalog.
+func (s LifecycleSettings) Validate() error {
+	err := s.PeriodOfAnalysis.Validate()
+	if err != nil {
+		return err
+	}
+	if s.Lifecycles < 1 {
+		return errors.New("compute: lifecycles must be at least 1")
+	}
+	for _, p := range s.Percentiles {
+		if p <= 0 || p >= 1 {
+			return fmt.Errorf("compute: lifecycle percentile %v must be between 0 and 1", p)
+		}
+	}
+	if len(s.Storms) == 0 {
+		return errors.New("compute: a lifecycle compute requires at least one storm")
+	}
+	for _, storm := range s.Storms {
+		if storm.AnnualProbability <= 0 {
+			return fmt.Errorf("compute: storm %v requires a positive annual_probability", storm.Name)
+		}
+		if len(storm.HazardProviderInfo.Hazards) == 0 {
+			return fmt.Errorf("compute: storm %v requires a hazard", storm.Name)
+		}
+		if len(storm.Seasonality) == 0 {
+			continue
+		}
+		if len(storm.Seasonality) != 12 {
+			return fmt.Errorf("compute: storm %v seasonality requires a value for each month", storm.Name)
+		}
+		total := 0.0
+		for _, m := range storm.Seasonality {
+			if m < 0 {
+				return fmt.Errorf("compute: storm %v seasonality must not be negative", storm.Name)
+			}
+			total += m
+		}
+		if total == 0 {
+			return fmt.Errorf("compute: storm %v seasonality must have at least one positive month", storm.Name)
+		}
+	}
+	return nil
+}
+
+// CatalogStorm is a storm with its hazard provider opened.
+type CatalogStorm struct {
+	Name              string
+	AnnualProbability float64
+	Seasonality       []float64
+	Duration          float64
+	HazardProvider    hazardproviders.HazardProvider
+}
+
+// CreateStormCatalog opens the hazard provider of each storm, every provider opened is closed if one fails.
+func CreateStormCatalog(storms []Storm) ([]CatalogStorm, error) {
+	catalog := make([]CatalogStorm, 0, len(storms))
+	for _, s := range storms {
+		hp, err := s.CreateHazardProvider()
+		if err != nil {
+			closeStormCatalog(catalog)
+			return nil, err
+		}
+		catalog = append(catalog, CatalogStorm{Name: s.Name, AnnualProbability: s.AnnualProbability, Seasonality: s.Seasonality, Duration: s.Duration, HazardProvider: hp})
+	}
+	return catalog, nil
+}
+func closeStormCatalog(catalog []CatalogStorm) {
+	for _, s := range catalog {
+		s.HazardProvider.Close()
+	}
+}
+
+// stormCatalogBoundary is the union of the boundaries of every storm.
+func stormCatalogBoundary(catalog []CatalogStorm) (geography.BBox, error) {
+	hps := make([]hazardproviders.HazardProvider, len(catalog))
+	for i, s := range catalog {
+		hps[i] = s.HazardProvider
+	}
+	return frequencyBoundary(hps)
+}
+
+// SampledStorm is a storm from the catalog and the day it arrives.
+type SampledStorm struct {
+	Storm   int
+	Arrival time.Time
+}
+
+// SampleStormSequence samples the storms of one lifecycle in order of arrival. The number of storms in each year is poisson with a rate equal to the sum of the annual probabilities, each storm is drawn in proportion to its annual probability and arrives on a day of a month drawn from its seasonality.
+func SampleStormSequence(catalog []CatalogStorm, poa PeriodOfAnalysis, seed int64) []SampledStorm {
+	r := rand.New(rand.NewSource(seed))
+	rate := 0.0
+	for _, s := range catalog {
+		rate += s.AnnualProbability
+	}
+	sequence := make([]SampledStorm, 0)
+	for year := poa.BaseYear; year <= poa.LastYear(); year++ {
+		for n := samplePoisson(r, rate); n > 0; n-- {
+			u := r.Float64() * rate
+			storm := 0
+			for storm < len(catalog)-1 && u >= catalog[storm].AnnualProbability {
+				u -= catalog[storm].AnnualProbability
+				storm++
+			}
+			month := sampleMonth(r, catalog[storm].Seasonality)
+			days := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			sequence = append(sequence, SampledStorm{Storm: storm, Arrival: time.Date(year, month, 1+r.Intn(days), 0, 0, 0, 0, time.UTC)})
+		}
+	}
+	sort.SliceStable(sequence, func(i, j int) bool { return sequence[i].Arrival.Before(sequence[j].Arrival) })
+	return sequence
+}
+
+// samplePoisson draws from a poisson distribution by multiplying uniform draws, which is efficient for the small annual rates of a storm catalog.
+func samplePoisson(r *rand.Rand, rate float64) int {
+	limit := math.Exp(-rate)
+	n := 0
+	p := r.Float64()
+	for p > limit {
+		n++
+		p *= r.Float64()
+	}
+	return n
+}
+
+// sampleMonth draws a month in proportion to the seasonality, or uniformly if there is none.
+func sampleMonth(r *rand.Rand, seasonality []float64) time.Month {
+	if len(seasonality) != 12 {
+		return time.Month(1 + r.Intn(12))
+	}
+	total := 0.0
+	for _, m := range seasonality {
+		total += m
+	}
+	u := r.Float64() * total
+	for i, m := range seasonality {
+		if u < m {
+			return time.Month(i + 1)
+		}
+		u -= m
+	}
+	return time.December
+}
+
+// lifecycleOutcome summarizes a structure's consequences over one lifecycle.
+type lifecycleOutcome struct {
+	presentValue float64
+	rebuilds     float64
+	daysDamaged  float64
+}
+
+// computeLifecycle runs a structure through the storms of a sequence that reach it with the reconstruction aware compute. depths are the depths of each catalog storm at the structure, nil if the storm does not reach it.
+func computeLifecycle(sd structures.StructureDeterministic, catalog []CatalogStorm, depths []*float64, sequence []SampledStorm, poa PeriodOfAnalysis, rules structures.RebuildRules) (lifecycleOutcome, error) {
+	events := make([]hazards.ArrivalDepthandDurationEvent, 0, len(sequence))
+	for _, s := range sequence {
+		if depths[s.Storm] == nil {
+			continue
+		}
+		e := hazards.ArrivalDepthandDurationEvent{}
+		e.SetDepth(*depths[s.Storm])
+		e.SetDuration(catalog[s.Storm].Duration)
+		e.SetArrivalTime(s.Arrival)
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		return lifecycleOutcome{}, nil
+	}
+	r, err := sd.ComputeWithReconstruction(&hazards.ArrivalDepthandDurationEventMulti{Events: events}, rules)
+	if err != nil {
+		return lifecycleOutcome{}, err
+	}
+	outcome := lifecycleOutcome{}
+	tr, err := r.Fetch("times rebuilt")
+	if err != nil {
+		return outcome, err
+	}
+	outcome.rebuilds = float64(tr.(int32))
+	hr, err := r.Fetch("hazard results")
+	if err != nil {
+		return outcome, err
+	}
+	eventResults := hr.(consequences.Result)
+	end := time.Date(poa.LastYear()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	damagedUntil := time.Time{}
+	for i, e := range events {
+		er, err := eventResults.Fetch(fmt.Sprintf("%d", i))
+		if err != nil {
+			return outcome, err
+		}
+		result := er.(consequences.Result)
+		sdam, _ := result.Fetch("structure damage")
+		cdam, _ := result.Fetch("content damage")
+		damage := sdam.(float64) + cdam.(float64)
+		//damages are discounted from the end of the year they occur in, as PresentValue does.
+		outcome.presentValue += damage / math.Pow(1+poa.DiscountRate, float64(e.ArrivalTime().Year()-poa.BaseYear+1))
+		if damage <= 0 {
+			continue
+		}
+		//the structure is damaged from the arrival until reconstruction completes, an abandoned structure has no completion date and stays damaged.
+		cd, _ := result.Fetch("completion_date")
+		completion := cd.(time.Time)
+		if completion.IsZero() || completion.After(end) {
+			completion = end
+		}
+		start := e.ArrivalTime()
+		if start.Before(damagedUntil) {
+			start = damagedUntil
+		}
+		if completion.After(start) {
+			outcome.daysDamaged += completion.Sub(start).Hours() / 24
+			damagedUntil = completion
+		}
+	}
+	return outcome, nil
+}
+
+// lifecycleStatistics summarizes observations of each lifecycle measure, the histograms are sized from the largest observation.
+func lifecycleStatistics(observations [][]float64) []lossStatistics {
+	stats := make([]lossStatistics, len(observations))
+	for i, obs := range observations {
+		largest := 0.0
+		for _, v := range obs {
+			largest = math.Max(largest, v)
+		}
+		binWidth := largest / 100
+		if i > 0 {
+			//rebuilds are counts and days are whole days.
+			binWidth = math.Max(1, binWidth)
+		}
+		stats[i] = initLossStatistics(binWidth)
+		for _, v := range obs {
+			stats[i].addObservation(v)
+		}
+	}
+	return stats
+}
+
+// LifecycleSimulation samples storm sequences over the period of analysis and runs every structure in stream through each sequence with the reconstruction aware compute, rebuilding between storms according to rules. Every structure sees the same sequences, and is sampled for each lifecycle from a seed derived from its own seed and the lifecycle. The mean, standard deviation and percentiles of the present value of damages, the number of rebuilds and the days spent damaged are written to w for each structure reached by a storm, and to aw by damage category, county and in total if it is not nil. Structures that cannot be computed with reconstruction, such as occupancy types without a reconstruction damage function, are skipped.
+func LifecycleSimulation(catalog []CatalogStorm, poa PeriodOfAnalysis, lifecycles int, percentiles []float64, rules structures.RebuildRules, stream func(sp consequences.StreamProcessor), seed int64, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	err := poa.Validate()
+	if err != nil {
+		return err
+	}
+	if lifecycles < 1 || len(catalog) == 0 {
+		return errors.New("compute: a lifecycle compute requires at least one lifecycle and one storm")
+	}
+	sequences := make([][]SampledStorm, lifecycles)
+	for l := range sequences {
+		sequences[l] = SampleStormSequence(catalog, poa, structures.DeriveSeed(seed, int64(l)))
+	}
+	measures := []string{"pv damage", "rebuilds", "days damaged"}
+	statHeaders := monteCarloHeaders(measures, percentiles)
+	header := append(append(structureHeader(), "lifecycles"), statHeaders...)
+	groups := make(map[string]*monteCarloGroup)
+	groupObservations := make(map[*monteCarloGroup][][]float64)
+	group := func(aggregation string, name string) *monteCarloGroup {
+		key := aggregation + "|" + name
+		g, ok := groups[key]
+		if !ok {
+			g = &monteCarloGroup{aggregation: aggregation, name: name}
+			groups[key] = g
+			observations := make([][]float64, len(measures))
+			for i := range observations {
+				observations[i] = make([]float64, lifecycles)
+			}
+			groupObservations[g] = observations
+		}
+		return g
+	}
+	index := 0
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		receptorSeed := receptorSeed(seed, f, index)
+		if _, ok := sampleReceptor(f, receptorSeed); !ok {
+			return
+		}
+		depths := make([]*float64, len(catalog))
+		wet := false
+		for i, s := range catalog {
+			d, err := s.HazardProvider.Hazard(f.Location())
+			if err != nil || !d.Has(hazards.Depth) {
+				continue
+			}
+			depth := d.Depth()
+			depths[i] = &depth
+			wet = true
+		}
+		if !wet {
+			return
+		}
+		observations := make([][]float64, len(measures))
+		for i := range observations {
+			observations[i] = make([]float64, lifecycles)
+		}
+		var sd structures.StructureDeterministic
+		for l, sequence := range sequences {
+			sd, _ = sampleReceptor(f, structures.DeriveSeed(receptorSeed, int64(l)))
+			outcome, err := computeLifecycle(sd, catalog, depths, sequence, poa, rules)
+			if err != nil {
+				log.Printf("compute: lifecycle skipped structure %v, %v\n", sd.Name, err)
+				return
+			}
+			observations[0][l] = outcome.presentValue
+			observations[1][l] = outcome.rebuilds
+			observations[2][l] = outcome.daysDamaged
+		}
+		county := sd.CBFips
+		if len(county) >= 5 {
+			county = county[0:5]
+		}
+		for _, g := range []*monteCarloGroup{group("total", "total"), group("damage category", sd.DamCat), group("county", county)} {
+			for i := range measures {
+				for l, v := range observations[i] {
+					groupObservations[g][i][l] += v
+				}
+			}
+		}
+		result := append(structureAttributes(sd), int32(lifecycles))
+		for _, l := range lifecycleStatistics(observations) {
+			result = append(result, l.results(percentiles)...)
+		}
+		w.Write(consequences.Result{Headers: header, Result: result})
+	})
+	if aw == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	aggregateHeader := append([]string{"aggregation", "group", "lifecycles"}, statHeaders...)
+	for _, k := range keys {
+		g := groups[k]
+		result := []interface{}{g.aggregation, g.name, int32(lifecycles)}
+		for _, l := range lifecycleStatistics(groupObservations[g]) {
+			result = append(result, l.results(percentiles)...)
+		}
+		aw.Write(consequences.Result{Headers: aggregateHeader, Result: result})
+	}
+	return nil
+}
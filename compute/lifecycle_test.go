@@ -0,0 +1,127 @@
+package compute
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/structures"
+)
+
+func TestSampleStormSequence(t *testing.T) {
+	september := make([]float64, 12)
+	september[8] = 1
+	catalog := []CatalogStorm{{Name: "frequent", AnnualProbability: .4, Seasonality: september}, {Name: "rare", AnnualProbability: .1}}
+	poa := PeriodOfAnalysis{BaseYear: 2000, Years: 2000}
+	sequence := SampleStormSequence(catalog, poa, 99)
+	counts := make([]int, len(catalog))
+	for i, s := range sequence {
+		counts[s.Storm]++
+		if s.Storm == 0 && s.Arrival.Month() != time.September {
+			t.Errorf("storm arrived in %v outside its season", s.Arrival.Month())
+		}
+		if i > 0 && s.Arrival.Before(sequence[i-1].Arrival) {
+			t.Fatal("the sequence is not in order of arrival")
+		}
+	}
+	if math.Abs(float64(counts[0])-800) > 100 || math.Abs(float64(counts[1])-200) > 50 {
+		t.Errorf("sampled %v storms in 2000 years; expected about [800 200]", counts)
+	}
+	again := SampleStormSequence(catalog, poa, 99)
+	if len(again) != len(sequence) || again[0] != sequence[0] {
+		t.Error("the same seed sampled a different sequence")
+	}
+}
+
+// reconstructionStream streams deterministic structures with a reconstruction damage function, the default occupancy types do not have one.
+func reconstructionStream(count int) func(sp consequences.StreamProcessor) {
+	depthDamage := structures.DamageFunction{DamageFunction: paireddata.PairedData{Xvals: []float64{0, 1, 2, 4}, Yvals: []float64{0, 10, 20, 40}}, DamageDriver: hazards.Depth}
+	rebuildDays := structures.DamageFunction{DamageFunction: paireddata.PairedData{Xvals: []float64{0, 1}, Yvals: []float64{0, 365}}, DamageDriver: hazards.Depth}
+	family := func(df structures.DamageFunction) structures.DamageFunctionFamily {
+		return structures.DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]structures.DamageFunction{hazards.Default: df}}
+	}
+	ot := structures.OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: map[string]structures.DamageFunctionFamily{"structure": family(depthDamage), "contents": family(depthDamage), "reconstruction": family(rebuildDays)}}
+	return func(sp consequences.StreamProcessor) {
+		for i := 0; i < count; i++ {
+			sp(structures.StructureDeterministic{
+				BaseStructure: structures.BaseStructure{Name: string(rune('a' + i)), DamCat: "RES", CBFips: "151530001001", X: float64(i)},
+				OccType:       ot,
+				StructVal:     100000,
+				ContVal:       50000,
+				FoundHt:       1,
+			})
+		}
+	}
+}
+func TestLifecycleSimulation(t *testing.T) {
+	catalog := []CatalogStorm{
+		{Name: "nuisance", AnnualProbability: .5, Duration: 1, HazardProvider: constantDepthHazardProvider{depth: 2}},
+		{Name: "hurricane", AnnualProbability: .05, Duration: 3, HazardProvider: constantDepthHazardProvider{depth: 5}},
+	}
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 50, DiscountRate: .0275}
+	run := func(rules structures.RebuildRules) ([]consequences.Result, []consequences.Result) {
+		w := &collectingResultsWriter{}
+		aw := &collectingResultsWriter{}
+		err := LifecycleSimulation(catalog, poa, 20, []float64{.5}, rules, reconstructionStream(3), 5, w, aw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.results, aw.results
+	}
+	results, aggregates := run(structures.DefaultRebuildRules())
+	if len(results) != 3 {
+		t.Fatalf("wrote %v structures; expected %v", len(results), 3)
+	}
+	for _, r := range results {
+		pv, _ := r.Fetch("pv damage mean")
+		rebuilds, _ := r.Fetch("rebuilds mean")
+		days, _ := r.Fetch("days damaged mean")
+		if pv.(float64) <= 0 || rebuilds.(float64) <= 0 || days.(float64) <= 0 {
+			t.Errorf("expected damages, rebuilds and days damaged over 50 years of storms, got %v %v %v", pv, rebuilds, days)
+		}
+		if days.(float64) > 50*366 {
+			t.Errorf("structure was damaged for %v days, longer than the period of analysis", days)
+		}
+	}
+	again, _ := run(structures.DefaultRebuildRules())
+	for i, r := range again {
+		pv, _ := r.Fetch("pv damage mean")
+		expected, _ := results[i].Fetch("pv damage mean")
+		if pv != expected {
+			t.Errorf("the same seed computed %v; expected %v", pv, expected)
+		}
+	}
+	total := 0.0
+	for _, r := range results {
+		pv, _ := r.Fetch("pv damage mean")
+		total += pv.(float64)
+	}
+	for _, r := range aggregates {
+		if g, _ := r.Fetch("group"); g == "total" {
+			pv, _ := r.Fetch("pv damage mean")
+			if math.Abs(pv.(float64)-total) > 1e-6 {
+				t.Errorf("total mean pv damage was %v; expected the sum of the structures %v", pv, total)
+			}
+		}
+	}
+	//abandoning at the first total loss can only reduce the damages that follow.
+	abandoned, _ := run(structures.RebuildRules{RebuildFactor: 1, TotalLossThreshold: .15, TotalLossAction: structures.AbandonTotalLoss})
+	for i, r := range abandoned {
+		pv, _ := r.Fetch("pv damage mean")
+		expected, _ := results[i].Fetch("pv damage mean")
+		if pv.(float64) > expected.(float64) {
+			t.Errorf("abandoning increased pv damages from %v to %v", expected, pv)
+		}
+	}
+}
+func TestLifecycleSettings_Validate(t *testing.T) {
+	s := DefaultLifecycleSettings()
+	s.Storms = []Storm{{Name: "a", AnnualProbability: .1, Seasonality: []float64{1, 2}, HazardProviderInfo: hazardproviders.HazardProviderInfo{Hazards: []hazardproviders.HazardProviderParameterAndPath{{FilePath: "a.tif"}}}}}
+	if s.Validate() == nil {
+		t.Error("expected an error for seasonality without a value for each month")
+	}
+}
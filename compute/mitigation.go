@@ -0,0 +1,137 @@
+package compute
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// MitigationSettings describes a mitigation benefit cost compute, every structure is evaluated with and without the measures, applied in order.
+type MitigationSettings struct {
+	Events                  []FrequencyEvent                   `json:"events"`
+	TailMethod              TailMethod                         `json:"tail_method,omitempty"` //trapezoidal if not set
+	PeriodOfAnalysis        PeriodOfAnalysis                   `json:"period_of_analysis"`    //annualizes the cost of the measures
+	Measures                []structures.MitigationMeasureInfo `json:"measures"`
+	AggregateOutputFilePath string                             `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total benefits and costs
+}
+
+// Validate checks the events, the period of analysis and that every measure can be created.
+func (s MitigationSettings) Validate() error {
+	err := EADSettings{Events: s.Events, TailMethod: s.TailMethod}.Validate()
+	if err != nil {
+		return err
+	}
+	err = s.PeriodOfAnalysis.Validate()
+	if err != nil {
+		return err
+	}
+	if len(s.Measures) == 0 {
+		return errors.New("compute: mitigation requires at least one measure")
+	}
+	_, err = s.CreateMitigationMeasures()
+	return err
+}
+
+// CreateMitigationMeasures creates the measures in the order they are applied.
+func (s MitigationSettings) CreateMitigationMeasures() ([]structures.MitigationMeasure, error) {
+	measures := make([]structures.MitigationMeasure, 0, len(s.Measures))
+	for i, info := range s.Measures {
+		m, err := info.CreateMitigationMeasure()
+		if err != nil {
+			return nil, fmt.Errorf("measure %v: %w", i, err)
+		}
+		measures = append(measures, m)
+	}
+	return measures, nil
+}
+
+// mitigationHeaders are the values written for each structure, life loss is only included with a lifeloss engine.
+func mitigationHeaders(lle *lifeloss.LifeLossEngine) []string {
+	h := []string{"total ead", "mitigated total ead", "ead reduction", "mitigation cost", "annual cost", "net benefits"}
+	if lle != nil {
+		h = append(h, "eall", "mitigated eall", "eall reduction")
+	}
+	return h
+}
+
+// MitigationBenefitCost computes the expected annual damage of every structure in stream before and after the measures are applied. hps and aeps must be ordered from most to least frequent. The cost of the measures is annualized over the period of analysis, and the ead reduction, annual cost, net benefits and benefit cost ratio of each structure are written to w. Structures removed by a measure have no damage once mitigated. The values, less the benefit cost ratio, summed by damage category, county and in total are written to aw if it is not nil. It is an error if a measure cannot be applied to a structure, such as elevating one without a ground elevation.
+func MitigationBenefitCost(hps []hazardproviders.HazardProvider, aeps []float64, tail TailMethod, poa PeriodOfAnalysis, measures []structures.MitigationMeasure, stream func(sp consequences.StreamProcessor), seed int64, lle *lifeloss.LifeLossEngine, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	if len(hps) != len(aeps) {
+		return errors.New("compute: mitigation requires one hazard provider for each annual exceedance probability")
+	}
+	err := checkFrequencies(aeps)
+	if err != nil {
+		return err
+	}
+	err = poa.Validate()
+	if err != nil {
+		return err
+	}
+	crf := poa.CapitalRecoveryFactor()
+	valueHeaders := mitigationHeaders(lle)
+	header := append(append(structureHeader(), valueHeaders...), "benefit cost ratio")
+	groups := make(eadGroups)
+	index := 0
+	var computeErr error
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		if computeErr != nil {
+			return
+		}
+		receptorSeed := receptorSeed(seed, f, index)
+		sd, ok := sampleReceptor(f, receptorSeed)
+		if !ok {
+			return
+		}
+		curves, gotWet := damageFrequency(f, sd, receptorSeed, hps, lle)
+		if !gotWet {
+			return
+		}
+		eads, err := integrateCurves(aeps, curves, tail)
+		if err != nil {
+			computeErr = err
+			return
+		}
+		//stream 1 draws lifeloss, so the measures draw from stream 2.
+		msd, cost, standing, err := structures.ApplyMitigation(sd, measures, structures.DeriveSeed(receptorSeed, 2))
+		if err != nil {
+			computeErr = err
+			return
+		}
+		mitigated := make([]float64, len(eads))
+		if standing {
+			mcurves, _ := damageFrequency(msd, msd, receptorSeed, hps, lle)
+			mitigated, err = integrateCurves(aeps, mcurves, tail)
+			if err != nil {
+				computeErr = err
+				return
+			}
+		}
+		reduction := eads[2] - mitigated[2]
+		annualCost := cost * crf
+		values := []float64{eads[2], mitigated[2], reduction, cost, annualCost, reduction - annualCost}
+		if lle != nil {
+			values = append(values, eads[3], mitigated[3], eads[3]-mitigated[3])
+		}
+		groups.add(sd, values)
+		bcr := 0.0
+		if annualCost > 0 {
+			bcr = reduction / annualCost
+		}
+		result := structureAttributes(sd)
+		for _, v := range values {
+			result = append(result, v)
+		}
+		result = append(result, bcr)
+		w.Write(consequences.Result{Headers: header, Result: result})
+	})
+	if computeErr != nil || aw == nil {
+		return computeErr
+	}
+	groups.write(valueHeaders, aw)
+	return nil
+}
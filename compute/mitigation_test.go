@@ -0,0 +1,68 @@
+package compute
+
+import (
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/structures"
+)
+
+func TestMitigationBenefitCost(t *testing.T) {
+	hps := []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 2}, constantDepthHazardProvider{depth: 6}}
+	aeps := []float64{.1, .01}
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 50, DiscountRate: .0275}
+	measures := []structures.MitigationMeasure{structures.Elevation{TargetElevation: 12, CostModel: structures.CostModel{FixedCost: 20000}}}
+	sp := testStructureStream{count: 5, seed: 3}
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := MitigationBenefitCost(hps, aeps, TrapezoidalTail, poa, measures, func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }, 3, nil, w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	for _, r := range w.results {
+		reduction, _ := r.Fetch("ead reduction")
+		annualCost, _ := r.Fetch("annual cost")
+		bcr, _ := r.Fetch("benefit cost ratio")
+		if reduction.(float64) <= 0 {
+			t.Errorf("raising above the events reduced ead by %v", reduction)
+		}
+		if math.Abs(annualCost.(float64)-20000*poa.CapitalRecoveryFactor()) > 1e-9 {
+			t.Errorf("annual cost was %v; expected %v", annualCost, 20000*poa.CapitalRecoveryFactor())
+		}
+		if math.Abs(bcr.(float64)-reduction.(float64)/annualCost.(float64)) > 1e-9 {
+			t.Errorf("benefit cost ratio was %v; expected %v", bcr, reduction.(float64)/annualCost.(float64))
+		}
+	}
+	if len(aw.results) == 0 {
+		t.Error("no aggregate benefits and costs were written")
+	}
+}
+func TestMitigationBenefitCost_AboveTarget(t *testing.T) {
+	hps := []hazardproviders.HazardProvider{constantDepthHazardProvider{depth: 2}, constantDepthHazardProvider{depth: 6}}
+	aeps := []float64{.1, .01}
+	poa := PeriodOfAnalysis{BaseYear: 2030, Years: 50, DiscountRate: .0275}
+	//every first floor is already above the target, so no structure is raised or charged for it.
+	measures := []structures.MitigationMeasure{structures.Elevation{TargetElevation: -1, CostModel: structures.CostModel{FixedCost: 20000}}}
+	sp := testStructureStream{count: 5, seed: 3}
+	w := &collectingResultsWriter{}
+	err := MitigationBenefitCost(hps, aeps, TrapezoidalTail, poa, measures, func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }, 3, nil, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	for _, r := range w.results {
+		reduction, _ := r.Fetch("ead reduction")
+		cost, _ := r.Fetch("mitigation cost")
+		if reduction.(float64) != 0 || cost.(float64) != 0 {
+			t.Errorf("a structure above the target had an ead reduction of %v for %v", reduction, cost)
+		}
+	}
+}
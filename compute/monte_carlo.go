@@ -0,0 +1,349 @@
+package compute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/data"
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/structures"
+)
+
+// MonteCarloSettings controls how many realizations a monte carlo compute draws and which statistics it reports.
+type MonteCarloSettings struct {
+	MinIterations           int       `json:"min_iterations"`                       //realizations drawn before convergence is first tested, and between each test after that
+	MaxIterations           int       `json:"max_iterations"`                       //realizations drawn if the compute never converges
+	ZAlpha                  float64   `json:"z_alpha"`                              //standard normal deviate for the confidence of the convergence test, 1.96 is 95%
+	RelativeError           float64   `json:"relative_error"`                       //allowable half width of the confidence interval on the mean total loss as a fraction of that mean
+	Percentiles             []float64 `json:"percentiles"`                          //non exceedance probabilities reported for each loss, e.g. .05, .5, .95
+	AggregateOutputFilePath string    `json:"aggregate_output_file_path,omitempty"` //json file for damage category, county and total statistics
+}
+
+// DefaultMonteCarloSettings returns the settings used when a configuration does not specify them.
+func DefaultMonteCarloSettings() MonteCarloSettings {
+	return MonteCarloSettings{
+		MinIterations: 100,
+		MaxIterations: 5000,
+		ZAlpha:        1.96,
+		RelativeError: .01,
+		Percentiles:   []float64{.05, .5, .95},
+	}
+}
+
+// UnmarshalJSON starts from DefaultMonteCarloSettings so a configuration only needs to specify the settings it changes.
+func (s *MonteCarloSettings) UnmarshalJSON(b []byte) error {
+	type settings MonteCarloSettings
+	d := settings(DefaultMonteCarloSettings())
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return err
+	}
+	*s = MonteCarloSettings(d)
+	return nil
+}
+
+// Validate checks the settings before any realizations are drawn.
+func (s MonteCarloSettings) Validate() error {
+	if s.MinIterations < 1 {
+		return errors.New("compute: monte carlo min_iterations must be at least 1")
+	}
+	if s.MaxIterations < s.MinIterations {
+		return errors.New("compute: monte carlo max_iterations must be at least min_iterations")
+	}
+	if s.ZAlpha <= 0 || s.RelativeError <= 0 {
+		return errors.New("compute: monte carlo z_alpha and relative_error must be positive")
+	}
+	for _, p := range s.Percentiles {
+		if p <= 0 || p >= 1 {
+			return fmt.Errorf("compute: monte carlo percentile %v must be between 0 and 1", p)
+		}
+	}
+	return nil
+}
+
+// moments is the method set of the product moments created by data.CreateProductMoments.
+type moments interface {
+	AddObservation(value float64)
+	GetMean() float64
+	GetSampleVariance() float64
+	GetSampleSize() int64
+}
+
+// lossStatistics accumulates the realizations of a single loss measure, the moments are exact and the percentiles come from the histogram.
+type lossStatistics struct {
+	moments   moments
+	histogram *data.InlineHistogram
+}
+
+func initLossStatistics(binWidth float64) lossStatistics {
+	if binWidth <= 0 {
+		binWidth = 1
+	}
+	return lossStatistics{moments: data.CreateProductMoments(), histogram: data.Init(binWidth, 0, binWidth)}
+}
+func (l lossStatistics) addObservation(value float64) {
+	l.moments.AddObservation(value)
+	l.histogram.AddObservation(value)
+}
+func (l lossStatistics) standardDeviation() float64 {
+	if l.moments.GetSampleSize() < 2 {
+		return 0
+	}
+	return math.Sqrt(l.moments.GetSampleVariance())
+}
+
+// converged tests whether the confidence interval on the mean is within the relative error, measures that do not vary are converged.
+func (l lossStatistics) converged(zAlpha float64, relativeError float64) bool {
+	n := float64(l.moments.GetSampleSize())
+	if n < 2 {
+		return false
+	}
+	sd := l.standardDeviation()
+	if sd == 0 {
+		return true
+	}
+	return zAlpha*sd/math.Sqrt(n) <= relativeError*math.Abs(l.moments.GetMean())
+}
+func (l lossStatistics) results(percentiles []float64) []interface{} {
+	r := []interface{}{l.moments.GetMean(), l.standardDeviation()}
+	for _, p := range percentiles {
+		r = append(r, l.histogram.InvCDF(p))
+	}
+	return r
+}
+
+// monteCarloHeaders lists the statistic columns for each loss measure.
+func monteCarloHeaders(measures []string, percentiles []float64) []string {
+	h := make([]string, 0, len(measures)*(2+len(percentiles)))
+	for _, m := range measures {
+		h = append(h, m+" mean", m+" std")
+		for _, p := range percentiles {
+			h = append(h, m+" p"+strconv.FormatFloat(p*100, 'f', -1, 64))
+		}
+	}
+	return h
+}
+
+// monteCarloGroup aggregates the realizations of every structure in a damage category, a county, or the whole study area.
+type monteCarloGroup struct {
+	aggregation string
+	name        string
+	stats       []lossStatistics
+	realization []float64
+}
+
+// monteCarloStructure is a structure and its hazard held in memory so the inventory is streamed, and the hazard provided, only once.
+type monteCarloStructure struct {
+	structure structures.StructureStochastic
+	hazards   []hazards.HazardEvent //one for each ensemble member, nil for members without a hazard at the structure
+	seed      int64
+	stats     []lossStatistics
+	groups    []*monteCarloGroup
+}
+
+// realize computes one realization of the structure, the sample is drawn from a seed derived from the structure's seed and the iteration. The ensemble member is drawn from its own seed derived from the realization's, a member without a hazard has no losses.
+func (m monteCarloStructure) realize(iteration int, lle *lifeloss.LifeLossEngine) ([]float64, error) {
+	seed := structures.DeriveSeed(m.seed, int64(iteration))
+	hazard := m.hazards[rand.New(rand.NewSource(structures.DeriveSeed(seed, 0))).Intn(len(m.hazards))]
+	if hazard == nil {
+		if lle != nil {
+			return make([]float64, 3), nil
+		}
+		return make([]float64, 2), nil
+	}
+	var r consequences.Result
+	var err error
+	if lle != nil {
+		r, err = computeLifelossForHazard(hazard, m.structure, seed, *lle)
+	} else {
+		r, err = m.structure.SampleStructure(seed).Compute(hazard)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sd, err := r.Fetch("structure damage")
+	if err != nil {
+		return nil, err
+	}
+	cd, err := r.Fetch("content damage")
+	if err != nil {
+		return nil, err
+	}
+	losses := []float64{sd.(float64), cd.(float64)}
+	if lle != nil {
+		ll, err := r.Fetch("ll_tot")
+		if err != nil {
+			return nil, err
+		}
+		losses = append(losses, float64(ll.(int32)))
+	}
+	return losses, nil
+}
+
+// memberHazards is the hazard of every member of an EnsembleHazardProvider at l, or the only hazard of other providers.
+func memberHazards(hp hazardproviders.HazardProvider, l geography.Location) ([]hazards.HazardEvent, error) {
+	if ep, ok := hp.(hazardproviders.EnsembleHazardProvider); ok {
+		return ep.EnsembleHazard(l)
+	}
+	d, err := hp.Hazard(l)
+	if err != nil {
+		return nil, err
+	}
+	return []hazards.HazardEvent{d}, nil
+}
+
+// MonteCarlo draws repeated realizations of every stochastic structure in the stream that has a hazard, cycling through the members of an EnsembleHazardProvider and sampling structure and content values, foundation heights and damage functions each time. Realizations are drawn in batches of MinIterations until the mean total loss converges or MaxIterations is reached. The mean, standard deviation and percentiles of each loss are written to w for every structure, and to aw by damage category, county and in total if aw is not nil. Life loss is only computed if lle is not nil.
+func MonteCarlo(hp hazardproviders.HazardProvider, stream func(sp consequences.StreamProcessor), settings MonteCarloSettings, seed int64, lle *lifeloss.LifeLossEngine, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	err := settings.Validate()
+	if err != nil {
+		return err
+	}
+	measures := []string{"structure damage", "content damage"}
+	if lle != nil {
+		measures = append(measures, "life loss")
+	}
+	inventory := make([]*monteCarloStructure, 0)
+	groups := make(map[string]*monteCarloGroup)
+	groupValues := make(map[*monteCarloGroup][]float64)
+	group := func(aggregation string, name string) *monteCarloGroup {
+		key := aggregation + "|" + name
+		g, ok := groups[key]
+		if !ok {
+			g = &monteCarloGroup{aggregation: aggregation, name: name, realization: make([]float64, len(measures))}
+			groups[key] = g
+			groupValues[g] = make([]float64, len(measures))
+		}
+		return g
+	}
+	total := group("total", "total")
+	index := 0
+	stream(func(f consequences.Receptor) {
+		defer func() { index++ }()
+		s, ok := f.(structures.StructureStochastic)
+		if !ok {
+			log.Printf("compute: monte carlo skipped %T, only stochastic structures are sampled\n", f)
+			return
+		}
+		members, err := memberHazards(hp, s.Location())
+		if err != nil {
+			return
+		}
+		s.UseUncertainty = true
+		ms := monteCarloStructure{structure: s, hazards: members, seed: receptorSeed(seed, s, index)}
+		county := s.CBFips
+		if len(county) >= 5 {
+			county = county[0:5]
+		}
+		ms.groups = []*monteCarloGroup{total, group("damage category", s.DamCat), group("county", county)}
+		//size the histograms from the central tendency of the values at risk.
+		values := []float64{s.StructVal.CentralTendency(), s.ContVal.CentralTendency(), float64(s.Pop2amo65 + s.Pop2amu65 + s.Pop2pmo65 + s.Pop2pmu65)}
+		ms.stats = make([]lossStatistics, len(measures))
+		for i := range measures {
+			if i < 2 {
+				ms.stats[i] = initLossStatistics(values[i] / 100)
+			} else {
+				ms.stats[i] = initLossStatistics(1)
+			}
+			for _, g := range ms.groups {
+				groupValues[g][i] += values[i]
+			}
+		}
+		inventory = append(inventory, &ms)
+	})
+	for g, values := range groupValues {
+		g.stats = make([]lossStatistics, len(measures))
+		for i := range measures {
+			if i < 2 {
+				g.stats[i] = initLossStatistics(values[i] / 1000)
+			} else {
+				g.stats[i] = initLossStatistics(1)
+			}
+		}
+	}
+	iteration := 0
+	for iteration < settings.MaxIterations {
+		batchEnd := iteration + settings.MinIterations
+		if batchEnd > settings.MaxIterations {
+			batchEnd = settings.MaxIterations
+		}
+		for ; iteration < batchEnd; iteration++ {
+			for _, g := range groups {
+				for i := range g.realization {
+					g.realization[i] = 0
+				}
+			}
+			for _, ms := range inventory {
+				losses, err := ms.realize(iteration, lle)
+				if err != nil {
+					return err
+				}
+				for i, v := range losses {
+					ms.stats[i].addObservation(v)
+					for _, g := range ms.groups {
+						g.realization[i] += v
+					}
+				}
+			}
+			for _, g := range groups {
+				for i, v := range g.realization {
+					g.stats[i].addObservation(v)
+				}
+			}
+		}
+		converged := true
+		for _, l := range total.stats {
+			converged = converged && l.converged(settings.ZAlpha, settings.RelativeError)
+		}
+		if converged {
+			break
+		}
+	}
+	log.Printf("monte carlo drew %v realizations of %v structures\n", iteration, len(inventory))
+	statHeaders := monteCarloHeaders(measures, settings.Percentiles)
+	header := append([]string{"fd_id", "x", "y", "damage category", "occupancy type", "cbfips", "iterations"}, statHeaders...)
+	for _, ms := range inventory {
+		s := ms.structure
+		r := []interface{}{s.Name, s.X, s.Y, s.DamCat, s.OccType.Name, s.CBFips, int32(iteration)}
+		for _, l := range ms.stats {
+			r = append(r, l.results(settings.Percentiles)...)
+		}
+		w.Write(consequences.Result{Headers: header, Result: r})
+	}
+	if aw == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	aggregateHeader := append([]string{"aggregation", "group", "iterations"}, statHeaders...)
+	for _, k := range keys {
+		g := groups[k]
+		r := []interface{}{g.aggregation, g.name, int32(iteration)}
+		for _, l := range g.stats {
+			r = append(r, l.results(settings.Percentiles)...)
+		}
+		aw.Write(consequences.Result{Headers: aggregateHeader, Result: r})
+	}
+	return nil
+}
+
+// StreamAbstractMonteCarlo runs a monte carlo compute of damages for every structure in the hazard boundary.
+func StreamAbstractMonteCarlo(hp hazardproviders.HazardProvider, sp consequences.StreamProvider, settings MonteCarloSettings, seed int64, w consequences.ResultsWriter, aw consequences.ResultsWriter) error {
+	stream, err := hazardStream(hp, sp)
+	if err != nil {
+		return err
+	}
+	return MonteCarlo(hp, stream, settings, seed, nil, w, aw)
+}
@@ -0,0 +1,134 @@
+package compute
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/lifeloss"
+	"github.com/USACE/go-consequences/warning"
+)
+
+func TestMonteCarloSettings_UnmarshalJSONDefaults(t *testing.T) {
+	var s MonteCarloSettings
+	err := json.Unmarshal([]byte(`{"max_iterations":200}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MaxIterations != 200 || s.MinIterations != 100 || s.ZAlpha != 1.96 || len(s.Percentiles) != 3 {
+		t.Errorf("unexpected settings %+v", s)
+	}
+	if s.Validate() != nil {
+		t.Error(s.Validate())
+	}
+}
+func TestMonteCarlo_AggregatesMatchStructures(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 4}
+	sp := testStructureStream{count: 20}
+	settings := DefaultMonteCarloSettings()
+	settings.MinIterations = 50
+	settings.MaxIterations = 200
+	w := &collectingResultsWriter{}
+	aw := &collectingResultsWriter{}
+	err := StreamAbstractMonteCarlo(hp, sp, settings, 1234, w, aw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.results) != sp.count {
+		t.Fatalf("wrote %v structures; expected %v", len(w.results), sp.count)
+	}
+	sum := 0.0
+	for _, r := range w.results {
+		iterations, _ := r.Fetch("iterations")
+		if iterations.(int32) < 50 || iterations.(int32) > 200 {
+			t.Errorf("drew %v realizations; expected between 50 and 200", iterations)
+		}
+		mean, _ := r.Fetch("structure damage mean")
+		std, _ := r.Fetch("structure damage std")
+		p5, _ := r.Fetch("structure damage p5")
+		p95, _ := r.Fetch("structure damage p95")
+		if std.(float64) <= 0 {
+			t.Errorf("expected structure damage to vary, std was %v", std)
+		}
+		if p5.(float64) > mean.(float64) || mean.(float64) > p95.(float64) {
+			t.Errorf("expected p5 %v <= mean %v <= p95 %v", p5, mean, p95)
+		}
+		sum += mean.(float64)
+	}
+	//total, one damage category and one county.
+	if len(aw.results) != 3 {
+		t.Fatalf("wrote %v aggregates; expected 3", len(aw.results))
+	}
+	for _, r := range aw.results {
+		mean, _ := r.Fetch("structure damage mean")
+		if math.Abs(mean.(float64)-sum) > 1e-6*sum {
+			group, _ := r.Fetch("group")
+			t.Errorf("%v mean was %v; expected the sum of the structure means %v", group, mean, sum)
+		}
+	}
+}
+func TestMonteCarlo_Reproducible(t *testing.T) {
+	hp := constantDepthHazardProvider{depth: 12}
+	settings := DefaultMonteCarloSettings()
+	settings.MinIterations = 20
+	settings.MaxIterations = 20
+	var baseline map[interface{}]interface{}
+	for _, reverse := range []bool{false, true} {
+		engine := lifeloss.Init(7, warning.InitComplianceBasedWarningSystem(7, .5))
+		sp := testStructureStream{count: 10, reverse: reverse}
+		w := &collectingResultsWriter{}
+		err := MonteCarlo(hp, func(p consequences.StreamProcessor) { sp.ByBbox(geography.BBox{}, p) }, settings, 7, &engine, w, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := resultsByName(t, w.results, "life loss mean")
+		if baseline == nil {
+			baseline = m
+			continue
+		}
+		for name, v := range m {
+			if v != baseline[name] {
+				t.Errorf("structure %v had %v mean life loss when streamed in reverse; expected %v", name, v, baseline[name])
+			}
+		}
+	}
+}
+
+// ensembleDepthHazardProvider has one member for each depth, a negative depth is a dry member.
+type ensembleDepthHazardProvider struct {
+	constantDepthHazardProvider
+	depths []float64
+}
+
+func (e ensembleDepthHazardProvider) EnsembleHazard(l geography.Location) ([]hazards.HazardEvent, error) {
+	events := make([]hazards.HazardEvent, len(e.depths))
+	for i, d := range e.depths {
+		if d >= 0 {
+			events[i], _ = constantDepthHazardProvider{depth: d}.Hazard(l)
+		}
+	}
+	return events, nil
+}
+func TestMonteCarlo_EnsembleMembers(t *testing.T) {
+	hp := ensembleDepthHazardProvider{depths: []float64{-1, 8}}
+	settings := DefaultMonteCarloSettings()
+	settings.MinIterations = 40
+	settings.MaxIterations = 40
+	settings.Percentiles = []float64{.25, .75}
+	w := &collectingResultsWriter{}
+	err := StreamAbstractMonteCarlo(hp, testStructureStream{count: 5}, settings, 99, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range w.results {
+		p25, _ := r.Fetch("structure damage p25")
+		p75, _ := r.Fetch("structure damage p75")
+		//percentiles come from the histogram, so the dry member's zeros fall in its first bin.
+		if p25.(float64) > .05*p75.(float64) {
+			t.Errorf("expected half the realizations from the dry member, got p25 %v and p75 %v", p25, p75)
+		}
+	}
+}
@@ -1,13 +1,11 @@
 package compute
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
+	"sort"
 
 	"github.com/USACE/go-consequences/consequences"
-	"github.com/USACE/go-consequences/geography"
 	"github.com/USACE/go-consequences/hazardproviders"
 	"github.com/USACE/go-consequences/hazards"
 	"github.com/USACE/go-consequences/indirecteconomics"
@@ -15,6 +13,8 @@ import (
 )
 
 // ComputeEAD takes an array of damages and frequencies and integrates the curve. we should probably refactor this into paired data as a function.
+//
+// Deprecated: use IntegrateDamageFrequency, which validates the curve and offers a choice of tail handling.
 func ComputeEAD(damages []float64, freq []float64) float64 {
 	triangle := 0.0
 	square := 0.0
@@ -38,6 +38,8 @@ func ComputeEAD(damages []float64, freq []float64) float64 {
 }
 
 // ComputeSpecialEAD integrates under the damage frequency curve but does not calculate the first triangle between 1 and the first frequency.
+//
+// Deprecated: use IntegrateDamageFrequency, which validates the curve and offers a choice of tail handling.
 func ComputeSpecialEAD(damages []float64, freq []float64) float64 {
 	//this differs from computeEAD in that it specifically does not calculate the first triangle between 1 and the first frequency to interpolate damages to zero.
 	if len(damages) != len(freq) {
@@ -76,9 +78,13 @@ func StreamAbstract(hp hazardproviders.HazardProvider, sp consequences.StreamPro
 		log.Panicf("Unable to get the raster bounding box: %s", err)
 	}
 	fmt.Println(bbox.ToString())
-	sp.ByBbox(bbox, func(f consequences.Receptor) {
+	stream, err := hazardStream(hp, sp)
+	if err != nil {
+		log.Panicf("Unable to stream the hazard boundary: %s", err)
+	}
+	stream(func(f consequences.Receptor) {
 		//ProvideHazard works off of a geography.Location
-		d, err2 := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
+		d, err2 := hp.Hazard(f.Location())
 		//compute damages based on hazard being able to provide depth
 		if err2 == nil {
 			r, err3 := f.Compute(d)
@@ -88,94 +94,43 @@ func StreamAbstract(hp hazardproviders.HazardProvider, sp consequences.StreamPro
 		}
 	})
 }
+// StreamAbstractMultiFrequency computes expected annual damages for every structure in the union of the hazard boundaries. the providers and frequencies may be in any order but must correspond.
+//
+// It writes the columns of ExpectedAnnualDamages with a trapezoidal tail: fd_id, x, y, damage category, occupancy type and cbfips, structure and content damage for each frequency from most to least frequent, then structure, content and total ead. This replaces the earlier layout of s EAD, c EAD, population, found_ht and cb_id columns followed by structure, content and hazard json columns for each frequency in the order given, so readers of that layout must be updated.
 func StreamAbstractMultiFrequency(hps []hazardproviders.HazardProvider, freqs []float64, sp consequences.StreamProvider, w consequences.ResultsWriter) {
 	fmt.Printf("Computing %v frequencies\n", len(hps))
-	//ASSUMPTION hazard providers and frequencies are in the same order
-	//ASSUMPTION ordered by most frequent to least frequent event
-	//ASSUMPTION! get bounding box from largest frequency.
-
-	largestHp := hps[len(hps)-1]
-	bbox, err := largestHp.HazardBoundary()
+	if len(hps) != len(freqs) {
+		fmt.Println("compute: each hazard provider requires a frequency")
+		return
+	}
+	order := make([]int, len(freqs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return freqs[order[i]] > freqs[order[j]] })
+	sortedHps := make([]hazardproviders.HazardProvider, len(hps))
+	sortedFreqs := make([]float64, len(freqs))
+	for i, o := range order {
+		sortedHps[i] = hps[o]
+		sortedFreqs[i] = freqs[o]
+	}
+	bbox, err := frequencyBoundary(sortedHps)
 	if err != nil {
 		fmt.Print(err)
 		return
 	}
-	//set up output tables for all frequencies.
-	header := []string{"fd_id", "x", "y", "damcat", "occtype", "s EAD", "c EAD", "pop2amu65", "pop2amo65", "pop2pmu65", "pop2pmo65", "found_ht", "cb_id"}
-
-	for _, f := range freqs {
-		header = append(header, fmt.Sprintf("%2.6fS", f))
-		header = append(header, fmt.Sprintf("%2.6fC", f))
-		header = append(header, fmt.Sprintf("%2.6fH", f))
+	err = ExpectedAnnualDamages(sortedHps, sortedFreqs, TrapezoidalTail, func(p consequences.StreamProcessor) {
+		sp.ByBbox(bbox, p)
+	}, 0, nil, w, nil)
+	if err != nil {
+		fmt.Print(err)
 	}
-
-	sp.ByBbox(bbox, func(f consequences.Receptor) {
-		s, sok := f.(structures.StructureStochastic)
-		if !sok {
-			return
-		}
-		results := []interface{}{s.Name, s.X, s.Y, s.DamCat, s.OccType.Name, 0.0, 0.0, s.Pop2amu65, s.Pop2amo65, s.Pop2pmu65, s.Pop2pmo65, s.FoundHt.CentralTendency(), s.CBFips}
-
-		sEADs := make([]float64, len(freqs))
-		cEADs := make([]float64, len(freqs))
-		hazarddata := make([]hazards.HazardEvent, len(freqs))
-		//ProvideHazard works off of a geography.Location
-		gotWet := false
-		for index, hp := range hps {
-			d, err := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
-			hazarddata = append(hazarddata, d)
-			//compute damages based on hazard being able to provide depth
-
-			if err == nil {
-				r, err3 := f.Compute(d)
-				if err3 == nil {
-					gotWet = true
-					sdam, err := r.Fetch("structure damage")
-					if err != nil {
-						//panic?
-						sEADs[index] = 0.0
-					} else {
-						damage := sdam.(float64)
-						sEADs[index] = damage
-					}
-					cdam, err := r.Fetch("content damage")
-					if err != nil {
-						//panic?
-						cEADs[index] = 0.0
-					} else {
-						damage := cdam.(float64)
-						cEADs[index] = damage
-					}
-				}
-				results = append(results, sEADs[index])
-				results = append(results, cEADs[index])
-				b, _ := json.Marshal(d)
-				shaz := string(b)
-				results = append(results, shaz)
-			} else {
-				//record zeros?
-				results = append(results, 0.0)
-				results = append(results, 0.0)
-				results = append(results, "no hazard")
-			}
-		}
-		sEAD := ComputeSpecialEAD(sEADs, freqs)
-		results[5] = sEAD
-		cEAD := ComputeEAD(cEADs, freqs)
-		results[6] = cEAD
-		var ret = consequences.Result{Headers: header, Result: results}
-		if gotWet {
-			w.Write(ret)
-		}
-
-	})
-
 }
 func StreamAbstractByFIPS(FIPSCODE string, hp hazardproviders.HazardProvider, sp consequences.StreamProvider, w consequences.ResultsWriter) {
 	fmt.Println("FIPS Code is " + FIPSCODE)
 	sp.ByFips(FIPSCODE, func(f consequences.Receptor) {
 		//ProvideHazard works off of a geography.Location
-		d, err := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
+		d, err := hp.Hazard(f.Location())
 		//compute damages based on hazard being able to provide depth
 		if err == nil {
 			r, err3 := f.Compute(d)
@@ -195,7 +150,7 @@ func StreamAbstractByFIPS_WithECAM(FIPSCODE string, hp hazardproviders.HazardPro
 		s, sok := f.(structures.StructureStochastic)
 		if sok {
 			//parse to get county level
-			d := s.SampleStructure(rand.Int63()) //this is not a good idea, it will advance the seed and change results beween ECAM and non ecam computes.
+			d := s.SampleStructure(s.SampleSeed()) //the same sample f.Compute draws below, so ECAM and non ECAM computes agree.
 			cbfips := s.CBFips[0:5]
 			c, cok := totalCounty[cbfips]
 			if cok {
@@ -207,7 +162,7 @@ func StreamAbstractByFIPS_WithECAM(FIPSCODE string, hp hazardproviders.HazardPro
 				totalCounty[cbfips] = newc
 			}
 		}
-		d, err := hp.Hazard(geography.Location{X: f.Location().X, Y: f.Location().Y})
+		d, err := hp.Hazard(f.Location())
 		//compute damages based on hazard being able to provide depth
 		if err == nil {
 			r, err3 := f.Compute(d)
@@ -0,0 +1,57 @@
+package compute
+
+import (
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/projection"
+)
+
+// hazardStream streams the receptors in the hazard boundary of hp. A TiledHazardProvider is streamed one tile at a time, and a receptor in overlapping tiles is only streamed by the first tile containing it.
+func hazardStream(hp hazardproviders.HazardProvider, sp consequences.StreamProvider) (func(consequences.StreamProcessor), error) {
+	tiled, ok := hp.(hazardproviders.TiledHazardProvider)
+	if !ok {
+		bbox, err := hp.HazardBoundary()
+		if err != nil {
+			return nil, err
+		}
+		return func(p consequences.StreamProcessor) {
+			sp.ByBbox(bbox, p)
+		}, nil
+	}
+	tiles, err := tiled.Tiles()
+	if err != nil {
+		return nil, err
+	}
+	return func(p consequences.StreamProcessor) {
+		transforms := make(map[string]*projection.Cache)
+		defer func() {
+			for _, c := range transforms {
+				c.Close()
+			}
+		}()
+		for i, tile := range tiles {
+			sp.ByBbox(tile, func(f consequences.Receptor) {
+				if firstTile(tiles, f.Location(), transforms) == i {
+					p(f)
+				}
+			})
+		}
+	}, nil
+}
+
+// firstTile is the index of the first tile containing l, or -1 if none do.
+func firstTile(tiles []geography.BBox, l geography.Location, transforms map[string]*projection.Cache) int {
+	for i, t := range tiles {
+		c, ok := transforms[t.SRID]
+		if !ok {
+			c = projection.NewCache(t.SRID)
+			transforms[t.SRID] = c
+		}
+		tl, err := c.Location(l)
+		if err == nil && t.Contains(tl) {
+			return i
+		}
+	}
+	return -1
+}
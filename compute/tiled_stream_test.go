@@ -0,0 +1,61 @@
+package compute
+
+import (
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+)
+
+// tiledDepthHazardProvider is a constant depth over overlapping tiles.
+type tiledDepthHazardProvider struct {
+	constantDepthHazardProvider
+	tiles []geography.BBox
+}
+
+func (c tiledDepthHazardProvider) Tiles() ([]geography.BBox, error) {
+	return c.tiles, nil
+}
+
+// bboxStructureStream only streams the test structures inside the box, counting how often it is queried.
+type bboxStructureStream struct {
+	testStructureStream
+	queries *int
+}
+
+func (bs bboxStructureStream) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	*bs.queries++
+	bs.testStructureStream.ByBbox(bbox, func(f consequences.Receptor) {
+		if bbox.Contains(f.Location()) {
+			sp(f)
+		}
+	})
+}
+func TestStreamAbstractConcurrent_Tiled(t *testing.T) {
+	hp := tiledDepthHazardProvider{
+		constantDepthHazardProvider: constantDepthHazardProvider{depth: 4},
+		tiles:                       []geography.BBox{{Bbox: []float64{0, 10, 10, 0}}, {Bbox: []float64{5, 20, 20, 5}}},
+	}
+	queries := 0
+	sp := bboxStructureStream{testStructureStream: testStructureStream{count: 30}, queries: &queries}
+	w := &collectingResultsWriter{}
+	err := StreamAbstractConcurrent(hp, sp, w, 2, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != 2 {
+		t.Errorf("expected a query for each tile, got %v", queries)
+	}
+	//structures 5 through 10 are in both tiles but are only computed once, structures past 20 are in neither.
+	if len(w.results) != 21 {
+		t.Fatalf("expected 21 results, got %v", len(w.results))
+	}
+	seen := make(map[interface{}]bool)
+	for _, r := range w.results {
+		name, _ := r.Fetch("fd_id")
+		if seen[name] {
+			t.Errorf("expected structure %v to be computed once", name)
+		}
+		seen[name] = true
+	}
+}
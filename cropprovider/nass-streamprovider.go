@@ -7,9 +7,13 @@ import (
 	"github.com/USACE/go-consequences/consequences"
 	"github.com/USACE/go-consequences/crops"
 	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/projection"
 	"github.com/dewberry/gdal"
 )
 
+// cdlSpatialReference is the reference system of the cropland data layer api, USA Contiguous Albers Equal Area Conic (USGS version).
+const cdlSpatialReference = "EPSG:5070"
+
 type NassCropProvider struct {
 	Year       string
 	CropFilter map[string]crops.Crop
@@ -34,7 +38,13 @@ func (n NassCropProvider) ByFips(fipscode string, sp consequences.StreamProcesso
 	}
 	result.iterate(sp, n.CropFilter)
 }
+
+// ByBbox streams the crops within bbox, which is transformed into the cropland data layer's reference system if it is in another.
 func (n NassCropProvider) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	bbox, err := projection.TransformBBox(bbox, cdlSpatialReference)
+	if err != nil {
+		panic(err)
+	}
 	result, err := GetCDLFileByBbox(n.Year, fmt.Sprintf("%v", bbox.Bbox[0]), fmt.Sprintf("%v", bbox.Bbox[3]), fmt.Sprintf("%v", bbox.Bbox[2]), fmt.Sprintf("%v", bbox.Bbox[1]))
 	if err != nil {
 		panic(err)
@@ -54,6 +64,7 @@ func (n nassTiffReader) iterate(sp consequences.StreamProcessor, cfilter map[str
 	gt := n.ds.GeoTransform()
 	xval := gt[0] + (gt[1] / 2)
 	yval := gt[3] + (gt[5] / 2)
+	srs := n.ds.Projection()
 	for i, b := range arr {
 		if i%nXs == 0 {
 			xval = gt[0] + (gt[1] / 2)
@@ -65,6 +76,7 @@ func (n nassTiffReader) iterate(sp consequences.StreamProcessor, cfilter map[str
 		c, ok := cfilter[s]
 		//need to add location
 		c.WithLocation(xval, yval)
+		c.WithSpatialReference(srs)
 		if ok {
 			sp(c)
 		}
@@ -16,6 +16,7 @@ type Crop struct {
 	SubstituteCrop     Substitute
 	x                  float64
 	y                  float64
+	srid               string  //spatial reference system of x and y
 	totalMarketValue   float64 //Marketable value, yeild *pricePerUnit
 	productionFunction productionFunction
 	lossFunction       DamageFunction
@@ -55,6 +56,12 @@ func (c *Crop) WithLocation(xloc float64, yloc float64) Crop {
 	return *c
 }
 
+//WithSpatialReference sets the spatial reference system of the crop's location
+func (c *Crop) WithSpatialReference(srid string) Crop {
+	c.srid = srid
+	return *c
+}
+
 //WithOutput allows the setting of the yeild per acre and price per unit of output and resulting value per output
 func (c *Crop) WithOutput(cropYeild float64, price float64) Crop {
 	c.totalMarketValue = cropYeild * price
@@ -99,7 +106,7 @@ func (c Crop) GetY() float64 {
 	return c.y
 }
 func (c Crop) Location() geography.Location {
-	return geography.Location{X: c.x, Y: c.y}
+	return geography.Location{X: c.x, Y: c.y, SRID: c.srid}
 }
 
 //GetTotalMarketValue returns crop.totalMarketValue
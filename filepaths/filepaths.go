@@ -0,0 +1,23 @@
+// Package filepaths checks the input files named in a configuration before the providers open them.
+package filepaths
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Check is an error if path is not set or names a local file that does not exist. gdal virtual file systems and urls are not checked.
+func Check(field string, path string) error {
+	if path == "" {
+		return fmt.Errorf("filepaths: %v is required", field)
+	}
+	if strings.HasPrefix(path, "/vsi") || strings.Contains(path, "://") {
+		return nil
+	}
+	_, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("filepaths: %v %v does not exist", field, path)
+	}
+	return nil
+}
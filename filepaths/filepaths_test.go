@@ -0,0 +1,29 @@
+package filepaths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "depth.tif")
+	err := os.WriteFile(existing, []byte{}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path  string
+		valid bool
+	}{
+		{existing, true},
+		{"", false},
+		{filepath.Join(filepath.Dir(existing), "missing.tif"), false},
+		{"/vsis3/bucket/depth.tif", true},
+		{"https://example.com/depth.tif", true},
+	} {
+		if err := Check("file_path", c.path); (err == nil) != c.valid {
+			t.Errorf("checking %q: %v", c.path, err)
+		}
+	}
+}
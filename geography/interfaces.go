@@ -2,16 +2,21 @@ package geography
 
 import (
 	"fmt"
+	"math"
 )
 
 type Location struct {
-	X    float64
-	Y    float64
-	SRID string
+	X         float64
+	Y         float64
+	SRID      string     //spatial reference system of X and Y as an epsg code, wkt or proj string, unset if unknown
+	Footprint []Location //optional polygon ring around the location, such as a building footprint, in the same reference system
+	Z         float64    //ground elevation at the location, only meaningful if HasZ
+	HasZ      bool
 }
 
 type BBox struct {
 	Bbox []float64
+	SRID string //spatial reference system of the box, see Location
 }
 
 type GeoJsonGeometry struct {
@@ -31,8 +36,83 @@ func (bb BBox) ToString() string {
 		bb.Bbox[0], bb.Bbox[3],
 		bb.Bbox[0], bb.Bbox[1])
 }
+
+// Contains is true if p is on or inside the box, in either y ordering.
 func (bb BBox) Contains(p Location) bool {
-	return bb.Bbox[0] <= p.X && p.X <= bb.Bbox[2] && bb.Bbox[1] <= p.Y && p.Y <= bb.Bbox[3]
+	ymin, ymax := math.Min(bb.Bbox[1], bb.Bbox[3]), math.Max(bb.Bbox[1], bb.Bbox[3])
+	return bb.Bbox[0] <= p.X && p.X <= bb.Bbox[2] && ymin <= p.Y && p.Y <= ymax
+}
+
+// Union returns the smallest bbox covering bb and other. the y ordering of bb is preserved, so upper left lower right boxes stay that way.
+func (bb BBox) Union(other BBox) BBox {
+	u := []float64{math.Min(bb.Bbox[0], other.Bbox[0]), 0, math.Max(bb.Bbox[2], other.Bbox[2]), 0}
+	ymin := math.Min(math.Min(bb.Bbox[1], bb.Bbox[3]), math.Min(other.Bbox[1], other.Bbox[3]))
+	ymax := math.Max(math.Max(bb.Bbox[1], bb.Bbox[3]), math.Max(other.Bbox[1], other.Bbox[3]))
+	if bb.Bbox[1] > bb.Bbox[3] {
+		u[1], u[3] = ymax, ymin
+	} else {
+		u[1], u[3] = ymin, ymax
+	}
+	return BBox{Bbox: u, SRID: bb.SRID}
+}
+
+// EdgePoints lists n points along each edge of the box, so the box can be transformed into a reference system where its edges curve.
+func (bb BBox) EdgePoints(n int) []Location {
+	points := make([]Location, 0, 4*n)
+	x0, y0, x1, y1 := bb.Bbox[0], bb.Bbox[1], bb.Bbox[2], bb.Bbox[3]
+	for i := 0; i < n; i++ {
+		f := float64(i) / float64(n)
+		points = append(points,
+			Location{X: x0 + f*(x1-x0), Y: y0},
+			Location{X: x1, Y: y0 + f*(y1-y0)},
+			Location{X: x1 - f*(x1-x0), Y: y1},
+			Location{X: x0, Y: y1 - f*(y1-y0)})
+	}
+	return points
+}
+
+// Envelope is the smallest box covering points, upper left lower right if yDown is true and lower left upper right otherwise.
+func Envelope(points []Location, yDown bool) BBox {
+	u := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points {
+		u[0], u[1] = math.Min(u[0], p.X), math.Min(u[1], p.Y)
+		u[2], u[3] = math.Max(u[2], p.X), math.Max(u[3], p.Y)
+	}
+	if yDown {
+		u[1], u[3] = u[3], u[1]
+	}
+	return BBox{Bbox: u}
+}
+
+// RingContains is true if x, y is inside a closed polygon ring by the even odd rule.
+func RingContains(x float64, y float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi, xj, yj := ring[i][0], ring[i][1], ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// RingCentroid is the centroid of the area of a closed polygon ring, or the mean of its points if it has no area.
+func RingCentroid(ring [][2]float64) (float64, float64) {
+	area, cx, cy := 0.0, 0.0, 0.0
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		cross := ring[j][0]*ring[i][1] - ring[i][0]*ring[j][1]
+		area += cross
+		cx += (ring[j][0] + ring[i][0]) * cross
+		cy += (ring[j][1] + ring[i][1]) * cross
+	}
+	if area == 0 {
+		for _, p := range ring {
+			cx += p[0] / float64(len(ring))
+			cy += p[1] / float64(len(ring))
+		}
+		return cx, cy
+	}
+	return cx / (3 * area), cy / (3 * area)
 }
 func (gjg GeoJsonGeometry) ToLocation() Location {
 	return Location{
@@ -0,0 +1,65 @@
+package geography
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	ul := BBox{Bbox: []float64{0, 10, 4, 2}}
+	points := ul.EdgePoints(4)
+	if len(points) != 16 {
+		t.Fatalf("expected 16 points, got %v", len(points))
+	}
+	for _, p := range points {
+		if !Envelope([]Location{{X: 0, Y: 2}, {X: 4, Y: 10}}, false).Contains(p) {
+			t.Errorf("expected %v to be on the edge of the box", p)
+		}
+	}
+	if e := Envelope(points, true); !reflect.DeepEqual(e.Bbox, ul.Bbox) {
+		t.Errorf("expected the envelope of the edges to be %v, got %v", ul.Bbox, e.Bbox)
+	}
+	if e := Envelope(points, false); !reflect.DeepEqual(e.Bbox, []float64{0, 2, 4, 10}) {
+		t.Errorf("expected a lower left upper right envelope, got %v", e.Bbox)
+	}
+}
+func TestUnion_KeepsSpatialReference(t *testing.T) {
+	a := BBox{Bbox: []float64{0, 10, 4, 2}, SRID: "EPSG:26915"}
+	u := a.Union(BBox{Bbox: []float64{2, 12, 6, 4}})
+	if !reflect.DeepEqual(u.Bbox, []float64{0, 12, 6, 2}) || u.SRID != a.SRID {
+		t.Errorf("expected {[0 12 6 2] EPSG:26915}, got %v", u)
+	}
+}
+func TestContains_EitherOrdering(t *testing.T) {
+	p := Location{X: 2, Y: 5}
+	for _, bb := range []BBox{{Bbox: []float64{0, 10, 4, 2}}, {Bbox: []float64{0, 2, 4, 10}}} {
+		if !bb.Contains(p) {
+			t.Errorf("expected %v to contain %v", bb.Bbox, p)
+		}
+	}
+	if (BBox{Bbox: []float64{0, 10, 4, 6}}).Contains(p) {
+		t.Error("expected a box above the location not to contain it")
+	}
+}
+func TestRingContains(t *testing.T) {
+	triangle := [][2]float64{{0, 0}, {10, 0}, {0, 10}, {0, 0}}
+	for _, c := range []struct {
+		x, y   float64
+		inside bool
+	}{{1, 1, true}, {4, 4, true}, {6, 6, false}, {-1, 1, false}, {5, -1, false}} {
+		if RingContains(c.x, c.y, triangle) != c.inside {
+			t.Errorf("expected %v, %v inside the triangle to be %v", c.x, c.y, c.inside)
+		}
+	}
+}
+func TestRingCentroid(t *testing.T) {
+	//a closed ring and the same ring without the closing point have the same centroid.
+	for _, square := range [][][2]float64{{{0, 0}, {4, 0}, {4, 2}, {0, 2}, {0, 0}}, {{0, 0}, {4, 0}, {4, 2}, {0, 2}}} {
+		if x, y := RingCentroid(square); x != 2 || y != 1 {
+			t.Errorf("expected the centroid of the rectangle at 2, 1, got %v, %v", x, y)
+		}
+	}
+	if x, y := RingCentroid([][2]float64{{0, 0}, {2, 2}}); x != 1 || y != 1 {
+		t.Errorf("expected a ring without area at the mean of its points, got %v, %v", x, y)
+	}
+}
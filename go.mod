@@ -6,6 +6,7 @@ require (
 	github.com/HydrologicEngineeringCenter/go-statistics v0.0.0-20221221211532-e53b36ba1a67
 	github.com/dewberry/gdal v0.3.4
 	github.com/leekchan/accounting v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
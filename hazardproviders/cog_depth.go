@@ -32,6 +32,12 @@ func Init_Meters_CustomFunction(fp string, function HazardFunction) (cogHazardPr
 func (chp cogHazardProvider) Close() {
 	chp.depthcr.Close()
 }
+
+// Clone implements CloneableHazardProvider
+func (chp cogHazardProvider) Clone() (HazardProvider, error) {
+	d, err := chp.depthcr.clone()
+	return cogHazardProvider{depthcr: d, process: chp.process}, err
+}
 func (chp cogHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
 	var h hazards.HazardEvent
 	d, err := chp.depthcr.ProvideValue(l)
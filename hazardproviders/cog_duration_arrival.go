@@ -37,6 +37,20 @@ func (chp cogDurationAndArrivalHazardProvider) Close() {
 	chp.durationCR.Close()
 	chp.arrivalCR.Close()
 }
+
+// Clone implements CloneableHazardProvider
+func (chp cogDurationAndArrivalHazardProvider) Clone() (HazardProvider, error) {
+	d, err := chp.durationCR.clone()
+	if err != nil {
+		return cogDurationAndArrivalHazardProvider{}, err
+	}
+	a, err := chp.arrivalCR.clone()
+	if err != nil {
+		d.Close()
+		return cogDurationAndArrivalHazardProvider{}, err
+	}
+	return cogDurationAndArrivalHazardProvider{durationCR: d, arrivalCR: a, startTime: chp.startTime, process: chp.process}, nil
+}
 func (chp cogDurationAndArrivalHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
 	var h hazards.HazardEvent
 	d, err := chp.durationCR.ProvideValue(l)
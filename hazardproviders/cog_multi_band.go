@@ -0,0 +1,328 @@
+package hazardproviders
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/dewberry/gdal"
+)
+
+// ParameterBand maps a band of a multi band raster to a hazard parameter.
+type ParameterBand struct {
+	Band   int               `json:"band"` //1 based band number
+	Hazard hazards.Parameter `json:"hazard_parameter_type"`
+	name   parameterName
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see parameterName.
+func (b *ParameterBand) UnmarshalJSON(data []byte) error {
+	type plain ParameterBand
+	return unmarshalNamed(data, (*plain)(b), &b.name)
+}
+
+// TimeSeriesBands maps the bands of a multi band raster to the depth, and optionally the velocity, at a sequence of times. A location is wet while its depth is above the threshold, arrival and departure are interpolated between time steps.
+type TimeSeriesBands struct {
+	DepthBands    []int       `json:"depth_bands"`               //band of the depth at each time step, in time order
+	VelocityBands []int       `json:"velocity_bands,omitempty"`  //band of the velocity at each time step, optional
+	Times         []time.Time `json:"times,omitempty"`           //time of each step, if not listed the steps start at the hazard's start_time
+	TimeStep      float64     `json:"time_step_hours,omitempty"` //hours between steps when times are not listed
+	Threshold     float64     `json:"threshold,omitempty"`       //depth a location must exceed to be wet
+	Episodes      bool        `json:"episodes,omitempty"`        //each wet period is a separate event of an ArrivalDepthandDurationEventMulti rather than one summary event
+}
+
+// MultiBandInfo describes a multi band raster hazard, its bands either hold hazard parameters or a time series.
+type MultiBandInfo struct {
+	FilePath       string           `json:"file_path"`
+	ParameterBands []ParameterBand  `json:"parameter_bands,omitempty"`
+	TimeSeries     *TimeSeriesBands `json:"time_series,omitempty"`
+	Sampling       Sampling         `json:"sampling,omitempty"` //applied to each band separately, nearest if not set
+}
+
+// Validate reports every problem with the band mapping it can find without opening the raster.
+func (info MultiBandInfo) Validate() error {
+	errs := []error{filepaths.Check("file_path", info.FilePath), info.Sampling.Validate()}
+	if (len(info.ParameterBands) == 0) == (info.TimeSeries == nil) {
+		errs = append(errs, errors.New("hazardproviders: a multi band hazard requires either parameter_bands or time_series"))
+	}
+	bands := make(map[int]bool)
+	checkBand := func(band int) {
+		if band < 1 {
+			errs = append(errs, fmt.Errorf("hazardproviders: band %v is not a band number, bands start at 1", band))
+		} else if bands[band] {
+			errs = append(errs, fmt.Errorf("hazardproviders: band %v is mapped more than once", band))
+		}
+		bands[band] = true
+	}
+	parameters := make(map[hazards.Parameter]bool)
+	for _, b := range info.ParameterBands {
+		checkBand(b.Band)
+		errs = append(errs, b.name.check())
+		if parameters[b.Hazard] {
+			errs = append(errs, fmt.Errorf("hazardproviders: %v is provided more than once", b.Hazard))
+		}
+		parameters[b.Hazard] = true
+	}
+	if ts := info.TimeSeries; ts != nil {
+		if len(ts.DepthBands) == 0 {
+			errs = append(errs, errors.New("hazardproviders: a time series requires at least one depth band"))
+		}
+		for _, b := range ts.DepthBands {
+			checkBand(b)
+		}
+		for _, b := range ts.VelocityBands {
+			checkBand(b)
+		}
+		if len(ts.VelocityBands) > 0 && len(ts.VelocityBands) != len(ts.DepthBands) {
+			errs = append(errs, errors.New("hazardproviders: a time series requires one velocity band for each depth band"))
+		}
+		if len(ts.Times) > 0 {
+			if len(ts.Times) != len(ts.DepthBands) {
+				errs = append(errs, errors.New("hazardproviders: a time series requires one time for each depth band"))
+			}
+			for i := 1; i < len(ts.Times); i++ {
+				if !ts.Times[i].After(ts.Times[i-1]) {
+					errs = append(errs, errors.New("hazardproviders: time series times must be increasing"))
+					break
+				}
+			}
+		} else if ts.TimeStep <= 0 {
+			errs = append(errs, errors.New("hazardproviders: a time series requires times or a positive time_step_hours"))
+		}
+		if ts.Threshold < 0 {
+			errs = append(errs, errors.New("hazardproviders: the time series threshold must not be negative"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// times are the time of each step of the series, starting at startTime if they are not listed.
+func (ts TimeSeriesBands) times(startTime time.Time) []time.Time {
+	if len(ts.Times) > 0 {
+		return ts.Times
+	}
+	times := make([]time.Time, len(ts.DepthBands))
+	for i := range times {
+		times[i] = startTime.Add(time.Duration(float64(i) * ts.TimeStep * float64(time.Hour)))
+	}
+	return times
+}
+
+type multiBandHazardProvider struct {
+	info      MultiBandInfo
+	startTime time.Time
+	ds        *gdal.Dataset
+	igt       [6]float64
+	bands     []int //bands read at each location, parameter bands or depth bands followed by velocity bands
+	nodata    []float64
+	transform *projection.Cache //transforms locations into the raster's reference system
+}
+
+// InitMultiBand opens a multi band raster hazard, startTime anchors arrival time bands in hours and time series without listed times.
+func InitMultiBand(info MultiBandInfo, startTime time.Time) (multiBandHazardProvider, error) {
+	err := info.Validate()
+	if err != nil {
+		return multiBandHazardProvider{}, err
+	}
+	fmt.Println("Connecting to: " + info.FilePath)
+	ds, err := gdal.Open(info.FilePath, gdal.Access(gdal.ReadOnly))
+	if err != nil {
+		return multiBandHazardProvider{}, errors.New("Cannot connect to raster at path " + info.FilePath + err.Error())
+	}
+	bands := make([]int, 0)
+	for _, b := range info.ParameterBands {
+		bands = append(bands, b.Band)
+	}
+	if info.TimeSeries != nil {
+		bands = append(append(bands, info.TimeSeries.DepthBands...), info.TimeSeries.VelocityBands...)
+	}
+	nodata := make([]float64, len(bands))
+	for i, b := range bands {
+		if b > ds.RasterCount() {
+			ds.Close()
+			return multiBandHazardProvider{}, fmt.Errorf("hazardproviders: band %v is not in %v, it has %v bands", b, info.FilePath, ds.RasterCount())
+		}
+		nodata[i] = -9999
+		if v, valid := ds.RasterBand(b).NoDataValue(); valid {
+			nodata[i] = v
+		}
+	}
+	return multiBandHazardProvider{info: info, startTime: startTime, ds: &ds, igt: ds.InvGeoTransform(), bands: bands, nodata: nodata, transform: projection.NewCache(ds.Projection())}, nil
+}
+func (mbp multiBandHazardProvider) Close() {
+	mbp.transform.Close()
+	mbp.ds.Close()
+}
+
+// Clone implements CloneableHazardProvider
+func (mbp multiBandHazardProvider) Clone() (HazardProvider, error) {
+	return InitMultiBand(mbp.info, mbp.startTime)
+}
+func (mbp multiBandHazardProvider) HazardBoundary() (geography.BBox, error) {
+	gt := mbp.ds.GeoTransform()
+	bbox := []float64{gt[0], gt[3], gt[0] + gt[1]*float64(mbp.ds.RasterXSize()), gt[3] + gt[5]*float64(mbp.ds.RasterYSize())}
+	return geography.BBox{Bbox: bbox, SRID: mbp.ds.Projection()}, nil
+}
+
+// values samples every mapped band at l, valid is false where a band has no data.
+func (mbp multiBandHazardProvider) values(l geography.Location) ([]float64, []bool, error) {
+	l, err := mbp.transform.Location(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := mbp.info.Sampling.window(l, mbp.igt, mbp.ds.RasterXSize(), mbp.ds.RasterYSize())
+	if err != nil {
+		return nil, nil, err
+	}
+	n := w.width * w.height
+	buffer := make([]float32, n*len(mbp.bands))
+	err = mbp.ds.IO(gdal.RWFlag(gdal.Read), w.x0, w.y0, w.width, w.height, buffer, w.width, w.height, len(mbp.bands), mbp.bands, 0, 0, 0)
+	if err != nil {
+		return nil, nil, NoDataHazardError{Input: err.Error()}
+	}
+	values := make([]float64, len(mbp.bands))
+	valid := make([]bool, len(mbp.bands))
+	for i := range mbp.bands {
+		cells, cellsValid := readCells(buffer[i*n:(i+1)*n], mbp.nodata[i])
+		values[i], valid[i] = mbp.info.Sampling.sample(w, cells, cellsValid)
+	}
+	return values, valid, nil
+}
+func (mbp multiBandHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	values, valid, err := mbp.values(l)
+	if err != nil {
+		return nil, err
+	}
+	if ts := mbp.info.TimeSeries; ts != nil {
+		n := len(ts.DepthBands)
+		var velocities []float64
+		if len(ts.VelocityBands) > 0 {
+			velocities = values[n:]
+		}
+		//models commonly write no data for cells that are dry at a time step.
+		depths := values[:n]
+		for i := range depths {
+			if !valid[i] {
+				depths[i] = 0
+			}
+		}
+		return timeSeriesEvent(ts.times(mbp.startTime), depths, velocities, ts.Threshold, ts.Episodes)
+	}
+	hd := emptyHazardData()
+	for i, b := range mbp.info.ParameterBands {
+		if !valid[i] {
+			return nil, NoDataHazardError{Input: fmt.Sprintf("band %v had the no data value observed", b.Band)}
+		}
+		if b.Hazard == hazards.ArrivalTime {
+			hd.SetParameter(b.Hazard, mbp.startTime.Add(time.Duration(values[i]*float64(time.Hour))))
+		} else {
+			hd.SetParameter(b.Hazard, values[i])
+		}
+	}
+	return hazards.HazardDataToMultiParameter(hd), nil
+}
+
+// emptyHazardData has every parameter set to the value HazardDataToMultiParameter treats as missing.
+func emptyHazardData() hazards.HazardData {
+	return hazards.HazardData{
+		Depth:       -901,
+		Velocity:    -901,
+		ArrivalTime: time.Time{},
+		Erosion:     -901,
+		Duration:    -901,
+		WaveHeight:  -901,
+		Salinity:    false,
+		Qualitative: "",
+		DV:          -901,
+	}
+}
+
+// wetPeriod is a period a location's depth is above the threshold, with the largest values observed during it.
+type wetPeriod struct {
+	arrival, departure time.Time
+	maxDepth           float64
+	maxVelocity        float64
+	maxDV              float64
+}
+
+// wetPeriods finds the periods depths exceed threshold, velocities may be nil. Crossings of the threshold are interpolated linearly between time steps, a period that has not ended by the last step departs at the last step.
+func wetPeriods(times []time.Time, depths []float64, velocities []float64, threshold float64) []wetPeriod {
+	crossing := func(i int) time.Time {
+		frac := (threshold - depths[i-1]) / (depths[i] - depths[i-1])
+		return times[i-1].Add(time.Duration(frac * float64(times[i].Sub(times[i-1]))))
+	}
+	periods := make([]wetPeriod, 0)
+	var current *wetPeriod
+	for i, d := range depths {
+		if d <= threshold {
+			if current != nil {
+				current.departure = crossing(i)
+				periods = append(periods, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = &wetPeriod{arrival: times[i]}
+			if i > 0 {
+				current.arrival = crossing(i)
+			}
+		}
+		if d > current.maxDepth {
+			current.maxDepth = d
+		}
+		if velocities != nil {
+			if velocities[i] > current.maxVelocity {
+				current.maxVelocity = velocities[i]
+			}
+			if d*velocities[i] > current.maxDV {
+				current.maxDV = d * velocities[i]
+			}
+		}
+	}
+	if current != nil {
+		current.departure = times[len(times)-1]
+		periods = append(periods, *current)
+	}
+	return periods
+}
+
+// timeSeriesEvent summarizes a location's series as a MultiParameterEvent with the maximum depth, the arrival of the first wet period and the days wet, along with the maximum velocity and depth times velocity if velocities are provided. With episodes, each wet period is an event of an ArrivalDepthandDurationEventMulti with its own maximum depth, arrival and days wet.
+func timeSeriesEvent(times []time.Time, depths []float64, velocities []float64, threshold float64, episodes bool) (hazards.HazardEvent, error) {
+	periods := wetPeriods(times, depths, velocities, threshold)
+	if len(periods) == 0 {
+		return nil, NoHazardFoundError{Input: fmt.Sprintf("the depth never exceeded %v", threshold)}
+	}
+	if episodes {
+		events := make([]hazards.ArrivalDepthandDurationEvent, len(periods))
+		for i, p := range periods {
+			events[i].SetArrivalTime(p.arrival)
+			events[i].SetDepth(p.maxDepth)
+			events[i].SetDuration(p.departure.Sub(p.arrival).Hours() / 24)
+		}
+		return &hazards.ArrivalDepthandDurationEventMulti{Events: events}, nil
+	}
+	hd := emptyHazardData()
+	hd.ArrivalTime = periods[0].arrival
+	hd.Duration = 0
+	hd.Depth = 0
+	for _, p := range periods {
+		hd.Duration += p.departure.Sub(p.arrival).Hours() / 24
+		if p.maxDepth > hd.Depth {
+			hd.Depth = p.maxDepth
+		}
+		if velocities != nil {
+			if p.maxVelocity > hd.Velocity {
+				hd.Velocity = p.maxVelocity
+			}
+			if p.maxDV > hd.DV {
+				hd.DV = p.maxDV
+			}
+		}
+	}
+	return hazards.HazardDataToMultiParameter(hd), nil
+}
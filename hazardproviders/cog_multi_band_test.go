@@ -0,0 +1,105 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func hourly(start time.Time, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+	return times
+}
+func TestTimeSeriesEvent_Summary(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	times := hourly(start, 6)
+	depths := []float64{0, 2, 4, 0, 2, 0}
+	velocities := []float64{0, 3, 1, 0, 1, 0}
+	e, err := timeSeriesEvent(times, depths, velocities, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Depth() != 4 {
+		t.Errorf("expected a max depth of 4, got %v", e.Depth())
+	}
+	if e.DV() != 6 {
+		t.Errorf("expected a max depth times velocity of 6, got %v", e.DV())
+	}
+	if e.Velocity() != 3 {
+		t.Errorf("expected a max velocity of 3, got %v", e.Velocity())
+	}
+	//the depth crosses 1 half way between steps 0 and 1, so the location is wet from 0.5h.
+	if !e.ArrivalTime().Equal(start.Add(30 * time.Minute)) {
+		t.Errorf("expected an arrival at 00:30, got %v", e.ArrivalTime())
+	}
+	//wet from 0.5h to 2.75h and from 3.5h to 4.5h.
+	if math.Abs(e.Duration()-3.25/24) > 1e-9 {
+		t.Errorf("expected 3.25 hours wet, got %v days", e.Duration())
+	}
+	if !e.Has(hazards.Depth) || !e.Has(hazards.ArrivalTime) || !e.Has(hazards.Duration) || !e.Has(hazards.DV) {
+		t.Errorf("expected depth, arrival time, duration and depth times velocity, got %v", e.Parameters())
+	}
+}
+func TestTimeSeriesEvent_Episodes(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	times := hourly(start, 6)
+	depths := []float64{0, 2, 4, 0, 2, 3}
+	e, err := timeSeriesEvent(times, depths, nil, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, ok := e.(*hazards.ArrivalDepthandDurationEventMulti)
+	if !ok {
+		t.Fatalf("expected an ArrivalDepthandDurationEventMulti, got %T", e)
+	}
+	if len(multi.Events) != 2 {
+		t.Fatalf("expected two wet periods, got %v", len(multi.Events))
+	}
+	second := multi.Events[1]
+	if second.Depth() != 3 || !second.ArrivalTime().Equal(start.Add(210*time.Minute)) {
+		t.Errorf("expected the second period to arrive at 03:30 with a depth of 3, got %v and %v", second.ArrivalTime(), second.Depth())
+	}
+	//the second period is still wet at the last step.
+	if math.Abs(second.Duration()-1.5/24) > 1e-9 {
+		t.Errorf("expected the second period to last 1.5 hours, got %v days", second.Duration())
+	}
+	_, err = timeSeriesEvent(times, []float64{0, .5, 0, 0, 0, 0}, nil, 1, true)
+	if err == nil {
+		t.Error("expected an error for a location that is never wet")
+	}
+}
+func TestMultiBandInfo_Validate(t *testing.T) {
+	var info MultiBandInfo
+	err := json.Unmarshal([]byte(`{"file_path": "/vsis3/bucket/model.tif", "parameter_bands": [{"band": 1, "hazard_parameter_type": "depth"}, {"band": 2, "hazard_parameter_type": "velocity"}]}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Validate(); err != nil {
+		t.Errorf("expected valid parameter bands, got %v", err)
+	}
+	if info.ParameterBands[1].Hazard != hazards.Velocity {
+		t.Errorf("expected band 2 to be velocity, got %v", info.ParameterBands[1].Hazard)
+	}
+	err = json.Unmarshal([]byte(`{"file_path": "/vsis3/bucket/model.tif", "parameter_bands": [{"band": 1, "hazard_parameter_type": "dpeth"}, {"band": 1, "hazard_parameter_type": "velocity"}]}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Validate() == nil {
+		t.Error("expected an unknown parameter and a repeated band to be invalid")
+	}
+	series := MultiBandInfo{FilePath: "/vsis3/bucket/model.tif", TimeSeries: &TimeSeriesBands{DepthBands: []int{1, 2, 3}, VelocityBands: []int{4, 5}}}
+	if series.Validate() == nil {
+		t.Error("expected a time series without a time step and with too few velocity bands to be invalid")
+	}
+	series.TimeSeries.VelocityBands = []int{4, 5, 6}
+	series.TimeSeries.TimeStep = 1
+	if err = series.Validate(); err != nil {
+		t.Errorf("expected a valid time series, got %v", err)
+	}
+}
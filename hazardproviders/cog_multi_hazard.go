@@ -3,72 +3,155 @@ package hazardproviders
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/USACE/go-consequences/geography"
 	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
 )
 
 type cogMultiHazardProvider struct {
-	paramCogMap map[hazards.Parameter]cogReader
-	startTime   time.Time
+	paramCogMap  map[hazards.Parameter]cogReader
+	startTime    time.Time
+	waterSurface *WaterSurfaceElevation //set if the depth raster holds water surface elevations
+	terrain      *cogReader             //ground elevation for locations without one, only with a water surface
 	//process     HazardFunction build this out later, it should be customizable, but not sure how to deal with the variety of info at the moment.
 }
 
 // InitMulti creates and produces an unexported cogMultiHazardProvider
 func InitMulti(hpinfo HazardProviderInfo) (cogMultiHazardProvider, error) {
-	tmpmap := make(map[hazards.Parameter]cogReader)
+	chp := cogMultiHazardProvider{paramCogMap: make(map[hazards.Parameter]cogReader), startTime: hpinfo.StartTime}
 	for _, hp_param_and_path := range hpinfo.Hazards {
 		cr, err := initCR(hp_param_and_path.FilePath)
 		if err != nil {
+			chp.Close()
 			return cogMultiHazardProvider{}, err
 		}
-		tmpmap[hp_param_and_path.Hazard] = cr
+		cr.sampling = hp_param_and_path.Sampling
+		chp.paramCogMap[hp_param_and_path.Hazard] = cr
+		if ws := hp_param_and_path.WaterSurface; ws != nil && hp_param_and_path.Hazard == hazards.Depth {
+			chp.waterSurface = ws
+			if ws.TerrainFilePath != "" {
+				terrain, err := initCR(ws.TerrainFilePath)
+				if err != nil {
+					chp.Close()
+					return cogMultiHazardProvider{}, err
+				}
+				chp.terrain = &terrain
+			}
+		}
 	}
-
-	return cogMultiHazardProvider{paramCogMap: tmpmap, startTime: hpinfo.StartTime}, nil
+	return chp, nil
 }
 func (chp cogMultiHazardProvider) Close() {
 	for _, v := range chp.paramCogMap {
 		v.Close()
 	}
+	if chp.terrain != nil {
+		chp.terrain.Close()
+	}
+}
+
+// Clone implements CloneableHazardProvider
+func (chp cogMultiHazardProvider) Clone() (HazardProvider, error) {
+	tmpmap := make(map[hazards.Parameter]cogReader)
+	for k, v := range chp.paramCogMap {
+		cr, err := v.clone()
+		if err != nil {
+			for _, c := range tmpmap {
+				c.Close()
+			}
+			return cogMultiHazardProvider{}, err
+		}
+		tmpmap[k] = cr
+	}
+	clone := cogMultiHazardProvider{paramCogMap: tmpmap, startTime: chp.startTime, waterSurface: chp.waterSurface}
+	if chp.terrain != nil {
+		terrain, err := chp.terrain.clone()
+		if err != nil {
+			clone.Close()
+			return cogMultiHazardProvider{}, err
+		}
+		clone.terrain = &terrain
+	}
+	return clone, nil
 }
 func (chp cogMultiHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
 	var h hazards.HazardEvent
-	hd := hazards.HazardData{
-		Depth:       -901,
-		Velocity:    -901,
-		ArrivalTime: time.Time{},
-		Erosion:     -901,
-		Duration:    -901,
-		WaveHeight:  -901,
-		Salinity:    false,
-		Qualitative: "",
-		DV:          -901,
-	}
+	hd := emptyHazardData()
 	for k, v := range chp.paramCogMap {
 		hval, err := v.ProvideValue(l)
 		if err != nil {
+			if optionalWithoutData(k, err) {
+				continue
+			}
 			return h, err
 		}
-		if k == hazards.ArrivalTime {
-			//arrival time is more complicated than other parameters and it needs to be converted to be relative to a fixed date and time like a start time.
-			sat := fmt.Sprintf("%fh", hval)
-			duration, _ := time.ParseDuration(sat)
-			t := chp.startTime.Add(duration)
-			hd.SetParameter(k, t)
-		} else {
-			hd.SetParameter(k, hval)
+		if k == hazards.Depth && chp.waterSurface != nil {
+			hval, err = chp.waterSurface.depthAboveGround(hval, l, chp.ground())
+			if err != nil {
+				return h, err
+			}
 		}
-
+		setHazardValue(&hd, k, hval, chp.startTime)
 	}
 	multi := hazards.HazardDataToMultiParameter(hd)
 	return multi, nil //chp.process(hd, h)
 }
 
+// setHazardValue sets a parameter read from a raster, arrival times are hours after the start time and salinity is a mask that is salt water where it is not zero.
+func setHazardValue(hd *hazards.HazardData, k hazards.Parameter, hval float64, startTime time.Time) {
+	if k == hazards.Salinity {
+		hd.SetParameter(k, hval != 0)
+		return
+	}
+	if k == hazards.ArrivalTime {
+		//arrival time is more complicated than other parameters and it needs to be converted to be relative to a fixed date and time like a start time.
+		sat := fmt.Sprintf("%fh", hval)
+		duration, _ := time.ParseDuration(sat)
+		hd.SetParameter(k, startTime.Add(duration))
+		return
+	}
+	hd.SetParameter(k, hval)
+}
+
+// optionalWithoutData is true if err is no data for wave height or salinity. Their rasters often cover only the coast, so a location without their data is calm or fresh water rather than without a hazard.
+func optionalWithoutData(k hazards.Parameter, err error) bool {
+	return (k == hazards.WaveHeight || k == hazards.Salinity) && errors.As(err, &NoDataHazardError{})
+}
+
+// ground samples the terrain, it is nil if there is no terrain.
+func (chp cogMultiHazardProvider) ground() func(geography.Location) (float64, error) {
+	if chp.terrain == nil {
+		return nil
+	}
+	return chp.terrain.ProvideValue
+}
+
+// HazardBoundary is the union of the extents of every parameter's raster, in the reference system of the first parameter in parameter order.
 func (chp cogMultiHazardProvider) HazardBoundary() (geography.BBox, error) {
-	for _, v := range chp.paramCogMap {
-		return v.GetBoundingBox() //what if the two grids are not the same extent?
+	if len(chp.paramCogMap) == 0 {
+		return geography.BBox{}, errors.New("no values in the map of parameter and cogreaders")
+	}
+	boxes := make([]geography.BBox, 0, len(chp.paramCogMap))
+	for _, k := range sortedParameters(chp.paramCogMap) {
+		cr := chp.paramCogMap[k]
+		bbox, err := cr.GetBoundingBox()
+		if err != nil {
+			return bbox, err
+		}
+		boxes = append(boxes, bbox)
+	}
+	return projection.UnionBBox(boxes)
+}
+
+// sortedParameters lists the parameters of m in order, so boundaries do not depend on map iteration.
+func sortedParameters(m map[hazards.Parameter]cogReader) []hazards.Parameter {
+	keys := make([]hazards.Parameter, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return geography.BBox{}, errors.New("no values in the map of parameter and cogreaders")
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/projection"
 	"github.com/dewberry/gdal"
 )
 
@@ -15,6 +16,8 @@ type cogReader struct {
 	verticalIsMeters bool //default false
 	rb               gdal.RasterBand
 	igt              [6]float64
+	sampling         Sampling          //default nearest
+	transforms       *projection.Cache //transforms locations into the raster's reference system
 }
 
 func initCR_Meters(fp string) (cogReader, error) {
@@ -42,33 +45,44 @@ func initCR(fp string) (cogReader, error) {
 		verticalIsMeters: false,
 		rb:               rb,
 		igt:              igt,
+		transforms:       projection.NewCache(ds.Projection()),
 	}
 	if valid {
 		cr.nodata = v
 	}
 	return cr, nil
 }
+
+// clone opens a new dataset handle on the same raster so it can be read on another goroutine.
+func (cr *cogReader) clone() (cogReader, error) {
+	c, err := initCR(cr.FilePath)
+	c.verticalIsMeters = cr.verticalIsMeters
+	c.sampling = cr.sampling
+	return c, err
+}
 func (cr *cogReader) Close() {
+	cr.transforms.Close()
 	cr.ds.Close()
 }
+
+// ProvideValue samples the raster at l, which is transformed into the raster's reference system if it is in another.
 func (cr *cogReader) ProvideValue(l geography.Location) (float64, error) {
-	igt := cr.igt
-	px := int(igt[0] + l.X*igt[1] + l.Y*igt[2])
-	py := int(igt[3] + l.X*igt[4] + l.Y*igt[5])
-	buffer := make([]float32, 1*1)
-	if px < 0 || px > cr.rb.XSize() {
-		return cr.nodata, NoDataHazardError{Input: "X is out of range"}
+	l, err := cr.transforms.Location(l)
+	if err != nil {
+		return cr.nodata, err
 	}
-	if py < 0 || py > cr.rb.YSize() {
-		return cr.nodata, NoDataHazardError{Input: "Y is out of range"}
+	w, err := cr.sampling.window(l, cr.igt, cr.rb.XSize(), cr.rb.YSize())
+	if err != nil {
+		return cr.nodata, err
 	}
-	err := cr.rb.IO(gdal.RWFlag(gdal.Read), px, py, 1, 1, buffer, 1, 1, 0, 0)
+	buffer := make([]float32, w.width*w.height)
+	err = cr.rb.IO(gdal.RWFlag(gdal.Read), w.x0, w.y0, w.width, w.height, buffer, w.width, w.height, 0, 0)
 	if err != nil {
 		return cr.nodata, NoDataHazardError{Input: err.Error()}
 	}
-	depth := buffer[0]
-	d := float64(depth)
-	if d == cr.nodata {
+	values, valid := readCells(buffer, cr.nodata)
+	d, ok := cr.sampling.sample(w, values, valid)
+	if !ok {
 		return cr.nodata, NoDataHazardError{Input: fmt.Sprintf("COG reader had the no data value observed, setting to %v", cr.nodata)}
 	}
 	if cr.verticalIsMeters {
@@ -85,11 +99,12 @@ func (cr *cogReader) GetBoundingBox() (geography.BBox, error) {
 	bbox[1] = gt[3]                     //upper left y
 	bbox[2] = gt[0] + gt[1]*float64(dx) //lower right x
 	bbox[3] = gt[3] + gt[5]*float64(dy) //lower right y
-	return geography.BBox{Bbox: bbox}, nil
+	return geography.BBox{Bbox: bbox, SRID: cr.ds.Projection()}, nil
 }
 func (cr *cogReader) SpatialReference() string {
 	return cr.ds.Projection()
 }
 func (cr *cogReader) UpdateSpatialReference(sr_wkt string) {
 	cr.ds.SetProjection(sr_wkt)
+	cr.transforms.SetTarget(sr_wkt)
 }
@@ -78,9 +78,17 @@ func InitCSV(fp string, b geography.BBox) (csvArrivalDepthDurationMultiHazardPro
 }
 
 func (c csvArrivalDepthDurationMultiHazardProvider) Close() {
-	c.f.Close()
+	if c.f != nil {
+		c.f.Close()
+	}
 }
 
+// Clone implements CloneableHazardProvider, the csv is fully read at init so the clone only shares immutable slices and does not own the file.
+func (c csvArrivalDepthDurationMultiHazardProvider) Clone() (HazardProvider, error) {
+	clone := c
+	clone.f = nil
+	return clone, nil
+}
 func (c csvArrivalDepthDurationMultiHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
 	var hm hazards.ArrivalDepthandDurationEventMulti
 	if c.bbox.Contains(l) {
@@ -0,0 +1,124 @@
+package hazardproviders
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/dewberry/gdal"
+)
+
+// DepthGridHazardProvider is a HazardProvider whose depths are a single raster, so the wet area can be summarized cell by cell before a compute.
+type DepthGridHazardProvider interface {
+	HazardProvider
+	DepthGrid(bins []float64) (DepthGridSummary, error)
+}
+
+// DepthGridSummary counts the cells of a depth raster, depths are in feet. A cell is wet if its depth is above zero.
+type DepthGridSummary struct {
+	Extent    geography.BBox //bounding box of the wet cells in the raster's reference system, empty if no cell is wet
+	Cells     int            //cells with data
+	WetCells  int
+	CellArea  float64   //area of a cell in the raster's units squared
+	MaxDepth  float64   //zero if no cell is wet
+	Bins      []float64 //lower edge of each depth bin, the last bin has no upper edge
+	Histogram []int     //wet cells in each bin, cells shallower than the first bin are not counted
+}
+
+// DepthBin is the index of the bin of depth given the lower edge of each bin in increasing order, -1 if depth is below the first bin.
+func DepthBin(bins []float64, depth float64) int {
+	return sort.Search(len(bins), func(i int) bool { return bins[i] > depth }) - 1
+}
+
+// depthGridCounter accumulates a DepthGridSummary one raster row at a time.
+type depthGridCounter struct {
+	summary                        DepthGridSummary
+	minCol, maxCol, minRow, maxRow int
+}
+
+func newDepthGridCounter(bins []float64) *depthGridCounter {
+	return &depthGridCounter{
+		summary: DepthGridSummary{Bins: bins, Histogram: make([]int, len(bins))},
+		minCol:  math.MaxInt,
+		minRow:  math.MaxInt,
+		maxCol:  -1,
+		maxRow:  -1,
+	}
+}
+
+// addRow counts the cells of a row, scale converts the raster's depths to feet.
+func (c *depthGridCounter) addRow(row int, buffer []float32, nodata float64, scale float64) {
+	values, valid := readCells(buffer, nodata)
+	for col, v := range values {
+		if !valid[col] || math.IsNaN(v) {
+			continue
+		}
+		c.summary.Cells++
+		d := v * scale
+		if d <= 0 {
+			continue
+		}
+		c.summary.WetCells++
+		c.summary.MaxDepth = math.Max(c.summary.MaxDepth, d)
+		if b := DepthBin(c.summary.Bins, d); b >= 0 {
+			c.summary.Histogram[b]++
+		}
+		c.minCol = min(c.minCol, col)
+		c.maxCol = max(c.maxCol, col)
+		c.minRow = min(c.minRow, row)
+		c.maxRow = max(c.maxRow, row)
+	}
+}
+
+// finish places the wet cells with the raster's geotransform.
+func (c *depthGridCounter) finish(gt [6]float64, srid string) DepthGridSummary {
+	s := c.summary
+	s.CellArea = math.Abs(gt[1] * gt[5])
+	if s.WetCells > 0 {
+		s.Extent = geography.BBox{Bbox: []float64{
+			gt[0] + gt[1]*float64(c.minCol),   //upper left x
+			gt[3] + gt[5]*float64(c.minRow),   //upper left y
+			gt[0] + gt[1]*float64(c.maxCol+1), //lower right x
+			gt[3] + gt[5]*float64(c.maxRow+1), //lower right y
+		}, SRID: srid}
+	}
+	return s
+}
+
+// summarizeDepths reads the raster one row at a time so the whole grid is never held in memory.
+func (cr *cogReader) summarizeDepths(bins []float64) (DepthGridSummary, error) {
+	width, height := cr.rb.XSize(), cr.rb.YSize()
+	scale := 1.0
+	if cr.verticalIsMeters {
+		scale = 3.28084
+	}
+	c := newDepthGridCounter(bins)
+	buffer := make([]float32, width)
+	for row := 0; row < height; row++ {
+		err := cr.rb.IO(gdal.RWFlag(gdal.Read), 0, row, width, 1, buffer, width, 1, 0, 0)
+		if err != nil {
+			return DepthGridSummary{}, err
+		}
+		c.addRow(row, buffer, cr.nodata, scale)
+	}
+	return c.finish(cr.ds.GeoTransform(), cr.ds.Projection()), nil
+}
+
+// DepthGrid implements DepthGridHazardProvider
+func (chp cogHazardProvider) DepthGrid(bins []float64) (DepthGridSummary, error) {
+	return chp.depthcr.summarizeDepths(bins)
+}
+
+// DepthGrid implements DepthGridHazardProvider, a water surface raster is not a depth until it is compared to the ground at a structure so it cannot be summarized.
+func (chp cogMultiHazardProvider) DepthGrid(bins []float64) (DepthGridSummary, error) {
+	cr, ok := chp.paramCogMap[hazards.Depth]
+	if !ok {
+		return DepthGridSummary{}, errors.New("hazardproviders: there is no depth raster to summarize")
+	}
+	if chp.waterSurface != nil {
+		return DepthGridSummary{}, errors.New("hazardproviders: a water surface elevation raster has no depths to summarize")
+	}
+	return cr.summarizeDepths(bins)
+}
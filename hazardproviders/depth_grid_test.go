@@ -0,0 +1,46 @@
+package hazardproviders
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDepthBin(t *testing.T) {
+	bins := []float64{0, 1, 3}
+	for _, c := range []struct {
+		depth    float64
+		expected int
+	}{
+		{-1, -1},
+		{0, 0},
+		{.5, 0},
+		{1, 1},
+		{2.9, 1},
+		{12, 2},
+	} {
+		if got := DepthBin(bins, c.depth); got != c.expected {
+			t.Errorf("expected %v in bin %v, got %v", c.depth, c.expected, got)
+		}
+	}
+}
+func TestDepthGridCounter(t *testing.T) {
+	c := newDepthGridCounter([]float64{1, 2})
+	//a 4 by 3 grid in meters with no data and dry cells around two wet cells.
+	c.addRow(0, []float32{-9999, 0, 0, 0}, -9999, 3.28084)
+	c.addRow(1, []float32{0, .5, 0, -9999}, -9999, 3.28084)
+	c.addRow(2, []float32{0, 0, .2, 0}, -9999, 3.28084)
+	s := c.finish([6]float64{100, 10, 0, 500, 0, -10}, "EPSG:5070")
+	if s.Cells != 10 || s.WetCells != 2 || s.CellArea != 100 {
+		t.Errorf("expected 10 cells with 2 wet of 100 square units, got %+v", s)
+	}
+	if !reflect.DeepEqual(s.Histogram, []int{1, 0}) {
+		t.Errorf("expected one cell between 1 and 2 feet and the other below the first bin, got %v", s.Histogram)
+	}
+	if !reflect.DeepEqual(s.Extent.Bbox, []float64{110, 490, 130, 470}) {
+		t.Errorf("expected the wet cells to span 110, 490 to 130, 470, got %v", s.Extent.Bbox)
+	}
+	empty := newDepthGridCounter([]float64{0}).finish([6]float64{0, 1, 0, 0, 0, -1}, "")
+	if empty.WetCells != 0 || empty.Extent.Bbox != nil {
+		t.Errorf("expected no wet cells, got %+v", empty)
+	}
+}
@@ -0,0 +1,525 @@
+package hazardproviders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/dewberry/gdal"
+)
+
+// GriddedFormat is the format of a gridded time series dataset.
+type GriddedFormat string
+
+const (
+	NetCDF GriddedFormat = "netcdf"
+	Zarr   GriddedFormat = "zarr"
+)
+
+// TimeReduction is how a variable's time series at a location becomes a hazard parameter.
+type TimeReduction string
+
+const (
+	MaxOverTime        TimeReduction = "max"                  //the largest value of the series
+	TimeStep           TimeReduction = "step"                 //the value at one time step
+	TimeAboveThreshold TimeReduction = "time_above_threshold" //days the series is above the threshold, for duration
+	FirstArrival       TimeReduction = "first_arrival"        //when the series first rises above the threshold, for arrival time
+)
+
+// GriddedVariable maps a variable of a gridded dataset to a hazard parameter.
+type GriddedVariable struct {
+	Hazard    hazards.Parameter `json:"hazard_parameter_type"`
+	Variable  string            `json:"variable"`
+	Reduction TimeReduction     `json:"reduction,omitempty"` //max if not set
+	TimeStep  int               `json:"time_step,omitempty"` //index of the time step of the step reduction, from 0
+	Threshold float64           `json:"threshold,omitempty"` //value the series must exceed for time_above_threshold and first_arrival
+	name      parameterName
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see parameterName.
+func (v *GriddedVariable) UnmarshalJSON(data []byte) error {
+	type plain GriddedVariable
+	return unmarshalNamed(data, (*plain)(v), &v.name)
+}
+
+// Validate checks the parameter name and that the reduction suits the parameter.
+func (v GriddedVariable) Validate() error {
+	errs := []error{v.name.check()}
+	if v.Variable == "" {
+		errs = append(errs, fmt.Errorf("hazardproviders: gridded hazard parameter %v requires a variable", v.Hazard))
+	}
+	if v.Hazard == hazards.Salinity || v.Hazard == hazards.Qualitative {
+		errs = append(errs, fmt.Errorf("hazardproviders: %v is not a numeric parameter a gridded variable can provide", v.Hazard))
+	}
+	switch v.Reduction {
+	case "", MaxOverTime, TimeStep:
+		if v.Hazard == hazards.ArrivalTime {
+			errs = append(errs, errors.New("hazardproviders: arrival_time from a gridded variable requires the first_arrival reduction"))
+		}
+	case TimeAboveThreshold:
+		if v.Hazard != hazards.Duration {
+			errs = append(errs, fmt.Errorf("hazardproviders: the time_above_threshold reduction provides duration, not %v", v.Hazard))
+		}
+	case FirstArrival:
+		if v.Hazard != hazards.ArrivalTime {
+			errs = append(errs, fmt.Errorf("hazardproviders: the first_arrival reduction provides arrival_time, not %v", v.Hazard))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("hazardproviders: unknown time reduction %v", v.Reduction))
+	}
+	if v.TimeStep < 0 {
+		errs = append(errs, errors.New("hazardproviders: time_step must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// GriddedInfo describes hazard variables of a NetCDF or Zarr dataset with a time dimension, and optionally an ensemble dimension, read through gdal. Each variable is reduced over time at a location, every variable must be on the same grid and times. With an ensemble dimension each member is a separate event, Hazard provides the first member and monte carlo computes sample every member.
+type GriddedInfo struct {
+	FilePath          string            `json:"file_path"`
+	Format            GriddedFormat     `json:"format,omitempty"` //from the extension if not set, zarr for .zarr and netcdf otherwise
+	Variables         []GriddedVariable `json:"variables"`
+	TimeDimension     string            `json:"time_dimension,omitempty"`     //time if not set
+	TimeUnits         string            `json:"time_units,omitempty"`         //cf units of the time dimension such as hours since 2020-01-01 00:00:00, read from the time variable if not set
+	EnsembleDimension string            `json:"ensemble_dimension,omitempty"` //dimension of the ensemble members, if any
+	Sampling          Sampling          `json:"sampling,omitempty"`           //applied to each time step separately, nearest if not set
+}
+
+// Validate reports every problem with the gridded dataset it can find without opening it.
+func (info GriddedInfo) Validate() error {
+	errs := []error{filepaths.Check("file_path", info.FilePath), info.Sampling.Validate()}
+	switch info.Format {
+	case "", NetCDF, Zarr:
+	default:
+		errs = append(errs, fmt.Errorf("hazardproviders: unknown gridded format %v", info.Format))
+	}
+	if len(info.Variables) == 0 {
+		errs = append(errs, errors.New("hazardproviders: a gridded hazard requires at least one variable"))
+	}
+	parameters := make(map[hazards.Parameter]bool)
+	for _, v := range info.Variables {
+		if parameters[v.Hazard] {
+			errs = append(errs, fmt.Errorf("hazardproviders: %v is provided more than once", v.Hazard))
+		}
+		parameters[v.Hazard] = true
+		errs = append(errs, v.Validate())
+	}
+	if info.TimeUnits != "" {
+		_, err := parseCFTime(info.TimeUnits)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+func (info GriddedInfo) format() GriddedFormat {
+	if info.Format != "" {
+		return info.Format
+	}
+	if strings.HasSuffix(strings.ToLower(strings.TrimRight(info.FilePath, "/")), ".zarr") {
+		return Zarr
+	}
+	return NetCDF
+}
+func (info GriddedInfo) timeDimension() string {
+	if info.TimeDimension == "" {
+		return "time"
+	}
+	return info.TimeDimension
+}
+
+// subdataset is the gdal name of a variable of the dataset.
+func (info GriddedInfo) subdataset(variable string) string {
+	if info.format() == Zarr {
+		return fmt.Sprintf(`ZARR:"%v":/%v`, info.FilePath, strings.TrimPrefix(variable, "/"))
+	}
+	return fmt.Sprintf(`NETCDF:"%v":%v`, info.FilePath, variable)
+}
+
+// maxCFDays is the largest number of days from the reference date a cf time value can be, about 270,000 years.
+const maxCFDays = 1e8
+
+// parseCFTime parses cf time units such as days since 2000-01-01 into a conversion from values to times. Whole units are added as whole days and seconds and only the fraction of a unit as a duration, so values far from the reference date do not overflow, a value more than maxCFDays from it is an error.
+func parseCFTime(units string) (func(v float64) (time.Time, error), error) {
+	parts := strings.SplitN(strings.TrimSpace(units), " since ", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("hazardproviders: time units %v are not of the form units since date", units)
+	}
+	var unit time.Duration
+	switch strings.ToLower(parts[0]) {
+	case "seconds", "second", "s", "sec", "secs":
+		unit = time.Second
+	case "minutes", "minute", "min", "mins":
+		unit = time.Minute
+	case "hours", "hour", "h", "hr", "hrs":
+		unit = time.Hour
+	case "days", "day", "d":
+		unit = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("hazardproviders: unknown time unit %v", parts[0])
+	}
+	date := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(parts[1]), " UTC"), "Z")
+	for _, layout := range []string{"2006-1-2 15:4:5", "2006-1-2T15:4:5", "2006-1-2 15:4", "2006-1-2"} {
+		epoch, err := time.Parse(layout, date)
+		if err == nil {
+			return func(v float64) (time.Time, error) {
+				if math.IsNaN(v) || math.Abs(v*float64(unit)/float64(24*time.Hour)) > maxCFDays {
+					return time.Time{}, fmt.Errorf("hazardproviders: time %v %v is out of range", v, units)
+				}
+				whole := math.Trunc(v)
+				seconds := int64(whole) * int64(unit/time.Second)
+				t := epoch.AddDate(0, 0, int(seconds/86400)).Add(time.Duration(seconds%86400) * time.Second)
+				return t.Add(time.Duration((v - whole) * float64(unit))), nil
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("hazardproviders: unable to parse the reference date of time units %v", units)
+}
+
+// dimensionValue finds the value of a dimension in a band's metadata items, netcdf writes NETCDF_DIM_name and zarr DIM_name_VALUE or only DIM_name_INDEX.
+func dimensionValue(item func(key string) string, dimension string) (float64, bool) {
+	for _, key := range []string{"NETCDF_DIM_" + dimension, "DIM_" + dimension + "_VALUE", "DIM_" + dimension + "_INDEX"} {
+		f, err := strconv.ParseFloat(strings.TrimSpace(item(key)), 64)
+		if err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// bandLayout is the band number of each time step of each member, bands[member][step], with the members and times in increasing order.
+type bandLayout struct {
+	members []float64
+	times   []float64
+	bands   [][]int
+}
+
+// layoutBands orders the bands of a variable by member and time from the metadata items of each band, a variable without a dimension has one member or one time step.
+func layoutBands(bandMetadata []func(key string) string, timeDimension string, ensembleDimension string) (bandLayout, error) {
+	type position struct{ member, time float64 }
+	positions := make(map[position]int)
+	members, times := make(map[float64]bool), make(map[float64]bool)
+	for i, metadata := range bandMetadata {
+		var p position
+		if ensembleDimension != "" {
+			m, ok := dimensionValue(metadata, ensembleDimension)
+			if !ok && len(bandMetadata) > 1 {
+				return bandLayout{}, fmt.Errorf("hazardproviders: band %v has no %v dimension", i+1, ensembleDimension)
+			}
+			p.member = m
+		}
+		t, ok := dimensionValue(metadata, timeDimension)
+		if !ok && len(bandMetadata) > 1 && ensembleDimension == "" {
+			return bandLayout{}, fmt.Errorf("hazardproviders: band %v has no %v dimension", i+1, timeDimension)
+		}
+		p.time = t
+		if _, dup := positions[p]; dup {
+			return bandLayout{}, fmt.Errorf("hazardproviders: band %v repeats a member and time", i+1)
+		}
+		positions[p] = i + 1
+		members[p.member], times[p.time] = true, true
+	}
+	sorted := func(m map[float64]bool) []float64 {
+		s := make([]float64, 0, len(m))
+		for v := range m {
+			s = append(s, v)
+		}
+		sort.Float64s(s)
+		return s
+	}
+	layout := bandLayout{members: sorted(members), times: sorted(times)}
+	layout.bands = make([][]int, len(layout.members))
+	for i, m := range layout.members {
+		layout.bands[i] = make([]int, len(layout.times))
+		for j, t := range layout.times {
+			b, ok := positions[position{member: m, time: t}]
+			if !ok {
+				return bandLayout{}, fmt.Errorf("hazardproviders: member %v has no band at time %v", m, t)
+			}
+			layout.bands[i][j] = b
+		}
+	}
+	return layout, nil
+}
+
+// reduce reduces a variable's series at a location, valid is false where a step had no data. Steps without data are dry for the threshold reductions.
+func (v GriddedVariable) reduce(times []time.Time, series []float64, valid []bool) (any, error) {
+	switch v.Reduction {
+	case TimeStep:
+		if v.TimeStep >= len(series) {
+			return nil, fmt.Errorf("hazardproviders: time step %v is past the last of %v steps", v.TimeStep, len(series))
+		}
+		if !valid[v.TimeStep] {
+			return nil, NoDataHazardError{Input: fmt.Sprintf("%v had the no data value observed at step %v", v.Variable, v.TimeStep)}
+		}
+		return series[v.TimeStep], nil
+	case TimeAboveThreshold, FirstArrival:
+		depths := make([]float64, len(series))
+		for i := range series {
+			if valid[i] {
+				depths[i] = series[i]
+			}
+		}
+		periods := wetPeriods(times, depths, nil, v.Threshold)
+		if len(periods) == 0 {
+			return nil, NoHazardFoundError{Input: fmt.Sprintf("%v never exceeded %v", v.Variable, v.Threshold)}
+		}
+		if v.Reduction == FirstArrival {
+			return periods[0].arrival, nil
+		}
+		days := 0.0
+		for _, p := range periods {
+			days += p.departure.Sub(p.arrival).Hours() / 24
+		}
+		return days, nil
+	default:
+		found := false
+		m := math.Inf(-1)
+		for i, s := range series {
+			if valid[i] {
+				m = math.Max(m, s)
+				found = true
+			}
+		}
+		if !found {
+			return nil, NoDataHazardError{Input: fmt.Sprintf("%v had the no data value observed at every step", v.Variable)}
+		}
+		return m, nil
+	}
+}
+
+// griddedVariable is an open variable of a gridded dataset.
+type griddedVariable struct {
+	ds     *gdal.Dataset
+	layout bandLayout
+	bands  []int //every band of the layout, member by member
+	nodata []float64
+}
+
+type griddedHazardProvider struct {
+	info      GriddedInfo
+	startTime time.Time
+	variables []griddedVariable
+	times     []time.Time
+	igt       [6]float64
+	xsize     int
+	ysize     int
+	bbox      geography.BBox
+	transform *projection.Cache //transforms locations into the dataset's reference system
+}
+
+// InitGridded opens every variable of a gridded dataset, startTime is the time of a series without a time dimension.
+func InitGridded(info GriddedInfo, startTime time.Time) (griddedHazardProvider, error) {
+	err := info.Validate()
+	if err != nil {
+		return griddedHazardProvider{}, err
+	}
+	gp := griddedHazardProvider{info: info, startTime: startTime}
+	for _, v := range info.Variables {
+		gv, err := openGriddedVariable(info, v.Variable)
+		if err != nil {
+			gp.Close()
+			return griddedHazardProvider{}, err
+		}
+		gp.variables = append(gp.variables, gv)
+		first := gp.variables[0]
+		if len(gv.layout.members) != len(first.layout.members) || len(gv.layout.times) != len(first.layout.times) || gv.ds.RasterXSize() != first.ds.RasterXSize() || gv.ds.RasterYSize() != first.ds.RasterYSize() {
+			gp.Close()
+			return griddedHazardProvider{}, fmt.Errorf("hazardproviders: %v is not on the grid, times and members of %v", v.Variable, info.Variables[0].Variable)
+		}
+	}
+	first := gp.variables[0]
+	times, err := gp.readTimes(first)
+	if err != nil {
+		gp.Close()
+		return griddedHazardProvider{}, err
+	}
+	gp.times = times
+	gp.igt = first.ds.InvGeoTransform()
+	gp.xsize, gp.ysize = first.ds.RasterXSize(), first.ds.RasterYSize()
+	gt := first.ds.GeoTransform()
+	gp.bbox = geography.BBox{Bbox: []float64{gt[0], gt[3], gt[0] + gt[1]*float64(gp.xsize), gt[3] + gt[5]*float64(gp.ysize)}, SRID: first.ds.Projection()}
+	gp.transform = projection.NewCache(first.ds.Projection())
+	return gp, nil
+}
+
+// openGriddedVariable opens a variable and lays out its bands.
+func openGriddedVariable(info GriddedInfo, variable string) (griddedVariable, error) {
+	name := info.subdataset(variable)
+	ds, err := gdal.Open(name, gdal.Access(gdal.ReadOnly))
+	if err != nil {
+		return griddedVariable{}, fmt.Errorf("hazardproviders: cannot open %v: %w", name, err)
+	}
+	metadata := make([]func(key string) string, ds.RasterCount())
+	for i := range metadata {
+		rb := ds.RasterBand(i + 1)
+		metadata[i] = func(key string) string {
+			return rb.MetadataItem(key, "")
+		}
+	}
+	layout, err := layoutBands(metadata, info.timeDimension(), info.EnsembleDimension)
+	if err != nil {
+		ds.Close()
+		return griddedVariable{}, fmt.Errorf("hazardproviders: %v: %w", name, err)
+	}
+	gv := griddedVariable{ds: &ds, layout: layout}
+	for _, member := range layout.bands {
+		for _, b := range member {
+			gv.bands = append(gv.bands, b)
+			nodata, valid := ds.RasterBand(b).NoDataValue()
+			if !valid {
+				nodata = -9999
+			}
+			gv.nodata = append(gv.nodata, nodata)
+		}
+	}
+	return gv, nil
+}
+
+// readTimes converts the time dimension's values to times with the configured units, or the units of the time variable.
+func (gp griddedHazardProvider) readTimes(v griddedVariable) ([]time.Time, error) {
+	if len(v.layout.times) == 1 {
+		return []time.Time{gp.startTime}, nil
+	}
+	units := gp.info.TimeUnits
+	if units == "" {
+		units = v.ds.MetadataItem(gp.info.timeDimension()+"#units", "")
+	}
+	if units == "" {
+		return nil, fmt.Errorf("hazardproviders: %v has no units for its %v dimension, set time_units", gp.info.FilePath, gp.info.timeDimension())
+	}
+	toTime, err := parseCFTime(units)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, len(v.layout.times))
+	for i, t := range v.layout.times {
+		times[i], err = toTime(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return times, nil
+}
+func (gp griddedHazardProvider) Close() {
+	for _, v := range gp.variables {
+		v.ds.Close()
+	}
+	if gp.transform != nil {
+		gp.transform.Close()
+	}
+}
+
+// Clone implements CloneableHazardProvider
+func (gp griddedHazardProvider) Clone() (HazardProvider, error) {
+	return InitGridded(gp.info, gp.startTime)
+}
+func (gp griddedHazardProvider) HazardBoundary() (geography.BBox, error) {
+	return gp.bbox, nil
+}
+
+// Hazard provides the first member of an ensemble, or the only member.
+func (gp griddedHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	events, err := gp.members(l)
+	if err != nil {
+		return nil, err
+	}
+	return events[0].event, events[0].err
+}
+
+// EnsembleHazard implements EnsembleHazardProvider, a member without a hazard at l is nil.
+func (gp griddedHazardProvider) EnsembleHazard(l geography.Location) ([]hazards.HazardEvent, error) {
+	events, err := gp.members(l)
+	if err != nil {
+		return nil, err
+	}
+	return ensembleEvents(events)
+}
+
+// memberEvent is the hazard of one member at a location, or why it has none.
+type memberEvent struct {
+	event hazards.HazardEvent
+	err   error
+}
+
+// ensembleEvents lists the members' events, it is an error only if no member has a hazard.
+func ensembleEvents(events []memberEvent) ([]hazards.HazardEvent, error) {
+	out := make([]hazards.HazardEvent, len(events))
+	var err error
+	found := false
+	for i, e := range events {
+		if e.err != nil {
+			err = e.err
+			continue
+		}
+		out[i] = e.event
+		found = true
+	}
+	if !found {
+		return nil, err
+	}
+	return out, nil
+}
+
+// members reads every variable at l and reduces each member's series.
+func (gp griddedHazardProvider) members(l geography.Location) ([]memberEvent, error) {
+	l, err := gp.transform.Location(l)
+	if err != nil {
+		return nil, err
+	}
+	w, err := gp.info.Sampling.window(l, gp.igt, gp.xsize, gp.ysize)
+	if err != nil {
+		return nil, err
+	}
+	n := w.width * w.height
+	steps := len(gp.times)
+	buffers := make([][]float32, len(gp.variables))
+	for vi, v := range gp.variables {
+		buffers[vi] = make([]float32, n*len(v.bands))
+		err = v.ds.IO(gdal.RWFlag(gdal.Read), w.x0, w.y0, w.width, w.height, buffers[vi], w.width, w.height, len(v.bands), v.bands, 0, 0, 0)
+		if err != nil {
+			return nil, NoDataHazardError{Input: err.Error()}
+		}
+	}
+	events := make([]memberEvent, len(gp.variables[0].layout.members))
+	for m := range events {
+		series, valid := make([][]float64, len(gp.variables)), make([][]bool, len(gp.variables))
+		for vi, v := range gp.variables {
+			series[vi], valid[vi] = make([]float64, steps), make([]bool, steps)
+			for t := 0; t < steps; t++ {
+				b := m*steps + t
+				cells, cellsValid := readCells(buffers[vi][b*n:(b+1)*n], v.nodata[b])
+				series[vi][t], valid[vi][t] = gp.info.Sampling.sample(w, cells, cellsValid)
+			}
+		}
+		events[m] = reduceMember(gp.info.Variables, gp.times, series, valid)
+	}
+	return events, nil
+}
+
+// reduceMember reduces one member's series of each variable. A variable that cannot be reduced, such as a duration that never exceeds its threshold, is left out of the event, the member has no hazard only if no variable can be reduced.
+func reduceMember(variables []GriddedVariable, times []time.Time, series [][]float64, valid [][]bool) memberEvent {
+	data := emptyHazardData()
+	var err error
+	reduced := 0
+	for i, v := range variables {
+		value, verr := v.reduce(times, series[i], valid[i])
+		if verr != nil {
+			if err == nil {
+				err = verr
+			}
+			continue
+		}
+		data.SetParameter(v.Hazard, value)
+		reduced++
+	}
+	if reduced == 0 {
+		return memberEvent{err: err}
+	}
+	return memberEvent{event: hazards.HazardDataToMultiParameter(data)}
+}
@@ -0,0 +1,166 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func TestParseCFTime(t *testing.T) {
+	for _, c := range []struct {
+		units    string
+		value    float64
+		expected time.Time
+	}{
+		{"hours since 2020-01-01 00:00:00", 36, time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)},
+		{"days since 2000-1-1", 1.5, time.Date(2000, 1, 2, 12, 0, 0, 0, time.UTC)},
+		{"seconds since 2021-06-01T06:00:00Z", 90, time.Date(2021, 6, 1, 6, 1, 30, 0, time.UTC)},
+		//beyond the 292 years a duration can hold.
+		{"days since 1700-01-01", 146097 * 3, time.Date(2900, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"hours since 1850-01-01", -24 * 365, time.Date(1849, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"seconds since 1600-01-01", 146097 * 86400 * 2, time.Date(2400, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		toTime, err := parseCFTime(c.units)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := toTime(c.value)
+		if err != nil || !v.Equal(c.expected) {
+			t.Errorf("expected %v %v to be %v, got %v %v", c.value, c.units, c.expected, v, err)
+		}
+	}
+	toTime, err := parseCFTime("days since 2000-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = toTime(1e12); err == nil {
+		t.Error("expected a time out of range to be an error")
+	}
+	for _, units := range []string{"hours", "fortnights since 2020-01-01", "hours since yesterday"} {
+		if _, err := parseCFTime(units); err == nil {
+			t.Errorf("expected %v to be invalid", units)
+		}
+	}
+}
+
+// bandItems are the metadata items of each band.
+func bandItems(items ...map[string]string) []func(key string) string {
+	out := make([]func(key string) string, len(items))
+	for i, m := range items {
+		out[i] = func(key string) string {
+			return m[key]
+		}
+	}
+	return out
+}
+func TestLayoutBands(t *testing.T) {
+	//two members of two time steps, written out of order as zarr might.
+	layout, err := layoutBands(bandItems(
+		map[string]string{"DIM_member_INDEX": "1", "DIM_time_VALUE": "0"},
+		map[string]string{"DIM_member_INDEX": "0", "DIM_time_VALUE": "6"},
+		map[string]string{"DIM_member_INDEX": "0", "DIM_time_VALUE": "0"},
+		map[string]string{"DIM_member_INDEX": "1", "DIM_time_VALUE": "6"},
+	), "time", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.members) != 2 || len(layout.times) != 2 || layout.bands[0][0] != 3 || layout.bands[0][1] != 2 || layout.bands[1][0] != 1 || layout.bands[1][1] != 4 {
+		t.Errorf("unexpected layout %+v", layout)
+	}
+	layout, err = layoutBands(bandItems(map[string]string{"NETCDF_DIM_time": "12"}, map[string]string{"NETCDF_DIM_time": "24"}), "time", "")
+	if err != nil || len(layout.members) != 1 || len(layout.times) != 2 || layout.times[1] != 24 {
+		t.Errorf("expected one member at two times, got %+v %v", layout, err)
+	}
+	_, err = layoutBands(bandItems(map[string]string{"DIM_member_INDEX": "0", "DIM_time_VALUE": "0"}, map[string]string{"DIM_member_INDEX": "1", "DIM_time_VALUE": "6"}), "time", "member")
+	if err == nil {
+		t.Error("expected members with different times to be an error")
+	}
+}
+func TestGriddedVariable_Reduce(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{start, start.Add(12 * time.Hour), start.Add(24 * time.Hour), start.Add(36 * time.Hour)}
+	series := []float64{0, 2, 4, -9999}
+	valid := []bool{true, true, true, false}
+	for _, c := range []struct {
+		v        GriddedVariable
+		expected any
+	}{
+		{GriddedVariable{}, 4.0},
+		{GriddedVariable{Reduction: TimeStep, TimeStep: 1}, 2.0},
+		//wet from 6 hours, the step without data is dry so it departs at 33 hours.
+		{GriddedVariable{Reduction: TimeAboveThreshold, Threshold: 1}, 1.125},
+		{GriddedVariable{Reduction: FirstArrival, Threshold: 1}, start.Add(6 * time.Hour)},
+	} {
+		value, err := c.v.reduce(times, series, valid)
+		if err != nil || value != c.expected {
+			t.Errorf("expected %v to reduce to %v, got %v %v", c.v.Reduction, c.expected, value, err)
+		}
+	}
+	_, err := GriddedVariable{Reduction: TimeStep, TimeStep: 3}.reduce(times, series, valid)
+	if !errors.As(err, &NoDataHazardError{}) {
+		t.Errorf("expected no data at the last step, got %v", err)
+	}
+	_, err = GriddedVariable{Reduction: FirstArrival, Threshold: 5}.reduce(times, series, valid)
+	if !errors.As(err, &NoHazardFoundError{}) {
+		t.Errorf("expected a series below the threshold to be dry, got %v", err)
+	}
+}
+func TestReduceMember(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{start, start.Add(12 * time.Hour)}
+	variables := []GriddedVariable{{Hazard: hazards.Depth}, {Hazard: hazards.Duration, Reduction: TimeAboveThreshold, Threshold: 5}}
+	series := [][]float64{{1, 2}, {1, 2}}
+	valid := [][]bool{{true, true}, {true, true}}
+	//the depth never exceeds the duration's threshold, the member keeps its depth.
+	m := reduceMember(variables, times, series, valid)
+	if m.err != nil || m.event.Depth() != 2 || m.event.Has(hazards.Duration) {
+		t.Errorf("expected a depth of 2 without a duration, got %v %v", m.event, m.err)
+	}
+	m = reduceMember(variables, times, series, [][]bool{{false, false}, {false, false}})
+	if m.err == nil {
+		t.Error("expected a member without any reduced variable to have no hazard")
+	}
+}
+func TestEnsembleEvents(t *testing.T) {
+	wet := hazards.DepthEvent{}
+	wet.SetDepth(3)
+	events, err := ensembleEvents([]memberEvent{{err: NoHazardFoundError{}}, {event: wet}})
+	if err != nil || len(events) != 2 || events[0] != nil || events[1].Depth() != 3 {
+		t.Errorf("expected a dry member and a wet member, got %v %v", events, err)
+	}
+	_, err = ensembleEvents([]memberEvent{{err: NoHazardFoundError{}}, {err: NoHazardFoundError{}}})
+	if !errors.As(err, &NoHazardFoundError{}) {
+		t.Errorf("expected every member dry to be no hazard, got %v", err)
+	}
+}
+func TestGriddedInfo_Validate(t *testing.T) {
+	var info HazardProviderInfo
+	err := json.Unmarshal([]byte(`{"gridded": {"file_path": "/vsis3/bucket/surge.zarr", "ensemble_dimension": "member", "time_units": "hours since 2020-01-01", "variables": [{"hazard_parameter_type": "depth", "variable": "depth"}, {"hazard_parameter_type": "duration", "variable": "depth", "reduction": "time_above_threshold", "threshold": 0.5}]}}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Validate(); err != nil {
+		t.Errorf("expected a valid gridded hazard, got %v", err)
+	}
+	if info.Gridded.format() != Zarr || info.Gridded.subdataset("depth") != `ZARR:"/vsis3/bucket/surge.zarr":/depth` {
+		t.Errorf("expected a zarr dataset, got %v", info.Gridded.subdataset("depth"))
+	}
+	for _, g := range []GriddedInfo{
+		{FilePath: "/vsis3/bucket/surge.nc"},
+		{FilePath: "/vsis3/bucket/surge.nc", Format: "grib", Variables: []GriddedVariable{{Hazard: hazards.Depth, Variable: "depth"}}},
+		{FilePath: "/vsis3/bucket/surge.nc", Variables: []GriddedVariable{{Hazard: hazards.ArrivalTime, Variable: "depth"}}},
+		{FilePath: "/vsis3/bucket/surge.nc", Variables: []GriddedVariable{{Hazard: hazards.Depth, Variable: "depth", Reduction: FirstArrival}}},
+		{FilePath: "/vsis3/bucket/surge.nc", Variables: []GriddedVariable{{Hazard: hazards.Depth, Variable: "depth"}, {Hazard: hazards.Depth, Variable: "wse"}}},
+		{FilePath: "/vsis3/bucket/surge.nc", Variables: []GriddedVariable{{Hazard: hazards.Depth, Variable: "depth"}}, TimeUnits: "hours"},
+	} {
+		if g.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", g)
+		}
+		if _, err := InitGridded(g, time.Time{}); err == nil {
+			t.Errorf("expected %+v to be rejected before the dataset is opened", g)
+		}
+	}
+}
@@ -9,16 +9,39 @@ import (
 )
 
 type HazardProviderParameterAndPath struct {
-	Hazard   hazards.Parameter `json:"hazard_parameter_type"`
-	FilePath string            `json:"hazard_provider_file_path"` //this should get fixed to be able to represent more complex information. e.g. what parameter?
+	Hazard       hazards.Parameter      `json:"hazard_parameter_type"`
+	FilePath     string                 `json:"hazard_provider_file_path"` //this should get fixed to be able to represent more complex information. e.g. what parameter?
+	Sampling     Sampling               `json:"sampling,omitempty"`        //how the raster is sampled at a structure, nearest if not set
+	WaterSurface *WaterSurfaceElevation `json:"water_surface,omitempty"`   //the depth raster holds water surface elevations, depth is taken above the ground
+	name         parameterName
 }
 type HazardProviderInfo struct {
 	Hazards   []HazardProviderParameterAndPath `json:"hazards"`
 	StartTime time.Time                        `json:"start_time"`
 	EndTime   time.Time                        `json:"end_time"`
+	MultiBand *MultiBandInfo                   `json:"multi_band,omitempty"` //a single multi band raster, used instead of hazards
+	RasPlan   *RasPlanInfo                     `json:"ras_plan,omitempty"`   //a HEC-RAS 2D plan hdf file, used instead of hazards
+	Mosaic    *MosaicInfo                      `json:"mosaic,omitempty"`     //many tiled rasters or vrts for each parameter, used instead of hazards
+	Vector    *VectorInfo                      `json:"vector,omitempty"`     //a point or polygon layer, used instead of hazards
+	Gridded   *GriddedInfo                     `json:"gridded,omitempty"`    //netcdf or zarr variables with time and ensemble dimensions, used instead of hazards
 }
 
 func (info HazardProviderInfo) CreateHazardProvider() (HazardProvider, error) {
+	if info.MultiBand != nil {
+		return InitMultiBand(*info.MultiBand, info.StartTime)
+	}
+	if info.RasPlan != nil {
+		return InitRasPlan(*info.RasPlan, info.StartTime)
+	}
+	if info.Mosaic != nil {
+		return InitMosaic(*info.Mosaic, info.StartTime)
+	}
+	if info.Vector != nil {
+		return InitVector(*info.Vector, info.StartTime)
+	}
+	if info.Gridded != nil {
+		return InitGridded(*info.Gridded, info.StartTime)
+	}
 	//ultimately make this more flexible, but for now...
 	return InitMulti(info)
 }
@@ -30,6 +53,24 @@ type HazardProvider interface {
 	HazardBoundary() (geography.BBox, error)
 	Close()
 }
+
+// CloneableHazardProvider is a HazardProvider that can produce an independent copy of itself. gdal datasets are not safe to share across goroutines, so concurrent computes give each worker its own clone.
+type CloneableHazardProvider interface {
+	HazardProvider
+	Clone() (HazardProvider, error)
+}
+
+// TiledHazardProvider is a HazardProvider over many rasters. Computes stream structures one tile at a time, so only the rasters near the structures being computed need to be open.
+type TiledHazardProvider interface {
+	HazardProvider
+	Tiles() ([]geography.BBox, error)
+}
+
+// EnsembleHazardProvider is a HazardProvider whose hazard has several equally likely members, such as an ensemble forecast. Monte carlo computes sample the members so each structure has a distribution of damages.
+type EnsembleHazardProvider interface {
+	HazardProvider
+	EnsembleHazard(location geography.Location) ([]hazards.HazardEvent, error) //every member's hazard, nil for members without one at the location
+}
 type HazardFunction func(valueIn hazards.HazardData, hazard hazards.HazardEvent) (hazards.HazardEvent, error)
 
 func DepthHazardFunction() HazardFunction {
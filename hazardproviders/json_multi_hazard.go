@@ -74,6 +74,26 @@ func (j jsonArrivalDepthDurationMultiHazardProvider) Close() {
 	}
 }
 
+// Clone implements CloneableHazardProvider
+func (j jsonArrivalDepthDurationMultiHazardProvider) Clone() (HazardProvider, error) {
+	depthCRs := make([]cogReader, len(j.depthCRs))
+	for i, cr := range j.depthCRs {
+		c, err := cr.clone()
+		if err != nil {
+			for _, opened := range depthCRs[:i] {
+				opened.Close()
+			}
+			return jsonArrivalDepthDurationMultiHazardProvider{}, err
+		}
+		depthCRs[i] = c
+	}
+	return jsonArrivalDepthDurationMultiHazardProvider{
+		arrivals:  j.arrivals,
+		depthCRs:  depthCRs,
+		durations: j.durations,
+		process:   j.process,
+	}, nil
+}
 func (j jsonArrivalDepthDurationMultiHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
 	var hm hazards.ArrivalDepthandDurationEventMulti
 	for i, cr := range j.depthCRs {
@@ -0,0 +1,392 @@
+package hazardproviders
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/dewberry/gdal"
+)
+
+// OverlapRule selects the value of a mosaic where its tiles overlap.
+type OverlapRule string
+
+const (
+	Priority OverlapRule = "priority" //the first tile listed with a value
+	MaxValue OverlapRule = "max"      //the largest value of the tiles
+)
+
+// defaultMaxOpenTiles is how many tiles a mosaic keeps open when max_open_tiles is not set.
+const defaultMaxOpenTiles = 8
+
+// MosaicParameter is one hazard parameter given as many tiled rasters or vrts.
+type MosaicParameter struct {
+	Hazard    hazards.Parameter `json:"hazard_parameter_type"`
+	FilePaths []string          `json:"file_paths"`         //rasters or vrts in priority order, a vrt is read through gdal as one tile
+	Overlap   OverlapRule       `json:"overlap,omitempty"`  //priority if not set
+	Sampling  Sampling          `json:"sampling,omitempty"` //how each tile is sampled at a structure, nearest if not set
+	name      parameterName
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see parameterName.
+func (m *MosaicParameter) UnmarshalJSON(data []byte) error {
+	type plain MosaicParameter
+	return unmarshalNamed(data, (*plain)(m), &m.name)
+}
+
+// Validate checks the parameter name, the overlap rule, the sampling method and that every tile exists, without opening them.
+func (m MosaicParameter) Validate() error {
+	errs := []error{m.name.check()}
+	switch m.Overlap {
+	case "", Priority, MaxValue:
+	default:
+		errs = append(errs, fmt.Errorf("hazardproviders: unknown overlap rule %v", m.Overlap))
+	}
+	if len(m.FilePaths) == 0 {
+		errs = append(errs, fmt.Errorf("hazardproviders: mosaic parameter %v requires at least one file path", m.Hazard))
+	}
+	for _, fp := range m.FilePaths {
+		errs = append(errs, filepaths.Check("file_paths", fp))
+	}
+	errs = append(errs, m.Sampling.Validate())
+	return errors.Join(errs...)
+}
+
+// MosaicInfo describes hazards whose parameters are each a mosaic of tiled rasters or vrts. The extent of each tile is read when the provider is created, tiles are opened as locations on them are requested and at most MaxOpenTiles are open at once. Computes stream structures one tile at a time over the tiles of every parameter.
+type MosaicInfo struct {
+	Hazards      []MosaicParameter `json:"hazards"`
+	MaxOpenTiles int               `json:"max_open_tiles,omitempty"` //8 if not set
+}
+
+// Validate reports every problem with the mosaic it can find without opening a dataset.
+func (info MosaicInfo) Validate() error {
+	if len(info.Hazards) == 0 {
+		return errors.New("hazardproviders: a mosaic requires at least one hazard")
+	}
+	errs := make([]error, 0)
+	if info.MaxOpenTiles < 0 {
+		errs = append(errs, errors.New("hazardproviders: max_open_tiles must not be negative"))
+	}
+	seen := make(map[hazards.Parameter]bool)
+	for _, m := range info.Hazards {
+		if seen[m.Hazard] {
+			errs = append(errs, fmt.Errorf("hazardproviders: mosaic parameter %v is listed more than once", m.Hazard))
+		}
+		seen[m.Hazard] = true
+		errs = append(errs, m.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// mosaicTile is the extent of one raster of a mosaic in its own reference system, a vrt also lists the extents of its sources.
+type mosaicTile struct {
+	path    string
+	bbox    geography.BBox
+	sources []geography.BBox
+}
+
+// readTile opens a raster only long enough to read its extent.
+func readTile(fp string) (mosaicTile, error) {
+	ds, err := gdal.Open(fp, gdal.Access(gdal.ReadOnly))
+	if err != nil {
+		return mosaicTile{}, fmt.Errorf("hazardproviders: cannot connect to raster at path %v: %w", fp, err)
+	}
+	defer ds.Close()
+	gt := ds.GeoTransform()
+	srid := ds.Projection()
+	t := mosaicTile{path: fp, bbox: pixelExtent(gt, 0, 0, float64(ds.RasterXSize()), float64(ds.RasterYSize()))}
+	t.bbox.SRID = srid
+	if strings.HasSuffix(strings.ToLower(fp), ".vrt") {
+		t.sources, err = vrtSourceExtents(strings.Join(ds.Metadata("xml:VRT"), ""), gt)
+		if err != nil {
+			return t, fmt.Errorf("hazardproviders: unable to read the sources of %v: %w", fp, err)
+		}
+		for i := range t.sources {
+			t.sources[i].SRID = srid
+		}
+	}
+	return t, nil
+}
+
+// pixelExtent is the upper left, lower right box of a block of pixels.
+func pixelExtent(gt [6]float64, xOff float64, yOff float64, xSize float64, ySize float64) geography.BBox {
+	return geography.BBox{Bbox: []float64{
+		gt[0] + xOff*gt[1],
+		gt[3] + yOff*gt[5],
+		gt[0] + (xOff+xSize)*gt[1],
+		gt[3] + (yOff+ySize)*gt[5],
+	}}
+}
+
+// vrtSourceExtents lists the extents of the sources of the first band of a vrt, from where each is placed in the vrt.
+func vrtSourceExtents(vrt string, gt [6]float64) ([]geography.BBox, error) {
+	var doc struct {
+		Bands []struct {
+			Elements []struct {
+				DstRect *struct {
+					XOff  float64 `xml:"xOff,attr"`
+					YOff  float64 `xml:"yOff,attr"`
+					XSize float64 `xml:"xSize,attr"`
+					YSize float64 `xml:"ySize,attr"`
+				} `xml:"DstRect"`
+			} `xml:",any"`
+		} `xml:"VRTRasterBand"`
+	}
+	err := xml.Unmarshal([]byte(vrt), &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Bands) == 0 {
+		return nil, errors.New("the vrt has no bands")
+	}
+	extents := make([]geography.BBox, 0)
+	for _, e := range doc.Bands[0].Elements {
+		if r := e.DstRect; r != nil {
+			extents = append(extents, pixelExtent(gt, r.XOff, r.YOff, r.XSize, r.YSize))
+		}
+	}
+	return extents, nil
+}
+
+// mosaicLayer is the tiles of one parameter.
+type mosaicLayer struct {
+	hazard   hazards.Parameter
+	overlap  OverlapRule
+	sampling Sampling
+	tiles    []mosaicTile
+}
+
+// tileReader samples one tile.
+type tileReader interface {
+	ProvideValue(l geography.Location) (float64, error)
+	Close()
+}
+
+// tileKey is a tile of a layer.
+type tileKey struct {
+	layer, tile int
+}
+
+// tileCache keeps at most max tiles open, closing the least recently used.
+type tileCache struct {
+	max     int
+	open    func(k tileKey) (tileReader, error)
+	readers map[tileKey]tileReader
+	order   []tileKey //least recently used first
+}
+
+func newTileCache(max int, open func(k tileKey) (tileReader, error)) *tileCache {
+	if max <= 0 {
+		max = defaultMaxOpenTiles
+	}
+	return &tileCache{max: max, open: open, readers: make(map[tileKey]tileReader)}
+}
+
+// reader returns the open reader of a tile, opening it if needed.
+func (c *tileCache) reader(k tileKey) (tileReader, error) {
+	for i, o := range c.order {
+		if o == k {
+			c.order = append(append(c.order[:i:i], c.order[i+1:]...), k)
+			return c.readers[k], nil
+		}
+	}
+	r, err := c.open(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.order) >= c.max {
+		c.readers[c.order[0]].Close()
+		delete(c.readers, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.readers[k] = r
+	c.order = append(c.order, k)
+	return r, nil
+}
+
+// Close closes every open tile.
+func (c *tileCache) Close() {
+	for _, r := range c.readers {
+		r.Close()
+	}
+	c.readers = make(map[tileKey]tileReader)
+	c.order = nil
+}
+
+type mosaicHazardProvider struct {
+	layers     []mosaicLayer
+	startTime  time.Time
+	maxOpen    int
+	tiles      *tileCache
+	transforms map[string]*projection.Cache //transforms locations into the reference system of tiles, by reference system
+}
+
+// InitMosaic reads the extent of every tile of a mosaic, tiles are not kept open.
+func InitMosaic(info MosaicInfo, startTime time.Time) (mosaicHazardProvider, error) {
+	err := info.Validate()
+	if err != nil {
+		return mosaicHazardProvider{}, err
+	}
+	layers := make([]mosaicLayer, 0, len(info.Hazards))
+	for _, m := range info.Hazards {
+		layer := mosaicLayer{hazard: m.Hazard, overlap: m.Overlap, sampling: m.Sampling}
+		for _, fp := range m.FilePaths {
+			t, err := readTile(fp)
+			if err != nil {
+				return mosaicHazardProvider{}, err
+			}
+			layer.tiles = append(layer.tiles, t)
+		}
+		layers = append(layers, layer)
+	}
+	return newMosaicHazardProvider(layers, startTime, info.MaxOpenTiles, openTile(layers)), nil
+}
+func newMosaicHazardProvider(layers []mosaicLayer, startTime time.Time, maxOpen int, open func(k tileKey) (tileReader, error)) mosaicHazardProvider {
+	return mosaicHazardProvider{
+		layers:     layers,
+		startTime:  startTime,
+		maxOpen:    maxOpen,
+		tiles:      newTileCache(maxOpen, open),
+		transforms: make(map[string]*projection.Cache),
+	}
+}
+
+// openTile opens tiles of layers as cogReaders.
+func openTile(layers []mosaicLayer) func(k tileKey) (tileReader, error) {
+	return func(k tileKey) (tileReader, error) {
+		layer := layers[k.layer]
+		cr, err := initCR(layer.tiles[k.tile].path)
+		if err != nil {
+			return nil, err
+		}
+		cr.sampling = layer.sampling
+		return &cr, nil
+	}
+}
+
+// Close closes the open tiles.
+func (mp mosaicHazardProvider) Close() {
+	mp.tiles.Close()
+	for _, c := range mp.transforms {
+		c.Close()
+	}
+}
+
+// Clone implements CloneableHazardProvider, the clone opens its own tiles.
+func (mp mosaicHazardProvider) Clone() (HazardProvider, error) {
+	return newMosaicHazardProvider(mp.layers, mp.startTime, mp.maxOpen, mp.tiles.open), nil
+}
+
+// Hazard samples each parameter's tiles at l.
+func (mp mosaicHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	var h hazards.HazardEvent
+	hd := emptyHazardData()
+	for i, layer := range mp.layers {
+		v, err := mp.value(i, l)
+		if err != nil {
+			if optionalWithoutData(layer.hazard, err) {
+				continue
+			}
+			return h, err
+		}
+		setHazardValue(&hd, layer.hazard, v, mp.startTime)
+	}
+	return hazards.HazardDataToMultiParameter(hd), nil
+}
+
+// value samples the tiles of a layer containing l, tiles without data at l are skipped.
+func (mp mosaicHazardProvider) value(layer int, l geography.Location) (float64, error) {
+	rule := mp.layers[layer].overlap
+	found := false
+	best := 0.0
+	for i, t := range mp.layers[layer].tiles {
+		in, err := mp.contains(t.bbox, l)
+		if err != nil {
+			return 0, err
+		}
+		if !in {
+			continue
+		}
+		r, err := mp.tiles.reader(tileKey{layer: layer, tile: i})
+		if err != nil {
+			return 0, err
+		}
+		v, err := r.ProvideValue(l)
+		if err != nil {
+			if errors.As(err, &NoDataHazardError{}) {
+				continue
+			}
+			return 0, err
+		}
+		if rule != MaxValue {
+			return v, nil
+		}
+		if !found || v > best {
+			best = v
+		}
+		found = true
+	}
+	if !found {
+		return 0, NoDataHazardError{Input: fmt.Sprintf("no tile of %v has data at the location", mp.layers[layer].hazard)}
+	}
+	return best, nil
+}
+
+// contains is true if bbox contains l once l is transformed into the box's reference system.
+func (mp mosaicHazardProvider) contains(bbox geography.BBox, l geography.Location) (bool, error) {
+	c, ok := mp.transforms[bbox.SRID]
+	if !ok {
+		c = projection.NewCache(bbox.SRID)
+		mp.transforms[bbox.SRID] = c
+	}
+	tl, err := c.Location(l)
+	if err != nil {
+		return false, err
+	}
+	return bbox.Contains(tl), nil
+}
+
+// HazardBoundary is the union of the extents of every tile of every parameter, in the reference system of the first tile.
+func (mp mosaicHazardProvider) HazardBoundary() (geography.BBox, error) {
+	boxes := make([]geography.BBox, 0)
+	for _, layer := range mp.layers {
+		for _, t := range layer.tiles {
+			boxes = append(boxes, t.bbox)
+		}
+	}
+	return projection.UnionBBox(boxes)
+}
+
+// Tiles implements TiledHazardProvider with the union of the tiles of every parameter, a vrt is listed by its sources and a tile shared by several parameters is listed once.
+func (mp mosaicHazardProvider) Tiles() ([]geography.BBox, error) {
+	if len(mp.layers) == 0 {
+		return nil, errors.New("hazardproviders: the mosaic has no parameters")
+	}
+	boxes := make([]geography.BBox, 0)
+	listed := make(map[string]bool)
+	add := func(b geography.BBox) {
+		key := fmt.Sprint(b.Bbox, b.SRID)
+		if !listed[key] {
+			listed[key] = true
+			boxes = append(boxes, b)
+		}
+	}
+	for _, layer := range mp.layers {
+		for _, t := range layer.tiles {
+			if len(t.sources) > 0 {
+				for _, b := range t.sources {
+					add(b)
+				}
+			} else {
+				add(t.bbox)
+			}
+		}
+	}
+	return boxes, nil
+}
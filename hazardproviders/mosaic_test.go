@@ -0,0 +1,216 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// constantTile has one value everywhere, or no data if nodata is set, and records when it is closed.
+type constantTile struct {
+	value  float64
+	nodata bool
+	closed *int
+}
+
+func (c constantTile) ProvideValue(l geography.Location) (float64, error) {
+	if c.nodata {
+		return 0, NoDataHazardError{Input: "no data"}
+	}
+	return c.value, nil
+}
+func (c constantTile) Close() {
+	*c.closed++
+}
+
+// twoTileMosaic is a depth mosaic of two overlapping tiles with values 2 and 5, the second tile has no data where x is above 15.
+func twoTileMosaic(rule OverlapRule, maxOpen int) (mosaicHazardProvider, *int, *int) {
+	opened, closed := 0, 0
+	layer := mosaicLayer{hazard: hazards.Depth, overlap: rule, tiles: []mosaicTile{
+		{path: "a.tif", bbox: geography.BBox{Bbox: []float64{0, 10, 10, 0}}},
+		{path: "b.tif", bbox: geography.BBox{Bbox: []float64{5, 15, 20, 5}}},
+	}}
+	mp := newMosaicHazardProvider([]mosaicLayer{layer}, time.Time{}, maxOpen, func(k tileKey) (tileReader, error) {
+		opened++
+		return constantTile{value: []float64{2, 5}[k.tile], closed: &closed}, nil
+	})
+	return mp, &opened, &closed
+}
+func TestMosaic_Overlap(t *testing.T) {
+	priority, _, _ := twoTileMosaic("", 0)
+	max, _, _ := twoTileMosaic(MaxValue, 0)
+	for _, c := range []struct {
+		mp       mosaicHazardProvider
+		l        geography.Location
+		expected float64
+	}{
+		{priority, geography.Location{X: 1, Y: 1}, 2},
+		{priority, geography.Location{X: 7, Y: 7}, 2},
+		{priority, geography.Location{X: 12, Y: 12}, 5},
+		{max, geography.Location{X: 7, Y: 7}, 5},
+		{max, geography.Location{X: 1, Y: 1}, 2},
+	} {
+		e, err := c.mp.Hazard(c.l)
+		if err != nil || e.Depth() != c.expected {
+			t.Errorf("expected a depth of %v at %v, got %v %v", c.expected, c.l, e, err)
+		}
+	}
+	_, err := priority.Hazard(geography.Location{X: 30, Y: 30})
+	if !errors.As(err, &NoDataHazardError{}) {
+		t.Errorf("expected a location outside every tile to have no data, got %v", err)
+	}
+}
+func TestMosaic_SkipsNoData(t *testing.T) {
+	closed := 0
+	layer := mosaicLayer{hazard: hazards.Depth, tiles: []mosaicTile{
+		{bbox: geography.BBox{Bbox: []float64{0, 10, 10, 0}}},
+		{bbox: geography.BBox{Bbox: []float64{0, 10, 10, 0}}},
+	}}
+	mp := newMosaicHazardProvider([]mosaicLayer{layer}, time.Time{}, 0, func(k tileKey) (tileReader, error) {
+		return constantTile{value: 3, nodata: k.tile == 0, closed: &closed}, nil
+	})
+	e, err := mp.Hazard(geography.Location{X: 5, Y: 5})
+	if err != nil || e.Depth() != 3 {
+		t.Errorf("expected the second tile's depth where the first has no data, got %v %v", e, err)
+	}
+}
+func TestMosaic_LimitsOpenTiles(t *testing.T) {
+	mp, opened, closed := twoTileMosaic(Priority, 1)
+	for _, l := range []geography.Location{{X: 1, Y: 1}, {X: 2, Y: 2}, {X: 12, Y: 12}, {X: 13, Y: 13}, {X: 1, Y: 1}} {
+		_, err := mp.Hazard(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *opened != 3 || *closed != 2 {
+		t.Errorf("expected 3 opens and 2 closes with one tile open at a time, got %v and %v", *opened, *closed)
+	}
+	mp.Close()
+	if *closed != 3 {
+		t.Errorf("expected close to close the open tile, got %v closes", *closed)
+	}
+}
+func TestMosaic_BoundaryAndTiles(t *testing.T) {
+	mp, _, _ := twoTileMosaic(Priority, 0)
+	bbox, err := mp.HazardBoundary()
+	if err != nil || !reflect.DeepEqual(bbox.Bbox, []float64{0, 15, 20, 0}) {
+		t.Errorf("expected the union [0 15 20 0], got %v %v", bbox, err)
+	}
+	mp.layers[0].tiles[1].sources = []geography.BBox{{Bbox: []float64{5, 15, 10, 5}}, {Bbox: []float64{10, 15, 20, 5}}}
+	tiles, err := mp.Tiles()
+	if err != nil || len(tiles) != 3 {
+		t.Errorf("expected the first tile and the two sources of the second, got %v %v", tiles, err)
+	}
+	//a velocity layer sharing the first depth tile and extending beyond the depth tiles.
+	mp.layers = append(mp.layers, mosaicLayer{hazard: hazards.Velocity, tiles: []mosaicTile{
+		{bbox: geography.BBox{Bbox: []float64{0, 10, 10, 0}}},
+		{bbox: geography.BBox{Bbox: []float64{20, 15, 30, 5}}},
+	}})
+	tiles, err = mp.Tiles()
+	if err != nil || len(tiles) != 4 || !reflect.DeepEqual(tiles[3].Bbox, []float64{20, 15, 30, 5}) {
+		t.Errorf("expected the depth tiles and the velocity tile beyond them, got %v %v", tiles, err)
+	}
+}
+func TestVrtSourceExtents(t *testing.T) {
+	vrt := `<VRTDataset rasterXSize="20" rasterYSize="10">
+  <GeoTransform>100, 1, 0, 50, 0, -1</GeoTransform>
+  <VRTRasterBand dataType="Float32" band="1">
+    <NoDataValue>-9999</NoDataValue>
+    <SimpleSource>
+      <SourceFilename relativeToVRT="1">a.tif</SourceFilename>
+      <SrcRect xOff="0" yOff="0" xSize="10" ySize="10" />
+      <DstRect xOff="0" yOff="0" xSize="10" ySize="10" />
+    </SimpleSource>
+    <ComplexSource>
+      <SourceFilename relativeToVRT="1">b.tif</SourceFilename>
+      <DstRect xOff="10" yOff="0" xSize="10" ySize="10" />
+    </ComplexSource>
+  </VRTRasterBand>
+</VRTDataset>`
+	extents, err := vrtSourceExtents(vrt, [6]float64{100, 1, 0, 50, 0, -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extents) != 2 || !reflect.DeepEqual(extents[1].Bbox, []float64{110, 50, 120, 40}) {
+		t.Errorf("expected two sources, the second at [110 50 120 40], got %v", extents)
+	}
+}
+func TestMosaicInfo_Validate(t *testing.T) {
+	var info HazardProviderInfo
+	err := json.Unmarshal([]byte(`{"mosaic": {"hazards": [{"hazard_parameter_type": "depth", "file_paths": ["/vsis3/bucket/a.tif", "/vsis3/bucket/b.vrt"], "overlap": "max"}]}}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Validate(); err != nil {
+		t.Errorf("expected a valid mosaic, got %v", err)
+	}
+	for _, m := range []MosaicInfo{
+		{},
+		{Hazards: []MosaicParameter{{Hazard: hazards.Depth}}},
+		{Hazards: []MosaicParameter{{Hazard: hazards.Depth, FilePaths: []string{"/vsis3/bucket/a.tif"}, Overlap: "min"}}},
+		{Hazards: []MosaicParameter{{Hazard: hazards.Depth, FilePaths: []string{"/vsis3/bucket/a.tif"}}, {Hazard: hazards.Depth, FilePaths: []string{"/vsis3/bucket/b.tif"}}}},
+	} {
+		if m.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", m)
+		}
+		if _, err := InitMosaic(m, time.Time{}); err == nil {
+			t.Errorf("expected %+v to be rejected before any tile is opened", m)
+		}
+	}
+	info.Hazards = []HazardProviderParameterAndPath{{Hazard: hazards.Depth, FilePath: "/vsis3/bucket/depth.tif"}}
+	if info.Validate() == nil {
+		t.Error("expected hazards and a mosaic together to be invalid")
+	}
+}
+func TestMosaic_CoastalLayers(t *testing.T) {
+	closed := 0
+	tile := []mosaicTile{{bbox: geography.BBox{Bbox: []float64{0, 10, 10, 0}}}}
+	layers := []mosaicLayer{{hazard: hazards.Depth, tiles: tile}, {hazard: hazards.WaveHeight, tiles: tile}, {hazard: hazards.Salinity, tiles: tile}}
+	//waves have no data where x is above 5, salinity is a mask of 1.
+	values := map[hazards.Parameter]float64{hazards.Depth: 4, hazards.WaveHeight: 3.5, hazards.Salinity: 1}
+	mp := newMosaicHazardProvider(layers, time.Time{}, 0, func(k tileKey) (tileReader, error) {
+		nodataAbove := 10.0
+		if layers[k.layer].hazard == hazards.WaveHeight {
+			nodataAbove = 5
+		}
+		return locationTile{value: values[layers[k.layer].hazard], nodataAbove: nodataAbove, closed: &closed}, nil
+	})
+	e, err := mp.Hazard(geography.Location{X: 2, Y: 2})
+	if err != nil || e.Parameters() != hazards.Depth|hazards.WaveHeight|hazards.HighWaveHeight|hazards.Salinity || e.WaveHeight() != 3.5 {
+		t.Errorf("expected depth, high waves and salt water, got %v %v", e, err)
+	}
+	e, err = mp.Hazard(geography.Location{X: 7, Y: 2})
+	if err != nil || e.Parameters() != hazards.Depth|hazards.Salinity {
+		t.Errorf("expected calm salt water without wave data, got %v %v", e, err)
+	}
+	var info HazardProviderInfo
+	err = json.Unmarshal([]byte(`{"hazards": [{"hazard_parameter_type": "depth", "hazard_provider_file_path": "/vsis3/bucket/depth.tif"}, {"hazard_parameter_type": "highwaveheight", "hazard_provider_file_path": "/vsis3/bucket/waves.tif"}]}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Validate() == nil {
+		t.Error("expected a raster of a wave height class to be invalid")
+	}
+}
+
+// locationTile has one value where x is at or below nodataAbove and no data beyond it.
+type locationTile struct {
+	value       float64
+	nodataAbove float64
+	closed      *int
+}
+
+func (c locationTile) ProvideValue(l geography.Location) (float64, error) {
+	if l.X > c.nodataAbove {
+		return 0, NoDataHazardError{Input: "no data"}
+	}
+	return c.value, nil
+}
+func (c locationTile) Close() {
+	*c.closed++
+}
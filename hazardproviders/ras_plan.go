@@ -0,0 +1,524 @@
+package hazardproviders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/dewberry/gdal"
+)
+
+// paths of the datasets of a HEC-RAS plan hdf file, {area} is the name of a 2D flow area.
+const (
+	rasGeometryAreas    = "Geometry/2D Flow Areas/"
+	rasCellCenters      = "Geometry/2D Flow Areas/{area}/Cells Center Coordinate"
+	rasCellFacePoints   = "Geometry/2D Flow Areas/{area}/Cells FacePoint Indexes"
+	rasFacePoints       = "Geometry/2D Flow Areas/{area}/FacePoints Coordinate"
+	rasFaceCells        = "Geometry/2D Flow Areas/{area}/Faces Cell Indexes"
+	rasCellMinElevation = "Geometry/2D Flow Areas/{area}/Cells Minimum Elevation"
+	rasMaxWSE           = "Results/Unsteady/Output/Output Blocks/Base Output/Summary Output/2D Flow Areas/{area}/Maximum Water Surface"
+	rasMaxFaceVelocity  = "Results/Unsteady/Output/Output Blocks/Base Output/Summary Output/2D Flow Areas/{area}/Maximum Face Velocity"
+	rasWSE              = "Results/Unsteady/Output/Output Blocks/Base Output/Unsteady Time Series/2D Flow Areas/{area}/Water Surface"
+	rasTime             = "Results/Unsteady/Output/Output Blocks/Base Output/Unsteady Time Series/Time"
+)
+
+// RasPlanInfo describes a HEC-RAS 2D plan hdf file read directly as a hazard. Depth is the maximum water surface less the terrain, or the cell's minimum elevation if there is no terrain. Velocity is the largest maximum face velocity of the cell's faces. Arrival time and duration come from the water surface time series, whose times are in days from the hazard's start time.
+type RasPlanInfo struct {
+	FilePath         string   `json:"file_path"`
+	FlowAreas        []string `json:"flow_areas,omitempty"`         //2D flow areas read, every area in the plan if not listed
+	TerrainFilePath  string   `json:"terrain_file_path,omitempty"`  //ground elevation raster, the minimum elevation of each cell is used if not set
+	Threshold        float64  `json:"threshold,omitempty"`          //depth a location must exceed to be wet for arrival time and duration
+	VerticalIsMeters bool     `json:"vertical_is_meters,omitempty"` //the plan and terrain are in meters, depths and velocities are converted to feet
+	SRID             string   `json:"srid,omitempty"`               //reference system of the plan, read from the file's projection if not set
+}
+
+// Validate reports every problem with the plan it can find without opening the file.
+func (info RasPlanInfo) Validate() error {
+	errs := []error{filepaths.Check("file_path", info.FilePath)}
+	if info.TerrainFilePath != "" {
+		errs = append(errs, filepaths.Check("terrain_file_path", info.TerrainFilePath))
+	}
+	if info.Threshold < 0 {
+		errs = append(errs, errors.New("hazardproviders: the ras plan threshold must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// rasFlowArea is the geometry and maximum results of a 2D flow area, elevations and velocities are in feet.
+type rasFlowArea struct {
+	name         string
+	cells        [][][2]float64 //polygon of each cell, ghost cells on the perimeter have none
+	bbox         [4]float64     //min x, min y, max x, max y
+	bucket       float64        //width of the squares of the cell index
+	index        map[[2]int][]int
+	minElevation []float64
+	maxWSE       []float64
+	maxVelocity  []float64                         //nil if the plan has no face velocities
+	wse          func(cell int) ([]float64, error) //water surface time series of a cell, nil if the plan has no time series
+}
+
+// newRasFlowArea builds the cell polygons and an index of them from the face points of each cell, cellFacePoints is padded with -1.
+func newRasFlowArea(name string, facePoints [][2]float64, cellFacePoints [][]int) rasFlowArea {
+	a := rasFlowArea{name: name, cells: make([][][2]float64, len(cellFacePoints)), index: make(map[[2]int][]int)}
+	a.bbox = [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	count := 0
+	for i, fps := range cellFacePoints {
+		polygon := make([][2]float64, 0, len(fps))
+		for _, fp := range fps {
+			if fp >= 0 && fp < len(facePoints) {
+				polygon = append(polygon, facePoints[fp])
+			}
+		}
+		if len(polygon) < 3 {
+			continue
+		}
+		a.cells[i] = polygon
+		count++
+		for _, p := range polygon {
+			a.bbox[0], a.bbox[1] = math.Min(a.bbox[0], p[0]), math.Min(a.bbox[1], p[1])
+			a.bbox[2], a.bbox[3] = math.Max(a.bbox[2], p[0]), math.Max(a.bbox[3], p[1])
+		}
+	}
+	if count == 0 {
+		return a
+	}
+	//about one cell per square of the index.
+	a.bucket = math.Sqrt((a.bbox[2] - a.bbox[0]) * (a.bbox[3] - a.bbox[1]) / float64(count))
+	if a.bucket <= 0 {
+		a.bucket = 1
+	}
+	for i, polygon := range a.cells {
+		if polygon == nil {
+			continue
+		}
+		minx, miny, maxx, maxy := polygon[0][0], polygon[0][1], polygon[0][0], polygon[0][1]
+		for _, p := range polygon {
+			minx, miny = math.Min(minx, p[0]), math.Min(miny, p[1])
+			maxx, maxy = math.Max(maxx, p[0]), math.Max(maxy, p[1])
+		}
+		x0, y0 := a.square(minx, miny)
+		x1, y1 := a.square(maxx, maxy)
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				a.index[[2]int{x, y}] = append(a.index[[2]int{x, y}], i)
+			}
+		}
+	}
+	return a
+}
+func (a rasFlowArea) square(x float64, y float64) (int, int) {
+	return int(math.Floor((x - a.bbox[0]) / a.bucket)), int(math.Floor((y - a.bbox[1]) / a.bucket))
+}
+
+// cell finds the cell containing x, y.
+func (a rasFlowArea) cell(x float64, y float64) (int, bool) {
+	if a.bucket == 0 || x < a.bbox[0] || x > a.bbox[2] || y < a.bbox[1] || y > a.bbox[3] {
+		return 0, false
+	}
+	sx, sy := a.square(x, y)
+	for _, i := range a.index[[2]int{sx, sy}] {
+		if geography.RingContains(x, y, a.cells[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// cellVelocities is the largest absolute face velocity of each cell's faces, faceCells holds the two cells on either side of each face, -1 if there is none.
+func cellVelocities(cellCount int, faceCells [][2]int, faceVelocities []float64) []float64 {
+	v := make([]float64, cellCount)
+	for f, cells := range faceCells {
+		if f >= len(faceVelocities) {
+			break
+		}
+		for _, c := range cells {
+			if c >= 0 && c < cellCount {
+				v[c] = math.Max(v[c], math.Abs(faceVelocities[f]))
+			}
+		}
+	}
+	return v
+}
+
+// hazard is the hazard at cell for a location with ground elevation ground. Depth times velocity is the product of the maximum depth and maximum velocity, which need not occur at the same time.
+func (a rasFlowArea) hazard(cell int, ground float64, times []time.Time, threshold float64) (hazards.HazardEvent, error) {
+	if cell >= len(a.maxWSE) || cell >= len(a.minElevation) {
+		return nil, NoDataHazardError{Input: fmt.Sprintf("2D flow area %v has no results for cell %v", a.name, cell)}
+	}
+	depth := a.maxWSE[cell] - ground
+	if depth <= 0 {
+		return nil, NoHazardFoundError{Input: fmt.Sprintf("2D flow area %v cell %v is dry", a.name, cell)}
+	}
+	hd := emptyHazardData()
+	hd.Depth = depth
+	if a.maxVelocity != nil {
+		hd.Velocity = a.maxVelocity[cell]
+		hd.DV = depth * hd.Velocity
+	}
+	if a.wse != nil && len(times) > 0 {
+		wse, err := a.wse(cell)
+		if err != nil {
+			return nil, err
+		}
+		if len(wse) != len(times) {
+			return nil, fmt.Errorf("hazardproviders: 2D flow area %v has %v water surfaces for %v times", a.name, len(wse), len(times))
+		}
+		depths := make([]float64, len(wse))
+		for i, w := range wse {
+			depths[i] = w - ground
+		}
+		periods := wetPeriods(times, depths, nil, threshold)
+		if len(periods) > 0 {
+			hd.ArrivalTime = periods[0].arrival
+			hd.Duration = 0
+			for _, p := range periods {
+				hd.Duration += p.departure.Sub(p.arrival).Hours() / 24
+			}
+		}
+	}
+	return hazards.HazardDataToMultiParameter(hd), nil
+}
+
+// rasDatasets reads the datasets of a plan by path, columns reads single columns of a two dimensional dataset that is kept open until done is called. flowAreas lists the 2D flow areas with cell geometry.
+type rasDatasets interface {
+	flowAreas() []string
+	has(path string) bool
+	read(path string) ([]float64, int, error)
+	columns(path string) (column func(col int) ([]float64, error), done func(), err error)
+}
+
+// rasHDF is a HEC-RAS plan hdf file opened through gdal's hdf5 driver, each dataset of the file is a gdal subdataset.
+type rasHDF struct {
+	ds          *gdal.Dataset
+	subdatasets map[string]string //subdataset name by rasPath
+}
+
+// rasPath normalizes a dataset path, gdal replaces spaces with underscores in the names of subdatasets.
+func rasPath(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), " ", "_")
+}
+
+// subdatasetNames maps the normalized path of each subdataset listed in gdal's SUBDATASETS metadata to its name.
+func subdatasetNames(metadata []string) map[string]string {
+	names := make(map[string]string)
+	for _, m := range metadata {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) != 2 || !strings.HasSuffix(kv[0], "_NAME") {
+			continue
+		}
+		i := strings.Index(kv[1], "://")
+		if i < 0 {
+			continue
+		}
+		names[rasPath(kv[1][i+3:])] = kv[1]
+	}
+	return names
+}
+
+// flowAreaNames lists the 2D flow areas with cell geometry in the file.
+func flowAreaNames(subdatasets map[string]string) []string {
+	prefix := rasPath(rasGeometryAreas)
+	suffix := strings.ReplaceAll(strings.TrimPrefix(rasCellCenters, rasGeometryAreas+"{area}"), " ", "_")
+	names := make([]string, 0)
+	for p := range subdatasets {
+		if strings.HasPrefix(p, prefix) && strings.HasSuffix(p, suffix) {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(p, prefix), suffix))
+		}
+	}
+	return names
+}
+func openRasHDF(fp string) (rasHDF, error) {
+	fmt.Println("Connecting to: " + fp)
+	ds, err := gdal.Open(fp, gdal.Access(gdal.ReadOnly))
+	if err != nil {
+		return rasHDF{}, errors.New("Cannot connect to ras plan at path " + fp + err.Error())
+	}
+	return rasHDF{ds: &ds, subdatasets: subdatasetNames(ds.Metadata("SUBDATASETS"))}, nil
+}
+func (h rasHDF) flowAreas() []string {
+	return flowAreaNames(h.subdatasets)
+}
+func (h rasHDF) has(path string) bool {
+	_, ok := h.subdatasets[rasPath(path)]
+	return ok
+}
+func (h rasHDF) open(path string) (gdal.Dataset, error) {
+	name, ok := h.subdatasets[rasPath(path)]
+	if !ok {
+		return gdal.Dataset{}, fmt.Errorf("hazardproviders: the ras plan has no dataset %v", path)
+	}
+	return gdal.Open(name, gdal.Access(gdal.ReadOnly))
+}
+
+// read reads a whole dataset, a two dimensional dataset is returned by row with the number of columns.
+func (h rasHDF) read(path string) ([]float64, int, error) {
+	ds, err := h.open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer ds.Close()
+	cols, rows := ds.RasterXSize(), ds.RasterYSize()
+	buffer := make([]float64, cols*rows)
+	err = ds.RasterBand(1).IO(gdal.RWFlag(gdal.Read), 0, 0, cols, rows, buffer, cols, rows, 0, 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("hazardproviders: unable to read %v: %w", path, err)
+	}
+	return buffer, cols, nil
+}
+
+func (h rasHDF) columns(path string) (func(col int) ([]float64, error), func(), error) {
+	ds, err := h.open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rb := ds.RasterBand(1)
+	rows := ds.RasterYSize()
+	column := func(col int) ([]float64, error) {
+		buffer := make([]float64, rows)
+		err := rb.IO(gdal.RWFlag(gdal.Read), col, 0, 1, rows, buffer, 1, rows, 0, 0)
+		return buffer, err
+	}
+	return column, ds.Close, nil
+}
+
+// projection is the plan's reference system from the file's root attributes.
+func (h rasHDF) projection() string {
+	for _, m := range h.ds.Metadata("") {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "Projection") {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+type rasPlanHazardProvider struct {
+	info      RasPlanInfo
+	startTime time.Time
+	file      rasHDF
+	areas     []rasFlowArea
+	series    []func() //close the water surface time series datasets kept open for the areas
+	times     []time.Time
+	terrain   *cogReader
+	srid      string
+	transform *projection.Cache //transforms locations into the plan's reference system
+}
+
+// InitRasPlan opens a HEC-RAS 2D plan hdf file and reads the geometry and maximum results of its 2D flow areas, startTime anchors the time series.
+func InitRasPlan(info RasPlanInfo, startTime time.Time) (rasPlanHazardProvider, error) {
+	err := info.Validate()
+	if err != nil {
+		return rasPlanHazardProvider{}, err
+	}
+	file, err := openRasHDF(info.FilePath)
+	if err != nil {
+		return rasPlanHazardProvider{}, err
+	}
+	rp := rasPlanHazardProvider{info: info, startTime: startTime, file: file, srid: info.SRID}
+	if rp.srid == "" {
+		rp.srid = file.projection()
+	}
+	rp.transform = projection.NewCache(rp.srid)
+	err = rp.load(file)
+	if err != nil {
+		rp.Close()
+		return rasPlanHazardProvider{}, err
+	}
+	return rp, nil
+}
+
+// load reads the times and the 2D flow areas from d, and opens the terrain.
+func (rp *rasPlanHazardProvider) load(d rasDatasets) error {
+	names := rp.info.FlowAreas
+	if len(names) == 0 {
+		names = d.flowAreas()
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("hazardproviders: %v has no 2D flow areas", rp.info.FilePath)
+	}
+	scale := 1.0
+	if rp.info.VerticalIsMeters {
+		scale = 3.28084
+	}
+	if d.has(rasTime) {
+		days, _, err := d.read(rasTime)
+		if err != nil {
+			return err
+		}
+		rp.times = make([]time.Time, len(days))
+		for i, d := range days {
+			rp.times[i] = rp.startTime.Add(time.Duration(d * 24 * float64(time.Hour)))
+		}
+	}
+	for _, name := range names {
+		area, err := rp.loadArea(d, name, scale)
+		if err != nil {
+			return err
+		}
+		rp.areas = append(rp.areas, area)
+	}
+	if rp.info.TerrainFilePath != "" {
+		var terrain cogReader
+		var err error
+		if rp.info.VerticalIsMeters {
+			terrain, err = initCR_Meters(rp.info.TerrainFilePath)
+		} else {
+			terrain, err = initCR(rp.info.TerrainFilePath)
+		}
+		if err != nil {
+			return err
+		}
+		rp.terrain = &terrain
+	}
+	return nil
+}
+
+// loadArea reads a 2D flow area from d, elevations and velocities are multiplied by scale to convert them to feet. It is an error if a dataset is too short for the cells of the area.
+func (rp *rasPlanHazardProvider) loadArea(d rasDatasets, name string, scale float64) (rasFlowArea, error) {
+	path := func(p string) string {
+		return strings.ReplaceAll(p, "{area}", name)
+	}
+	fp, fpCols, err := d.read(path(rasFacePoints))
+	if err != nil {
+		return rasFlowArea{}, err
+	}
+	if fpCols < 2 {
+		return rasFlowArea{}, fmt.Errorf("hazardproviders: 2D flow area %v face points do not have x and y coordinates", name)
+	}
+	facePoints := make([][2]float64, len(fp)/fpCols)
+	for i := range facePoints {
+		facePoints[i] = [2]float64{fp[i*fpCols], fp[i*fpCols+1]}
+	}
+	cfp, cfpCols, err := d.read(path(rasCellFacePoints))
+	if err != nil {
+		return rasFlowArea{}, err
+	}
+	if cfpCols == 0 {
+		return rasFlowArea{}, fmt.Errorf("hazardproviders: 2D flow area %v has no cell face points", name)
+	}
+	cellFacePoints := make([][]int, len(cfp)/cfpCols)
+	for i := range cellFacePoints {
+		cellFacePoints[i] = make([]int, cfpCols)
+		for j := range cellFacePoints[i] {
+			cellFacePoints[i][j] = int(cfp[i*cfpCols+j])
+		}
+	}
+	area := newRasFlowArea(name, facePoints, cellFacePoints)
+	n := len(cellFacePoints)
+	area.minElevation, _, err = d.read(path(rasCellMinElevation))
+	if err != nil {
+		return rasFlowArea{}, err
+	}
+	if len(area.minElevation) < n {
+		return rasFlowArea{}, fmt.Errorf("hazardproviders: 2D flow area %v has %v minimum elevations for %v cells", name, len(area.minElevation), n)
+	}
+	for i := range area.minElevation {
+		area.minElevation[i] *= scale
+	}
+	//the first row of the maximum water surface is the value, the second the time it occurred. ghost cells at the end of the geometry have no results.
+	wse, cols, err := d.read(path(rasMaxWSE))
+	if err != nil {
+		return rasFlowArea{}, err
+	}
+	area.maxWSE = wse[:cols]
+	for i := range area.maxWSE {
+		area.maxWSE[i] *= scale
+	}
+	if d.has(path(rasMaxFaceVelocity)) && d.has(path(rasFaceCells)) {
+		fv, fvCols, err := d.read(path(rasMaxFaceVelocity))
+		if err != nil {
+			return rasFlowArea{}, err
+		}
+		fc, fcCols, err := d.read(path(rasFaceCells))
+		if err != nil {
+			return rasFlowArea{}, err
+		}
+		if fcCols < 2 {
+			return rasFlowArea{}, fmt.Errorf("hazardproviders: 2D flow area %v faces do not have the cells on either side", name)
+		}
+		faceCells := make([][2]int, len(fc)/fcCols)
+		for i := range faceCells {
+			faceCells[i] = [2]int{int(fc[i*fcCols]), int(fc[i*fcCols+1])}
+		}
+		velocities := fv[:fvCols]
+		for i := range velocities {
+			velocities[i] *= scale
+		}
+		area.maxVelocity = cellVelocities(n, faceCells, velocities)
+	}
+	if len(rp.times) > 0 && d.has(path(rasWSE)) {
+		column, done, err := d.columns(path(rasWSE))
+		if err != nil {
+			return rasFlowArea{}, err
+		}
+		rp.series = append(rp.series, done)
+		area.wse = func(cell int) ([]float64, error) {
+			buffer, err := column(cell)
+			for i := range buffer {
+				buffer[i] *= scale
+			}
+			return buffer, err
+		}
+	}
+	return area, nil
+}
+func (rp rasPlanHazardProvider) Close() {
+	for _, done := range rp.series {
+		done()
+	}
+	if rp.terrain != nil {
+		rp.terrain.Close()
+	}
+	rp.transform.Close()
+	rp.file.ds.Close()
+}
+
+// Clone implements CloneableHazardProvider
+func (rp rasPlanHazardProvider) Clone() (HazardProvider, error) {
+	return InitRasPlan(rp.info, rp.startTime)
+}
+
+// HazardBoundary is the extent of the 2D flow areas read.
+func (rp rasPlanHazardProvider) HazardBoundary() (geography.BBox, error) {
+	if len(rp.areas) == 0 {
+		return geography.BBox{}, errors.New("hazardproviders: the ras plan has no 2D flow areas")
+	}
+	bbox := geography.BBox{Bbox: []float64{rp.areas[0].bbox[0], rp.areas[0].bbox[3], rp.areas[0].bbox[2], rp.areas[0].bbox[1]}, SRID: rp.srid}
+	for _, a := range rp.areas[1:] {
+		bbox = bbox.Union(geography.BBox{Bbox: []float64{a.bbox[0], a.bbox[3], a.bbox[2], a.bbox[1]}})
+	}
+	return bbox, nil
+}
+
+// Hazard provides the depth, velocity, depth times velocity, arrival time and duration of the cell containing l.
+func (rp rasPlanHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	l, err := rp.transform.Location(l)
+	if err != nil {
+		return nil, err
+	}
+	if l.SRID == "" {
+		//the terrain transforms from the plan's reference system.
+		l.SRID = rp.srid
+	}
+	for _, a := range rp.areas {
+		cell, ok := a.cell(l.X, l.Y)
+		if !ok {
+			continue
+		}
+		ground := a.minElevation[cell]
+		if rp.terrain != nil {
+			ground, err = rp.terrain.ProvideValue(l)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return a.hazard(cell, ground, rp.times, rp.info.Threshold)
+	}
+	return nil, NoDataHazardError{Input: "the location is not in a 2D flow area"}
+}
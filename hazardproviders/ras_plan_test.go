@@ -0,0 +1,259 @@
+package hazardproviders
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+)
+
+// twoByTwoMesh is a 2D flow area of four 10 unit square cells with a ghost cell on its perimeter.
+func twoByTwoMesh() rasFlowArea {
+	facePoints := make([][2]float64, 9)
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			facePoints[r*3+c] = [2]float64{float64(c) * 10, float64(r) * 10}
+		}
+	}
+	cellFacePoints := [][]int{{0, 1, 4, 3}, {1, 2, 5, 4}, {3, 4, 7, 6}, {4, 5, 8, 7}, {0, 1, -1, -1}}
+	return newRasFlowArea("perimeter 1", facePoints, cellFacePoints)
+}
+func TestRasFlowArea_Cell(t *testing.T) {
+	a := twoByTwoMesh()
+	for _, c := range []struct {
+		x, y float64
+		cell int
+	}{{5, 5, 0}, {15, 5, 1}, {5, 15, 2}, {15, 15, 3}} {
+		cell, ok := a.cell(c.x, c.y)
+		if !ok || cell != c.cell {
+			t.Errorf("expected %v, %v to be in cell %v, got %v %v", c.x, c.y, c.cell, cell, ok)
+		}
+	}
+	if _, ok := a.cell(25, 5); ok {
+		t.Error("expected a location outside the mesh to have no cell")
+	}
+}
+func TestCellVelocities(t *testing.T) {
+	faceCells := [][2]int{{0, 1}, {0, 2}, {1, 3}, {2, 3}, {0, -1}}
+	v := cellVelocities(4, faceCells, []float64{1, -3, 2, .5, 4})
+	if !reflect.DeepEqual(v, []float64{4, 2, 3, 2}) {
+		t.Errorf("expected [4 2 3 2], got %v", v)
+	}
+}
+func TestRasFlowArea_Hazard(t *testing.T) {
+	a := twoByTwoMesh()
+	a.maxWSE = []float64{12, 9, 11, 10}
+	a.minElevation = []float64{8, 10, 9, 9, math.NaN()}
+	a.maxVelocity = []float64{4, 2, 3, 2}
+	series := [][]float64{{8, 10, 12, 10, 8}, {9, 9, 9, 9, 9}}
+	a.wse = func(cell int) ([]float64, error) {
+		return series[cell], nil
+	}
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	e, err := a.hazard(0, a.minElevation[0], hourly(start, 5), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Depth() != 4 || e.Velocity() != 4 || e.DV() != 16 {
+		t.Errorf("expected a depth of 4, velocity of 4 and depth times velocity of 16, got %v %v %v", e.Depth(), e.Velocity(), e.DV())
+	}
+	//the depth crosses 1 at 00:30 and again at 03:30.
+	if !e.ArrivalTime().Equal(start.Add(30 * time.Minute)) {
+		t.Errorf("expected an arrival at 00:30, got %v", e.ArrivalTime())
+	}
+	if math.Abs(e.Duration()-3.0/24) > 1e-9 {
+		t.Errorf("expected 3 hours wet, got %v days", e.Duration())
+	}
+	for _, p := range []hazards.Parameter{hazards.Depth, hazards.Velocity, hazards.DV, hazards.ArrivalTime, hazards.Duration} {
+		if !e.Has(p) {
+			t.Errorf("expected the event to have %v, got %v", p, e.Parameters())
+		}
+	}
+	//a terrain above the cell's minimum elevation makes the location shallower.
+	e, err = a.hazard(0, 10, hourly(start, 5), 1)
+	if err != nil || e.Depth() != 2 {
+		t.Errorf("expected a depth of 2 above the terrain, got %v %v", e, err)
+	}
+	_, err = a.hazard(1, a.minElevation[1], hourly(start, 5), 1)
+	if err == nil {
+		t.Error("expected a cell whose water surface is below its minimum elevation to be dry")
+	}
+	_, err = a.hazard(4, 0, hourly(start, 5), 1)
+	if err == nil {
+		t.Error("expected a ghost cell to have no results")
+	}
+}
+func TestFlowAreaNames(t *testing.T) {
+	metadata := []string{
+		`SUBDATASET_1_NAME=HDF5:"plan.p01.hdf"://Geometry/2D_Flow_Areas/Perimeter_1/Cells_Center_Coordinate`,
+		`SUBDATASET_1_DESC=[5x2] //Geometry/2D_Flow_Areas/Perimeter_1/Cells_Center_Coordinate (64-bit floating-point)`,
+		`SUBDATASET_2_NAME=HDF5:"plan.p01.hdf"://Geometry/2D_Flow_Areas/Perimeter_1/FacePoints_Coordinate`,
+		`SUBDATASET_3_NAME=HDF5:"plan.p01.hdf"://Results/Unsteady/Output/Output_Blocks/Base_Output/Unsteady_Time_Series/Time`,
+	}
+	names := subdatasetNames(metadata)
+	if len(names) != 3 {
+		t.Errorf("expected three subdatasets, got %v", names)
+	}
+	h := rasHDF{subdatasets: names}
+	if !h.has(rasTime) || h.has(rasWSE) {
+		t.Error("expected the time dataset to be found by its path with spaces")
+	}
+	if areas := flowAreaNames(names); !reflect.DeepEqual(areas, []string{"Perimeter_1"}) {
+		t.Errorf("expected the area Perimeter_1, got %v", areas)
+	}
+}
+func TestRasPlanInfo_Validate(t *testing.T) {
+	info := HazardProviderInfo{RasPlan: &RasPlanInfo{FilePath: "/vsis3/bucket/plan.p01.hdf"}}
+	if err := info.Validate(); err != nil {
+		t.Errorf("expected a valid ras plan, got %v", err)
+	}
+	info.Hazards = []HazardProviderParameterAndPath{{Hazard: hazards.Depth, FilePath: "/vsis3/bucket/depth.tif"}}
+	if info.Validate() == nil {
+		t.Error("expected hazards and a ras plan together to be invalid")
+	}
+	if (RasPlanInfo{FilePath: "/vsis3/bucket/plan.p01.hdf", Threshold: -1}).Validate() == nil {
+		t.Error("expected a negative threshold to be invalid")
+	}
+}
+
+// rasDatasetsStandIn holds the rows of the datasets of a plan by path, it stands in for a plan hdf file.
+type rasDatasetsStandIn map[string][][]float64
+
+func (d rasDatasetsStandIn) flowAreas() []string {
+	parts := strings.SplitN(rasCellCenters, "{area}", 2)
+	names := make([]string, 0)
+	for p := range d {
+		if strings.HasPrefix(p, parts[0]) && strings.HasSuffix(p, parts[1]) {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(p, parts[0]), parts[1]))
+		}
+	}
+	return names
+}
+func (d rasDatasetsStandIn) has(path string) bool {
+	_, ok := d[path]
+	return ok
+}
+func (d rasDatasetsStandIn) read(path string) ([]float64, int, error) {
+	rows, ok := d[path]
+	if !ok {
+		return nil, 0, fmt.Errorf("no dataset %v", path)
+	}
+	values := make([]float64, 0)
+	for _, r := range rows {
+		values = append(values, r...)
+	}
+	return values, len(rows[0]), nil
+}
+func (d rasDatasetsStandIn) columns(path string) (func(col int) ([]float64, error), func(), error) {
+	rows, ok := d[path]
+	if !ok {
+		return nil, nil, fmt.Errorf("no dataset %v", path)
+	}
+	column := func(col int) ([]float64, error) {
+		values := make([]float64, len(rows))
+		for i, r := range rows {
+			values[i] = r[col]
+		}
+		return values, nil
+	}
+	return column, func() {}, nil
+}
+
+// twoByTwoPlan is a plan in meters of the two by two mesh, the time series is every six hours.
+func twoByTwoPlan() rasDatasetsStandIn {
+	area := func(p string) string {
+		return strings.ReplaceAll(p, "{area}", "perimeter 1")
+	}
+	facePoints := make([][]float64, 9)
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			facePoints[r*3+c] = []float64{float64(c) * 10, float64(r) * 10}
+		}
+	}
+	return rasDatasetsStandIn{
+		area(rasCellCenters):      {{5, 5}, {15, 5}, {5, 15}, {15, 15}, {5, 0}},
+		area(rasFacePoints):       facePoints,
+		area(rasCellFacePoints):   {{0, 1, 4, 3}, {1, 2, 5, 4}, {3, 4, 7, 6}, {4, 5, 8, 7}, {0, 1, -1, -1}},
+		area(rasCellMinElevation): {{2}, {3}, {2}, {2}, {0}},
+		area(rasMaxWSE):           {{3, 3, 2.5, 2}, {.5, .5, .5, .5}},
+		area(rasFaceCells):        {{0, 1}, {0, 2}, {1, 3}, {2, 3}, {0, -1}},
+		area(rasMaxFaceVelocity):  {{1, -3, 2, .5, 4}, {.5, .5, .5, .5, .5}},
+		rasTime:                   {{0}, {.25}, {.5}, {.75}, {1}},
+		area(rasWSE):              {{2, 3, 2, 2, 0}, {2.5, 3, 2, 2, 0}, {3, 3, 2, 2, 0}, {2.5, 3, 2, 2, 0}, {2, 3, 2, 2, 0}},
+	}
+}
+func TestRasPlan_Load(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	rp := rasPlanHazardProvider{info: RasPlanInfo{FlowAreas: []string{"perimeter 1"}, Threshold: 1, VerticalIsMeters: true}, startTime: start, transform: projection.NewCache("")}
+	err := rp.load(twoByTwoPlan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.times) != 5 || !rp.times[1].Equal(start.Add(6*time.Hour)) || !rp.times[4].Equal(start.Add(24*time.Hour)) {
+		t.Errorf("expected times every six hours for a day, got %v", rp.times)
+	}
+	e, err := rp.Hazard(geography.Location{X: 5, Y: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feet := 3.28084
+	if math.Abs(e.Depth()-feet) > 1e-9 || math.Abs(e.Velocity()-4*feet) > 1e-9 {
+		t.Errorf("expected a depth of %v and velocity of %v feet, got %v %v", feet, 4*feet, e.Depth(), e.Velocity())
+	}
+	//the depth rises half a meter every six hours to a meter at noon, it exceeds a foot from the time it rises a foot until it falls below one.
+	rise := time.Duration(6 * float64(time.Hour) / (.5 * feet))
+	if math.Abs(e.ArrivalTime().Sub(start.Add(rise)).Seconds()) > 1 {
+		t.Errorf("expected an arrival at %v, got %v", start.Add(rise), e.ArrivalTime())
+	}
+	if expected := (24*time.Hour - 2*rise).Hours() / 24; math.Abs(e.Duration()-expected) > 1e-6 {
+		t.Errorf("expected %v days wet, got %v", expected, e.Duration())
+	}
+	short := twoByTwoPlan()
+	short[strings.ReplaceAll(rasCellMinElevation, "{area}", "perimeter 1")] = [][]float64{{2}, {3}}
+	rp = rasPlanHazardProvider{info: RasPlanInfo{FlowAreas: []string{"perimeter 1"}}, startTime: start, transform: projection.NewCache("")}
+	if rp.load(short) == nil {
+		t.Error("expected fewer minimum elevations than cells to be an error")
+	}
+	//without listed flow areas every area with cell geometry is read.
+	rp = rasPlanHazardProvider{info: RasPlanInfo{}, startTime: start, transform: projection.NewCache("")}
+	err = rp.load(twoByTwoPlan())
+	if err != nil || len(rp.areas) != 1 || rp.areas[0].name != "perimeter 1" {
+		t.Errorf("expected the plan's only flow area to be read, got %v %v", rp.areas, err)
+	}
+	empty := twoByTwoPlan()
+	delete(empty, strings.ReplaceAll(rasCellCenters, "{area}", "perimeter 1"))
+	rp = rasPlanHazardProvider{info: RasPlanInfo{}, startTime: start, transform: projection.NewCache("")}
+	if rp.load(empty) == nil {
+		t.Error("expected a plan without 2D flow areas to be an error")
+	}
+}
+
+// testdata/perimeter.p01.hdf is the two by two plan written as a HEC-RAS plan hdf file, its flow area is named Perimeter 1.
+func TestInitRasPlan(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	rp, err := InitRasPlan(RasPlanInfo{FilePath: "testdata/perimeter.p01.hdf", Threshold: 1, VerticalIsMeters: true}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	if len(rp.areas) != 1 || len(rp.times) != 5 {
+		t.Fatalf("expected one flow area and five times, got %v areas and %v times", len(rp.areas), len(rp.times))
+	}
+	e, err := rp.Hazard(geography.Location{X: 5, Y: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feet := 3.28084
+	if math.Abs(e.Depth()-feet) > 1e-6 || math.Abs(e.Velocity()-4*feet) > 1e-6 {
+		t.Errorf("expected a depth of %v and velocity of %v feet, got %v %v", feet, 4*feet, e.Depth(), e.Velocity())
+	}
+	if !e.Has(hazards.ArrivalTime) || !e.Has(hazards.Duration) {
+		t.Errorf("expected an arrival time and duration from the time series, got %v", e.Parameters())
+	}
+}
@@ -0,0 +1,188 @@
+package hazardproviders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/USACE/go-consequences/geography"
+)
+
+// SamplingMethod is how a raster is sampled at a location.
+type SamplingMethod string
+
+const (
+	Nearest          SamplingMethod = "nearest"            //the cell containing the location
+	Bilinear         SamplingMethod = "bilinear"           //interpolated between the centers of the four nearest cells
+	MaxWithinRadius  SamplingMethod = "max_within_radius"  //the largest value of the cells centered within the radius
+	MeanWithinRadius SamplingMethod = "mean_within_radius" //the mean value of the cells centered within the radius
+	MaxOverFootprint SamplingMethod = "max_over_footprint" //the largest value of the cells centered within the location's footprint, or within a square footprint_width wide where the location has none
+)
+
+// Sampling selects how a raster is sampled at a location, the zero value reads the cell containing the location. Cells holding the no data value are skipped, a location has no data if the cell containing it has none for nearest and bilinear sampling, or if every cell sampled has none otherwise. The cell containing the location is always sampled, so a radius or footprint smaller than a cell behaves like nearest.
+type Sampling struct {
+	Method         SamplingMethod `json:"method,omitempty"`
+	Radius         float64        `json:"radius,omitempty"`          //in the units of the raster's reference system
+	FootprintWidth float64        `json:"footprint_width,omitempty"` //in the units of the raster's reference system, the width of the square footprint centered on a location that does not have a footprint, such as a structure read from points rather than building polygons
+}
+
+// Validate checks the method is known and has the distances it needs.
+func (s Sampling) Validate() error {
+	switch s.Method {
+	case "", Nearest, Bilinear:
+	case MaxWithinRadius, MeanWithinRadius:
+		if s.Radius <= 0 {
+			return fmt.Errorf("hazardproviders: sampling method %v requires a positive radius", s.Method)
+		}
+	case MaxOverFootprint:
+		if s.FootprintWidth <= 0 {
+			return errors.New("hazardproviders: sampling method max_over_footprint requires a positive footprint_width for locations without a footprint")
+		}
+	default:
+		return fmt.Errorf("hazardproviders: unknown sampling method %v", s.Method)
+	}
+	return nil
+}
+
+// cellWindow is the block of cells read to sample a location, weights holds the weight of each cell row by row and cells with no weight are not sampled.
+type cellWindow struct {
+	x0, y0        int
+	width, height int
+	weights       []float64
+	center        int //index of the cell containing the location
+}
+type cell struct {
+	x, y   int
+	weight float64
+}
+
+// toPixel converts a location to fractional pixel coordinates with the inverse geo transform of a raster.
+func toPixel(igt [6]float64, x float64, y float64) (float64, float64) {
+	return igt[0] + x*igt[1] + y*igt[2], igt[3] + x*igt[4] + y*igt[5]
+}
+
+// window finds the cells of an xsize by ysize raster sampled at l, it is a NoDataHazardError if l is not on the raster.
+func (s Sampling) window(l geography.Location, igt [6]float64, xsize int, ysize int) (cellWindow, error) {
+	fx, fy := toPixel(igt, l.X, l.Y)
+	px, py := int(math.Floor(fx)), int(math.Floor(fy))
+	if px < 0 || px >= xsize {
+		return cellWindow{}, NoDataHazardError{Input: "X is out of range"}
+	}
+	if py < 0 || py >= ysize {
+		return cellWindow{}, NoDataHazardError{Input: "Y is out of range"}
+	}
+	cells := []cell{{x: px, y: py, weight: 1}}
+	inRaster := func(x, y int) bool {
+		return x >= 0 && x < xsize && y >= 0 && y < ysize && !(x == px && y == py)
+	}
+	switch s.Method {
+	case Bilinear:
+		u, v := fx-.5, fy-.5
+		x0, y0 := math.Floor(u), math.Floor(v)
+		dx, dy := u-x0, v-y0
+		cells = cells[:0]
+		for _, c := range []cell{
+			{x: int(x0), y: int(y0), weight: (1 - dx) * (1 - dy)},
+			{x: int(x0) + 1, y: int(y0), weight: dx * (1 - dy)},
+			{x: int(x0), y: int(y0) + 1, weight: (1 - dx) * dy},
+			{x: int(x0) + 1, y: int(y0) + 1, weight: dx * dy},
+		} {
+			//the cell containing the location is one of the four and always has a weight of at least a quarter.
+			if c.weight > 0 && (inRaster(c.x, c.y) || (c.x == px && c.y == py)) {
+				cells = append(cells, c)
+			}
+		}
+	case MaxWithinRadius, MeanWithinRadius:
+		rx, ry := s.Radius*math.Hypot(igt[1], igt[2]), s.Radius*math.Hypot(igt[4], igt[5])
+		for y := int(math.Floor(fy - ry)); y <= int(math.Floor(fy+ry)); y++ {
+			for x := int(math.Floor(fx - rx)); x <= int(math.Floor(fx+rx)); x++ {
+				ex, ey := (float64(x)+.5-fx)/rx, (float64(y)+.5-fy)/ry
+				if inRaster(x, y) && ex*ex+ey*ey <= 1 {
+					cells = append(cells, cell{x: x, y: y, weight: 1})
+				}
+			}
+		}
+	case MaxOverFootprint:
+		ring := l.Footprint
+		if len(ring) < 3 {
+			h := s.FootprintWidth / 2
+			ring = []geography.Location{{X: l.X - h, Y: l.Y - h}, {X: l.X + h, Y: l.Y - h}, {X: l.X + h, Y: l.Y + h}, {X: l.X - h, Y: l.Y + h}}
+		}
+		polygon := make([][2]float64, len(ring))
+		minx, miny, maxx, maxy := fx, fy, fx, fy
+		for i, p := range ring {
+			x, y := toPixel(igt, p.X, p.Y)
+			polygon[i] = [2]float64{x, y}
+			minx, maxx = math.Min(minx, x), math.Max(maxx, x)
+			miny, maxy = math.Min(miny, y), math.Max(maxy, y)
+		}
+		for y := int(math.Floor(miny)); y <= int(math.Floor(maxy)); y++ {
+			for x := int(math.Floor(minx)); x <= int(math.Floor(maxx)); x++ {
+				if inRaster(x, y) && geography.RingContains(float64(x)+.5, float64(y)+.5, polygon) {
+					cells = append(cells, cell{x: x, y: y, weight: 1})
+				}
+			}
+		}
+	}
+	return newCellWindow(px, py, cells), nil
+}
+
+// newCellWindow is the smallest window holding cells, px and py are the cell containing the location.
+func newCellWindow(px int, py int, cells []cell) cellWindow {
+	x0, y0, x1, y1 := px, py, px, py
+	for _, c := range cells {
+		x0, y0 = min(x0, c.x), min(y0, c.y)
+		x1, y1 = max(x1, c.x), max(y1, c.y)
+	}
+	w := cellWindow{x0: x0, y0: y0, width: x1 - x0 + 1, height: y1 - y0 + 1}
+	w.weights = make([]float64, w.width*w.height)
+	for _, c := range cells {
+		w.weights[(c.y-y0)*w.width+c.x-x0] = c.weight
+	}
+	w.center = (py-y0)*w.width + px - x0
+	return w
+}
+
+// readCells converts a buffer read for a window to values, valid is false for cells holding the no data value.
+func readCells(buffer []float32, nodata float64) ([]float64, []bool) {
+	values := make([]float64, len(buffer))
+	valid := make([]bool, len(buffer))
+	for i, v := range buffer {
+		values[i] = float64(v)
+		valid[i] = values[i] != nodata
+	}
+	return values, valid
+}
+
+// sample combines the values read for the window, ok is false if the location has no data.
+func (s Sampling) sample(w cellWindow, values []float64, valid []bool) (float64, bool) {
+	switch s.Method {
+	case MaxWithinRadius, MaxOverFootprint:
+		found := false
+		m := math.Inf(-1)
+		for i, weight := range w.weights {
+			if weight > 0 && valid[i] {
+				m = math.Max(m, values[i])
+				found = true
+			}
+		}
+		return m, found
+	case Bilinear, MeanWithinRadius:
+		if s.Method == Bilinear && !valid[w.center] {
+			return values[w.center], false
+		}
+		sum, total := 0.0, 0.0
+		for i, weight := range w.weights {
+			if weight > 0 && valid[i] {
+				sum += weight * values[i]
+				total += weight
+			}
+		}
+		if total == 0 {
+			return 0, false
+		}
+		return sum / total, true
+	default:
+		return values[w.center], valid[w.center]
+	}
+}
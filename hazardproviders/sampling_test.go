@@ -0,0 +1,140 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/geography"
+)
+
+// grid is a 4 by 4 raster with 10 unit cells whose upper left corner is at 0, 40, -9999 is no data.
+var grid = []float64{
+	1, 2, 3, 4,
+	5, 6, 7, 8,
+	9, 10, -9999, 12,
+	13, 14, 15, 16,
+}
+var gridIgt = [6]float64{0, .1, 0, 4, 0, -.1}
+
+// sampleGrid samples grid at l as a cogReader would.
+func sampleGrid(s Sampling, l geography.Location) (float64, error) {
+	w, err := s.window(l, gridIgt, 4, 4)
+	if err != nil {
+		return 0, err
+	}
+	buffer := make([]float32, 0, w.width*w.height)
+	for y := w.y0; y < w.y0+w.height; y++ {
+		for x := w.x0; x < w.x0+w.width; x++ {
+			buffer = append(buffer, float32(grid[y*4+x]))
+		}
+	}
+	values, valid := readCells(buffer, -9999)
+	v, ok := s.sample(w, values, valid)
+	if !ok {
+		return v, NoDataHazardError{Input: "no data"}
+	}
+	return v, nil
+}
+func TestSampling_Nearest(t *testing.T) {
+	v, err := sampleGrid(Sampling{}, geography.Location{X: 19.9, Y: 20.1})
+	if err != nil || v != 6 {
+		t.Errorf("expected 6, got %v %v", v, err)
+	}
+	//the last row and column are on the raster, the first cell past them is not.
+	_, err = sampleGrid(Sampling{}, geography.Location{X: 39.5, Y: .5})
+	if err != nil {
+		t.Errorf("expected the last cell to be on the raster, got %v", err)
+	}
+	for _, l := range []geography.Location{{X: 40, Y: 5}, {X: 5, Y: 0}, {X: -.5, Y: 5}} {
+		_, err = sampleGrid(Sampling{Method: Nearest}, l)
+		if !errors.As(err, &NoDataHazardError{}) {
+			t.Errorf("expected %v to be off the raster, got %v", l, err)
+		}
+	}
+	_, err = sampleGrid(Sampling{}, geography.Location{X: 25, Y: 15})
+	if err == nil {
+		t.Error("expected the no data cell to have no data")
+	}
+}
+func TestSampling_Bilinear(t *testing.T) {
+	s := Sampling{Method: Bilinear}
+	//half way between the centers of the cells holding 1, 2, 5 and 6.
+	v, err := sampleGrid(s, geography.Location{X: 10, Y: 30})
+	if err != nil || v != 3.5 {
+		t.Errorf("expected 3.5, got %v %v", v, err)
+	}
+	//at a cell center the value is the cell's.
+	v, err = sampleGrid(s, geography.Location{X: 15, Y: 25})
+	if err != nil || v != 6 {
+		t.Errorf("expected 6, got %v %v", v, err)
+	}
+	//in the corner cell only the cell itself is on the raster.
+	v, err = sampleGrid(s, geography.Location{X: 1, Y: 39})
+	if err != nil || v != 1 {
+		t.Errorf("expected 1, got %v %v", v, err)
+	}
+	//the no data neighbor is skipped and the weights of 6, 7 and 10 are rescaled.
+	v, err = sampleGrid(s, geography.Location{X: 19, Y: 21})
+	if err != nil || math.Abs(v-52.0/7) > 1e-9 {
+		t.Errorf("expected 52/7, got %v %v", v, err)
+	}
+	//a location in a no data cell has no data.
+	_, err = sampleGrid(s, geography.Location{X: 20, Y: 20})
+	if err == nil {
+		t.Error("expected the no data cell to have no data")
+	}
+}
+func TestSampling_Radius(t *testing.T) {
+	l := geography.Location{X: 15, Y: 25}
+	v, err := sampleGrid(Sampling{Method: MaxWithinRadius, Radius: 10}, l)
+	if err != nil || v != 10 {
+		t.Errorf("expected the largest of 2, 5, 6, 7 and 10, got %v %v", v, err)
+	}
+	v, err = sampleGrid(Sampling{Method: MeanWithinRadius, Radius: 10}, l)
+	if err != nil || v != 6 {
+		t.Errorf("expected the mean of 2, 5, 6, 7 and 10, got %v %v", v, err)
+	}
+	//a radius smaller than a cell samples the cell containing the location.
+	v, err = sampleGrid(Sampling{Method: MaxWithinRadius, Radius: 1}, l)
+	if err != nil || v != 6 {
+		t.Errorf("expected 6, got %v %v", v, err)
+	}
+	//the no data cell is skipped rather than making the location dry.
+	v, err = sampleGrid(Sampling{Method: MaxWithinRadius, Radius: 10}, geography.Location{X: 25, Y: 15})
+	if err != nil || v != 15 {
+		t.Errorf("expected 15, got %v %v", v, err)
+	}
+}
+func TestSampling_Footprint(t *testing.T) {
+	s := Sampling{Method: MaxOverFootprint, FootprintWidth: 12}
+	v, err := sampleGrid(s, geography.Location{X: 10, Y: 30})
+	if err != nil || v != 6 {
+		t.Errorf("expected the largest of 1, 2, 5 and 6, got %v %v", v, err)
+	}
+	//a triangular footprint covering the centers of 5, 9, 13 and 14 but not 15.
+	l := geography.Location{X: 5, Y: 25, Footprint: []geography.Location{{X: 0, Y: 40}, {X: 0, Y: 0}, {X: 20, Y: 0}}}
+	v, err = sampleGrid(s, l)
+	if err != nil || v != 14 {
+		t.Errorf("expected 14, got %v %v", v, err)
+	}
+}
+func TestSampling_Validate(t *testing.T) {
+	var h HazardProviderParameterAndPath
+	err := json.Unmarshal([]byte(`{"hazard_parameter_type": "depth", "hazard_provider_file_path": "/vsis3/bucket/depth.tif", "sampling": {"method": "mean_within_radius", "radius": 5}}`), &h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Errorf("expected a valid hazard, got %v", err)
+	}
+	if h.Sampling.Method != MeanWithinRadius || h.Sampling.Radius != 5 {
+		t.Errorf("expected mean within 5, got %+v", h.Sampling)
+	}
+	for _, s := range []Sampling{{Method: MaxWithinRadius}, {Method: "cubic"}, {Method: MaxOverFootprint, FootprintWidth: -1}, {Method: MaxOverFootprint}} {
+		if s.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", s)
+		}
+	}
+}
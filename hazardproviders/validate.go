@@ -0,0 +1,95 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// parameterName is a hazard_parameter_type as written in a configuration. Unrecognized names unmarshal to the default parameter, so the types that read one keep the name for Validate to check.
+type parameterName string
+
+// check is an error if the name is set and is not a hazard parameter.
+func (n parameterName) check() error {
+	if n == "" {
+		return nil
+	}
+	_, err := hazards.ParseParameter(string(n))
+	return err
+}
+
+// unmarshalNamed unmarshals data into v, a pointer to a type with the fields but not the UnmarshalJSON method of the type being unmarshaled, and keeps its hazard_parameter_type as written in name.
+func unmarshalNamed(data []byte, v interface{}, name *parameterName) error {
+	var raw struct {
+		Hazard parameterName `json:"hazard_parameter_type"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*name = raw.Hazard
+	return json.Unmarshal(data, v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see parameterName.
+func (h *HazardProviderParameterAndPath) UnmarshalJSON(b []byte) error {
+	type plain HazardProviderParameterAndPath
+	return unmarshalNamed(b, (*plain)(h), &h.name)
+}
+
+// Validate checks the hazard parameter name, the sampling method, the water surface settings and that the files exist, without opening them.
+func (h HazardProviderParameterAndPath) Validate() error {
+	errs := []error{h.name.check()}
+	if h.Hazard&hazards.ClassParameters != 0 {
+		errs = append(errs, fmt.Errorf("hazardproviders: %v is classified from other parameters and cannot be read from a raster", h.Hazard))
+	}
+	errs = append(errs, h.Sampling.Validate(), h.validateWaterSurface(), filepaths.Check("hazard_provider_file_path", h.FilePath))
+	return errors.Join(errs...)
+}
+
+// Validate reports every problem with the hazards it can find without opening a dataset.
+func (info HazardProviderInfo) Validate() error {
+	sources := 0
+	for _, provided := range []bool{len(info.Hazards) > 0, info.MultiBand != nil, info.RasPlan != nil, info.Mosaic != nil, info.Vector != nil, info.Gridded != nil} {
+		if provided {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("hazardproviders: only one of hazards, multi_band, ras_plan, mosaic, vector and gridded can be provided")
+	}
+	if info.MultiBand != nil {
+		return info.MultiBand.Validate()
+	}
+	if info.RasPlan != nil {
+		return info.RasPlan.Validate()
+	}
+	if info.Mosaic != nil {
+		return info.Mosaic.Validate()
+	}
+	if info.Vector != nil {
+		return info.Vector.Validate()
+	}
+	if info.Gridded != nil {
+		return info.Gridded.Validate()
+	}
+	if len(info.Hazards) == 0 {
+		return errors.New("hazardproviders: at least one hazard is required")
+	}
+	errs := make([]error, 0)
+	parameters := make(map[hazards.Parameter]bool)
+	for i, h := range info.Hazards {
+		err := h.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hazard %v: %w", i, err))
+		}
+		if parameters[h.Hazard] {
+			errs = append(errs, fmt.Errorf("hazard %v: hazardproviders: %v is provided more than once", i, h.Hazard))
+		}
+		parameters[h.Hazard] = true
+	}
+	return errors.Join(errs...)
+}
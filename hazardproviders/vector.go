@@ -0,0 +1,224 @@
+package hazardproviders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/USACE/go-consequences/vectorlayer"
+)
+
+// VectorField maps a field of a vector layer to a hazard parameter. Qualitative fields are read as text, salinity as an integer that is true if not zero, arrival time as hours after the hazard's start time and everything else as a number.
+type VectorField struct {
+	Hazard hazards.Parameter `json:"hazard_parameter_type"`
+	Field  string            `json:"field"`
+	name   parameterName
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see parameterName.
+func (v *VectorField) UnmarshalJSON(data []byte) error {
+	type plain VectorField
+	return unmarshalNamed(data, (*plain)(v), &v.name)
+}
+
+// VectorInfo describes a point or polygon layer of hazards read with ogr. A location takes the fields of the first polygon containing it, or of the nearest point if the layer has points. The layer is read into memory when the provider is created.
+type VectorInfo struct {
+	FilePath     string        `json:"file_path"`
+	Driver       string        `json:"driver"`                  //ogr driver, such as GPKG, ESRI Shapefile or GeoJSON
+	LayerName    string        `json:"layername,omitempty"`     //the first layer if not set
+	Fields       []VectorField `json:"fields"`                  //fields read as hazard parameters
+	SearchRadius float64       `json:"search_radius,omitempty"` //in the units of the layer's reference system, the nearest point must be within it, any distance if not set
+}
+
+// Validate reports every problem with the vector layer it can find without opening it.
+func (info VectorInfo) Validate() error {
+	errs := []error{filepaths.Check("file_path", info.FilePath)}
+	if info.Driver == "" {
+		errs = append(errs, errors.New("hazardproviders: a vector hazard requires an ogr driver"))
+	}
+	if info.SearchRadius < 0 {
+		errs = append(errs, errors.New("hazardproviders: search_radius must not be negative"))
+	}
+	if len(info.Fields) == 0 {
+		errs = append(errs, errors.New("hazardproviders: a vector hazard requires at least one field"))
+	}
+	for _, f := range info.Fields {
+		errs = append(errs, f.name.check())
+		if f.Field == "" {
+			errs = append(errs, fmt.Errorf("hazardproviders: vector hazard parameter %v requires a field", f.Hazard))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// vectorFeature is the geometry and hazard of one feature, points or polygon rings in the layer's reference system.
+type vectorFeature struct {
+	points [][2]float64
+	rings  [][][2]float64 //every ring of every polygon, holes included, a location is inside by the even odd rule over all of them
+	bbox   [4]float64     //min x, min y, max x, max y
+	hazard hazards.HazardData
+}
+
+// newVectorFeature computes the bounds of a feature.
+func newVectorFeature(points [][2]float64, rings [][][2]float64, hazard hazards.HazardData) vectorFeature {
+	f := vectorFeature{points: points, rings: rings, hazard: hazard, bbox: [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+	extend := func(p [2]float64) {
+		f.bbox[0], f.bbox[1] = math.Min(f.bbox[0], p[0]), math.Min(f.bbox[1], p[1])
+		f.bbox[2], f.bbox[3] = math.Max(f.bbox[2], p[0]), math.Max(f.bbox[3], p[1])
+	}
+	for _, p := range points {
+		extend(p)
+	}
+	for _, r := range rings {
+		for _, p := range r {
+			extend(p)
+		}
+	}
+	return f
+}
+
+// contains is true if x, y is inside the feature's polygons.
+func (f vectorFeature) contains(x float64, y float64) bool {
+	if len(f.rings) == 0 || x < f.bbox[0] || x > f.bbox[2] || y < f.bbox[1] || y > f.bbox[3] {
+		return false
+	}
+	inside := false
+	for _, r := range f.rings {
+		if geography.RingContains(x, y, r) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distance is the distance from x, y to the feature's nearest point, infinite if it has none.
+func (f vectorFeature) distance(x float64, y float64) float64 {
+	d := math.Inf(1)
+	for _, p := range f.points {
+		d = math.Min(d, math.Hypot(p[0]-x, p[1]-y))
+	}
+	return d
+}
+
+// lookupVector finds the hazard of the first feature containing x, y, or the nearest point within radius, a radius of zero is any distance.
+func lookupVector(features []vectorFeature, x float64, y float64, radius float64) (hazards.HazardData, bool) {
+	nearest, best := -1, math.Inf(1)
+	for i, f := range features {
+		if f.contains(x, y) {
+			return f.hazard, true
+		}
+		if d := f.distance(x, y); d < best && (radius == 0 || d <= radius) {
+			nearest, best = i, d
+		}
+	}
+	if nearest < 0 {
+		return hazards.HazardData{}, false
+	}
+	return features[nearest].hazard, true
+}
+
+type vectorHazardProvider struct {
+	features   []vectorFeature
+	srid       string
+	bbox       geography.BBox
+	radius     float64
+	transforms *projection.Cache //transforms locations into the layer's reference system
+}
+
+// InitVector reads every feature of a vector layer with its hazard, the layer is closed once it is read.
+func InitVector(info VectorInfo, startTime time.Time) (vectorHazardProvider, error) {
+	err := info.Validate()
+	if err != nil {
+		return vectorHazardProvider{}, err
+	}
+	ds, l, err := vectorlayer.Open(info.Driver, info.FilePath, info.LayerName)
+	if err != nil {
+		return vectorHazardProvider{}, fmt.Errorf("vector hazard: %w", err)
+	}
+	defer ds.Destroy()
+	def := l.Definition()
+	idxs := make([]int, len(info.Fields))
+	for i, f := range info.Fields {
+		idxs[i] = def.FieldIndex(f.Field)
+		if idxs[i] < 0 {
+			return vectorHazardProvider{}, errors.New("hazardproviders: vector hazard " + info.FilePath + " expected field named " + f.Field + " none was found")
+		}
+	}
+	srid := vectorlayer.SpatialReference(l)
+	features := make([]vectorFeature, 0)
+	l.ResetReading()
+	for f := l.NextFeature(); f != nil; f = l.NextFeature() {
+		hd := emptyHazardData()
+		for i, vf := range info.Fields {
+			if !f.IsFieldSetAndNotNull(idxs[i]) {
+				continue
+			}
+			switch vf.Hazard {
+			case hazards.Qualitative:
+				hd.SetParameter(vf.Hazard, f.FieldAsString(idxs[i]))
+			case hazards.Salinity:
+				hd.SetParameter(vf.Hazard, f.FieldAsInteger(idxs[i]) != 0)
+			default:
+				setHazardValue(&hd, vf.Hazard, f.FieldAsFloat64(idxs[i]), startTime)
+			}
+		}
+		g := f.Geometry()
+		if !g.IsNull() && !g.IsEmpty() {
+			points, rings := vectorlayer.ReadGeometry(g, nil, nil)
+			if len(points) > 0 || len(rings) > 0 {
+				features = append(features, newVectorFeature(points, rings, hd))
+			}
+		}
+		f.Destroy()
+	}
+	if len(features) == 0 {
+		return vectorHazardProvider{}, errors.New("hazardproviders: vector hazard " + info.FilePath + " has no point or polygon features")
+	}
+	return newVectorHazardProvider(features, srid, info.SearchRadius), nil
+}
+func newVectorHazardProvider(features []vectorFeature, srid string, radius float64) vectorHazardProvider {
+	extent := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, f := range features {
+		extent[0], extent[1] = math.Min(extent[0], f.bbox[0]), math.Min(extent[1], f.bbox[1])
+		extent[2], extent[3] = math.Max(extent[2], f.bbox[2]), math.Max(extent[3], f.bbox[3])
+	}
+	//points reach as far as the search radius.
+	extent[0], extent[1], extent[2], extent[3] = extent[0]-radius, extent[1]-radius, extent[2]+radius, extent[3]+radius
+	return vectorHazardProvider{
+		features:   features,
+		srid:       srid,
+		bbox:       geography.BBox{Bbox: []float64{extent[0], extent[3], extent[2], extent[1]}, SRID: srid},
+		radius:     radius,
+		transforms: projection.NewCache(srid),
+	}
+}
+func (vp vectorHazardProvider) Close() {
+	vp.transforms.Close()
+}
+
+// Clone implements CloneableHazardProvider, the features are shared and only read.
+func (vp vectorHazardProvider) Clone() (HazardProvider, error) {
+	return newVectorHazardProvider(vp.features, vp.srid, vp.radius), nil
+}
+
+// HazardBoundary is the extent of the layer, widened by the search radius.
+func (vp vectorHazardProvider) HazardBoundary() (geography.BBox, error) {
+	return vp.bbox, nil
+}
+func (vp vectorHazardProvider) Hazard(l geography.Location) (hazards.HazardEvent, error) {
+	var h hazards.HazardEvent
+	l, err := vp.transforms.Location(l)
+	if err != nil {
+		return h, err
+	}
+	hd, ok := lookupVector(vp.features, l.X, l.Y, vp.radius)
+	if !ok {
+		return h, NoHazardFoundError{Input: "the location is not in a polygon or near a point of the vector hazard"}
+	}
+	return hazards.HazardDataToMultiParameter(hd), nil
+}
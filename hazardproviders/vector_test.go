@@ -0,0 +1,95 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// surgeZones is a category 1 zone of 0 to 10 with a hole from 4 to 6, a category 2 zone beside it and two high water marks.
+func surgeZones() []vectorFeature {
+	square := func(x0, y0, x1, y1 float64) [][2]float64 {
+		return [][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	}
+	zone := func(category string, rings ...[][2]float64) vectorFeature {
+		hd := emptyHazardData()
+		hd.Qualitative = category
+		return newVectorFeature(nil, rings, hd)
+	}
+	mark := func(x, y, depth float64) vectorFeature {
+		hd := emptyHazardData()
+		hd.Depth = depth
+		return newVectorFeature([][2]float64{{x, y}}, nil, hd)
+	}
+	return []vectorFeature{
+		zone("category 1", square(0, 0, 10, 10), square(4, 4, 6, 6)),
+		zone("category 2", square(10, 0, 20, 10)),
+		mark(30, 5, 2),
+		mark(40, 5, 3),
+	}
+}
+func TestLookupVector(t *testing.T) {
+	features := surgeZones()
+	for _, c := range []struct {
+		x, y        float64
+		qualitative string
+		depth       float64
+	}{
+		{2, 2, "category 1", -901},
+		{15, 5, "category 2", -901},
+		{5, 5, "", 2},
+		{34, 5, "", 2},
+		{37, 5, "", 3},
+	} {
+		hd, ok := lookupVector(features, c.x, c.y, 0)
+		if !ok || hd.Qualitative != c.qualitative || hd.Depth != c.depth {
+			t.Errorf("expected %v %v at %v, %v, got %+v %v", c.qualitative, c.depth, c.x, c.y, hd, ok)
+		}
+	}
+	if _, ok := lookupVector(features, 50, 5, 5); ok {
+		t.Error("expected no hazard farther than the search radius from every point")
+	}
+}
+func TestVectorHazardProvider(t *testing.T) {
+	vp := newVectorHazardProvider(surgeZones(), "", 1)
+	e, err := vp.Hazard(geography.Location{X: 2, Y: 2})
+	if err != nil || !e.Has(hazards.Qualitative) || e.Qualitative() != "category 1" {
+		t.Errorf("expected a category 1 event, got %v %v", e, err)
+	}
+	_, err = vp.Hazard(geography.Location{X: 25, Y: 5})
+	if !errors.As(err, &NoHazardFoundError{}) {
+		t.Errorf("expected no hazard between the zones and the marks, got %v", err)
+	}
+	bbox, err := vp.HazardBoundary()
+	if err != nil || !reflect.DeepEqual(bbox.Bbox, []float64{-1, 11, 41, -1}) {
+		t.Errorf("expected the layer extent widened by the radius, got %v %v", bbox, err)
+	}
+}
+func TestVectorInfo_Validate(t *testing.T) {
+	var info HazardProviderInfo
+	err := json.Unmarshal([]byte(`{"vector": {"file_path": "/vsis3/bucket/surge.gpkg", "driver": "GPKG", "fields": [{"hazard_parameter_type": "qualitative", "field": "category"}]}}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Validate(); err != nil {
+		t.Errorf("expected a valid vector hazard, got %v", err)
+	}
+	for _, v := range []VectorInfo{
+		{FilePath: "/vsis3/bucket/surge.gpkg", Fields: []VectorField{{Hazard: hazards.Depth, Field: "depth"}}},
+		{FilePath: "/vsis3/bucket/surge.gpkg", Driver: "GPKG"},
+		{FilePath: "/vsis3/bucket/surge.gpkg", Driver: "GPKG", Fields: []VectorField{{Hazard: hazards.Depth}}},
+		{FilePath: "/vsis3/bucket/surge.gpkg", Driver: "GPKG", Fields: []VectorField{{Hazard: hazards.Depth, Field: "depth"}}, SearchRadius: -1},
+	} {
+		if v.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", v)
+		}
+		if _, err := InitVector(v, time.Time{}); err == nil {
+			t.Errorf("expected %+v to be rejected before the layer is opened", v)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package hazardproviders
+
+import (
+	"errors"
+
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// WaterSurfaceElevation marks a depth raster as holding water surface elevations. Depth at a location is the water surface less the location's ground elevation, or less a terrain sample when the location has none.
+type WaterSurfaceElevation struct {
+	DatumOffset     float64 `json:"datum_offset,omitempty"`      //added to the water surface to put it in the vertical datum of the structure ground elevations, not applied to the terrain
+	TerrainFilePath string  `json:"terrain_file_path,omitempty"` //ground elevation raster in the vertical datum of the water surface, used for locations without a ground elevation
+}
+
+// Validate checks the terrain exists, without opening it.
+func (w WaterSurfaceElevation) Validate() error {
+	if w.TerrainFilePath == "" {
+		return nil
+	}
+	return filepaths.Check("terrain_file_path", w.TerrainFilePath)
+}
+
+// depthAboveGround converts a water surface elevation at l to a depth, terrain samples the ground when l has no elevation and is nil if there is no terrain. A water surface at or below the ground is a NoHazardFoundError.
+func (w WaterSurfaceElevation) depthAboveGround(wse float64, l geography.Location, terrain func(geography.Location) (float64, error)) (float64, error) {
+	var depth float64
+	if l.HasZ {
+		depth = wse + w.DatumOffset - l.Z
+	} else {
+		if terrain == nil {
+			return 0, NoDataHazardError{Input: "the location has no ground elevation and there is no terrain"}
+		}
+		ground, err := terrain(l)
+		if err != nil {
+			return 0, err
+		}
+		depth = wse - ground
+	}
+	if depth <= 0 {
+		return 0, NoHazardFoundError{Input: "the water surface is at or below the ground"}
+	}
+	return depth, nil
+}
+
+// validateWaterSurface checks a water surface is only given for depth.
+func (h HazardProviderParameterAndPath) validateWaterSurface() error {
+	if h.WaterSurface == nil {
+		return nil
+	}
+	if h.Hazard != hazards.Depth {
+		return errors.New("hazardproviders: water_surface can only be used with the depth hazard parameter")
+	}
+	return h.WaterSurface.Validate()
+}
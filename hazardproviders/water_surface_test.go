@@ -0,0 +1,51 @@
+package hazardproviders
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func TestWaterSurfaceElevation_DepthAboveGround(t *testing.T) {
+	w := WaterSurfaceElevation{DatumOffset: -1.5}
+	terrain := func(l geography.Location) (float64, error) {
+		return 100, nil
+	}
+	d, err := w.depthAboveGround(105, geography.Location{Z: 101, HasZ: true}, terrain)
+	if err != nil || d != 2.5 {
+		t.Errorf("expected 2.5 above the structure's ground with the offset, got %v %v", d, err)
+	}
+	//the terrain is in the water surface's datum, so the offset is not applied.
+	d, err = w.depthAboveGround(105, geography.Location{}, terrain)
+	if err != nil || d != 5 {
+		t.Errorf("expected 5 above the terrain, got %v %v", d, err)
+	}
+	_, err = w.depthAboveGround(100, geography.Location{Z: 99, HasZ: true}, terrain)
+	if !errors.As(err, &NoHazardFoundError{}) {
+		t.Errorf("expected a water surface below the ground to be dry, got %v", err)
+	}
+	_, err = w.depthAboveGround(105, geography.Location{}, nil)
+	if !errors.As(err, &NoDataHazardError{}) {
+		t.Errorf("expected no data without a ground elevation or terrain, got %v", err)
+	}
+}
+func TestWaterSurfaceElevation_Validate(t *testing.T) {
+	var h HazardProviderParameterAndPath
+	err := json.Unmarshal([]byte(`{"hazard_parameter_type": "depth", "hazard_provider_file_path": "/vsis3/bucket/wse.tif", "water_surface": {"datum_offset": 0.5, "terrain_file_path": "/vsis3/bucket/terrain.tif"}}`), &h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Errorf("expected a valid water surface, got %v", err)
+	}
+	if h.WaterSurface == nil || h.WaterSurface.DatumOffset != .5 {
+		t.Errorf("expected an offset of 0.5, got %+v", h.WaterSurface)
+	}
+	h.Hazard = hazards.Velocity
+	if h.Validate() == nil {
+		t.Error("expected a water surface on velocity to be invalid")
+	}
+}
@@ -5,6 +5,24 @@ import (
 	"time"
 )
 
+// HighWaveHeightThreshold is the wave height in feet at and above which waves are high, waves below it are medium.
+const HighWaveHeightThreshold = 3.0
+
+// LongDurationThreshold is the duration in days at and above which flooding is long duration.
+const LongDurationThreshold = 3.0
+
+// ClassifyWaveHeight turns on the WaveHeight bitflag and the medium or high wave height class, a wave height of zero or less is calm and has no class.
+func ClassifyWaveHeight(p Parameter, waveHeight float64) Parameter {
+	p = SetHasWaveHeight(p)
+	if waveHeight <= 0 {
+		return p
+	}
+	if waveHeight < HighWaveHeightThreshold {
+		return SetHasMediumWaveHeight(p)
+	}
+	return SetHasHighWaveHeight(p)
+}
+
 // CoastalEvent describes a coastal event
 type CoastalEvent struct {
 	depth         float64 `default:"-901.0"` //still depth
@@ -82,12 +100,7 @@ func (ad CoastalEvent) Parameters() Parameter {
 	}
 
 	if ad.WaveHeight() > 0.0 {
-		adp = SetHasWaveHeight(adp)
-		if ad.WaveHeight() < 3.0 {
-			adp = SetHasMediumWaveHeight(adp)
-		} else {
-			adp = SetHasHighWaveHeight(adp)
-		}
+		adp = ClassifyWaveHeight(adp, ad.WaveHeight())
 	}
 
 	if ad.Salinity() {
@@ -59,3 +59,20 @@ func Test_CoastalWithErosion(t *testing.T) {
 		t.Error("Expected PercentEroded of 20, but got something else.")
 	}
 }
+
+func TestHazardDataToMultiParameter_WaveAndDurationClasses(t *testing.T) {
+	for _, c := range []struct {
+		waveHeight float64
+		duration   float64
+		expected   Parameter
+	}{
+		{1.5, 1, Depth | Duration | WaveHeight | MediumWaveHeight | Salinity},
+		{4, 5, Depth | Duration | LongDuration | WaveHeight | HighWaveHeight | Salinity},
+		{0, 3, Depth | Duration | LongDuration | WaveHeight | Salinity},
+	} {
+		e := HazardDataToMultiParameter(HazardData{Depth: 2, Velocity: -901, Erosion: -901, Duration: c.duration, WaveHeight: c.waveHeight, Salinity: true, DV: -901})
+		if e.Parameters() != c.expected {
+			t.Errorf("expected %v for a %v foot wave over %v days, got %v", c.expected, c.waveHeight, c.duration, e.Parameters())
+		}
+	}
+}
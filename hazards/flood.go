@@ -339,18 +339,13 @@ func HazardDataToMultiParameter(hd HazardData) MultiParameterEvent {
 	if hd.Duration != -901 {
 		mpe.duration = hd.Duration
 		parameter = SetHasDuration(parameter)
-		if mpe.duration >= 72 {
+		if mpe.duration >= LongDurationThreshold {
 			parameter = SetHasLongDuration(parameter)
 		}
 	}
 	if hd.WaveHeight != -901 {
 		mpe.waveHeight = hd.WaveHeight
-		parameter = SetHasWaveHeight(parameter)
-		if mpe.WaveHeight() < 3.0 {
-			parameter = SetHasMediumWaveHeight(parameter)
-		} else {
-			parameter = SetHasHighWaveHeight(parameter)
-		}
+		parameter = ClassifyWaveHeight(parameter, mpe.waveHeight)
 	}
 	if hd.Salinity { //trust the provider.
 		mpe.salinity = hd.Salinity
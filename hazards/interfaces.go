@@ -48,7 +48,7 @@ func (hd *HazardData) SetParameter(p Parameter, value any) {
 	case Erosion:
 		hd.Erosion = value.(float64)
 	case Duration:
-		hd.Duration = value.(float64) //decimal hours.
+		hd.Duration = value.(float64) //decimal days.
 	case WaveHeight:
 		hd.WaveHeight = value.(float64)
 	case Salinity:
@@ -86,6 +86,9 @@ const (
 	LongDuration     Parameter = 16384 //15
 )
 
+// ClassParameters are the classes set from the values of other parameters, such as medium and high wave heights from the wave height or long duration from the duration.
+const ClassParameters = ArrivalTime2ft | MediumWaveHeight | HighWaveHeight | ModerateVelocity | HighVelocity | LongDuration
+
 var parametersToStrings = map[Parameter]string{
 	Default:          "default",
 	Depth:            "depth",
@@ -214,6 +217,27 @@ func toParameter(s string) Parameter {
 	return p
 }
 
+// ParseParameter parses a comma separated string to the parameter value, unlike UnmarshalJSON it reports the names it does not recognize rather than ignoring them. An empty string is the default parameter.
+func ParseParameter(s string) (Parameter, error) {
+	if s == "" {
+		return Default, nil
+	}
+	var p Parameter
+	unknown := make([]string, 0)
+	for _, sp := range strings.Split(s, ", ") {
+		pval, found := stringsToParameters[sp]
+		if !found {
+			unknown = append(unknown, sp)
+			continue
+		}
+		p = p | pval
+	}
+	if len(unknown) > 0 {
+		return p, fmt.Errorf("hazards: unknown hazard parameter %v", strings.Join(unknown, ", "))
+	}
+	return p, nil
+}
+
 // MarshalJSON marshals the enum as a quoted json string
 func (p Parameter) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString(`"`)
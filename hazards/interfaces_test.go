@@ -17,3 +17,16 @@ func TestMarshalMultiParameterJSON(t *testing.T) {
 		t.Error("unmarshal failed.")
 	}
 }
+func TestParseParameter(t *testing.T) {
+	p, err := ParseParameter("depth, velocity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != Depth|Velocity {
+		t.Errorf("expected depth and velocity, got %v", p)
+	}
+	_, err = ParseParameter("depth, dpeth")
+	if err == nil {
+		t.Error("expected an error for an unknown parameter")
+	}
+}
@@ -61,6 +61,16 @@ func Init(seed int64, warningSystem warning.WarningResponseSystem) LifeLossEngin
 	return LifeLossEngine{LethalityCurves: lethalityCurves, StabilityCriteria: stabilityCriteria, WarningSystem: warningSystem, SeedGenerator: rng}
 }
 
+// WithSeed returns a copy of the engine with its seed generator, and its warning system if it is seedable, reseeded from seed. the original engine is left untouched so it can be shared across goroutines.
+func (le LifeLossEngine) WithSeed(seed int64) LifeLossEngine {
+	out := le
+	out.SeedGenerator = rand.New(rand.NewSource(seed))
+	if ws, ok := le.WarningSystem.(warning.SeedableWarningResponseSystem); ok {
+		out.WarningSystem = ws.WithSeed(out.SeedGenerator.Int63())
+	}
+	return out
+}
+
 func LifeLossHeader() []string {
 	return []string{"ll_u65", "ll_o65", "ll_tot"} //@TODO: Consider adding structure stability state, fatality rates, and sampled hazard parameters
 }
@@ -97,7 +107,7 @@ func (le LifeLossEngine) ComputeLifeLoss(e hazards.HazardEvent, s structures.Str
 	if stability == Collapsed {
 		//log.Println("Stability Based Lifeloss")
 		//select high fataility rate
-		lethalityRate := le.LethalityCurves[HighLethality].Sample()
+		lethalityRate := le.LethalityCurves[HighLethality].SampleWithSeededRand(rng)
 		log.Printf("high lethality rate: %v\n", lethalityRate)
 		//apply same fatality rate to everyone
 		//log.Println(lethalityRate)
@@ -167,7 +177,9 @@ func (lle LifeLossEngine) submergenceCriteria(e hazards.HazardEvent, s structure
 		log.Printf("low lethality rate: %v\n", lowLethality)
 		highLethality := lle.LethalityCurves[HighLethality].SampleWithSeededRand(rng)
 		log.Printf("high lethality rate: %v\n", highLethality)
-		for k, v := range mobilitySet {
+		//iterate in a fixed order so the draws are reproducible for a seed
+		for _, k := range []Mobility{Mobile, NotMobile} {
+			v := mobilitySet[k]
 			//apply to the appropriate age/time of day
 			//log.Println(v)
 			if k == Mobile {
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
-	"os"
 
-	"github.com/USACE/go-consequences/compute"
+	"github.com/USACE/go-consequences/scenario"
 )
 
 /*
@@ -22,20 +22,24 @@ import (
 	}
 */
 func main() {
-	fp := os.Args[1]
-	b, err := os.ReadFile(fp)
+	dryRun := flag.Bool("dry-run", false, "validate the scenario and print the resolved plan without computing")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: go-consequences [-dry-run] scenario.json|scenario.yaml")
+	}
+	s, err := scenario.Load(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
-	var config compute.Config
-	json.Unmarshal(b, &config)
-	computable, err := config.CreateComputable()
+	err = s.Validate()
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("the scenario is not valid:\n%v", err)
+	}
+	if *dryRun {
+		fmt.Print(s.Plan())
+		return
 	}
-	defer computable.ResultsWriter.Close()
-	defer computable.HazardProvider.Close()
-	err = computable.Compute()
+	err = s.Run()
 	if err != nil {
 		log.Fatal(err)
 	}
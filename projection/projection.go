@@ -0,0 +1,204 @@
+// Package projection transforms locations and bounding boxes between spatial reference systems, so structure inventories and hazard grids in different reference systems line up.
+package projection
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/USACE/go-consequences/geography"
+	"github.com/dewberry/gdal"
+)
+
+// WGS84 is the reference system of the national structure inventory api.
+const WGS84 = "EPSG:4326"
+
+// edgePoints is the number of points each edge of a bounding box is divided into when it is transformed, edges can curve in the target reference system.
+const edgePoints = 21
+
+// SpatialReference parses a reference system given as an epsg code such as 4326 or EPSG:4326, wkt or a proj string. Axes are in x then y order, longitude before latitude.
+func SpatialReference(srs string) (gdal.SpatialReference, error) {
+	sr := gdal.CreateSpatialReference("")
+	var err error
+	if code, convErr := strconv.Atoi(strings.TrimSpace(srs)); convErr == nil {
+		err = sr.FromEPSG(code)
+	} else {
+		err = sr.SetFromUserInput(srs)
+	}
+	if err != nil {
+		sr.Destroy()
+		return sr, fmt.Errorf("projection: unable to parse spatial reference %v: %w", srs, err)
+	}
+	sr.SetAxisMappingStrategy(gdal.OAMS_TraditionalGisOrder)
+	return sr, nil
+}
+
+// Transformer transforms locations and bounding boxes from one reference system to another, it is not safe for concurrent use.
+type Transformer struct {
+	to       string
+	identity bool
+	ct       gdal.CoordinateTransform
+}
+
+// NewTransformer creates a transformer from one reference system to another. If either is not set, or they are the same system, locations are not changed.
+func NewTransformer(from string, to string) (*Transformer, error) {
+	t := &Transformer{to: to, identity: true}
+	if from == "" || to == "" || from == to {
+		return t, nil
+	}
+	fsr, err := SpatialReference(from)
+	if err != nil {
+		return nil, err
+	}
+	defer fsr.Destroy()
+	tsr, err := SpatialReference(to)
+	if err != nil {
+		return nil, err
+	}
+	defer tsr.Destroy()
+	if fsr.IsSame(tsr) {
+		return t, nil
+	}
+	t.ct = gdal.CreateCoordinateTransform(fsr, tsr)
+	t.identity = false
+	return t, nil
+}
+
+// Close releases the transformation.
+func (t *Transformer) Close() {
+	if !t.identity {
+		t.ct.Destroy()
+		t.identity = true
+	}
+}
+func (t *Transformer) transform(xs []float64, ys []float64) error {
+	if t.identity {
+		return nil
+	}
+	if !t.ct.Transform(len(xs), xs, ys, make([]float64, len(xs))) {
+		return errors.New("projection: unable to transform to " + t.to)
+	}
+	return nil
+}
+
+// Location transforms a location and its footprint, the result is stamped with the target reference system.
+func (t *Transformer) Location(l geography.Location) (geography.Location, error) {
+	if t.identity {
+		if l.SRID == "" {
+			l.SRID = t.to
+		}
+		return l, nil
+	}
+	n := len(l.Footprint) + 1
+	xs, ys := make([]float64, n), make([]float64, n)
+	xs[0], ys[0] = l.X, l.Y
+	for i, p := range l.Footprint {
+		xs[i+1], ys[i+1] = p.X, p.Y
+	}
+	err := t.transform(xs, ys)
+	if err != nil {
+		return l, err
+	}
+	out := geography.Location{X: xs[0], Y: ys[0], SRID: t.to, Z: l.Z, HasZ: l.HasZ}
+	if len(l.Footprint) > 0 {
+		out.Footprint = make([]geography.Location, len(l.Footprint))
+		for i := range l.Footprint {
+			out.Footprint[i] = geography.Location{X: xs[i+1], Y: ys[i+1], SRID: t.to}
+		}
+	}
+	return out, nil
+}
+
+// BBox transforms a bounding box, the result is the smallest box covering the transformed edges with the y ordering of bb.
+func (t *Transformer) BBox(bb geography.BBox) (geography.BBox, error) {
+	if t.identity {
+		if bb.SRID == "" {
+			bb.SRID = t.to
+		}
+		return bb, nil
+	}
+	points := bb.EdgePoints(edgePoints)
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = p.X, p.Y
+	}
+	err := t.transform(xs, ys)
+	if err != nil {
+		return bb, err
+	}
+	for i := range points {
+		points[i] = geography.Location{X: xs[i], Y: ys[i]}
+	}
+	out := geography.Envelope(points, bb.Bbox[1] > bb.Bbox[3])
+	out.SRID = t.to
+	return out, nil
+}
+
+// TransformBBox transforms a bounding box from its own reference system to another, a box or target without a reference system is returned unchanged.
+func TransformBBox(bb geography.BBox, to string) (geography.BBox, error) {
+	t, err := NewTransformer(bb.SRID, to)
+	if err != nil {
+		return bb, err
+	}
+	defer t.Close()
+	return t.BBox(bb)
+}
+
+// UnionBBox is the union of boxes, each transformed into the reference system of the first.
+func UnionBBox(boxes []geography.BBox) (geography.BBox, error) {
+	if len(boxes) == 0 {
+		return geography.BBox{}, errors.New("projection: there are no boxes to union")
+	}
+	u := boxes[0]
+	for _, b := range boxes[1:] {
+		b, err := TransformBBox(b, u.SRID)
+		if err != nil {
+			return u, err
+		}
+		u = u.Union(b)
+	}
+	return u, nil
+}
+
+// Cache keeps a transformer to one reference system for each reference system locations arrive in, it is not safe for concurrent use.
+type Cache struct {
+	to           string
+	transformers map[string]*Transformer
+}
+
+// NewCache creates a cache of transformers to a reference system, an empty reference system leaves locations unchanged.
+func NewCache(to string) *Cache {
+	return &Cache{to: to, transformers: make(map[string]*Transformer)}
+}
+
+// Location transforms l from its reference system to the cache's, locations without a reference system are assumed to be in the cache's.
+func (c *Cache) Location(l geography.Location) (geography.Location, error) {
+	if l.SRID == "" || c.to == "" || l.SRID == c.to {
+		return l, nil
+	}
+	t, ok := c.transformers[l.SRID]
+	if !ok {
+		var err error
+		t, err = NewTransformer(l.SRID, c.to)
+		if err != nil {
+			return l, err
+		}
+		c.transformers[l.SRID] = t
+	}
+	return t.Location(l)
+}
+
+// SetTarget changes the reference system locations are transformed to.
+func (c *Cache) SetTarget(to string) {
+	c.Close()
+	c.to = to
+}
+
+// Close releases every transformer.
+func (c *Cache) Close() {
+	for k, t := range c.transformers {
+		t.Close()
+		delete(c.transformers, k)
+	}
+}
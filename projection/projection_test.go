@@ -0,0 +1,59 @@
+package projection
+
+import (
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/geography"
+)
+
+func TestCache_UnknownReferenceSystems(t *testing.T) {
+	c := NewCache("EPSG:26915")
+	defer c.Close()
+	l := geography.Location{X: 500000, Y: 4000000}
+	out, err := c.Location(l)
+	if err != nil || out.X != l.X || out.Y != l.Y {
+		t.Errorf("expected a location without a reference system to be unchanged, got %v %v", out, err)
+	}
+	unknown := NewCache("")
+	out, err = unknown.Location(geography.Location{X: -90, Y: 30, SRID: WGS84})
+	if err != nil || out.X != -90 || out.Y != 30 {
+		t.Errorf("expected a raster without a reference system to leave locations unchanged, got %v %v", out, err)
+	}
+}
+func TestTransformBBox_UTMToWGS84(t *testing.T) {
+	//a 10km box in utm zone 15 north around -93, 36.
+	bb := geography.BBox{Bbox: []float64{495000, 3990000, 505000, 3980000}, SRID: "EPSG:32615"}
+	out, err := TransformBBox(bb, WGS84)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.SRID != WGS84 {
+		t.Errorf("expected the box to be stamped %v, got %v", WGS84, out.SRID)
+	}
+	if out.Bbox[1] < out.Bbox[3] {
+		t.Errorf("expected the upper left lower right ordering to be kept, got %v", out.Bbox)
+	}
+	if math.Abs((out.Bbox[0]+out.Bbox[2])/2+93) > .01 || out.Bbox[0] > -93.05 || out.Bbox[2] < -92.95 {
+		t.Errorf("expected the box to be centered on longitude -93, got %v", out.Bbox)
+	}
+	back, err := TransformBBox(out, "EPSG:32615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Bbox[0] > 495000 || back.Bbox[1] < 3990000 || back.Bbox[2] < 505000 || back.Bbox[3] > 3980000 {
+		t.Errorf("expected the box transformed back to cover the original, got %v", back.Bbox)
+	}
+}
+func TestUnionBBox(t *testing.T) {
+	u, err := UnionBBox([]geography.BBox{{Bbox: []float64{0, 10, 10, 0}, SRID: "EPSG:26915"}, {Bbox: []float64{5, 20, 20, 5}, SRID: "EPSG:26915"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.SRID != "EPSG:26915" || u.Bbox[0] != 0 || u.Bbox[1] != 20 || u.Bbox[2] != 20 || u.Bbox[3] != 0 {
+		t.Errorf("expected the upper left lower right box 0 20 20 0, got %v", u)
+	}
+	if _, err := UnionBBox(nil); err == nil {
+		t.Error("expected no boxes to be an error")
+	}
+}
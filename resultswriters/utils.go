@@ -2,6 +2,9 @@ package resultswriters
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 
 	"github.com/USACE/go-consequences/consequences"
@@ -32,6 +35,36 @@ type ResultsWriterInfo struct {
 	FilePath string            `json:"output_file_path"`
 }
 
+// Validate reports every problem with the results writer it can find without creating the output.
+func (info ResultsWriterInfo) Validate() error {
+	errs := make([]error, 0)
+	switch info.Type {
+	case JSON, GPKG, SHP, PARQUET:
+	case OGR:
+		if info.Driver == "" {
+			errs = append(errs, errors.New("resultswriters: results_writer_driver is required for an OGR results writer"))
+		}
+	case "":
+		errs = append(errs, errors.New("resultswriters: results_writer_type is required"))
+	default:
+		errs = append(errs, fmt.Errorf("resultswriters: unknown results_writer_type %v", string(info.Type)))
+	}
+	errs = append(errs, CheckOutputFilePath("output_file_path", info.FilePath))
+	return errors.Join(errs...)
+}
+
+// CheckOutputFilePath is an error if path is not set or the directory it would be written to does not exist.
+func CheckOutputFilePath(field string, path string) error {
+	if path == "" {
+		return fmt.Errorf("resultswriters: %v is required", field)
+	}
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("resultswriters: the directory of %v %v does not exist", field, path)
+	}
+	return nil
+}
 func (info ResultsWriterInfo) CreateResultsWriter() (consequences.ResultsWriter, error) {
 	switch info.Type {
 	case JSON:
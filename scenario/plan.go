@@ -0,0 +1,245 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/structureprovider"
+)
+
+// Plan describes what Run would do, with defaults resolved, without opening any dataset.
+func (s Scenario) Plan() string {
+	var b strings.Builder
+	mode := s.ResolvedMode()
+	if s.Name != "" {
+		fmt.Fprintf(&b, "scenario: %v\n", s.Name)
+	}
+	fmt.Fprintf(&b, "mode: %v\n", mode)
+	if mode != Crops {
+		writeInventory(&b, s.StructureProviderInfo)
+	}
+	receptors := "structures"
+	if mode == Crops {
+		receptors = "crops"
+	}
+	switch {
+	case s.ComputeByFips:
+		fmt.Fprintf(&b, "%v streamed: by fips code %v\n", receptors, s.FipsCode)
+	case mode == AggregatedStageDamage:
+		fmt.Fprintf(&b, "%v streamed: within each stage's hazard boundary\n", receptors)
+	default:
+		fmt.Fprintf(&b, "%v streamed: within the hazard boundary\n", receptors)
+	}
+	fmt.Fprintf(&b, "hazards:\n")
+	switch mode {
+	case AggregatedStageDamage:
+		if s.AggregatedStageDamage != nil {
+			for i, stage := range s.AggregatedStageDamage.Stages {
+				writeHazard(&b, fmt.Sprintf("stage %v", i), stage)
+			}
+		}
+	case Crops:
+		writeHazard(&b, "hazard_provider_info", s.HazardProviderInfo)
+	default:
+		for _, h := range s.HazardInputs() {
+			writeHazard(&b, h.Label, h.HazardProviderInfo)
+		}
+	}
+	if settings := s.settings(mode); settings != nil {
+		j, err := json.Marshal(settings)
+		if err == nil && string(j) != "null" {
+			fmt.Fprintf(&b, "%v settings: %s\n", mode, j)
+		}
+	}
+	if mode == AggregatedStageDamage {
+		if s.AggregatedStageDamage != nil {
+			fmt.Fprintf(&b, "content results: %v\n", s.AggregatedStageDamage.ContentOutputFilePath)
+			fmt.Fprintf(&b, "structure results: %v\n", s.AggregatedStageDamage.StructureOutputFilePath)
+		}
+		fmt.Fprintf(&b, "seed: %v\n", s.MasterSeed())
+		return b.String()
+	}
+	if mode != Crops {
+		fmt.Fprintf(&b, "seed: %v\n", s.MasterSeed())
+		if s.Workers > 0 {
+			fmt.Fprintf(&b, "workers: %v\n", s.Workers)
+		}
+		if s.ComputeLifeloss {
+			fmt.Fprintf(&b, "lifeloss: warning compliance rate %v\n", s.ComplianceRate)
+		}
+		if s.ComputeReconstruction {
+			fmt.Fprintf(&b, "reconstruction: multi hazard events are rebuilt between events\n")
+		}
+		if s.RebuildRules != nil {
+			j, err := json.Marshal(s.RebuildRules)
+			if err == nil {
+				fmt.Fprintf(&b, "rebuild rules: %s\n", j)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "results: %v %v\n", s.ResultsWriterInfo.Type, s.ResultsWriterInfo.FilePath)
+	if fp := s.AggregateOutputFilePath(); fp != "" {
+		fmt.Fprintf(&b, "aggregate results: %v\n", fp)
+	}
+	return b.String()
+}
+func writeInventory(b *strings.Builder, spi structureprovider.StructureProviderInfo) {
+	fmt.Fprintf(b, "structures: %v", spi.StructureProviderType.String())
+	if spi.StructureFilePath != "" {
+		fmt.Fprintf(b, " %v", spi.StructureFilePath)
+	}
+	if spi.LayerName != "" {
+		fmt.Fprintf(b, " layer %v", spi.LayerName)
+	}
+	fmt.Fprintf(b, "\n")
+	if spi.OccTypeFilePath != "" {
+		fmt.Fprintf(b, "occupancy types: %v\n", spi.OccTypeFilePath)
+	} else {
+		fmt.Fprintf(b, "occupancy types: default\n")
+	}
+	if spi.OccTypeOverridesFilePath != "" {
+		fmt.Fprintf(b, "occupancy type overrides: %v\n", spi.OccTypeOverridesFilePath)
+	}
+	if spi.Filter != nil {
+		j, err := json.Marshal(spi.Filter)
+		if err == nil {
+			fmt.Fprintf(b, "filter: %s\n", j)
+		}
+	}
+	if c := spi.NSICache; c != nil {
+		version := c.Version
+		if version == "" {
+			version = "latest"
+		}
+		mode := "read from and saved to"
+		if c.Offline {
+			mode = "read offline from"
+		}
+		fmt.Fprintf(b, "nsi cache: %v version %v in %v\n", mode, version, c.Directory)
+	}
+	if spi.FieldMapping != nil {
+		j, err := json.Marshal(spi.FieldMapping)
+		if err == nil {
+			fmt.Fprintf(b, "field mapping: %s\n", j)
+		}
+	}
+}
+func writeHazard(b *strings.Builder, label string, info hazardproviders.HazardProviderInfo) {
+	if mb := info.MultiBand; mb != nil {
+		sampling := samplingDescription(mb.Sampling)
+		if mb.TimeSeries != nil {
+			fmt.Fprintf(b, "  %v: %v time steps above %v in %v%v\n", label, len(mb.TimeSeries.DepthBands), mb.TimeSeries.Threshold, mb.FilePath, sampling)
+		}
+		for _, band := range mb.ParameterBands {
+			fmt.Fprintf(b, "  %v: %v band %v of %v%v\n", label, band.Hazard, band.Band, mb.FilePath, sampling)
+		}
+	}
+	if rp := info.RasPlan; rp != nil {
+		areas := "every 2D flow area"
+		if len(rp.FlowAreas) > 0 {
+			areas = "2D flow areas " + strings.Join(rp.FlowAreas, ", ")
+		}
+		ground := "cell minimum elevations"
+		if rp.TerrainFilePath != "" {
+			ground = "terrain " + rp.TerrainFilePath
+		}
+		fmt.Fprintf(b, "  %v: ras plan %v, %v, depth above %v\n", label, rp.FilePath, areas, ground)
+	}
+	if mo := info.Mosaic; mo != nil {
+		for _, m := range mo.Hazards {
+			overlap := m.Overlap
+			if overlap == "" {
+				overlap = hazardproviders.Priority
+			}
+			fmt.Fprintf(b, "  %v: %v mosaic of %v tiles by %v%v\n", label, m.Hazard, len(m.FilePaths), overlap, samplingDescription(m.Sampling))
+		}
+	}
+	if v := info.Vector; v != nil {
+		lookup := "the nearest point"
+		if v.SearchRadius > 0 {
+			lookup = fmt.Sprintf("the nearest point within %v", v.SearchRadius)
+		}
+		for _, f := range v.Fields {
+			fmt.Fprintf(b, "  %v: %v field %v of %v by the containing polygon or %v\n", label, f.Hazard, f.Field, v.FilePath, lookup)
+		}
+	}
+	if g := info.Gridded; g != nil {
+		members := ""
+		if g.EnsembleDimension != "" {
+			members = ", each " + g.EnsembleDimension + " member a separate event"
+		}
+		for _, v := range g.Variables {
+			reduction := v.Reduction
+			if reduction == "" {
+				reduction = hazardproviders.MaxOverTime
+			}
+			switch reduction {
+			case hazardproviders.TimeStep:
+				fmt.Fprintf(b, "  %v: %v from %v of %v at step %v%v%v\n", label, v.Hazard, v.Variable, g.FilePath, v.TimeStep, members, samplingDescription(g.Sampling))
+			case hazardproviders.TimeAboveThreshold, hazardproviders.FirstArrival:
+				fmt.Fprintf(b, "  %v: %v from %v of %v by %v %v%v%v\n", label, v.Hazard, v.Variable, g.FilePath, reduction, v.Threshold, members, samplingDescription(g.Sampling))
+			default:
+				fmt.Fprintf(b, "  %v: %v from %v of %v by %v%v%v\n", label, v.Hazard, v.Variable, g.FilePath, reduction, members, samplingDescription(g.Sampling))
+			}
+		}
+	}
+	for _, h := range info.Hazards {
+		fmt.Fprintf(b, "  %v: %v %v%v%v\n", label, h.Hazard, h.FilePath, samplingDescription(h.Sampling), waterSurfaceDescription(h.WaterSurface))
+	}
+}
+
+// waterSurfaceDescription describes how a water surface elevation raster becomes a depth, it is empty for depth rasters.
+func waterSurfaceDescription(w *hazardproviders.WaterSurfaceElevation) string {
+	if w == nil {
+		return ""
+	}
+	d := " as water surface elevations"
+	if w.DatumOffset != 0 {
+		d += fmt.Sprintf(" offset by %v", w.DatumOffset)
+	}
+	d += " above structure ground elevations"
+	if w.TerrainFilePath != "" {
+		d += ", or terrain " + w.TerrainFilePath + " where there are none"
+	}
+	return d
+}
+
+// samplingDescription describes a sampling method other than nearest.
+func samplingDescription(s hazardproviders.Sampling) string {
+	switch s.Method {
+	case hazardproviders.MaxWithinRadius, hazardproviders.MeanWithinRadius:
+		return fmt.Sprintf(" sampled by %v %v", s.Method, s.Radius)
+	case hazardproviders.MaxOverFootprint:
+		return fmt.Sprintf(" sampled by %v, %v wide where there is no footprint", s.Method, s.FootprintWidth)
+	case hazardproviders.Bilinear:
+		return fmt.Sprintf(" sampled by %v", s.Method)
+	}
+	return ""
+}
+
+// settings are the settings of mode, nil for a single event.
+func (s Scenario) settings(mode Mode) interface{} {
+	switch mode {
+	case MonteCarlo:
+		return s.MonteCarlo
+	case EAD:
+		return s.EAD
+	case EquivalentAnnual:
+		return s.EquivalentAnnual
+	case Alternatives:
+		return s.Config.Alternatives
+	case Mitigation:
+		return s.Mitigation
+	case Lifecycle:
+		return s.Lifecycle
+	case Exposure:
+		return s.Config.Exposure
+	case AggregatedStageDamage:
+		return s.AggregatedStageDamage
+	case Crops:
+		return s.Crops
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+// Package scenario describes a complete compute in a single json or yaml document, validates it before any dataset is opened, and runs it.
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/USACE/go-consequences/compute"
+	"github.com/USACE/go-consequences/cropprovider"
+	"github.com/USACE/go-consequences/crops"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/hazardproviders"
+	"github.com/USACE/go-consequences/hazards"
+	"github.com/USACE/go-consequences/resultswriters"
+	"github.com/USACE/go-consequences/structureprovider"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode is the kind of compute a scenario runs, each mode other than a single event reads the settings of the same name.
+type Mode string
+
+const (
+	SingleEvent           Mode = "single_event"
+	MonteCarlo            Mode = "monte_carlo"
+	EAD                   Mode = "ead"
+	EquivalentAnnual      Mode = "equivalent_annual_damage"
+	Alternatives          Mode = "alternatives"
+	Mitigation            Mode = "mitigation"
+	Lifecycle             Mode = "lifecycle"
+	AggregatedStageDamage Mode = "aggregated_stage_damage"
+	Crops                 Mode = "crops"
+	Exposure              Mode = "exposure"
+)
+
+// Scenario is a compute configuration that names its mode. The inventory, including occupancy type overrides and filters, is described by structure_provider_info, the hazard by hazard_provider_info or the mode's settings, the output by results_writer_info and the mode's aggregate output, and the random streams by seed.
+type Scenario struct {
+	Name string `json:"name,omitempty"`
+	Mode Mode   `json:"mode,omitempty"` //inferred from the settings provided if not set
+	compute.Config
+	AggregatedStageDamage *AggregatedStageDamageSettings `json:"aggregated_stage_damage,omitempty"`
+	Crops                 *CropSettings                  `json:"crops,omitempty"`
+}
+
+// AggregatedStageDamageSettings describe an aggregated stage damage compute, the structure inventory is computed against each stage and the damages by damage category are written at the stage of the index location.
+type AggregatedStageDamageSettings struct {
+	Stages                  []hazardproviders.HazardProviderInfo `json:"stages"`
+	IndexLocationX          float64                              `json:"index_location_x"`
+	IndexLocationY          float64                              `json:"index_location_y"`
+	TerrainElevation        float64                              `json:"terrain_elevation"` //ground elevation at the index location, the depth there is added to it to give the stage
+	ContentOutputFilePath   string                               `json:"content_output_file_path"`
+	StructureOutputFilePath string                               `json:"structure_output_file_path"`
+}
+
+// Validate reports every problem with the stages and output files.
+func (s AggregatedStageDamageSettings) Validate() error {
+	if len(s.Stages) == 0 {
+		return errors.New("scenario: aggregated stage damage requires at least one stage")
+	}
+	errs := make([]error, 0)
+	for i, stage := range s.Stages {
+		err := stage.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stage %v: %w", i, err))
+		}
+	}
+	errs = append(errs, resultswriters.CheckOutputFilePath("content_output_file_path", s.ContentOutputFilePath))
+	errs = append(errs, resultswriters.CheckOutputFilePath("structure_output_file_path", s.StructureOutputFilePath))
+	return errors.Join(errs...)
+}
+
+// CropSettings describe an agricultural compute against the cropland data layer, hazard_provider_info must provide the arrival time in decimal days and the duration.
+type CropSettings struct {
+	Year      string   `json:"year"`       //cropland data layer year
+	CropCodes []string `json:"crop_codes"` //cropland data layer codes of the crops computed
+}
+
+// Validate checks the year is set and every crop code is a known crop.
+func (s CropSettings) Validate() error {
+	errs := make([]error, 0)
+	if s.Year == "" {
+		errs = append(errs, errors.New("scenario: crops requires a year"))
+	}
+	if len(s.CropCodes) == 0 {
+		errs = append(errs, errors.New("scenario: crops requires at least one crop code"))
+	}
+	m := crops.NASSCropMap()
+	for _, c := range s.CropCodes {
+		if _, ok := m[c]; !ok {
+			errs = append(errs, fmt.Errorf("scenario: unknown crop code %v", c))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load reads a scenario from a json file, or a yaml file if its extension is .yaml or .yml.
+func Load(path string) (Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	var s Scenario
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &s)
+	default:
+		err = json.Unmarshal(b, &s)
+	}
+	if err != nil {
+		return Scenario{}, fmt.Errorf("scenario: unable to parse %v: %w", path, err)
+	}
+	return s, nil
+}
+
+// UnmarshalYAML decodes yaml through json so a yaml scenario uses the same keys and defaults as a json scenario.
+func (s *Scenario) UnmarshalYAML(value *yaml.Node) error {
+	var doc interface{}
+	err := value.Decode(&doc)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	type scenario Scenario
+	var decoded scenario
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		return err
+	}
+	*s = Scenario(decoded)
+	return nil
+}
+
+// configuredModes lists the modes whose settings are provided.
+func (s Scenario) configuredModes() []Mode {
+	modes := make([]Mode, 0)
+	if s.MonteCarlo != nil {
+		modes = append(modes, MonteCarlo)
+	}
+	if s.EAD != nil {
+		modes = append(modes, EAD)
+	}
+	if s.EquivalentAnnual != nil {
+		modes = append(modes, EquivalentAnnual)
+	}
+	if s.Config.Alternatives != nil {
+		modes = append(modes, Alternatives)
+	}
+	if s.Mitigation != nil {
+		modes = append(modes, Mitigation)
+	}
+	if s.Lifecycle != nil {
+		modes = append(modes, Lifecycle)
+	}
+	if s.Config.Exposure != nil {
+		modes = append(modes, Exposure)
+	}
+	if s.AggregatedStageDamage != nil {
+		modes = append(modes, AggregatedStageDamage)
+	}
+	if s.Crops != nil {
+		modes = append(modes, Crops)
+	}
+	return modes
+}
+
+// ResolvedMode is the mode the scenario names, or if it does not name one, the mode whose settings are provided or a single event.
+func (s Scenario) ResolvedMode() Mode {
+	if s.Mode != "" {
+		return s.Mode
+	}
+	configured := s.configuredModes()
+	if len(configured) > 0 {
+		return configured[0]
+	}
+	return SingleEvent
+}
+
+// validateMode checks the mode is known and that only its settings are provided.
+func (s Scenario) validateMode() error {
+	configured := s.configuredModes()
+	if s.Mode == "" {
+		if len(configured) > 1 {
+			return errors.New("scenario: mode is required when the settings of more than one mode are provided")
+		}
+		return nil
+	}
+	switch s.Mode {
+	case SingleEvent, MonteCarlo, EAD, EquivalentAnnual, Alternatives, Mitigation, Lifecycle, Exposure, AggregatedStageDamage, Crops:
+	default:
+		return fmt.Errorf("scenario: unknown mode %v", string(s.Mode))
+	}
+	errs := make([]error, 0)
+	found := s.Mode == SingleEvent
+	for _, m := range configured {
+		if m == s.Mode {
+			found = true
+		} else {
+			errs = append(errs, fmt.Errorf("scenario: %v settings are not used by mode %v", m, s.Mode))
+		}
+	}
+	if !found {
+		errs = append(errs, fmt.Errorf("scenario: mode %v requires %v settings", s.Mode, s.Mode))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every problem with the scenario that can be found before any dataset is opened: the mode and its settings, missing files, unknown hazard parameters and incomplete structure provider and results writer settings.
+func (s Scenario) Validate() error {
+	errs := []error{s.validateMode()}
+	switch s.ResolvedMode() {
+	case AggregatedStageDamage:
+		errs = append(errs, s.StructureProviderInfo.Validate())
+		if s.AggregatedStageDamage != nil {
+			errs = append(errs, s.AggregatedStageDamage.Validate())
+		}
+	case Crops:
+		if s.Crops != nil {
+			errs = append(errs, s.Crops.Validate())
+		}
+		err := s.HazardProviderInfo.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hazard_provider_info: %w", err))
+		}
+		_, _, err = s.cropHazardPaths()
+		errs = append(errs, err, s.ResultsWriterInfo.Validate())
+	default:
+		errs = append(errs, s.Config.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// cropHazardPaths finds the duration and arrival time grids of a crop compute.
+func (s Scenario) cropHazardPaths() (string, string, error) {
+	var duration, arrival string
+	for _, h := range s.HazardProviderInfo.Hazards {
+		switch h.Hazard {
+		case hazards.Duration:
+			duration = h.FilePath
+		case hazards.ArrivalTime:
+			arrival = h.FilePath
+		}
+	}
+	if duration == "" || arrival == "" {
+		return duration, arrival, errors.New("scenario: crops requires a duration and an arrivaltime hazard")
+	}
+	return duration, arrival, nil
+}
+
+// Run validates the scenario, opens its providers and writers and computes it.
+func (s Scenario) Run() error {
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+	switch s.ResolvedMode() {
+	case AggregatedStageDamage:
+		return s.runAggregatedStageDamage()
+	case Crops:
+		return s.runCrops()
+	}
+	computable, err := s.Config.CreateComputable()
+	if err != nil {
+		return err
+	}
+	defer computable.ResultsWriter.Close()
+	if computable.HazardProvider != nil {
+		//ead computes close their own frequency hazard providers.
+		defer computable.HazardProvider.Close()
+	}
+	return computable.Compute()
+}
+func (s Scenario) runAggregatedStageDamage() error {
+	sp, err := s.CreateStructureProvider()
+	if err != nil {
+		return err
+	}
+	if ssp, ok := sp.(structureprovider.SeedableStructureProvider); ok {
+		ssp.SetSeed(s.MasterSeed())
+	}
+	settings := s.AggregatedStageDamage
+	hps := make([]hazardproviders.HazardProvider, 0, len(settings.Stages))
+	defer func() {
+		for _, hp := range hps {
+			hp.Close()
+		}
+	}()
+	for _, stage := range settings.Stages {
+		hp, err := stage.CreateHazardProvider()
+		if err != nil {
+			return err
+		}
+		hps = append(hps, hp)
+	}
+	index := geography.Location{X: settings.IndexLocationX, Y: settings.IndexLocationY}
+	compute.Aggregated_StageDamage(hps, sp, index, settings.TerrainElevation, settings.ContentOutputFilePath, settings.StructureOutputFilePath)
+	return structureprovider.StreamError(sp)
+}
+func (s Scenario) runCrops() error {
+	duration, arrival, err := s.cropHazardPaths()
+	if err != nil {
+		return err
+	}
+	hp, err := hazardproviders.InitDaAHP(duration, arrival, s.HazardProviderInfo.StartTime)
+	if err != nil {
+		return err
+	}
+	defer hp.Close()
+	rw, err := s.CreateResultsWriter()
+	if err != nil {
+		return err
+	}
+	defer rw.Close()
+	csp := cropprovider.InitNassCropProvider(s.Crops.Year, s.Crops.CropCodes)
+	if s.ComputeByFips {
+		compute.StreamAbstractByFIPS(s.FipsCode, hp, csp, rw)
+	} else {
+		compute.StreamAbstract(hp, csp, rw)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates empty files in a temporary directory and returns the directory.
+func writeFiles(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, n := range names {
+		err := os.WriteFile(filepath.Join(dir, n), []byte{}, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+func writeScenario(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(strings.ReplaceAll(content, "DIR", dir)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const eadScenarioJSON = `{
+	"name": "levee",
+	"mode": "ead",
+	"structure_provider_info": {"structure_provider_type": "GPKG", "structure_file_path": "DIR/nsi.gpkg", "layername": "nsi", "filter": {"damage_categories": ["RES"]}},
+	"results_writer_info": {"results_writer_type": "GPKG", "output_file_path": "DIR/results.gpkg"},
+	"seed": 1234,
+	"ead": {
+		"events": [
+			{"annual_exceedance_probability": 0.1, "hazard_provider_info": {"hazards": [{"hazard_parameter_type": "depth", "hazard_provider_file_path": "DIR/10yr.tif"}]}},
+			{"annual_exceedance_probability": 0.01, "hazard_provider_info": {"hazards": [{"hazard_parameter_type": "depth", "hazard_provider_file_path": "DIR/100yr.tif"}]}}
+		],
+		"aggregate_output_file_path": "DIR/ead.json"
+	}
+}`
+
+const eadScenarioYAML = `
+name: levee
+mode: ead
+structure_provider_info:
+  structure_provider_type: GPKG
+  structure_file_path: DIR/nsi.gpkg
+  layername: nsi
+  filter:
+    damage_categories: [RES]
+results_writer_info:
+  results_writer_type: GPKG
+  output_file_path: DIR/results.gpkg
+seed: 1234
+ead:
+  events:
+    - annual_exceedance_probability: 0.1
+      hazard_provider_info:
+        hazards:
+          - hazard_parameter_type: depth
+            hazard_provider_file_path: DIR/10yr.tif
+    - annual_exceedance_probability: 0.01
+      hazard_provider_info:
+        hazards:
+          - hazard_parameter_type: depth
+            hazard_provider_file_path: DIR/100yr.tif
+  aggregate_output_file_path: DIR/ead.json
+`
+
+func TestLoad_YAMLMatchesJSON(t *testing.T) {
+	dir := writeFiles(t, "nsi.gpkg", "10yr.tif", "100yr.tif")
+	js, err := Load(writeScenario(t, dir, "scenario.json", eadScenarioJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ys, err := Load(writeScenario(t, dir, "scenario.yaml", eadScenarioYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(js, ys) {
+		t.Errorf("the yaml scenario %+v differs from the json scenario %+v", ys, js)
+	}
+	err = js.Validate()
+	if err != nil {
+		t.Errorf("expected a valid scenario, got %v", err)
+	}
+}
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	dir := writeFiles(t, "100yr.tif")
+	content := `{
+	"mode": "single_event",
+	"structure_provider_info": {"structure_provider_type": "GPKG", "structure_file_path": "DIR/missing.gpkg"},
+	"hazard_provider_info": {"hazards": [{"hazard_parameter_type": "dpeth", "hazard_provider_file_path": "DIR/100yr.tif"}, {"hazard_parameter_type": "velocity", "hazard_provider_file_path": "DIR/velocity.tif"}]},
+	"results_writer_info": {"results_writer_type": "CSV", "output_file_path": "DIR/results.csv"}
+}`
+	s, err := Load(writeScenario(t, dir, "scenario.json", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Validate()
+	if err == nil {
+		t.Fatal("expected the scenario to be invalid")
+	}
+	for _, expected := range []string{"missing.gpkg does not exist", "layername is required", "unknown hazard parameter dpeth", "velocity.tif does not exist", "unknown results_writer_type CSV"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the problems to include %q, got:\n%v", expected, err)
+		}
+	}
+}
+func TestValidate_Mode(t *testing.T) {
+	dir := writeFiles(t, "nsi.gpkg", "10yr.tif", "100yr.tif")
+	s, err := Load(writeScenario(t, dir, "scenario.json", eadScenarioJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Mode = ""
+	if s.ResolvedMode() != EAD {
+		t.Errorf("expected the mode to be inferred as ead, got %v", s.ResolvedMode())
+	}
+	s.Mode = MonteCarlo
+	err = s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ead settings are not used by mode monte_carlo") || !strings.Contains(err.Error(), "mode monte_carlo requires monte_carlo settings") {
+		t.Errorf("expected the mode to conflict with the settings, got %v", err)
+	}
+	s.Mode = "flood"
+	if s.Validate() == nil {
+		t.Error("expected an unknown mode to be invalid")
+	}
+}
+func TestPlan_ResolvesDefaults(t *testing.T) {
+	dir := writeFiles(t, "nsi.gpkg", "storm.tif")
+	content := `{
+	"mode": "lifecycle",
+	"structure_provider_info": {"structure_provider_type": "GPKG", "structure_file_path": "DIR/nsi.gpkg", "layername": "nsi"},
+	"results_writer_info": {"results_writer_type": "JSON", "output_file_path": "DIR/results.json"},
+	"lifecycle": {"storms": [{"name": "hurricane", "annual_probability": 0.1, "duration": 5, "hazard_provider_info": {"hazards": [{"hazard_parameter_type": "depth", "hazard_provider_file_path": "DIR/storm.tif"}]}}]}
+}`
+	s, err := Load(writeScenario(t, dir, "scenario.json", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := s.Plan()
+	for _, expected := range []string{"mode: lifecycle", "occupancy types: default", "storm hurricane: depth " + dir + "/storm.tif", `"lifecycles":100`, "results: JSON " + dir + "/results.json"} {
+		if !strings.Contains(plan, expected) {
+			t.Errorf("expected the plan to include %q, got:\n%v", expected, plan)
+		}
+	}
+}
+func TestPlan_AggregatedStageDamageLifelossSeed(t *testing.T) {
+	dir := writeFiles(t, "nsi.gpkg", "stage.tif")
+	content := `{
+	"mode": "aggregated_stage_damage",
+	"structure_provider_info": {"structure_provider_type": "GPKG", "structure_file_path": "DIR/nsi.gpkg", "layername": "nsi"},
+	"lifeloss_seed": 77,
+	"aggregated_stage_damage": {"stages": [{"hazards": [{"hazard_parameter_type": "depth", "hazard_provider_file_path": "DIR/stage.tif"}]}], "content_output_file_path": "DIR/content.csv", "structure_output_file_path": "DIR/structure.csv"}
+}`
+	s, err := Load(writeScenario(t, dir, "scenario.json", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MasterSeed() != 77 {
+		t.Errorf("expected the lifeloss seed 77 to be used, got %v", s.MasterSeed())
+	}
+	if plan := s.Plan(); !strings.Contains(plan, "seed: 77") {
+		t.Errorf("expected the plan to report seed 77, got:\n%v", plan)
+	}
+}
@@ -0,0 +1,548 @@
+package structureprovider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errMissingValue is returned by an expression that uses a column without a value.
+var errMissingValue = errors.New("structureprovider: an expression column has no value")
+
+// expression is a parsed attribute expression. Values are float64, string, or bool for conditions.
+type expression interface {
+	eval(lookup func(name string) (interface{}, bool)) (interface{}, error)
+	columns() []string
+	kind(columnKind func(name string) (valueKind, error)) (valueKind, error) //the kind of value eval returns, or an error if the kinds of its parts do not fit together
+}
+
+// valueKind is the kind of value an expression evaluates to.
+type valueKind int
+
+const (
+	numberKind valueKind = iota
+	textKind
+	conditionKind
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case numberKind:
+		return "a number"
+	case textKind:
+		return "text"
+	default:
+		return "a condition"
+	}
+}
+
+type numberLiteral float64
+
+func (n numberLiteral) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	return float64(n), nil
+}
+func (n numberLiteral) columns() []string { return nil }
+func (n numberLiteral) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	return numberKind, nil
+}
+
+type stringLiteral string
+
+func (s stringLiteral) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	return string(s), nil
+}
+func (s stringLiteral) columns() []string { return nil }
+func (s stringLiteral) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	return textKind, nil
+}
+
+type column string
+
+func (c column) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	v, ok := lookup(string(c))
+	if !ok {
+		return nil, errMissingValue
+	}
+	return v, nil
+}
+func (c column) columns() []string { return []string{string(c)} }
+func (c column) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	return columnKind(string(c))
+}
+
+type binaryOperation struct {
+	operator    string
+	left, right expression
+}
+
+func (b binaryOperation) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	l, err := b.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("structureprovider: %v requires numbers, got %v and %v", b.operator, l, r)
+	}
+	switch b.operator {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	default:
+		if rf == 0 {
+			return nil, errors.New("structureprovider: division by zero")
+		}
+		return lf / rf, nil
+	}
+}
+func (b binaryOperation) columns() []string {
+	return append(b.left.columns(), b.right.columns()...)
+}
+func (b binaryOperation) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	l, r, err := kinds(b.left, b.right, columnKind)
+	if err != nil {
+		return 0, err
+	}
+	if l != numberKind || r != numberKind {
+		return 0, fmt.Errorf("structureprovider: %v requires numbers, not %v and %v", b.operator, l, r)
+	}
+	return numberKind, nil
+}
+
+// comparison compares two numbers or two strings.
+type comparison struct {
+	operator    string
+	left, right expression
+}
+
+func (c comparison) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	l, err := c.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	order := 0
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("structureprovider: cannot compare %v to %v", l, r)
+		}
+		if lv < rv {
+			order = -1
+		} else if lv > rv {
+			order = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("structureprovider: cannot compare %v to %v", l, r)
+		}
+		order = strings.Compare(lv, rv)
+	default:
+		return nil, fmt.Errorf("structureprovider: cannot compare %v to %v", l, r)
+	}
+	switch c.operator {
+	case "=":
+		return order == 0, nil
+	case "!=", "<>":
+		return order != 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+func (c comparison) columns() []string {
+	return append(c.left.columns(), c.right.columns()...)
+}
+func (c comparison) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	l, r, err := kinds(c.left, c.right, columnKind)
+	if err != nil {
+		return 0, err
+	}
+	if l != r || l == conditionKind {
+		return 0, fmt.Errorf("structureprovider: cannot compare %v to %v", l, r)
+	}
+	return conditionKind, nil
+}
+
+// inList is true if a value equals any of a list of values.
+type inList struct {
+	value  expression
+	values []expression
+}
+
+func (in inList) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	for _, v := range in.values {
+		equal, err := comparison{operator: "=", left: in.value, right: v}.eval(lookup)
+		if err != nil {
+			return nil, err
+		}
+		if equal.(bool) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (in inList) columns() []string {
+	c := in.value.columns()
+	for _, v := range in.values {
+		c = append(c, v.columns()...)
+	}
+	return c
+}
+func (in inList) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	for _, v := range in.values {
+		_, err := comparison{operator: "=", left: in.value, right: v}.kind(columnKind)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return conditionKind, nil
+}
+
+// logicalOperation is AND or OR of two conditions, or NOT of the left condition.
+type logicalOperation struct {
+	operator    string
+	left, right expression
+}
+
+func (o logicalOperation) eval(lookup func(name string) (interface{}, bool)) (interface{}, error) {
+	condition := func(e expression) (bool, error) {
+		v, err := e.eval(lookup)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("structureprovider: %v requires conditions, got %v", o.operator, v)
+		}
+		return b, nil
+	}
+	l, err := condition(o.left)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case o.operator == "NOT":
+		return !l, nil
+	case o.operator == "AND" && !l:
+		return false, nil
+	case o.operator == "OR" && l:
+		return true, nil
+	}
+	return condition(o.right)
+}
+func (o logicalOperation) columns() []string {
+	if o.right == nil {
+		return o.left.columns()
+	}
+	return append(o.left.columns(), o.right.columns()...)
+}
+func (o logicalOperation) kind(columnKind func(name string) (valueKind, error)) (valueKind, error) {
+	operands := []expression{o.left}
+	if o.right != nil {
+		operands = append(operands, o.right)
+	}
+	for _, e := range operands {
+		k, err := e.kind(columnKind)
+		if err != nil {
+			return 0, err
+		}
+		if k != conditionKind {
+			return 0, fmt.Errorf("structureprovider: %v requires conditions, not %v", o.operator, k)
+		}
+	}
+	return conditionKind, nil
+}
+
+// kinds are the kinds of the two sides of an operation.
+func kinds(left expression, right expression, columnKind func(name string) (valueKind, error)) (valueKind, valueKind, error) {
+	l, err := left.kind(columnKind)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := right.kind(columnKind)
+	return l, r, err
+}
+
+// parseExpression parses an ogr style expression of columns, numbers and single quoted strings. Arithmetic uses +, -, *, / and parentheses, e.g. "(land_val + impr_val) * 1.1". Conditions compare with =, !=, <>, <, <=, > and >=, test membership with IN and NOT IN, and combine with AND, OR and NOT, e.g. "st_damcat = 'RES' AND val_struct > 0". Keywords are not case sensitive.
+func parseExpression(s string) (expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := expressionParser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("structureprovider: unexpected %v in expression %v", p.tokens[p.position].text, s)
+	}
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	stringToken
+	identifierToken
+	operatorToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{numberToken, string(runes[i:j])})
+			i = j
+		case r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != '\'' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("structureprovider: unterminated string in expression %v", s)
+			}
+			tokens = append(tokens, token{stringToken, string(runes[i+1 : j])})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{identifierToken, string(runes[i:j])})
+			i = j
+		case i+1 < len(runes) && (string(runes[i:i+2]) == "<=" || string(runes[i:i+2]) == ">=" || string(runes[i:i+2]) == "<>" || string(runes[i:i+2]) == "!="):
+			tokens = append(tokens, token{operatorToken, string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune("+-*/(),=<>", r):
+			tokens = append(tokens, token{operatorToken, string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("structureprovider: unexpected %q in expression %v", r, s)
+		}
+	}
+	return tokens, nil
+}
+
+// expressionParser is a recursive descent parser, each method parses one level of precedence.
+type expressionParser struct {
+	tokens   []token
+	position int
+}
+
+// accept consumes the next token if it is one of the operators.
+func (p *expressionParser) accept(operators ...string) (string, bool) {
+	if p.position >= len(p.tokens) || p.tokens[p.position].kind != operatorToken {
+		return "", false
+	}
+	for _, o := range operators {
+		if p.tokens[p.position].text == o {
+			p.position++
+			return o, true
+		}
+	}
+	return "", false
+}
+
+// keyword consumes the next token if it is the keyword.
+func (p *expressionParser) keyword(k string) bool {
+	if p.position < len(p.tokens) && p.tokens[p.position].kind == identifierToken && strings.EqualFold(p.tokens[p.position].text, k) {
+		p.position++
+		return true
+	}
+	return false
+}
+func (p *expressionParser) or() (expression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalOperation{operator: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+func (p *expressionParser) and() (expression, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalOperation{operator: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+func (p *expressionParser) not() (expression, error) {
+	if p.keyword("NOT") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return logicalOperation{operator: "NOT", left: e}, nil
+	}
+	return p.comparison()
+}
+func (p *expressionParser) comparison() (expression, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if o, ok := p.accept("=", "!=", "<>", "<", "<=", ">", ">="); ok {
+		right, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{operator: o, left: left, right: right}, nil
+	}
+	negated := p.keyword("NOT")
+	if !p.keyword("IN") {
+		if negated {
+			return nil, errors.New("structureprovider: expected IN after NOT")
+		}
+		return left, nil
+	}
+	if _, ok := p.accept("("); !ok {
+		return nil, errors.New("structureprovider: IN requires a parenthesized list")
+	}
+	in := inList{value: left}
+	for {
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		in.values = append(in.values, v)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if _, ok := p.accept(")"); !ok {
+		return nil, errors.New("structureprovider: IN list is missing a closing parenthesis")
+	}
+	if negated {
+		return logicalOperation{operator: "NOT", left: in}, nil
+	}
+	return in, nil
+}
+func (p *expressionParser) sum() (expression, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		o, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOperation{operator: o, left: left, right: right}
+	}
+}
+func (p *expressionParser) product() (expression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		o, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOperation{operator: o, left: left, right: right}
+	}
+}
+func (p *expressionParser) unary() (expression, error) {
+	if _, ok := p.accept("-"); ok {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryOperation{operator: "-", left: numberLiteral(0), right: e}, nil
+	}
+	return p.primary()
+}
+func (p *expressionParser) primary() (expression, error) {
+	if _, ok := p.accept("("); ok {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, errors.New("structureprovider: expression is missing a closing parenthesis")
+		}
+		return e, nil
+	}
+	if p.position >= len(p.tokens) {
+		return nil, errors.New("structureprovider: expression ends unexpectedly")
+	}
+	t := p.tokens[p.position]
+	p.position++
+	switch t.kind {
+	case numberToken:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("structureprovider: invalid number %v in expression", t.text)
+		}
+		return numberLiteral(v), nil
+	case stringToken:
+		return stringLiteral(t.text), nil
+	case identifierToken:
+		for _, k := range []string{"AND", "OR", "NOT", "IN"} {
+			if strings.EqualFold(t.text, k) {
+				return nil, fmt.Errorf("structureprovider: unexpected %v in expression", t.text)
+			}
+		}
+		return column(t.text), nil
+	}
+	return nil, fmt.Errorf("structureprovider: unexpected %v in expression", t.text)
+}
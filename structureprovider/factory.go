@@ -2,9 +2,13 @@ package structureprovider
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/filepaths"
 	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/structures"
 )
 
 type StructureProviderType string
@@ -42,12 +46,105 @@ type StructureProvider interface {
 	ByBbox(bbox geography.BBox, sp consequences.StreamProcessor)
 }
 
+// SeedableStructureProvider is a structure provider whose structures sample from seeds derived from a master seed
+type SeedableStructureProvider interface {
+	StructureProvider
+	SetSeed(seed int64)
+}
+
+// StreamErrorReporter is a structure provider whose streams can stop early, Err is the first error a stream stopped on since the provider was created.
+type StreamErrorReporter interface {
+	StructureProvider
+	Err() error
+}
+
+// StreamError is the error a stream of sp stopped on, nil if sp does not report stream errors or none has stopped.
+func StreamError(sp consequences.StreamProvider) error {
+	if r, ok := sp.(StreamErrorReporter); ok {
+		return r.Err()
+	}
+	return nil
+}
+
+// streamError holds the first error the streams of a provider stopped on, it is shared by the copies of the provider and safe for concurrent streams.
+type streamError struct {
+	mu  sync.Mutex
+	err error
+}
+
+// record keeps err if it is the first error, a provider created without a streamError prints it instead.
+func (e *streamError) record(err error) {
+	if e == nil {
+		fmt.Println(err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+func (e *streamError) Err() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
 type StructureProviderInfo struct {
-	StructureProviderDriver string                `json:"structure_provider_driver,omitempty"` // ESRI SHP, GPKG, PARQUET (OGR DRIVERS...)
-	StructureProviderType   StructureProviderType `json:"structure_provider_type"`             // Provider_NSI or Provider_Local
-	StructureFilePath       string                `json:"structure_file_path,omitempty"`       // Required if StructureProviderType == Provider_Local
-	OccTypeFilePath         string                `json:"occtype_file_path,omitempty"`         // optional
-	LayerName               string                `json:"layername,omitempty"`                 // required if specified a geopackage StructureFilePath
+	StructureProviderDriver  string                `json:"structure_provider_driver,omitempty"`   // ESRI SHP, GPKG, PARQUET (OGR DRIVERS...)
+	StructureProviderType    StructureProviderType `json:"structure_provider_type"`               // Provider_NSI or Provider_Local
+	StructureFilePath        string                `json:"structure_file_path,omitempty"`         // Required if StructureProviderType == Provider_Local
+	OccTypeFilePath          string                `json:"occtype_file_path,omitempty"`           // optional
+	LayerName                string                `json:"layername,omitempty"`                   // required if specified a geopackage StructureFilePath
+	OccTypeOverridesFilePath string                `json:"occtype_overrides_file_path,omitempty"` // optional, json occupancy types that replace or extend the damage functions of the occupancy types
+	Filter                   *StructureFilter      `json:"filter,omitempty"`                      // optional, restricts the structures streamed
+	FieldMapping             FieldMapping          `json:"field_mapping,omitempty"`               // optional, where a local layer's structure attributes are read from if its columns are not named as the NSI's
+	NSICache                 *NSICacheInfo         `json:"nsi_cache,omitempty"`                   // optional, caches the NSI API's features on disk and can serve them offline
+}
+
+// Validate reports every problem with the structure provider it can find without opening a dataset.
+func (spi StructureProviderInfo) Validate() error {
+	errs := make([]error, 0)
+	switch spi.StructureProviderType {
+	case NSIAPI:
+	case SHP, GPKG, OGR:
+		errs = append(errs, filepaths.Check("structure_file_path", spi.StructureFilePath))
+		if spi.StructureProviderType != SHP && spi.LayerName == "" {
+			errs = append(errs, errors.New("structureprovider: layername is required for "+spi.StructureProviderType.String()))
+		}
+		if spi.StructureProviderType == OGR && spi.StructureProviderDriver == "" {
+			errs = append(errs, errors.New("structureprovider: structure_provider_driver is required for "+spi.StructureProviderType.String()))
+		}
+	case "":
+		errs = append(errs, errors.New("structureprovider: structure_provider_type is required"))
+	default:
+		errs = append(errs, fmt.Errorf("structureprovider: unknown structure_provider_type %v", string(spi.StructureProviderType)))
+	}
+	if spi.OccTypeFilePath != "" {
+		errs = append(errs, filepaths.Check("occtype_file_path", spi.OccTypeFilePath))
+	}
+	if spi.OccTypeOverridesFilePath != "" {
+		errs = append(errs, filepaths.Check("occtype_overrides_file_path", spi.OccTypeOverridesFilePath))
+	}
+	if spi.Filter != nil {
+		errs = append(errs, spi.Filter.Validate())
+	}
+	if spi.NSICache != nil {
+		if spi.StructureProviderType != NSIAPI {
+			errs = append(errs, errors.New("structureprovider: nsi_cache is not used by "+spi.StructureProviderType.String()))
+		}
+		errs = append(errs, spi.NSICache.Validate())
+	}
+	if spi.FieldMapping != nil {
+		if spi.StructureProviderType == NSIAPI {
+			errs = append(errs, errors.New("structureprovider: field_mapping is not used by "+spi.StructureProviderType.String()))
+		}
+		errs = append(errs, spi.FieldMapping.Validate())
+	}
+	return errors.Join(errs...)
 }
 
 // NewStructureProvider generates a structure provider
@@ -57,41 +154,69 @@ func (spi StructureProviderInfo) CreateStructureProvider() (StructureProvider, e
 	switch spi.StructureProviderType {
 	case NSIAPI: // nsi
 
+		var nsp nsiStreamProvider
 		if len(spi.OccTypeFilePath) == 0 {
-			p = InitNSISP()
+			nsp = InitNSISP()
 		} else {
-			p = InitNSISPwithOcctypeFilePath(spi.OccTypeFilePath)
+			nsp = InitNSISPwithOcctypeFilePath(spi.OccTypeFilePath)
 		}
+		nsp.Cache = spi.NSICache
+		p = &nsp
 
 	case SHP:
-		if len(spi.OccTypeFilePath) == 0 {
-			p, err = InitStructureProvider(spi.StructureFilePath, spi.LayerName, "ESRI Shapefile")
-		} else {
-			p, err = InitStructureProviderwithOcctypePath(spi.StructureFilePath, spi.LayerName, "ESRI Shapefile", spi.OccTypeFilePath)
-		}
+		p, err = spi.initLocal("ESRI Shapefile")
 	case GPKG:
 		if spi.LayerName == "" {
 			return nil, errors.New("NewStructureProvider - LayerName must be specified in StructureProviderInfo for geopackage provider")
 		}
-		if len(spi.OccTypeFilePath) == 0 {
-			p, err = InitStructureProvider(spi.StructureFilePath, spi.LayerName, "GPKG")
-		} else {
-			p, err = InitStructureProviderwithOcctypePath(spi.StructureFilePath, spi.LayerName, "GPKG", spi.OccTypeFilePath)
-		}
+		p, err = spi.initLocal("GPKG")
 	case OGR:
 		if spi.LayerName == "" {
 			return nil, errors.New("NewStructureProvider - LayerName must be specified in StructureProviderInfo for ogr provider")
 		}
-		if len(spi.OccTypeFilePath) == 0 {
-			p, err = InitStructureProvider(spi.StructureFilePath, spi.LayerName, spi.StructureProviderDriver)
-		} else {
-			p, err = InitStructureProviderwithOcctypePath(spi.StructureFilePath, spi.LayerName, spi.StructureProviderDriver, spi.OccTypeFilePath)
-		}
+		p, err = spi.initLocal(spi.StructureProviderDriver)
 	case Unknown:
 		return nil, errors.New("NewStructureProvider - unable to generate new structure provider from " + spi.StructureProviderType.String())
 
 	default:
 		return nil, errors.New("NewStructureProvider - unable to generate new structure provider for from " + spi.StructureProviderType.String())
 	}
-	return p, err
+	if err != nil {
+		return p, err
+	}
+	if spi.OccTypeOverridesFilePath != "" {
+		err = overrideOccupancyTypes(p, spi.OccTypeOverridesFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if spi.Filter != nil {
+		return Filter(p, *spi.Filter)
+	}
+	return p, nil
+}
+
+// initLocal opens a local structure layer with the gdal driver, reading its columns through the field mapping if there is one.
+func (spi StructureProviderInfo) initLocal(driver string) (StructureProvider, error) {
+	if spi.FieldMapping != nil {
+		return InitStructureProviderWithFieldMapping(spi.StructureFilePath, spi.LayerName, driver, spi.OccTypeFilePath, spi.FieldMapping)
+	}
+	if len(spi.OccTypeFilePath) == 0 {
+		return InitStructureProvider(spi.StructureFilePath, spi.LayerName, driver)
+	}
+	return InitStructureProviderwithOcctypePath(spi.StructureFilePath, spi.LayerName, driver, spi.OccTypeFilePath)
+}
+
+// overrideOccupancyTypes applies the occupancy type overrides at path to the occupancy types of p.
+func overrideOccupancyTypes(p StructureProvider, path string) error {
+	var otp structures.OccupancyTypeProvider
+	switch sp := p.(type) {
+	case *gdalDataSet:
+		otp = sp.OccTypeProvider
+	case *nsiStreamProvider:
+		otp = sp.OccTypeProvider
+	default:
+		return fmt.Errorf("structureprovider: occupancy type overrides are not supported by %T", p)
+	}
+	return structures.OverrideOccupancyTypesFromFile(otp.OccupancyTypeMap(), path)
 }
@@ -0,0 +1,309 @@
+package structureprovider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/USACE/go-consequences/structures"
+)
+
+// Conversion converts a mapped number into the units of the structure inventory, feet and dollars.
+type Conversion string
+
+const (
+	MetersToFeet       Conversion = "meters_to_feet"
+	ThousandsOfDollars Conversion = "thousands_of_dollars"
+	MillionsOfDollars  Conversion = "millions_of_dollars"
+)
+
+// scale multiplies a value into feet or dollars, ok is false for an unknown conversion.
+func (c Conversion) scale() (float64, bool) {
+	switch c {
+	case "":
+		return 1, true
+	case MetersToFeet:
+		return 3.28084, true
+	case ThousandsOfDollars:
+		return 1000, true
+	case MillionsOfDollars:
+		return 1000000, true
+	}
+	return 0, false
+}
+
+// FieldSource is where a structure attribute is read from: a column, an arithmetic expression of columns, or a constant. Default is used where the column is missing from the layer or a feature has no value for it, and can be given alone. Without a default a feature the expression cannot be evaluated for is not streamed and the stream reports an error. Conversions apply to columns and expressions, constants and defaults are already in feet and dollars.
+type FieldSource struct {
+	Field      string      `json:"field,omitempty"`
+	Expression string      `json:"expression,omitempty"` //e.g. "land_val + impr_val", see parseExpression
+	Constant   interface{} `json:"constant,omitempty"`
+	Conversion Conversion  `json:"conversion,omitempty"`
+	Default    interface{} `json:"default,omitempty"`
+}
+
+// FieldMapping maps structure attributes, named by their NSI column such as fd_id, val_struct or found_ht, to where they are read from in a structure layer, so an assessor or parcel inventory can be used without renaming its columns. An attribute that is not mapped is read from the column of the same name.
+type FieldMapping map[string]FieldSource
+
+// stringAttributes are the structure attributes read as text, the others are numbers.
+var stringAttributes = []string{"fd_id", "cbfips", "st_damcat", "occtype", "found_type", "bldgtype", "firmzone"}
+
+// requiredAttributes must have a source, x and y are only read for features without a geometry.
+func requiredAttributes() []string {
+	required := make([]string, 0)
+	for _, a := range StructureSchema() {
+		if a != "x" && a != "y" {
+			required = append(required, a)
+		}
+	}
+	return required
+}
+
+// Validate checks every mapped attribute is known and has at most one source, and that conversions, constants and defaults suit the attribute.
+func (fm FieldMapping) Validate() error {
+	attributes := append(StructureSchema(), OptionalSchema()...)
+	errs := make([]error, 0)
+	for attribute, source := range fm {
+		if !contains(attributes, attribute) {
+			errs = append(errs, fmt.Errorf("structureprovider: field_mapping has unknown attribute %v", attribute))
+			continue
+		}
+		sources := 0
+		for _, set := range []bool{source.Field != "", source.Expression != "", source.Constant != nil} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v can only have one of a field, an expression and a constant", attribute))
+		}
+		if sources == 0 && source.Default == nil {
+			errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v requires a field, an expression, a constant or a default", attribute))
+		}
+		text := contains(stringAttributes, attribute)
+		if _, ok := source.Conversion.scale(); !ok {
+			errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v has unknown conversion %v", attribute, source.Conversion))
+		} else if text && source.Conversion != "" {
+			errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v is text and cannot be converted", attribute))
+		}
+		if source.Expression != "" {
+			if text {
+				errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v is text and cannot be an expression", attribute))
+			} else if e, err := parseExpression(source.Expression); err != nil {
+				errs = append(errs, fmt.Errorf("field_mapping %v: %w", attribute, err))
+			} else if k, err := e.kind(columnKind); err != nil {
+				errs = append(errs, fmt.Errorf("field_mapping %v: %w", attribute, err))
+			} else if k != numberKind {
+				errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v must be arithmetic, not %v", attribute, k))
+			}
+		}
+		for name, v := range map[string]interface{}{"constant": source.Constant, "default": source.Default} {
+			if v == nil {
+				continue
+			}
+			_, isText := v.(string)
+			_, isNumber := v.(float64)
+			if text && !isText {
+				errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v %v must be text", attribute, name))
+			}
+			if !text && !isNumber {
+				errs = append(errs, fmt.Errorf("structureprovider: field_mapping %v %v must be a number", attribute, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// columnKind is the kind of a column in a field mapping expression, which reads every column as a number.
+func columnKind(name string) (valueKind, error) {
+	return numberKind, nil
+}
+
+// featureValues are the methods of a gdal feature that structure attributes are read with.
+type featureValues interface {
+	FieldAsString(index int) string
+	FieldAsFloat64(index int) float64
+	IsFieldSetAndNotNull(index int) bool
+}
+
+// mappedField reads one structure attribute from a feature.
+type mappedField struct {
+	index      int            //source column, -1 if there is none
+	expression expression     //set if the attribute is an expression
+	columns    map[string]int //the column of each name in the expression
+	constant   interface{}
+	fallback   interface{} //the default
+	scale      float64
+	text       bool
+}
+
+// value is the attribute of f, ok is false if it has none.
+func (m mappedField) value(f featureValues) (interface{}, bool) {
+	if m.constant != nil {
+		return m.constant, true
+	}
+	if m.expression != nil {
+		if n, err := m.evaluate(f); err == nil {
+			return n, true
+		}
+	} else if m.index >= 0 && f.IsFieldSetAndNotNull(m.index) {
+		if m.text {
+			return f.FieldAsString(m.index), true
+		}
+		return f.FieldAsFloat64(m.index) * m.scale, true
+	}
+	if m.fallback != nil {
+		return m.fallback, true
+	}
+	return nil, false
+}
+
+// evaluate is the expression of m for f, it is an error if a column it uses is missing or has no value.
+func (m mappedField) evaluate(f featureValues) (float64, error) {
+	v, err := m.expression.eval(func(name string) (interface{}, bool) {
+		i := m.columns[name]
+		if i < 0 || !f.IsFieldSetAndNotNull(i) {
+			return nil, false
+		}
+		return f.FieldAsFloat64(i), true
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("structureprovider: expression is %v, not a number", v)
+	}
+	return n * m.scale, nil
+}
+func (m mappedField) available() bool {
+	return m.index >= 0 || m.expression != nil || m.constant != nil || m.fallback != nil
+}
+
+// structureFields reads the structure attributes of a layer's features, keyed by attribute.
+type structureFields map[string]mappedField
+
+// resolve finds the columns of every attribute in a layer, fieldIndex is the index of a column or -1 if the layer does not have it. It is an error if a required attribute has no source, or a mapped column is missing without a default.
+func (fm FieldMapping) resolve(fieldIndex func(name string) int) (structureFields, error) {
+	fields := make(structureFields)
+	errs := make([]error, 0)
+	for _, attribute := range append(StructureSchema(), OptionalSchema()...) {
+		source, mapped := fm[attribute]
+		scale, _ := source.Conversion.scale()
+		mf := mappedField{index: -1, constant: source.Constant, fallback: source.Default, scale: scale, text: contains(stringAttributes, attribute)}
+		switch {
+		case source.Field != "":
+			mf.index = fieldIndex(source.Field)
+			if mf.index < 0 && mf.fallback == nil {
+				errs = append(errs, fmt.Errorf("structureprovider: %v is mapped to column %v, which the layer does not have", attribute, source.Field))
+			}
+		case source.Expression != "":
+			e, err := parseExpression(source.Expression)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			mf.expression = e
+			mf.columns = make(map[string]int)
+			for _, c := range e.columns() {
+				mf.columns[c] = fieldIndex(c)
+				if mf.columns[c] < 0 && mf.fallback == nil {
+					errs = append(errs, fmt.Errorf("structureprovider: the expression for %v uses column %v, which the layer does not have", attribute, c))
+				}
+			}
+		case !mapped:
+			mf.index = fieldIndex(attribute)
+		}
+		if contains(requiredAttributes(), attribute) && !mf.available() {
+			errs = append(errs, fmt.Errorf("structureprovider: expected a column named %v, or a field_mapping for it, none was found", attribute))
+		}
+		fields[attribute] = mf
+	}
+	return fields, errors.Join(errs...)
+}
+func (sf structureFields) text(f featureValues, attribute string) string {
+	v, ok := sf[attribute].value(f)
+	if !ok {
+		return ""
+	}
+	s, _ := v.(string)
+	return s
+}
+func (sf structureFields) number(f featureValues, attribute string) (float64, bool) {
+	v, ok := sf[attribute].value(f)
+	if !ok {
+		return 0, false
+	}
+	n, ok := v.(float64)
+	return n, ok
+}
+func (sf structureFields) count(f featureValues, attribute string) int32 {
+	n, _ := sf.number(f, attribute)
+	return int32(math.Round(n))
+}
+
+// featureAttributes are the attributes of a feature read through a layer's structureFields.
+type featureAttributes struct {
+	structures.BaseStructure
+	structures.PopulationSet
+	hasXY                                          bool //x and y were read, for features without a geometry
+	occtype, foundType, firmZone, constructionType string
+	structVal, contVal, foundHt                    float64
+	numStories                                     int32
+}
+
+// read reads the attributes of f, it is an error if an expression without a default cannot be evaluated for f rather than the attribute being zero.
+func (sf structureFields) read(f featureValues) (featureAttributes, error) {
+	a := featureAttributes{
+		occtype:          sf.text(f, "occtype"),
+		foundType:        sf.text(f, "found_type"),
+		firmZone:         sf.text(f, "firmzone"),
+		constructionType: sf.text(f, "bldgtype"),
+		numStories:       sf.count(f, "num_story"),
+	}
+	a.Name = sf.text(f, "fd_id")
+	a.CBFips = sf.text(f, "cbfips")
+	a.DamCat = sf.text(f, "st_damcat")
+	x, hasX := sf.number(f, "x")
+	y, hasY := sf.number(f, "y")
+	a.X, a.Y, a.hasXY = x, y, hasX && hasY
+	a.GroundElevation, a.HasGroundElevation = sf.number(f, "ground_elv")
+	a.structVal, _ = sf.number(f, "val_struct")
+	a.contVal, _ = sf.number(f, "val_cont")
+	a.foundHt, _ = sf.number(f, "found_ht")
+	a.Pop2amu65 = sf.count(f, "pop2amu65")
+	a.Pop2amo65 = sf.count(f, "pop2amo65")
+	a.Pop2pmu65 = sf.count(f, "pop2pmu65")
+	a.Pop2pmo65 = sf.count(f, "pop2pmo65")
+	for _, attribute := range append(StructureSchema(), OptionalSchema()...) {
+		mf := sf[attribute]
+		if mf.expression == nil || mf.constant != nil || mf.fallback != nil {
+			continue
+		}
+		if _, err := mf.evaluate(f); err != nil {
+			return a, fmt.Errorf("structureprovider: %v of structure %v: %w", attribute, a.Name, err)
+		}
+	}
+	return a, nil
+}
+
+// fipsColumn is the column cbfips is read from, or -1 if it is a constant or default so streaming by fips cannot filter on a column.
+func (sf structureFields) fipsColumn() int {
+	cb := sf["cbfips"]
+	if cb.constant != nil {
+		return -1
+	}
+	return cb.index
+}
+
+// occupancyType is the occupancy type of the name and foundation type, or of the name alone, or the default if neither is known.
+func occupancyType[T any](m map[string]T, defaultOcctype T, name string, foundType string) T {
+	if foundType != "" {
+		if ot, ok := m[name+"-"+foundType]; ok {
+			return ot
+		}
+	}
+	if ot, ok := m[name]; ok {
+		return ot
+	}
+	return defaultOcctype
+}
@@ -0,0 +1,187 @@
+package structureprovider
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// testFeature is a feature of a layer with columns, a nil value is null.
+type testFeature struct {
+	columns []string
+	values  []interface{}
+}
+
+func (f testFeature) index(name string) int {
+	for i, c := range f.columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+func (f testFeature) FieldAsString(index int) string {
+	s, _ := f.values[index].(string)
+	return s
+}
+func (f testFeature) FieldAsFloat64(index int) float64 {
+	n, _ := f.values[index].(float64)
+	return n
+}
+func (f testFeature) IsFieldSetAndNotNull(index int) bool {
+	return f.values[index] != nil
+}
+
+const parcelMapping = `{
+	"fd_id": {"field": "PARCEL_ID"},
+	"cbfips": {"field": "BLOCK"},
+	"st_damcat": {"constant": "RES"},
+	"occtype": {"field": "USE_CODE"},
+	"val_struct": {"expression": "IMPR_VAL + OUTBLDG_VAL", "conversion": "thousands_of_dollars"},
+	"val_cont": {"expression": "IMPR_VAL / 2", "conversion": "thousands_of_dollars"},
+	"found_ht": {"field": "FFH_M", "conversion": "meters_to_feet", "default": 1},
+	"found_type": {"default": "S"},
+	"num_story": {"field": "STORIES", "default": 1}
+}`
+
+func TestFieldMapping_ParcelInventory(t *testing.T) {
+	var fm FieldMapping
+	err := json.Unmarshal([]byte(parcelMapping), &fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fm.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	f := testFeature{
+		columns: []string{"PARCEL_ID", "BLOCK", "USE_CODE", "IMPR_VAL", "OUTBLDG_VAL", "FFH_M", "STORIES"},
+		values:  []interface{}{"0042-117-A", "482015100001000", "RES1-1SNB", 180.0, 20.0, nil, 2.0},
+	}
+	fields, err := fm.resolve(f.index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := fields.read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "0042-117-A" || a.CBFips != "482015100001000" || a.DamCat != "RES" || a.occtype != "RES1-1SNB" || a.foundType != "S" {
+		t.Errorf("unexpected text attributes %+v", a)
+	}
+	if a.structVal != 200000 || a.contVal != 90000 || a.foundHt != 1 || a.numStories != 2 {
+		t.Errorf("expected 200000 and 90000 dollars, a default 1 foot foundation and 2 stories, got %v %v %v %v", a.structVal, a.contVal, a.foundHt, a.numStories)
+	}
+	if a.hasXY || a.HasGroundElevation {
+		t.Errorf("expected no x, y or ground elevation, got %+v", a)
+	}
+	f.values[5] = 1.0
+	if a, err = fields.read(f); err != nil || a.foundHt != 3.28084 {
+		t.Errorf("expected a 1 meter foundation to be 3.28084 feet, got %v %v", a.foundHt, err)
+	}
+	f.values[4] = nil
+	if _, err = fields.read(f); !errors.Is(err, errMissingValue) {
+		t.Errorf("expected a structure value without an outbuilding value to be an error, got %v", err)
+	}
+	withDefault := fields["val_struct"]
+	withDefault.fallback = 150000.0
+	fields["val_struct"] = withDefault
+	if a, err = fields.read(f); err != nil || a.structVal != 150000 {
+		t.Errorf("expected the default structure value without an outbuilding value, got %v %v", a.structVal, err)
+	}
+}
+func TestFieldMapping_Resolve(t *testing.T) {
+	nsi := testFeature{columns: append(StructureSchema(), "pop2amu65")}
+	if _, err := FieldMapping(nil).resolve(nsi.index); err != nil {
+		t.Errorf("expected the nsi columns to need no mapping, got %v", err)
+	}
+	parcels := testFeature{columns: []string{"fd_id", "cbfips", "st_damcat", "occtype", "val_struct", "val_cont", "found_ht"}}
+	if _, err := FieldMapping(nil).resolve(parcels.index); err == nil {
+		t.Error("expected a layer without found_type to require a mapping")
+	}
+	for _, fm := range []FieldMapping{
+		{"found_type": {Field: "FOUNDATION"}},
+		{"found_type": {Default: "S"}, "val_struct": {Expression: "LAND + IMPR"}},
+	} {
+		if _, err := fm.resolve(parcels.index); err == nil {
+			t.Errorf("expected %v to refer to a missing column", fm)
+		}
+	}
+	if _, err := (FieldMapping{"found_type": {Field: "FOUNDATION", Default: "S"}}).resolve(parcels.index); err != nil {
+		t.Errorf("expected a default to stand in for a missing column, got %v", err)
+	}
+	if _, err := (FieldMapping{"found_type": {Default: "S"}, "val_struct": {Expression: "LAND + IMPR", Default: 100000.0}}).resolve(parcels.index); err != nil {
+		t.Errorf("expected a default to stand in for an expression of missing columns, got %v", err)
+	}
+}
+func TestFieldMapping_Validate(t *testing.T) {
+	for _, invalid := range []FieldMapping{
+		{"parcel": {Field: "PARCEL_ID"}},
+		{"val_struct": {Field: "VALUE", Constant: 100.0}},
+		{"val_struct": {}},
+		{"val_struct": {Field: "VALUE", Conversion: "acres"}},
+		{"fd_id": {Field: "PARCEL_ID", Conversion: MetersToFeet}},
+		{"occtype": {Expression: "USE_CODE"}},
+		{"val_struct": {Expression: "(LAND + IMPR"}},
+		{"found_ht": {Constant: "1"}},
+		{"cbfips": {Default: 48201.0}},
+		{"val_struct": {Expression: "VALUE > 0"}},
+		{"val_struct": {Expression: "LAND + 'A'"}},
+	} {
+		if invalid.Validate() == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+}
+func TestParseExpression(t *testing.T) {
+	lookup := func(name string) (interface{}, bool) {
+		v, ok := map[string]interface{}{"a": 6.0, "b": 2.0}[name]
+		return v, ok
+	}
+	for expression, expected := range map[string]float64{
+		"a + b * 2":   10,
+		"(a + b) * 2": 16,
+		"a / b - 1":   2,
+		"-a + 10":     4,
+		"1.5":         1.5,
+	} {
+		e, err := parseExpression(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := e.eval(lookup)
+		if err != nil || v != expected {
+			t.Errorf("expected %v to be %v, got %v %v", expression, expected, v, err)
+		}
+	}
+	e, _ := parseExpression("a + missing")
+	if _, err := e.eval(lookup); err != errMissingValue {
+		t.Errorf("expected a missing column to have no value, got %v", err)
+	}
+	for expression, expected := range map[string]bool{
+		"a > b":                         true,
+		"a >= 6 AND b != 2":             false,
+		"a < b OR NOT b <> 2":           true,
+		"a * 2 = 12 and 'RES' = 'RES'":  true,
+		"a IN (1, 2, 3)":                false,
+		"a NOT IN (1, 2, 3)":            true,
+		"(a = 1 OR b = 2) AND a <= 6.0": true,
+	} {
+		e, err := parseExpression(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := e.eval(lookup)
+		if k, _ := e.kind(columnKind); err != nil || v != expected || k != conditionKind {
+			t.Errorf("expected condition %v to be %v, got %v %v", expression, expected, v, err)
+		}
+	}
+	e, _ = parseExpression("a = 'RES'")
+	if _, err := e.eval(lookup); err == nil {
+		t.Error("expected an error comparing a number to text")
+	}
+	for _, invalid := range []string{"a +", "a b", "(a", "a % b", "'open", "a = ", "a AND", "a NOT b", "a IN (1", "and = 1"} {
+		if _, err := parseExpression(invalid); err == nil {
+			t.Errorf("expected %v not to parse", invalid)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package structureprovider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/filepaths"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/projection"
+	"github.com/USACE/go-consequences/structures"
+	"github.com/USACE/go-consequences/vectorlayer"
+)
+
+// StructureFilter restricts the structures a provider streams, an empty list, expression or polygon file does not restrict the stream. Receptors that are not structures are not filtered.
+type StructureFilter struct {
+	DamageCategories []string `json:"damage_categories,omitempty"` //st_damcat values to keep
+	OccupancyTypes   []string `json:"occupancy_types,omitempty"`   //occupancy type names to keep
+	IncludeFdIds     []string `json:"include_fd_ids,omitempty"`    //fd_ids to keep
+	ExcludeFdIds     []string `json:"exclude_fd_ids,omitempty"`    //fd_ids to drop, even if they are included
+	Expression       string   `json:"expression,omitempty"`        //ogr style condition on the NSI attributes of a structure, e.g. "st_damcat = 'RES' AND val_struct > 0", see parseExpression
+	PolygonFilePath  string   `json:"polygon_file_path,omitempty"` //polygon or multipolygon layer, only structures inside one of its polygons are kept
+	PolygonDriver    string   `json:"polygon_driver,omitempty"`    //ogr driver of the polygon layer, such as GPKG, ESRI Shapefile or GeoJSON
+	PolygonLayerName string   `json:"polygon_layername,omitempty"` //the first layer if not set
+}
+
+// Validate checks an fd_id is not both included and excluded, the expression is a condition on known attributes, and the polygon layer exists and has a driver.
+func (f StructureFilter) Validate() error {
+	errs := make([]error, 0)
+	for _, id := range f.ExcludeFdIds {
+		if contains(f.IncludeFdIds, id) {
+			errs = append(errs, errors.New("structureprovider: fd_id "+id+" is both included and excluded"))
+		}
+	}
+	if f.Expression != "" {
+		_, err := parseCondition(f.Expression)
+		errs = append(errs, err)
+	}
+	if f.PolygonFilePath != "" {
+		errs = append(errs, filepaths.Check("filter polygon_file_path", f.PolygonFilePath))
+		if f.PolygonDriver == "" {
+			errs = append(errs, errors.New("structureprovider: a filter polygon_file_path requires a polygon_driver"))
+		}
+	} else if f.PolygonDriver != "" || f.PolygonLayerName != "" {
+		errs = append(errs, errors.New("structureprovider: a filter polygon_driver or polygon_layername requires a polygon_file_path"))
+	}
+	return errors.Join(errs...)
+}
+
+// parseCondition parses a filter expression, which must be a condition on the attributes filterAttributes names, comparing text attributes to text and numbers to numbers.
+func parseCondition(s string) (expression, error) {
+	e, err := parseExpression(s)
+	if err != nil {
+		return nil, fmt.Errorf("filter expression: %w", err)
+	}
+	k, err := e.kind(attributeKind)
+	if err != nil {
+		return nil, fmt.Errorf("filter expression %v: %w", s, err)
+	}
+	if k != conditionKind {
+		return nil, fmt.Errorf("structureprovider: filter expression %v is not a condition", s)
+	}
+	return e, nil
+}
+
+// attributeKind is the kind of a structure attribute named by its NSI column, in any case.
+func attributeKind(name string) (valueKind, error) {
+	name = strings.ToLower(name)
+	if contains(stringAttributes, name) {
+		return textKind, nil
+	}
+	if !contains(append(StructureSchema(), OptionalSchema()...), name) {
+		return 0, fmt.Errorf("structureprovider: unknown attribute %v", name)
+	}
+	return numberKind, nil
+}
+
+// Keeps is true if the filter's lists pass r, the expression and polygons are applied by the provider Filter returns.
+func (f StructureFilter) Keeps(r consequences.Receptor) bool {
+	var base structures.BaseStructure
+	var occtype string
+	switch s := r.(type) {
+	case structures.StructureStochastic:
+		base, occtype = s.BaseStructure, s.OccType.Name
+	case structures.StructureDeterministic:
+		base, occtype = s.BaseStructure, s.OccType.Name
+	default:
+		return true
+	}
+	if len(f.DamageCategories) > 0 && !contains(f.DamageCategories, base.DamCat) {
+		return false
+	}
+	if len(f.OccupancyTypes) > 0 && !contains(f.OccupancyTypes, occtype) {
+		return false
+	}
+	if len(f.IncludeFdIds) > 0 && !contains(f.IncludeFdIds, base.Name) {
+		return false
+	}
+	return !contains(f.ExcludeFdIds, base.Name)
+}
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAttributes are the attributes of a structure a filter expression can use, named by their NSI column, stochastic values by their central tendency. ok is false if r is not a structure.
+func filterAttributes(r consequences.Receptor) (map[string]interface{}, bool) {
+	var base structures.BaseStructure
+	var pop structures.PopulationSet
+	a := make(map[string]interface{})
+	switch s := r.(type) {
+	case structures.StructureStochastic:
+		base, pop = s.BaseStructure, s.PopulationSet
+		a["occtype"], a["found_type"], a["firmzone"], a["bldgtype"] = s.OccType.Name, s.FoundType, s.FirmZone, s.ConstructionType
+		a["val_struct"], a["val_cont"], a["found_ht"] = s.StructVal.CentralTendency(), s.ContVal.CentralTendency(), s.FoundHt.CentralTendency()
+		a["num_story"] = float64(s.NumStories)
+	case structures.StructureDeterministic:
+		base, pop = s.BaseStructure, s.PopulationSet
+		a["occtype"], a["found_type"], a["firmzone"], a["bldgtype"] = s.OccType.Name, s.FoundType, s.FirmZone, s.ConstructionType
+		a["val_struct"], a["val_cont"], a["found_ht"] = s.StructVal, s.ContVal, s.FoundHt
+		a["num_story"] = float64(s.NumStories)
+	default:
+		return nil, false
+	}
+	a["fd_id"], a["cbfips"], a["st_damcat"], a["x"], a["y"] = base.Name, base.CBFips, base.DamCat, base.X, base.Y
+	a["pop2amu65"], a["pop2amo65"] = float64(pop.Pop2amu65), float64(pop.Pop2amo65)
+	a["pop2pmu65"], a["pop2pmo65"] = float64(pop.Pop2pmu65), float64(pop.Pop2pmo65)
+	if base.HasGroundElevation {
+		a["ground_elv"] = base.GroundElevation
+	}
+	return a, true
+}
+
+// filterPolygons are the polygon rings of a filter's polygon layer in the layer's reference system.
+type filterPolygons struct {
+	polygons [][][][2]float64 //rings of each feature, holes included, a location is inside a feature by the even odd rule over its rings
+	bbox     [4]float64       //min x, min y, max x, max y of every polygon
+	srid     string
+}
+
+// contains is true if x, y is inside any of the features.
+func (fp filterPolygons) contains(x float64, y float64) bool {
+	if x < fp.bbox[0] || x > fp.bbox[2] || y < fp.bbox[1] || y > fp.bbox[3] {
+		return false
+	}
+	for _, rings := range fp.polygons {
+		inside := false
+		for _, r := range rings {
+			if geography.RingContains(x, y, r) {
+				inside = !inside
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+func newFilterPolygons(polygons [][][][2]float64, srid string) filterPolygons {
+	fp := filterPolygons{polygons: polygons, srid: srid, bbox: [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+	for _, rings := range polygons {
+		for _, r := range rings {
+			for _, p := range r {
+				fp.bbox[0], fp.bbox[1] = math.Min(fp.bbox[0], p[0]), math.Min(fp.bbox[1], p[1])
+				fp.bbox[2], fp.bbox[3] = math.Max(fp.bbox[2], p[0]), math.Max(fp.bbox[3], p[1])
+			}
+		}
+	}
+	return fp
+}
+
+// readFilterPolygons reads the rings of every polygon feature of the filter's polygon layer, the layer is closed once it is read.
+func (f StructureFilter) readFilterPolygons() (filterPolygons, error) {
+	ds, l, err := vectorlayer.Open(f.PolygonDriver, f.PolygonFilePath, f.PolygonLayerName)
+	if err != nil {
+		return filterPolygons{}, fmt.Errorf("filter polygons: %w", err)
+	}
+	defer ds.Destroy()
+	srid := vectorlayer.SpatialReference(l)
+	polygons := make([][][][2]float64, 0)
+	l.ResetReading()
+	for feature := l.NextFeature(); feature != nil; feature = l.NextFeature() {
+		g := feature.Geometry()
+		if !g.IsNull() && !g.IsEmpty() {
+			if _, rings := vectorlayer.ReadGeometry(g, nil, nil); len(rings) > 0 {
+				polygons = append(polygons, rings)
+			}
+		}
+		feature.Destroy()
+	}
+	if len(polygons) == 0 {
+		return filterPolygons{}, errors.New("structureprovider: filter polygons " + f.PolygonFilePath + " has no polygon features")
+	}
+	return newFilterPolygons(polygons, srid), nil
+}
+
+// attributeCondition compares a structure attribute to literals, so a provider can test it on a feature's columns before the feature becomes a structure.
+type attributeCondition struct {
+	attribute string        //lower case NSI attribute
+	operator  string        //=, <>, <, <=, >, >= or IN
+	values    []interface{} //float64 or string literals, more than one only for IN
+}
+
+// sql is the condition as an ogr sql clause on column. A feature whose column is null is not ruled out, its structure may take a default.
+func (c attributeCondition) sql(column string) string {
+	values := make([]string, len(c.values))
+	for i, v := range c.values {
+		if s, ok := v.(string); ok {
+			values[i] = "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		} else {
+			values[i] = strconv.FormatFloat(v.(float64), 'f', -1, 64)
+		}
+	}
+	column = `"` + strings.ReplaceAll(column, `"`, `""`) + `"`
+	test := column + " " + c.operator + " " + values[0]
+	if c.operator == "IN" {
+		test = column + " IN (" + strings.Join(values, ", ") + ")"
+	}
+	return "(" + column + " IS NULL OR " + test + ")"
+}
+
+// prefilter is the part of a filter a provider can apply as it reads features. Every structure the filter keeps meets all of the conditions and is within bounds, so a provider can skip the features that do not.
+type prefilter struct {
+	conditions []attributeCondition
+	bounds     *geography.BBox //extent of the polygon layer in its reference system, nil if the filter has no polygons
+}
+
+// prefilteringProvider is a structure provider that can skip the features a prefilter rules out before they become structures, the filter is still applied to every structure it streams.
+type prefilteringProvider interface {
+	byFipsPrefiltered(fipscode string, pf prefilter, sp consequences.StreamProcessor)
+	byBboxPrefiltered(bbox geography.BBox, pf prefilter, sp consequences.StreamProcessor)
+}
+
+// conditions are the comparisons of an attribute to literals e requires, from the parts of e joined by AND. Other parts of e are left to the filter.
+func conditions(e expression) []attributeCondition {
+	reversed := map[string]string{"=": "=", "!=": "<>", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+	switch c := e.(type) {
+	case logicalOperation:
+		if c.operator == "AND" {
+			return append(conditions(c.left), conditions(c.right)...)
+		}
+	case comparison:
+		col, isColumn := c.left.(column)
+		v, isLiteral := literal(c.right)
+		operator := c.operator
+		if !isColumn || !isLiteral {
+			col, isColumn = c.right.(column)
+			v, isLiteral = literal(c.left)
+			operator = reversed[operator]
+		}
+		if isColumn && isLiteral {
+			if operator == "!=" {
+				operator = "<>"
+			}
+			return []attributeCondition{{attribute: strings.ToLower(string(col)), operator: operator, values: []interface{}{v}}}
+		}
+	case inList:
+		col, isColumn := c.value.(column)
+		values := make([]interface{}, len(c.values))
+		for i, e := range c.values {
+			v, isLiteral := literal(e)
+			if !isLiteral {
+				return nil
+			}
+			values[i] = v
+		}
+		if isColumn {
+			return []attributeCondition{{attribute: strings.ToLower(string(col)), operator: "IN", values: values}}
+		}
+	}
+	return nil
+}
+func literal(e expression) (interface{}, bool) {
+	switch l := e.(type) {
+	case numberLiteral:
+		return float64(l), true
+	case stringLiteral:
+		return string(l), true
+	}
+	return nil, false
+}
+
+// newPrefilter is the part of the filter a provider can apply as it reads features: the damage categories, the included fd_ids, the comparisons the expression requires and the extent of the polygons.
+func newPrefilter(filter StructureFilter, e expression, polygons *filterPolygons) prefilter {
+	pf := prefilter{}
+	in := func(attribute string, values []string) {
+		if len(values) > 0 {
+			c := attributeCondition{attribute: attribute, operator: "IN", values: make([]interface{}, len(values))}
+			for i, v := range values {
+				c.values[i] = v
+			}
+			pf.conditions = append(pf.conditions, c)
+		}
+	}
+	in("st_damcat", filter.DamageCategories)
+	in("fd_id", filter.IncludeFdIds)
+	if e != nil {
+		pf.conditions = append(pf.conditions, conditions(e)...)
+	}
+	if polygons != nil {
+		b := polygons.bbox
+		pf.bounds = &geography.BBox{Bbox: []float64{b[0], b[3], b[2], b[1]}, SRID: polygons.srid}
+	}
+	return pf
+}
+
+type filteredStructureProvider struct {
+	StructureProvider
+	filter     StructureFilter
+	expression expression      //parsed filter expression, nil if there is none
+	polygons   *filterPolygons //nil if there is no polygon layer
+	pushdown   prefilter       //applied as features are read by a prefilteringProvider
+	errs       *streamError    //the first structure that could not be located in the polygon layer
+}
+
+// Filter wraps sp so ByFips and ByBbox only stream the structures filter keeps. It works the same over every provider, the expression is parsed and the polygon layer read when the provider is created. A local layer also skips the features the filter rules out by their columns or the polygons' extent as it reads them.
+func Filter(sp StructureProvider, filter StructureFilter) (SeedableStructureProvider, error) {
+	fsp := filteredStructureProvider{StructureProvider: sp, filter: filter, errs: &streamError{}}
+	if filter.Expression != "" {
+		e, err := parseCondition(filter.Expression)
+		if err != nil {
+			return nil, err
+		}
+		fsp.expression = e
+	}
+	if filter.PolygonFilePath != "" {
+		fp, err := filter.readFilterPolygons()
+		if err != nil {
+			return nil, err
+		}
+		fsp.polygons = &fp
+	}
+	fsp.pushdown = newPrefilter(filter, fsp.expression, fsp.polygons)
+	return fsp, nil
+}
+func (fsp filteredStructureProvider) ByFips(fipscode string, sp consequences.StreamProcessor) {
+	process, done := fsp.process(sp)
+	defer done()
+	if p, ok := fsp.StructureProvider.(prefilteringProvider); ok {
+		p.byFipsPrefiltered(fipscode, fsp.pushdown, process)
+		return
+	}
+	fsp.StructureProvider.ByFips(fipscode, process)
+}
+func (fsp filteredStructureProvider) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	process, done := fsp.process(sp)
+	defer done()
+	if p, ok := fsp.StructureProvider.(prefilteringProvider); ok {
+		p.byBboxPrefiltered(bbox, fsp.pushdown, process)
+		return
+	}
+	fsp.StructureProvider.ByBbox(bbox, process)
+}
+
+// SetSeed implements SeedableStructureProvider, it has no effect if the wrapped provider is not seedable.
+func (fsp filteredStructureProvider) SetSeed(seed int64) {
+	if ssp, ok := fsp.StructureProvider.(SeedableStructureProvider); ok {
+		ssp.SetSeed(seed)
+	}
+}
+
+// Err implements StreamErrorReporter, it joins the wrapped provider's stream error and the first structure the filter could not locate in its polygons.
+func (fsp filteredStructureProvider) Err() error {
+	return errors.Join(StreamError(fsp.StructureProvider), fsp.errs.Err())
+}
+
+// process passes sp the structures the filter keeps, done closes the transforms into the polygon layer's reference system once the stream ends.
+func (fsp filteredStructureProvider) process(sp consequences.StreamProcessor) (consequences.StreamProcessor, func()) {
+	var transforms *projection.Cache
+	if fsp.polygons != nil {
+		transforms = projection.NewCache(fsp.polygons.srid)
+	}
+	done := func() {
+		if transforms != nil {
+			transforms.Close()
+		}
+	}
+	return func(r consequences.Receptor) {
+		if fsp.keeps(r, transforms) {
+			sp(r)
+		}
+	}, done
+}
+
+// keeps applies the filter's lists, expression and polygons to r. A structure the expression cannot be evaluated for, such as one without a ground elevation, is dropped as a null is in an sql where clause. A structure that cannot be located in the polygon layer's reference system is dropped and the first is recorded as a stream error.
+func (fsp filteredStructureProvider) keeps(r consequences.Receptor, transforms *projection.Cache) bool {
+	if !fsp.filter.Keeps(r) {
+		return false
+	}
+	if fsp.expression != nil {
+		a, ok := filterAttributes(r)
+		if ok {
+			v, err := fsp.expression.eval(func(name string) (interface{}, bool) {
+				v, ok := a[strings.ToLower(name)]
+				return v, ok
+			})
+			if keep, _ := v.(bool); err != nil || !keep {
+				return false
+			}
+		}
+	}
+	if fsp.polygons != nil {
+		if _, ok := filterAttributes(r); ok {
+			l, err := transforms.Location(r.Location())
+			if err != nil {
+				fsp.errs.record(fmt.Errorf("structureprovider: unable to locate a structure in the filter polygons: %w", err))
+				return false
+			}
+			if !fsp.polygons.contains(l.X, l.Y) {
+				return false
+			}
+		}
+	}
+	return true
+}
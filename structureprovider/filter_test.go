@@ -0,0 +1,258 @@
+package structureprovider
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/structures"
+	"github.com/dewberry/gdal"
+)
+
+type sliceStructureProvider []consequences.Receptor
+
+func (s sliceStructureProvider) ByFips(fipscode string, sp consequences.StreamProcessor) {
+	for _, r := range s {
+		sp(r)
+	}
+}
+func (s sliceStructureProvider) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	s.ByFips("", sp)
+}
+func TestFilter(t *testing.T) {
+	structure := func(name string, damcat string, occtype string) structures.StructureStochastic {
+		s := structures.StructureStochastic{}
+		s.Name = name
+		s.DamCat = damcat
+		s.OccType.Name = occtype
+		return s
+	}
+	sp := sliceStructureProvider{
+		structure("1", "RES", "RES1-1SNB"),
+		structure("2", "RES", "RES2"),
+		structure("3", "COM", "COM1"),
+		structure("4", "RES", "RES1-1SNB"),
+	}
+	filtered, err := Filter(sp, StructureFilter{DamageCategories: []string{"RES"}, OccupancyTypes: []string{"RES1-1SNB"}, ExcludeFdIds: []string{"4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := keptNames(filtered)
+	if len(kept) != 1 || kept[0] != "1" {
+		t.Errorf("expected only structure 1 to be kept, got %v", kept)
+	}
+	if (StructureFilter{IncludeFdIds: []string{"1"}, ExcludeFdIds: []string{"1"}}).Validate() == nil {
+		t.Error("expected an error for an fd_id that is included and excluded")
+	}
+}
+func keptNames(sp StructureProvider) []string {
+	kept := make([]string, 0)
+	sp.ByBbox(geography.BBox{}, func(r consequences.Receptor) {
+		switch s := r.(type) {
+		case structures.StructureStochastic:
+			kept = append(kept, s.Name)
+		case structures.StructureDeterministic:
+			kept = append(kept, s.Name)
+		}
+	})
+	return kept
+}
+func TestFilter_Expression(t *testing.T) {
+	structure := func(name string, damcat string, value float64, x float64) structures.StructureStochastic {
+		s := structures.StructureStochastic{}
+		s.Name, s.DamCat, s.X = name, damcat, x
+		s.StructVal = consequences.ParameterValue{Value: value}
+		s.OccType.Name = damcat + "1"
+		return s
+	}
+	d := structures.StructureDeterministic{StructVal: 50000}
+	d.Name, d.DamCat, d.OccType.Name = "5", "RES", "RES1"
+	sp := sliceStructureProvider{
+		structure("1", "RES", 100000, 0),
+		structure("2", "RES", 0, 0),
+		structure("3", "COM", 250000, 0),
+		structure("4", "RES", 300000, 10),
+		d,
+	}
+	for expression, expected := range map[string][]string{
+		"st_damcat = 'RES' AND val_struct > 0":                 {"1", "4", "5"},
+		"ST_DAMCAT = 'RES' and not (val_struct <= 0 or x > 5)": {"1", "5"},
+		"fd_id IN ('2', '3') OR occtype <> 'RES1'":             {"2", "3"},
+		"fd_id NOT IN ('1', '2') AND ground_elv > 0":           {},
+	} {
+		filtered, err := Filter(sp, StructureFilter{Expression: expression})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := keptNames(filtered); !reflect.DeepEqual(kept, expected) {
+			t.Errorf("expected %v to keep %v, got %v", expression, expected, kept)
+		}
+	}
+	for _, invalid := range []string{"val_struct + 1", "parcel = 'A'", "st_damcat = 'RES' AND", "fd_id IN '1'", "st_damcat = 1", "val_struct > 'high'", "fd_id + 1 > 0", "NOT val_struct", "st_damcat IN ('RES', 2)"} {
+		if (StructureFilter{Expression: invalid}).Validate() == nil {
+			t.Errorf("expected filter expression %v to be invalid", invalid)
+		}
+		if _, err := Filter(sp, StructureFilter{Expression: invalid}); err == nil {
+			t.Errorf("expected Filter to reject expression %v", invalid)
+		}
+	}
+}
+func TestFilter_Polygons(t *testing.T) {
+	square := func(x0 float64, y0 float64, size float64) [][2]float64 {
+		return [][2]float64{{x0, y0}, {x0 + size, y0}, {x0 + size, y0 + size}, {x0, y0 + size}, {x0, y0}}
+	}
+	//a square with a hole, and a second square apart from it as in a multipolygon
+	fp := newFilterPolygons([][][][2]float64{{square(0, 0, 10), square(4, 4, 2)}, {square(20, 0, 5)}}, "")
+	structure := func(name string, x float64, y float64) structures.StructureStochastic {
+		s := structures.StructureStochastic{}
+		s.Name, s.X, s.Y = name, x, y
+		return s
+	}
+	sp := sliceStructureProvider{
+		structure("inside", 1, 1),
+		structure("hole", 5, 5),
+		structure("second", 22, 3),
+		structure("between", 15, 3),
+		structure("outside", -1, 20),
+	}
+	filtered := filteredStructureProvider{StructureProvider: sp, polygons: &fp}
+	if kept := keptNames(filtered); !reflect.DeepEqual(kept, []string{"inside", "second"}) {
+		t.Errorf("expected the structures inside a polygon and outside its hole to be kept, got %v", kept)
+	}
+	for _, invalid := range []StructureFilter{
+		{PolygonFilePath: "missing.gpkg", PolygonDriver: "GPKG"},
+		{PolygonFilePath: "filter.go"},
+		{PolygonLayerName: "impact_areas"},
+	} {
+		if invalid.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+func TestFilter_StreamError(t *testing.T) {
+	nsp := InitNSISP()
+	filtered, err := Filter(nsp, StructureFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = StreamError(filtered); err != nil {
+		t.Errorf("expected no stream error, got %v", err)
+	}
+	first := errors.New("first")
+	nsp.record(first)
+	nsp.record(errors.New("second"))
+	if err = StreamError(filtered); !errors.Is(err, first) || err.Error() != "first" {
+		t.Errorf("expected the first stream error through the filter, got %v", err)
+	}
+	if err = StreamError(sliceStructureProvider{}); err != nil {
+		t.Errorf("expected a provider without stream errors to report none, got %v", err)
+	}
+}
+func TestFilter_EvaluationError(t *testing.T) {
+	s := structures.StructureStochastic{}
+	s.Name, s.DamCat = "1", "RES"
+	elevated := s
+	elevated.Name, elevated.GroundElevation, elevated.HasGroundElevation = "2", 10, true
+	filtered, err := Filter(sliceStructureProvider{s, elevated}, StructureFilter{Expression: "ground_elv > 5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept := keptNames(filtered); !reflect.DeepEqual(kept, []string{"2"}) {
+		t.Errorf("expected only the structure with a ground elevation to be kept, got %v", kept)
+	}
+	if err = StreamError(filtered); err != nil {
+		t.Errorf("expected the structure without a ground elevation to be dropped like a null, not reported, got %v", err)
+	}
+}
+
+type prefilteringStandIn struct {
+	sliceStructureProvider
+	prefilters []prefilter
+}
+
+func (p *prefilteringStandIn) byFipsPrefiltered(fipscode string, pf prefilter, sp consequences.StreamProcessor) {
+	p.prefilters = append(p.prefilters, pf)
+	p.ByFips(fipscode, sp)
+}
+func (p *prefilteringStandIn) byBboxPrefiltered(bbox geography.BBox, pf prefilter, sp consequences.StreamProcessor) {
+	p.prefilters = append(p.prefilters, pf)
+	p.ByBbox(bbox, sp)
+}
+func TestFilter_Prefilter(t *testing.T) {
+	s := structures.StructureStochastic{}
+	s.Name, s.DamCat = "1", "RES"
+	s.StructVal = consequences.ParameterValue{Value: 200}
+	sp := &prefilteringStandIn{sliceStructureProvider: sliceStructureProvider{s}}
+	filtered, err := Filter(sp, StructureFilter{
+		DamageCategories: []string{"RES"},
+		Expression:       "st_damcat = 'RES' AND 100 < val_struct AND (occtype = 'A' OR x > 1) AND fd_id IN ('1', '2') AND val_cont != 0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept := keptNames(filtered); !reflect.DeepEqual(kept, []string{}) {
+		t.Errorf("expected the filter to still apply to prefiltered structures, got %v", kept)
+	}
+	filtered.ByFips("", func(r consequences.Receptor) {})
+	expected := []attributeCondition{
+		{attribute: "st_damcat", operator: "IN", values: []interface{}{"RES"}},
+		{attribute: "st_damcat", operator: "=", values: []interface{}{"RES"}},
+		{attribute: "val_struct", operator: ">", values: []interface{}{100.0}},
+		{attribute: "fd_id", operator: "IN", values: []interface{}{"1", "2"}},
+		{attribute: "val_cont", operator: "<>", values: []interface{}{0.0}},
+	}
+	if len(sp.prefilters) != 2 {
+		t.Fatalf("expected both streams to be prefiltered, got %v", len(sp.prefilters))
+	}
+	for _, pf := range sp.prefilters {
+		if !reflect.DeepEqual(pf.conditions, expected) || pf.bounds != nil {
+			t.Errorf("expected conditions %v and no bounds, got %v %v", expected, pf.conditions, pf.bounds)
+		}
+	}
+	fp := newFilterPolygons([][][][2]float64{{{{0, 0}, {10, 0}, {0, 5}, {0, 0}}}, {{{20, 1}, {25, 1}, {20, 2}, {20, 1}}}}, "EPSG:26915")
+	if pf := newPrefilter(StructureFilter{}, nil, &fp); pf.conditions != nil || !reflect.DeepEqual(*pf.bounds, geography.BBox{Bbox: []float64{0, 5, 25, 0}, SRID: "EPSG:26915"}) {
+		t.Errorf("expected the upper left lower right extent of the polygons, got %v", pf.bounds)
+	}
+}
+func TestStructureFields_Where(t *testing.T) {
+	columns := append(StructureSchema(), "CONT")
+	fields, err := FieldMapping{"val_cont": {Field: "CONT", Conversion: ThousandsOfDollars}}.resolve(func(name string) int {
+		for i, c := range columns {
+			if c == name {
+				return i
+			}
+		}
+		return -1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := func(index int) (string, gdal.FieldType) {
+		switch columns[index] {
+		case "fd_id":
+			return columns[index], gdal.FT_Integer
+		case "val_struct", "CONT":
+			return columns[index], gdal.FT_Real
+		}
+		return columns[index], gdal.FT_String
+	}
+	where := fields.where([]attributeCondition{
+		{attribute: "st_damcat", operator: "IN", values: []interface{}{"RES", "COM"}},
+		{attribute: "st_damcat", operator: "<>", values: []interface{}{"RES"}},
+		{attribute: "val_struct", operator: ">", values: []interface{}{1000.5}},
+		{attribute: "val_cont", operator: ">", values: []interface{}{1.0}},
+		{attribute: "fd_id", operator: "=", values: []interface{}{"1"}},
+		{attribute: "occtype", operator: "=", values: []interface{}{"RES1"}},
+		{attribute: "cbfips", operator: "=", values: []interface{}{"48'201"}},
+	}, column)
+	expected := []string{
+		`("st_damcat" IS NULL OR "st_damcat" IN ('RES', 'COM'))`,
+		`("val_struct" IS NULL OR "val_struct" > 1000.5)`,
+		`("cbfips" IS NULL OR "cbfips" = '48''201')`,
+	}
+	if !reflect.DeepEqual(where, expected) {
+		t.Errorf("expected %v, got %v", expected, where)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
 	"github.com/USACE/go-consequences/structures"
+	"github.com/USACE/go-consequences/vectorlayer"
 	"github.com/dewberry/gdal"
 )
 
@@ -40,79 +42,52 @@ func featuretoStructure(
 	f *gdal.Feature,
 	m map[string]structures.OccupancyTypeStochastic,
 	defaultOcctype structures.OccupancyTypeStochastic,
-	idxs []int,
-	oidxs []int,
+	fields structureFields,
 ) (structures.StructureStochastic, error) {
 	defer f.Destroy()
-	s := structures.StructureStochastic{}
-	s.Name = fmt.Sprintf("%v", f.FieldAsInteger(idxs[0]))
-	OccTypeName := f.FieldAsString(idxs[5])
-	var occtype = defaultOcctype
-	//dont have access to foundation type in the structure schema yet.
-	if idxs[9] > 0 {
-		if otf, okf := m[OccTypeName+"-"+f.FieldAsString(idxs[9])]; okf {
-			occtype = otf
-		} else {
-			if ot, ok := m[OccTypeName]; ok {
-				occtype = ot
-			} else {
-				occtype = defaultOcctype
-				msg := "Using default " + OccTypeName + " not found"
-				fmt.Println(msg)
-				//return s, errors.New(msg)
-			}
-		}
-	} else {
-		if ot, ok := m[OccTypeName]; ok {
-			occtype = ot
-		} else {
-			occtype = defaultOcctype
-			msg := "Using default " + OccTypeName + " not found"
-			fmt.Println(msg)
-			//return s, errors.New(msg)
-		}
-	}
+	a, err := fields.read(f)
+	if err != nil {
+		return structures.StructureStochastic{}, err
+	}
+	s := structures.StructureStochastic{BaseStructure: a.BaseStructure, PopulationSet: a.PopulationSet}
+	s.OccType = occupancyType(m, defaultOcctype, a.occtype, a.foundType)
+	err = locate(f, a, &s.BaseStructure)
+	s.FoundType = a.foundType
+	s.StructVal = consequences.ParameterValue{Value: a.structVal}
+	s.ContVal = consequences.ParameterValue{Value: a.contVal}
+	s.FoundHt = consequences.ParameterValue{Value: a.foundHt}
+	s.NumStories = a.numStories
+	s.ConstructionType = a.constructionType
+	s.FirmZone = a.firmZone
+	return s, err
+}
 
-	s.OccType = occtype
-	s.CBFips = f.FieldAsString(idxs[1])
+// locate places a structure at the first point of the feature's geometry, or at its x and y if it has no geometry. A polygon, such as a building footprint, places the structure at the centroid of the exterior ring of its first polygon and keeps that ring as the structure's footprint.
+func locate(f *gdal.Feature, a featureAttributes, s *structures.BaseStructure) error {
 	g := f.Geometry()
 	if g.IsNull() || g.IsEmpty() {
-		s.X = f.FieldAsFloat64(idxs[2])
-		s.Y = f.FieldAsFloat64(idxs[3])
-	} else {
-		s.X = f.Geometry().X(0)
-		s.Y = f.Geometry().Y(0)
-	}
-	s.DamCat = f.FieldAsString(idxs[4])
-	s.FoundType = f.FieldAsString(idxs[9])
-	s.StructVal = consequences.ParameterValue{Value: f.FieldAsFloat64(idxs[6])}
-	s.ContVal = consequences.ParameterValue{Value: f.FieldAsFloat64(idxs[7])}
-	s.FoundHt = consequences.ParameterValue{Value: f.FieldAsFloat64(idxs[8])}
-	if oidxs[0] != -1 {
-		s.NumStories = int32(f.FieldAsInteger(oidxs[0]))
-	}
-	if oidxs[1] != -1 {
-		s.Pop2amu65 = int32(f.FieldAsInteger(oidxs[1]))
-	}
-	if oidxs[2] != -1 {
-		s.Pop2amo65 = int32(f.FieldAsInteger(oidxs[2]))
-	}
-	if oidxs[3] != -1 {
-		s.Pop2pmu65 = int32(f.FieldAsInteger(oidxs[3]))
-	}
-	if oidxs[4] != -1 {
-		s.Pop2pmo65 = int32(f.FieldAsInteger(oidxs[4]))
-	}
-	if oidxs[5] != -1 {
-		s.GroundElevation = f.FieldAsFloat64(oidxs[5])
+		if !a.hasXY {
+			return fmt.Errorf("structureprovider: structure %v has no geometry and no x and y", a.Name)
+		}
+		return nil
 	}
-	if oidxs[6] != -1 {
-		s.ConstructionType = f.FieldAsString(oidxs[6])
+	points, rings := vectorlayer.ReadGeometry(g, nil, nil)
+	if len(points) > 0 || len(rings) == 0 {
+		s.X = g.X(0)
+		s.Y = g.Y(0)
+		return nil
 	}
-	if oidxs[7] != -1 {
-		s.FirmZone = f.FieldAsString(oidxs[7])
+	placeOnFootprint(s, rings[0])
+	return nil
+}
+
+// placeOnFootprint places a structure at the centroid of a building footprint ring and keeps the ring as its footprint.
+func placeOnFootprint(s *structures.BaseStructure, ring [][2]float64) {
+	s.X, s.Y = geography.RingCentroid(ring)
+	s.Footprint = make([]geography.Location, len(ring))
+	for i, p := range ring {
+		s.Footprint[i] = geography.Location{X: p[0], Y: p[1]}
 	}
-	return s, nil
 }
 
 func swapOcctypeMap(
@@ -129,77 +104,22 @@ func featuretoDeterministicStructure(
 	f *gdal.Feature,
 	m map[string]structures.OccupancyTypeDeterministic,
 	defaultOcctype structures.OccupancyTypeDeterministic,
-	idxs []int,
-	oidxs []int,
+	fields structureFields,
 ) (structures.StructureDeterministic, error) {
 	defer f.Destroy()
-	s := structures.StructureDeterministic{}
-	s.Name = fmt.Sprintf("%v", f.FieldAsInteger(idxs[0]))
-	OccTypeName := f.FieldAsString(idxs[5])
-	var occtype = defaultOcctype
-	//dont have access to foundation type in the structure schema yet.
-	if idxs[9] > 0 {
-		if otf, okf := m[OccTypeName+"-"+f.FieldAsString(idxs[9])]; okf {
-			occtype = otf
-		} else {
-			if ot, ok := m[OccTypeName]; ok {
-				occtype = ot
-			} else {
-				occtype = defaultOcctype
-				msg := "Using default " + OccTypeName + " not found"
-				fmt.Println(msg)
-				//return s, errors.New(msg)
-			}
-		}
-	} else {
-		if ot, ok := m[OccTypeName]; ok {
-			occtype = ot
-		} else {
-			occtype = defaultOcctype
-			msg := "Using default " + OccTypeName + " not found"
-			fmt.Println(msg)
-			//return s, errors.New(msg)
-		}
-	}
-
-	s.OccType = occtype
-	s.CBFips = f.FieldAsString(idxs[1])
-	g := f.Geometry()
-	if g.IsNull() || g.IsEmpty() {
-		s.X = f.FieldAsFloat64(idxs[2])
-		s.Y = f.FieldAsFloat64(idxs[3])
-	} else {
-		s.X = f.Geometry().X(0)
-		s.Y = f.Geometry().Y(0)
-	}
-	s.DamCat = f.FieldAsString(idxs[4])
-	s.StructVal = f.FieldAsFloat64(idxs[6])
-	s.ContVal = f.FieldAsFloat64(idxs[7])
-	s.FoundHt = f.FieldAsFloat64(idxs[8])
-	s.FoundType = f.FieldAsString(idxs[9])
-	if oidxs[0] != -1 {
-		s.NumStories = int32(f.FieldAsInteger(oidxs[0]))
-	}
-	if oidxs[1] != -1 {
-		s.Pop2amu65 = int32(f.FieldAsInteger(oidxs[1]))
-	}
-	if oidxs[2] != -1 {
-		s.Pop2amo65 = int32(f.FieldAsInteger(oidxs[2]))
-	}
-	if oidxs[3] != -1 {
-		s.Pop2pmu65 = int32(f.FieldAsInteger(oidxs[3]))
-	}
-	if oidxs[4] != -1 {
-		s.Pop2pmo65 = int32(f.FieldAsInteger(oidxs[4]))
-	}
-	if oidxs[5] != -1 {
-		s.GroundElevation = f.FieldAsFloat64(oidxs[5])
-	}
-	if oidxs[6] != -1 {
-		s.ConstructionType = f.FieldAsString(oidxs[6])
-	}
-	if oidxs[7] != -1 {
-		s.FirmZone = f.FieldAsString(oidxs[7])
-	}
-	return s, nil
+	a, err := fields.read(f)
+	if err != nil {
+		return structures.StructureDeterministic{}, err
+	}
+	s := structures.StructureDeterministic{BaseStructure: a.BaseStructure, PopulationSet: a.PopulationSet}
+	s.OccType = occupancyType(m, defaultOcctype, a.occtype, a.foundType)
+	err = locate(f, a, &s.BaseStructure)
+	s.StructVal = a.structVal
+	s.ContVal = a.contVal
+	s.FoundHt = a.foundHt
+	s.FoundType = a.foundType
+	s.NumStories = a.numStories
+	s.ConstructionType = a.constructionType
+	s.FirmZone = a.firmZone
+	return s, err
 }
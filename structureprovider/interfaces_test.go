@@ -0,0 +1,19 @@
+package structureprovider
+
+import (
+	"testing"
+
+	"github.com/USACE/go-consequences/structures"
+)
+
+func TestPlaceOnFootprint(t *testing.T) {
+	s := structures.BaseStructure{Name: "1", SRID: "EPSG:5070"}
+	placeOnFootprint(&s, [][2]float64{{10, 20}, {14, 20}, {14, 22}, {10, 22}, {10, 20}})
+	if s.X != 12 || s.Y != 21 {
+		t.Errorf("expected the structure at the centroid 12, 21 of its footprint, got %v, %v", s.X, s.Y)
+	}
+	l := s.Location()
+	if len(l.Footprint) != 5 || l.Footprint[2].X != 14 || l.Footprint[2].Y != 22 {
+		t.Errorf("expected the location to carry the footprint ring, got %v", l.Footprint)
+	}
+}
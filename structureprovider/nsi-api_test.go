@@ -231,3 +231,19 @@ func countByState(ss string) int {
 
 	return m[ss]
 }
+func TestNsiFeaturetoStructure_GroundElevation(t *testing.T) {
+	otp := structures.JsonOccupancyTypeProvider{}
+	otp.InitDefault()
+	m := otp.OccupancyTypeMap()
+	fh, _ := structures.InitFoundationUncertainty()
+	for _, c := range []struct {
+		ground float64
+		has    bool
+	}{{12.5, true}, {0, false}} {
+		f := NsiFeature{Properties: NsiProperties{Name: 1, Occtype: "RES1-1SNB", FoundType: "S", GroundElevation: c.ground}}
+		s := NsiFeaturetoStructure(f, m, m["RES1-1SNB"], false, fh)
+		if s.HasGroundElevation != c.has || s.GroundElevation != c.ground {
+			t.Errorf("expected a ground_elv of %v to have a ground elevation %v, got %v %v", c.ground, c.has, s.HasGroundElevation, s.GroundElevation)
+		}
+	}
+}
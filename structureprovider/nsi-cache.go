@@ -0,0 +1,54 @@
+package structureprovider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/USACE/go-consequences/geography"
+)
+
+// NSICacheInfo persists the features the NSI API streams, one json feature per line, so repeat runs read a county or bounding box from disk and disconnected runs can read it at all. Features are kept in a directory for each version, so a cache of one NSI version is never read for another.
+type NSICacheInfo struct {
+	Directory string `json:"directory"`
+	Version   string `json:"version,omitempty"` //label of the NSI version cached, latest if not set
+	Offline   bool   `json:"offline,omitempty"` //only read from the cache, a fips code or bounding box that is not cached is a stream error
+}
+
+// Validate checks the cache has a directory, which must already exist when offline.
+func (c NSICacheInfo) Validate() error {
+	if c.Directory == "" {
+		return errors.New("structureprovider: an nsi cache requires a directory")
+	}
+	if strings.ContainsAny(c.Version, `/\`) {
+		return fmt.Errorf("structureprovider: nsi cache version %v cannot contain a path separator", c.Version)
+	}
+	if c.Offline {
+		if _, err := os.Stat(c.Directory); err != nil {
+			return fmt.Errorf("structureprovider: offline nsi cache directory %v does not exist", c.Directory)
+		}
+	}
+	return nil
+}
+
+// path is the file the features of key are cached in.
+func (c NSICacheInfo) path(key string) string {
+	version := c.Version
+	if version == "" {
+		version = "latest"
+	}
+	return filepath.Join(c.Directory, version, key+".ndjson")
+}
+func fipsCacheKey(fipscode string) string {
+	return "fips-" + fipscode
+}
+
+// bboxCacheKey names a bounding box by a hash of its coordinates, which would not make a portable file name.
+func bboxCacheKey(bbox geography.BBox) string {
+	h := sha256.Sum256([]byte(bbox.ToString()))
+	return "bbox-" + hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,143 @@
+package structureprovider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/geography"
+)
+
+// nsiStandIn serves a feature stream of count structures for any fips code, or fails every request.
+func nsiStandIn(t *testing.T, count int, fail bool) (*httptest.Server, *int32) {
+	requests := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Query().Get("fmt") != "fs" {
+			t.Errorf("expected a feature stream request, got %v", r.URL)
+		}
+		fips := r.URL.Query().Get("fips")
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(w, "{\"type\": \"Feature\",\n \"properties\": {\"fd_id\": %v, \"cbfips\": \"%v0001001\", \"occtype\": \"RES1-1SNB\", \"st_damcat\": \"RES\", \"val_struct\": 100000, \"found_type\": \"S\"}}\n", i, fips)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+func countStructures(nsp nsiStreamProvider, fips string) int {
+	count := 0
+	nsp.ByFips(fips, func(r consequences.Receptor) {
+		count++
+	})
+	return count
+}
+func TestNSICache_ServesRepeatRunsAndOffline(t *testing.T) {
+	server, requests := nsiStandIn(t, 5, false)
+	dir := t.TempDir()
+	nsp := InitNSISP()
+	nsp.ApiURL = server.URL
+	nsp.Cache = &NSICacheInfo{Directory: dir, Version: "2022"}
+	for run := 0; run < 2; run++ {
+		if count := countStructures(nsp, "15005"); count != 5 {
+			t.Errorf("run %v streamed %v structures; expected 5", run, count)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("made %v requests; expected the second run to read the cache", *requests)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "2022", "fips-15005.ndjson"))
+	if err != nil || strings.Count(string(b), "\n") != 5 {
+		t.Errorf("expected 5 cached features, one per line, got %q %v", b, err)
+	}
+	offline := InitNSISP()
+	offline.ApiURL = "http://127.0.0.1:1"
+	offline.Cache = &NSICacheInfo{Directory: dir, Version: "2022", Offline: true}
+	if count := countStructures(offline, "15005"); count != 5 {
+		t.Errorf("offline streamed %v cached structures; expected 5", count)
+	}
+	if err := StreamError(offline); err != nil {
+		t.Errorf("expected a cached county to stream without error, got %v", err)
+	}
+	if count := countStructures(offline, "15007"); count != 0 {
+		t.Errorf("offline streamed %v structures of an uncached county; expected none", count)
+	}
+	if StreamError(offline) == nil {
+		t.Error("expected an uncached county to be a stream error offline")
+	}
+	offline.Cache.Version = "2024"
+	if count := countStructures(offline, "15005"); count != 0 {
+		t.Errorf("offline streamed %v structures cached for another version; expected none", count)
+	}
+}
+func TestNSICache_FailedRequestsAreNotCached(t *testing.T) {
+	server, _ := nsiStandIn(t, 5, true)
+	dir := t.TempDir()
+	nsp := InitNSISP()
+	nsp.ApiURL = server.URL
+	nsp.Cache = &NSICacheInfo{Directory: dir}
+	if count := countStructures(nsp, "15005"); count != 0 {
+		t.Errorf("streamed %v structures from a failed request", count)
+	}
+	if StreamError(nsp) == nil {
+		t.Error("expected a failed request to be a stream error")
+	}
+	nsp.Cache = nil
+	if count := countStructures(nsp, "15005"); count != 0 {
+		t.Errorf("streamed %v structures from a failed request without a cache", count)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "latest"))
+	if len(entries) != 0 {
+		t.Errorf("expected nothing cached, found %v files", len(entries))
+	}
+	nsp.ApiURL = "http://127.0.0.1:1"
+	if count := countStructures(nsp, "15005"); count != 0 {
+		t.Errorf("streamed %v structures without a server", count)
+	}
+	//a stream cut off part way through a feature.
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{\"type\": \"Feature\", \"properties\": {\"fd_id\": 1, \"occtype\": \"RES1-1SNB\"}}\n{\"type\": \"Feature\", \"properties\": {\"fd_")
+	}))
+	defer truncated.Close()
+	partial := InitNSISP()
+	partial.ApiURL = truncated.URL
+	partial.Cache = &NSICacheInfo{Directory: dir}
+	if count := countStructures(partial, "15005"); count != 1 {
+		t.Errorf("streamed %v structures before the stream was cut off; expected 1", count)
+	}
+	if StreamError(partial) == nil {
+		t.Error("expected a stream cut off part way through a feature to be a stream error")
+	}
+	entries, _ = os.ReadDir(filepath.Join(dir, "latest"))
+	if len(entries) != 0 {
+		t.Errorf("expected a partial download not to be cached, found %v files", len(entries))
+	}
+}
+func TestNSICacheInfo_Validate(t *testing.T) {
+	if (NSICacheInfo{Directory: t.TempDir(), Offline: true}).Validate() != nil {
+		t.Error("expected an existing offline cache to be valid")
+	}
+	for _, invalid := range []NSICacheInfo{
+		{},
+		{Directory: filepath.Join(t.TempDir(), "missing"), Offline: true},
+		{Directory: t.TempDir(), Version: "../2022"},
+	} {
+		if invalid.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+	a := bboxCacheKey(geography.BBox{Bbox: []float64{-90, 30, -89, 29}})
+	b := bboxCacheKey(geography.BBox{Bbox: []float64{-90, 30, -89, 28}})
+	if a == b || !strings.HasPrefix(a, "bbox-") {
+		t.Errorf("expected distinct bounding box keys, got %v and %v", a, b)
+	}
+}
@@ -2,16 +2,19 @@ package structureprovider
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/USACE/go-consequences/consequences"
 	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/projection"
 	"github.com/USACE/go-consequences/structures"
 )
 
@@ -47,6 +50,9 @@ type nsiStreamProvider struct {
 	OccTypeProvider       structures.OccupancyTypeProvider
 	FoundationUncertainty *structures.FoundationUncertainty
 	UseUncertainty        bool
+	Seed                  int64         //master seed, each structure's seed is derived from it and the structure's fd_id
+	Cache                 *NSICacheInfo //features are read from and saved to the cache if it is set
+	*streamError                        //the first error a stream stopped on
 }
 
 func InitNSISP() nsiStreamProvider {
@@ -57,7 +63,7 @@ func InitNSISP() nsiStreamProvider {
 	url := "https://nsi.sec.usace.army.mil/nsiapi/structures"
 	// TODO probably don't hard code a possibly changing url
 	fh, _ := structures.InitFoundationUncertainty()
-	return nsiStreamProvider{ApiURL: url, OccTypeProvider: otp, FoundationUncertainty: fh}
+	return nsiStreamProvider{ApiURL: url, OccTypeProvider: otp, FoundationUncertainty: fh, streamError: &streamError{}}
 }
 func InitNSISPwithOcctypeFilePath(occtypefp string) nsiStreamProvider {
 	//this will only work with go1.16+
@@ -67,121 +73,179 @@ func InitNSISPwithOcctypeFilePath(occtypefp string) nsiStreamProvider {
 	url := "https://nsi.sec.usace.army.mil/nsiapi/structures"
 	// TODO probably don't hard code a possibly changing url
 	fh, _ := structures.InitFoundationUncertainty()
-	return nsiStreamProvider{ApiURL: url, OccTypeProvider: otp, FoundationUncertainty: fh}
+	return nsiStreamProvider{ApiURL: url, OccTypeProvider: otp, FoundationUncertainty: fh, streamError: &streamError{}}
 }
-func urlFinder() string {
-	url := "https://www.hec.usace.army.mil/fwlink/?linkid=1&type=string"
-	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // accept untrusted servers
-	}
-	client := &http.Client{Transport: transCfg}
-
-	response, err := client.Get(url)
 
+// SetSeed implements SeedableStructureProvider
+func (nsp *nsiStreamProvider) SetSeed(seed int64) {
+	nsp.Seed = seed
+}
+func urlFinder() (string, error) {
+	url := "https://www.hec.usace.army.mil/fwlink/?linkid=1&type=string"
+	response, err := nsiClient().Get(url)
 	if err != nil {
-		fmt.Println(err)
+		return "", err
 	}
 	defer response.Body.Close()
 	rootBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		fmt.Println(err)
+		return "", err
 	}
-	return string(rootBytes)
+	return string(rootBytes), nil
 }
 func (nsp nsiStreamProvider) ByFips(fipscode string, sp consequences.StreamProcessor) {
 	url := fmt.Sprintf("%s?fips=%s&fmt=fs", nsp.ApiURL, fipscode)
-	nsp.nsiStructureStream(url, sp)
+	nsp.nsiStructureStream(url, fipsCacheKey(fipscode), sp)
 }
+
+// ByBbox streams the structures within bbox, which is transformed to WGS84 if it is in another reference system.
 func (nsp nsiStreamProvider) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	bbox, err := projection.TransformBBox(bbox, projection.WGS84)
+	if err != nil {
+		nsp.record(err)
+		return
+	}
 	url := fmt.Sprintf("%s?bbox=%s&fmt=fs", nsp.ApiURL, bbox.ToString())
-	nsp.nsiStructureStream(url, sp)
+	nsp.nsiStructureStream(url, bboxCacheKey(bbox), sp)
 }
 func (nsp nsiStreamProvider) ByJsonPost(jsonbody string, sp consequences.StreamProcessor) {
 	body := []byte(jsonbody)
 	url := fmt.Sprintf("%s?fmt=fs", nsp.ApiURL)
 	nsp.nsiPostStructureStream(url, bytes.NewBuffer(body), sp)
 }
-func (nsp nsiStreamProvider) nsiStructureStream(url string, sp consequences.StreamProcessor) {
-	m := nsp.OccTypeProvider.OccupancyTypeMap()
-	fmt.Println(url)
-	//define a default occtype in case of emergancy
-	defaultOcctype := m["RES1-1SNB"]
-	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // accept untrusted servers
-	}
-	client := &http.Client{Transport: transCfg}
-
-	response, err := client.Get(url)
 
+// nsiStructureStream streams the structures of the features at url, or of the features cached under key if the provider has a cache. Features are streamed until the first error, which is the provider's stream error.
+func (nsp nsiStreamProvider) nsiStructureStream(url string, key string, sp consequences.StreamProcessor) {
+	err := nsp.cachedFeatures(url, key, nsp.structureProcessor(sp))
 	if err != nil {
-		fmt.Println(err)
-	}
-	defer response.Body.Close()
-	dec := json.NewDecoder(response.Body)
-	//b, err := ioutil.ReadAll(response.Body)
-	//fmt.Println(string(b))
-	for {
-		var n NsiFeature
-		if err := dec.Decode(&n); err == io.EOF {
-			break
-		} else if err != nil {
-			fmt.Printf("Error unmarshalling JSON record: %s.  Stopping Compute.\n", err)
-			if err == io.ErrUnexpectedEOF {
-				break
-			}
-		}
-		sp(NsiFeaturetoStructure(n, m, defaultOcctype, nsp.UseUncertainty, nsp.FoundationUncertainty))
+		nsp.record(err)
 	}
 }
 func (nsp nsiStreamProvider) nsiPostStructureStream(url string, body io.Reader, sp consequences.StreamProcessor) {
+	response, err := nsiClient().Post(url, "application/json", body)
+	if err == nil {
+		defer response.Body.Close()
+		err = checkResponse(response, url)
+	}
+	if err == nil {
+		err = decodeFeatures(response.Body, nsp.structureProcessor(sp), nil)
+	}
+	if err != nil {
+		nsp.record(err)
+	}
+}
+
+// structureProcessor converts each feature to a structure for sp.
+func (nsp nsiStreamProvider) structureProcessor(sp consequences.StreamProcessor) NsiStreamProcessor {
 	m := nsp.OccTypeProvider.OccupancyTypeMap()
-	fmt.Println(url)
 	//define a default occtype in case of emergancy
 	defaultOcctype := m["RES1-1SNB"]
-	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // accept untrusted servers
+	return func(n NsiFeature) {
+		s := NsiFeaturetoStructure(n, m, defaultOcctype, nsp.UseUncertainty, nsp.FoundationUncertainty)
+		s.Seed = structures.StructureSeed(nsp.Seed, s.Name)
+		sp(s)
 	}
-	client := &http.Client{Transport: transCfg}
+}
 
-	response, err := client.Post(url, "application/json", body)
+// cachedFeatures processes the features cached under key, or downloads them from url and caches them if they are not cached and the cache is not offline. Without a cache the features are downloaded every time. It is an error if an offline cache does not have key, or if the download fails, a download is only cached once every feature has been read.
+func (nsp nsiStreamProvider) cachedFeatures(url string, key string, process NsiStreamProcessor) error {
+	if nsp.Cache == nil {
+		return downloadFeatures(url, process, nil)
+	}
+	path := nsp.Cache.path(key)
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		return decodeFeatures(f, process, nil)
+	}
+	if nsp.Cache.Offline {
+		return fmt.Errorf("structureprovider: %v is not in the nsi cache at %v and the cache is offline", key, nsp.Cache.Directory)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	//download into a temporary file so an interrupted download is never read as a complete one.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = downloadFeatures(url, process, tmp)
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
+// downloadFeatures processes the features streamed from url, writing each to w as a line of json if w is not nil.
+func downloadFeatures(url string, process NsiStreamProcessor, w io.Writer) error {
+	response, err := nsiClient().Get(url)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 	defer response.Body.Close()
-	dec := json.NewDecoder(response.Body)
-	//b, err := ioutil.ReadAll(response.Body)
-	//fmt.Println(string(b))
+	err = checkResponse(response, url)
+	if err != nil {
+		return err
+	}
+	return decodeFeatures(response.Body, process, w)
+}
+
+// decodeFeatures processes each json feature read from r until it is exhausted, writing each to w as a line of json if w is not nil.
+func decodeFeatures(r io.Reader, process NsiStreamProcessor, w io.Writer) error {
+	dec := json.NewDecoder(r)
 	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("structureprovider: unable to read an nsi feature, stopping the stream: %w", err)
+		}
 		var n NsiFeature
-		if err := dec.Decode(&n); err == io.EOF {
-			break
-		} else if err != nil {
-			fmt.Printf("Error unmarshalling JSON record: %s.  Stopping Compute.\n", err)
-			if err == io.ErrUnexpectedEOF {
-				break
+		err = json.Unmarshal(raw, &n)
+		if err != nil {
+			return fmt.Errorf("structureprovider: unable to read an nsi feature, stopping the stream: %w", err)
+		}
+		if w != nil {
+			var line bytes.Buffer
+			err = json.Compact(&line, raw)
+			if err == nil {
+				line.WriteByte('\n')
+				_, err = w.Write(line.Bytes())
+			}
+			if err != nil {
+				return err
 			}
 		}
-		sp(NsiFeaturetoStructure(n, m, defaultOcctype, nsp.UseUncertainty, nsp.FoundationUncertainty))
+		process(n)
 	}
 }
+func checkResponse(response *http.Response, url string) error {
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("structureprovider: the nsi api responded %v to %v", response.Status, url)
+	}
+	return nil
+}
+
+// nsiTimeout bounds a whole request, including streaming the features of the largest county.
+const nsiTimeout = 30 * time.Minute
+
+// nsiClient requests features over the default transport, which verifies the server's certificate, and gives up on a request that takes longer than nsiTimeout.
+func nsiClient() *http.Client {
+	return &http.Client{Timeout: nsiTimeout}
+}
 
-// NsiFeaturetoStructure converts an nsi.NsiFeature to a structures.Structure
+// NsiFeaturetoStructure converts an nsi.NsiFeature to a structures.Structure, the structure has a ground elevation only if ground_elv is present and nonzero.
 func NsiFeaturetoStructure(f NsiFeature, m map[string]structures.OccupancyTypeStochastic, defaultOcctype structures.OccupancyTypeStochastic, useUncertainty bool, fh *structures.FoundationUncertainty) structures.StructureStochastic {
-	var occtype = defaultOcctype
-	if otf, okf := m[f.Properties.Occtype+"-"+f.Properties.FoundType]; okf {
-		occtype = otf
-	} else {
-		if ot, ok := m[f.Properties.Occtype]; ok {
-			occtype = ot
-		} else {
-			occtype = defaultOcctype
-			msg := "Using default " + f.Properties.Occtype + " not found"
-			fmt.Print(msg) //panic(msg)
-		}
-	}
 	s := structures.StructureStochastic{
-		OccType:          occtype,
+		OccType:          occupancyType(m, defaultOcctype, f.Properties.Occtype, f.Properties.FoundType),
 		StructVal:        consequences.ParameterValue{Value: f.Properties.StructVal},
 		ContVal:          consequences.ParameterValue{Value: f.Properties.ContVal},
 		FoundHt:          consequences.ParameterValue{Value: f.Properties.FoundHt},
@@ -195,12 +259,14 @@ func NsiFeaturetoStructure(f NsiFeature, m map[string]structures.OccupancyTypeSt
 			Pop2amu65: f.Properties.Pop2amu65},
 		NumStories: f.Properties.NumStories,
 		BaseStructure: structures.BaseStructure{
-			Name:            strconv.Itoa(f.Properties.Name),
-			CBFips:          f.Properties.CB,
-			DamCat:          f.Properties.DamCat,
-			X:               f.Properties.X,
-			Y:               f.Properties.Y,
-			GroundElevation: f.Properties.GroundElevation,
+			Name:               strconv.Itoa(f.Properties.Name),
+			CBFips:             f.Properties.CB,
+			DamCat:             f.Properties.DamCat,
+			X:                  f.Properties.X,
+			Y:                  f.Properties.Y,
+			GroundElevation:    f.Properties.GroundElevation,
+			HasGroundElevation: f.Properties.GroundElevation != 0, //a missing ground_elv decodes as zero, depth is then taken above the terrain
+			SRID:               projection.WGS84,
 		},
 	}
 	s.UseUncertainty = useUncertainty
@@ -3,10 +3,12 @@ package structureprovider
 import (
 	"errors"
 	"fmt"
-	"math/rand"
+	"math"
+	"strings"
 
 	"github.com/USACE/go-consequences/consequences"
 	"github.com/USACE/go-consequences/geography"
+	"github.com/USACE/go-consequences/projection"
 	"github.com/USACE/go-consequences/structures"
 	"github.com/dewberry/gdal"
 )
@@ -14,28 +16,36 @@ import (
 type gdalDataSet struct {
 	FilePath              string
 	LayerName             string
-	schemaIDX             []int
-	optionalSchemaIDX     []int
+	fields                structureFields
 	ds                    *gdal.DataSource
 	deterministic         bool
 	seed                  int64
 	OccTypeProvider       structures.OccupancyTypeProvider
 	FoundationUncertainty *structures.FoundationUncertainty
+	*streamError          //the first error a stream stopped on, or the first feature it could not read as a structure
 }
 
 func InitStructureProvider(filepath string, layername string, driver string) (*gdalDataSet, error) {
 	//validation?
-	gpk, err := initalizestructureprovider(filepath, layername, driver)
+	gpk, err := initalizestructureprovider(filepath, layername, driver, nil)
 	gpk.setOcctypeProvider(false, "")
 	gpk.UpdateFoundationHeightUncertainty(false, "")
 	return &gpk, err
 }
 func InitStructureProviderwithOcctypePath(filepath string, layername string, driver string, occtypefp string) (*gdalDataSet, error) {
 	//validation?
-	gpk, err := initalizestructureprovider(filepath, layername, driver)
+	gpk, err := initalizestructureprovider(filepath, layername, driver, nil)
 	gpk.setOcctypeProvider(true, occtypefp)
 	return &gpk, err
 }
+
+// InitStructureProviderWithFieldMapping opens a structure layer whose columns are read through mapping, the default occupancy types are used if occtypefp is empty.
+func InitStructureProviderWithFieldMapping(filepath string, layername string, driver string, occtypefp string, mapping FieldMapping) (*gdalDataSet, error) {
+	gpk, err := initalizestructureprovider(filepath, layername, driver, mapping)
+	gpk.setOcctypeProvider(occtypefp != "", occtypefp)
+	gpk.UpdateFoundationHeightUncertainty(false, "")
+	return &gpk, err
+}
 func (ds *gdalDataSet) UpdateFoundationHeightUncertainty(useFile bool, foundationHeightUncertaintyJsonFilePath string) {
 	if useFile {
 		fh, err := structures.InitFoundationUncertaintyFromFile(foundationHeightUncertaintyJsonFilePath)
@@ -48,7 +58,7 @@ func (ds *gdalDataSet) UpdateFoundationHeightUncertainty(useFile bool, foundatio
 		ds.FoundationUncertainty = fh
 	}
 }
-func initalizestructureprovider(filepath string, layername string, driver string) (gdalDataSet, error) {
+func initalizestructureprovider(filepath string, layername string, driver string, mapping FieldMapping) (gdalDataSet, error) {
 	driverOut := gdal.OGRDriverByName(driver)
 	ds, dsok := driverOut.Open(filepath, int(gdal.ReadOnly))
 	if !dsok {
@@ -66,22 +76,11 @@ func initalizestructureprovider(filepath string, layername string, driver string
 	}
 	l := ds.LayerByName(layername)
 	def := l.Definition()
-	s := StructureSchema()
-	sIDX := make([]int, len(s))
-	for i, f := range s {
-		idx := def.FieldIndex(f)
-		if idx < 0 {
-			return gdalDataSet{}, errors.New("gdal dataset at path " + filepath + " Expected field named " + f + " none was found")
-		}
-		sIDX[i] = idx
-	}
-	o := OptionalSchema()
-	oIDX := make([]int, len(o))
-	for i, f := range o {
-		idx := def.FieldIndex(f)
-		oIDX[i] = idx
+	fields, err := mapping.resolve(def.FieldIndex)
+	if err != nil {
+		return gdalDataSet{}, fmt.Errorf("gdal dataset at path %v: %w", filepath, err)
 	}
-	gpk := gdalDataSet{FilePath: filepath, LayerName: layername, schemaIDX: sIDX, optionalSchemaIDX: oIDX, ds: &ds, seed: 1234}
+	gpk := gdalDataSet{FilePath: filepath, LayerName: layername, fields: fields, ds: &ds, seed: 1234, streamError: &streamError{}}
 	return gpk, nil
 }
 func (gpk *gdalDataSet) setOcctypeProvider(useFilepath bool, filepath string) {
@@ -98,6 +97,8 @@ func (gpk *gdalDataSet) setOcctypeProvider(useFilepath bool, filepath string) {
 func (gpk *gdalDataSet) SetDeterministic(useDeterministic bool) {
 	gpk.deterministic = useDeterministic
 }
+
+// SetSeed implements SeedableStructureProvider
 func (gpk *gdalDataSet) SetSeed(seed int64) {
 	gpk.seed = seed
 }
@@ -117,115 +118,228 @@ func (gpk *gdalDataSet) UpdateSpatialReference(sr_wkt string) {
 
 // StreamByFips a streaming service for structure stochastic based on a bounding box
 func (gpk gdalDataSet) ByFips(fipscode string, sp consequences.StreamProcessor) {
+	gpk.byFipsPrefiltered(fipscode, prefilter{}, sp)
+}
+
+// byFipsPrefiltered implements prefilteringProvider.
+func (gpk gdalDataSet) byFipsPrefiltered(fipscode string, pf prefilter, sp consequences.StreamProcessor) {
 	if gpk.deterministic {
-		gpk.processFipsStreamDeterministic(fipscode, sp)
+		gpk.processFipsStreamDeterministic(fipscode, pf, sp)
 	} else {
-		gpk.processFipsStream(fipscode, sp)
+		gpk.processFipsStream(fipscode, pf, sp)
 	}
 
 }
-func (gpk gdalDataSet) processFipsStream(fipscode string, sp consequences.StreamProcessor) {
+func (gpk gdalDataSet) processFipsStream(fipscode string, pf prefilter, sp consequences.StreamProcessor) {
 	m := gpk.OccTypeProvider.OccupancyTypeMap()
 	//define a default occtype in case of emergancy
 	defaultOcctype := m["RES1-1SNB"]
 	idx := 0
 	l := gpk.ds.LayerByName(gpk.LayerName)
-	fdef := l.Definition().FieldDefinition(gpk.schemaIDX[1])
-	filterstring := "SUBSTR(" + fdef.Name() + ",1," + fmt.Sprint(len(fipscode)) + ") = '" + fipscode + "'"
-	err := l.SetAttributeFilter(filterstring)
-	if err != nil {
-		panic(err)
+	srs := gpk.SpatialReference()
+	if !gpk.filterLayer(l, gpk.filterFips(l, fipscode), nil, pf) {
+		return
 	}
 	fc, _ := l.FeatureCount(true)
-	r := rand.New(rand.NewSource(gpk.seed))
 	for idx < fc { // Iterate and fetch the records from result cursor
 		f := l.NextFeature()
 		idx++
 		if f != nil {
-			s, err := featuretoStructure(f, m, defaultOcctype, gpk.schemaIDX, gpk.optionalSchemaIDX)
+			s, err := featuretoStructure(f, m, defaultOcctype, gpk.fields)
+			if err != nil {
+				gpk.record(err)
+				continue
+			}
+			s.SRID = srs
 			s.ApplyFoundationHeightUncertanty(gpk.FoundationUncertainty)
 			s.UseUncertainty = true
-			sd := s.SampleStructure(r.Int63())
-			if err == nil {
-				sp(sd)
+			s.Seed = structures.StructureSeed(gpk.seed, s.Name)
+			if strings.HasPrefix(s.CBFips, fipscode) {
+				sp(s) //Compute samples with s.Seed, so repeated computes see the same structure.
 			}
 		}
 	}
 }
-func (gpk gdalDataSet) processFipsStreamDeterministic(fipscode string, sp consequences.StreamProcessor) {
+func (gpk gdalDataSet) processFipsStreamDeterministic(fipscode string, pf prefilter, sp consequences.StreamProcessor) {
 	m := gpk.OccTypeProvider.OccupancyTypeMap()
 	m2 := swapOcctypeMap(m)
 	//define a default occtype in case of emergancy
 	defaultOcctype := m2["RES1-1SNB"]
 	idx := 0
 	l := gpk.ds.LayerByName(gpk.LayerName)
-	fdef := l.Definition().FieldDefinition(gpk.schemaIDX[1])
-	filterstring := "SUBSTR(" + fdef.Name() + ",1," + fmt.Sprint(len(fipscode)) + ") = '" + fipscode + "'"
-	err := l.SetAttributeFilter(filterstring)
-	if err != nil {
-		panic(err)
+	srs := gpk.SpatialReference()
+	if !gpk.filterLayer(l, gpk.filterFips(l, fipscode), nil, pf) {
+		return
 	}
 	fc, _ := l.FeatureCount(true)
 	for idx < fc { // Iterate and fetch the records from result cursor
 		f := l.NextFeature()
 		idx++
 		if f != nil {
-			s, err := featuretoDeterministicStructure(f, m2, defaultOcctype, gpk.schemaIDX, gpk.optionalSchemaIDX)
-			if err == nil {
+			s, err := featuretoDeterministicStructure(f, m2, defaultOcctype, gpk.fields)
+			if err != nil {
+				gpk.record(err)
+				continue
+			}
+			s.SRID = srs
+			if strings.HasPrefix(s.CBFips, fipscode) {
 				sp(s)
 			}
 		}
 	}
 }
+
+// filterFips is the clause restricting l to the features in fipscode, none if cbfips is not read from a column, then every feature is read and those outside fipscode are dropped as they are streamed.
+func (gpk gdalDataSet) filterFips(l gdal.Layer, fipscode string) []string {
+	idx := gpk.fields.fipsColumn()
+	if idx < 0 {
+		return nil
+	}
+	fdef := l.Definition().FieldDefinition(idx)
+	return []string{"SUBSTR(" + fdef.Name() + ",1," + fmt.Sprint(len(fipscode)) + ") = '" + fipscode + "'"}
+}
+
+// verbatimAttributes are the attributes a structure takes unchanged from a column, so conditions on them can be tested on the column.
+var verbatimAttributes = []string{"fd_id", "cbfips", "st_damcat", "found_type", "firmzone", "bldgtype", "val_struct", "val_cont", "ground_elv"}
+
+// where are the ogr sql clauses of the conditions a layer can test, column is the name and type of a column. A condition is tested if its attribute is read unchanged from a column of its type, text only for equality as ogr may compare text without case.
+func (sf structureFields) where(conditions []attributeCondition, column func(index int) (string, gdal.FieldType)) []string {
+	clauses := make([]string, 0)
+	for _, c := range conditions {
+		mf, ok := sf[c.attribute]
+		if !ok || !contains(verbatimAttributes, c.attribute) || mf.index < 0 || mf.expression != nil || mf.constant != nil || mf.scale != 1 {
+			continue
+		}
+		name, fieldType := column(mf.index)
+		switch fieldType {
+		case gdal.FT_String:
+			if !mf.text || (c.operator != "=" && c.operator != "IN") {
+				continue
+			}
+		case gdal.FT_Integer, gdal.FT_Integer64, gdal.FT_Real:
+			if mf.text {
+				continue
+			}
+		default:
+			continue
+		}
+		clauses = append(clauses, c.sql(name))
+	}
+	return clauses
+}
+
+// filterLayer restricts the features l reads to those meeting clauses and the prefilter's conditions, within bbox if it is set and within the prefilter's bounds. ok is false if no feature can be within both.
+func (gpk gdalDataSet) filterLayer(l gdal.Layer, clauses []string, bbox *geography.BBox, pf prefilter) bool {
+	def := l.Definition()
+	where := gpk.fields.where(pf.conditions, func(index int) (string, gdal.FieldType) {
+		fd := def.FieldDefinition(index)
+		return fd.Name(), fd.Type()
+	})
+	err := l.SetAttributeFilter(strings.Join(append(clauses, where...), " AND "))
+	if err != nil && len(where) > 0 {
+		//the prefilter only saves reading features the filter drops, every structure streamed is filtered again.
+		err = l.SetAttributeFilter(strings.Join(clauses, " AND "))
+	}
+	if err != nil {
+		panic(err)
+	}
+	rects := make([]geography.BBox, 0)
+	if bbox != nil {
+		rects = append(rects, *bbox)
+	}
+	if pf.bounds != nil {
+		b, err := projection.TransformBBox(*pf.bounds, gpk.SpatialReference())
+		if err == nil {
+			rects = append(rects, b)
+		}
+	}
+	if len(rects) == 0 {
+		l.SetSpatialFilter(gdal.Geometry{})
+		return true
+	}
+	minX, minY, maxX, maxY := math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)
+	for _, r := range rects {
+		minX, maxX = math.Max(minX, r.Bbox[0]), math.Min(maxX, r.Bbox[2])
+		minY, maxY = math.Max(minY, math.Min(r.Bbox[1], r.Bbox[3])), math.Min(maxY, math.Max(r.Bbox[1], r.Bbox[3]))
+	}
+	if minX > maxX || minY > maxY {
+		return false
+	}
+	l.SetSpatialFilterRect(minX, minY, maxX, maxY)
+	return true
+}
+
+// ByBbox streams the structures within bbox, which is transformed into the layer's reference system if it is in another.
 func (gpk gdalDataSet) ByBbox(bbox geography.BBox, sp consequences.StreamProcessor) {
+	gpk.byBboxPrefiltered(bbox, prefilter{}, sp)
+}
+
+// byBboxPrefiltered implements prefilteringProvider.
+func (gpk gdalDataSet) byBboxPrefiltered(bbox geography.BBox, pf prefilter, sp consequences.StreamProcessor) {
+	bbox, err := projection.TransformBBox(bbox, gpk.SpatialReference())
+	if err != nil {
+		gpk.record(err)
+		return
+	}
 	if gpk.deterministic {
-		gpk.processBboxStreamDeterministic(bbox, sp)
+		gpk.processBboxStreamDeterministic(bbox, pf, sp)
 	} else {
-		gpk.processBboxStream(bbox, sp)
+		gpk.processBboxStream(bbox, pf, sp)
 	}
 
 }
-func (gpk gdalDataSet) processBboxStream(bbox geography.BBox, sp consequences.StreamProcessor) {
+func (gpk gdalDataSet) processBboxStream(bbox geography.BBox, pf prefilter, sp consequences.StreamProcessor) {
 	m := gpk.OccTypeProvider.OccupancyTypeMap()
 	//define a default occtype in case of emergancy
 	defaultOcctype := m["RES1-1SNB"]
 	idx := 0
 	l := gpk.ds.LayerByName(gpk.LayerName)
-	l.SetSpatialFilterRect(bbox.Bbox[0], bbox.Bbox[3], bbox.Bbox[2], bbox.Bbox[1])
+	srs := gpk.SpatialReference()
+	if !gpk.filterLayer(l, nil, &bbox, pf) {
+		return
+	}
 	fc, _ := l.FeatureCount(true)
-	r := rand.New(rand.NewSource(gpk.seed))
 	for idx < fc { // Iterate and fetch the records from result cursor
 		f := l.NextFeature()
 		idx++
 		if f != nil {
-			s, err := featuretoStructure(f, m, defaultOcctype, gpk.schemaIDX, gpk.optionalSchemaIDX)
+			s, err := featuretoStructure(f, m, defaultOcctype, gpk.fields)
+			if err != nil {
+				gpk.record(err)
+				continue
+			}
+			s.SRID = srs
 			s.ApplyFoundationHeightUncertanty(gpk.FoundationUncertainty)
 			s.UseUncertainty = true
-			sd := s.SampleStructure(r.Int63())
-			if err == nil {
-				sp(sd)
-			}
+			s.Seed = structures.StructureSeed(gpk.seed, s.Name)
+			sp(s) //Compute samples with s.Seed, so repeated computes see the same structure.
 		}
 	}
 }
 
-func (gpk gdalDataSet) processBboxStreamDeterministic(bbox geography.BBox, sp consequences.StreamProcessor) {
+func (gpk gdalDataSet) processBboxStreamDeterministic(bbox geography.BBox, pf prefilter, sp consequences.StreamProcessor) {
 	m := gpk.OccTypeProvider.OccupancyTypeMap()
 	m2 := swapOcctypeMap(m)
 	//define a default occtype in case of emergancy
 	defaultOcctype := m2["RES1-1SNB"]
 	idx := 0
 	l := gpk.ds.LayerByName(gpk.LayerName)
-	l.SetSpatialFilterRect(bbox.Bbox[0], bbox.Bbox[3], bbox.Bbox[2], bbox.Bbox[1])
+	srs := gpk.SpatialReference()
+	if !gpk.filterLayer(l, nil, &bbox, pf) {
+		return
+	}
 	fc, _ := l.FeatureCount(true)
 	for idx < fc { // Iterate and fetch the records from result cursor
 		f := l.NextFeature()
 		idx++
 		if f != nil {
-			s, err := featuretoDeterministicStructure(f, m2, defaultOcctype, gpk.schemaIDX, gpk.optionalSchemaIDX)
-			if err == nil {
-				sp(s)
+			s, err := featuretoDeterministicStructure(f, m2, defaultOcctype, gpk.fields)
+			if err != nil {
+				gpk.record(err)
+				continue
 			}
+			s.SRID = srs
+			sp(s)
 		}
 	}
 }
@@ -0,0 +1,74 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// DepthVelocitySurface is a damage table indexed by both depth and velocity, for damage functions driven by "depth, velocity". Damages[i][j] is the percent damage at Velocities[i] and Depths[j]. Each velocity's row is interpolated along depth like a depth damage curve, no damage below the first depth and the last damage above the last, then damage is interpolated linearly between velocities and held at the first and last velocity beyond them.
+type DepthVelocitySurface struct {
+	Depths     []float64   `json:"depths"`
+	Velocities []float64   `json:"velocities"`
+	Damages    [][]float64 `json:"damages"`
+}
+
+// Validate checks the depths and velocities increase and that there is a damage for each.
+func (s DepthVelocitySurface) Validate() error {
+	errs := make([]error, 0)
+	increasing := func(name string, vals []float64) {
+		if len(vals) == 0 {
+			errs = append(errs, fmt.Errorf("structures: a depth velocity surface requires at least one of %v", name))
+		}
+		for i := 1; i < len(vals); i++ {
+			if vals[i] <= vals[i-1] {
+				errs = append(errs, fmt.Errorf("structures: depth velocity surface %v must increase", name))
+				return
+			}
+		}
+	}
+	increasing("depths", s.Depths)
+	increasing("velocities", s.Velocities)
+	if len(s.Damages) != len(s.Velocities) {
+		errs = append(errs, fmt.Errorf("structures: a depth velocity surface has %v velocities and %v rows of damages", len(s.Velocities), len(s.Damages)))
+	}
+	for i, row := range s.Damages {
+		if len(row) != len(s.Depths) {
+			errs = append(errs, fmt.Errorf("structures: depth velocity surface row %v has %v damages for %v depths", i, len(row), len(s.Depths)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SampleValue interpolates the percent damage at a depth and velocity.
+func (s DepthVelocitySurface) SampleValue(depth float64, velocity float64) float64 {
+	row := func(i int) float64 {
+		return paireddata.PairedData{Xvals: s.Depths, Yvals: s.Damages[i]}.SampleValue(depth)
+	}
+	last := len(s.Velocities) - 1
+	if velocity <= s.Velocities[0] {
+		return row(0)
+	}
+	if velocity >= s.Velocities[last] {
+		return row(last)
+	}
+	upper := 1
+	for s.Velocities[upper] < velocity {
+		upper++
+	}
+	frac := (velocity - s.Velocities[upper-1]) / (s.Velocities[upper] - s.Velocities[upper-1])
+	return row(upper-1) + frac*(row(upper)-row(upper-1))
+}
+
+// validateDepthVelocity checks a damage function driven by depth and velocity together has a surface, and that any surface is valid.
+func validateDepthVelocity(driver hazards.Parameter, surface *DepthVelocitySurface) error {
+	if surface == nil {
+		if driver == hazards.Depth|hazards.Velocity {
+			return errors.New("structures: a damage function driven by depth, velocity requires a depthvelocity surface")
+		}
+		return nil
+	}
+	return surface.Validate()
+}
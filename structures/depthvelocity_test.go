@@ -0,0 +1,86 @@
+package structures
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func TestDepthVelocitySurface_SampleValue(t *testing.T) {
+	s := DepthVelocitySurface{Depths: []float64{1, 5}, Velocities: []float64{0, 10}, Damages: [][]float64{{10, 50}, {30, 100}}}
+	for _, c := range []struct {
+		depth, velocity, expected float64
+	}{
+		{3, 0, 30},
+		{3, 5, 47.5},
+		{3, 20, 65},
+		{0, 5, 0},
+		{9, -1, 50},
+	} {
+		if got := s.SampleValue(c.depth, c.velocity); math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("expected %v at depth %v and velocity %v, got %v", c.expected, c.depth, c.velocity, got)
+		}
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []DepthVelocitySurface{
+		{},
+		{Depths: []float64{1, 1}, Velocities: []float64{0}, Damages: [][]float64{{1, 2}}},
+		{Depths: []float64{1, 2}, Velocities: []float64{0, 1}, Damages: [][]float64{{1, 2}}},
+		{Depths: []float64{1, 2}, Velocities: []float64{0}, Damages: [][]float64{{1}}},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+// velocityOccupancyTypes has a depth velocity surface for structures and a depth times velocity curve for contents when there is velocity, and depth curves otherwise.
+const velocityOccupancyTypes = `{"occupancytypes": {"FLASH": {"name": "FLASH", "componentdamagefunctions": {
+	"structure": {"damagefunctions": {
+		"default": {"source": "test", "damagedriver": "depth", "damagefunction": {"xvalues": [0, 10], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 10}}]}},
+		"depth, velocity": {"source": "test", "damagedriver": "depth, velocity", "depthvelocity": {"depths": [0, 4], "velocities": [0, 8], "damages": [[0, 20], [0, 100]]}}
+	}},
+	"contents": {"damagefunctions": {
+		"default": {"source": "test", "damagedriver": "depth", "damagefunction": {"xvalues": [0, 10], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 10}}]}},
+		"depth, velocity": {"source": "test", "damagedriver": "depthtimesvelocity", "damagefunction": {"xvalues": [0, 40], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 80}}]}}
+	}}
+}}}}`
+
+func TestComputeConsequences_velocity(t *testing.T) {
+	var otc OccupancyTypesContainer
+	err := json.Unmarshal([]byte(velocityOccupancyTypes), &otc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := StructureStochastic{OccType: otc.OccupancyTypes["FLASH"], StructVal: consequences.ParameterValue{Value: 100.0}, ContVal: consequences.ParameterValue{Value: 100.0}, FoundHt: consequences.ParameterValue{Value: 1.0}, BaseStructure: BaseStructure{DamCat: "category"}}
+	for _, c := range []struct {
+		velocity           float64
+		structure, content float64
+	}{
+		//3 feet above the first floor at 4 feet per second is halfway between 15 and 75 percent, contents see 4 times 4 depth times velocity.
+		{4, 45, 32},
+		//without velocity the depth curves apply to the 3 feet above the first floor.
+		{-901, 3, 3},
+	} {
+		e := hazards.HazardDataToMultiParameter(hazards.HazardData{Depth: 4, Velocity: c.velocity, Erosion: -901, Duration: -901, WaveHeight: -901, DV: -901})
+		r, err := s.Compute(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd, _ := r.Fetch("structure damage")
+		cd, _ := r.Fetch("content damage")
+		if math.Abs(sd.(float64)-c.structure) > 1e-9 || math.Abs(cd.(float64)-c.content) > 1e-9 {
+			t.Errorf("expected %v structure and %v content damage at velocity %v, got %v and %v", c.structure, c.content, c.velocity, sd, cd)
+		}
+	}
+	invalid := `{"damagefunctions": {"depth, velocity": {"source": "test", "damagedriver": "depth, velocity"}}}`
+	var dffs DamageFunctionFamilyStochastic
+	if json.Unmarshal([]byte(invalid), &dffs) == nil {
+		t.Error("expected a depth, velocity damage function without a surface to be invalid")
+	}
+}
@@ -0,0 +1,39 @@
+package structures
+
+import (
+	"errors"
+	"math"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// durationAdjusted multiplies a percent damage by the damage function's duration multiplier at the event's duration in days, to at most 100 percent. Damage is unchanged without a multiplier or a duration, the multiplier starts at zero days so every duration has one.
+func (df DamageFunction) durationAdjusted(percent float64, e hazards.HazardEvent) float64 {
+	if df.DurationMultiplier == nil || !e.Has(hazards.Duration) {
+		return percent
+	}
+	return math.Min(percent*df.DurationMultiplier.SampleValue(e.Duration()), 100)
+}
+
+// validateDurationMultiplier checks a duration multiplier has increasing durations starting at zero days, so no duration samples the zero paired data gives before its first, and a multiplier for each that is not negative.
+func validateDurationMultiplier(m *paireddata.PairedData) error {
+	if m == nil {
+		return nil
+	}
+	if len(m.Xvals) == 0 || len(m.Xvals) != len(m.Yvals) {
+		return errors.New("structures: a duration multiplier requires a multiplier for each of at least one duration")
+	}
+	if m.Xvals[0] != 0 {
+		return errors.New("structures: duration multiplier durations must start at zero days")
+	}
+	for i := range m.Xvals {
+		if i > 0 && m.Xvals[i] <= m.Xvals[i-1] {
+			return errors.New("structures: duration multiplier durations must increase")
+		}
+		if m.Yvals[i] < 0 {
+			return errors.New("structures: duration multipliers must not be negative")
+		}
+	}
+	return nil
+}
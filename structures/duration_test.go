@@ -0,0 +1,62 @@
+package structures
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// pondingOccupancyTypes has a short duration depth curve, a steeper curve for long duration and a contents multiplier that doubles damage after 10 days.
+const pondingOccupancyTypes = `{"occupancytypes": {"PONDING": {"name": "PONDING", "componentdamagefunctions": {
+	"structure": {"damagefunctions": {
+		"depth, duration": {"source": "test", "damagedriver": "depth", "damagefunction": {"xvalues": [0, 10], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 10}}]}},
+		"depth, longduration": {"source": "test", "damagedriver": "depth", "damagefunction": {"xvalues": [0, 10], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 30}}]}}
+	}},
+	"contents": {"damagefunctions": {
+		"default": {"source": "test", "damagedriver": "depth", "damagefunction": {"xvalues": [0, 10], "ydistributions": [{"type": "DeterministicDistribution", "parameters": {"value": 0}}, {"type": "DeterministicDistribution", "parameters": {"value": 60}}]}, "durationmultiplier": {"xvalues": [0, 10], "yvalues": [1, 2]}}
+	}}
+}}}}`
+
+func TestComputeConsequences_duration(t *testing.T) {
+	var otc OccupancyTypesContainer
+	err := json.Unmarshal([]byte(pondingOccupancyTypes), &otc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := StructureStochastic{OccType: otc.OccupancyTypes["PONDING"], StructVal: consequences.ParameterValue{Value: 100.0}, ContVal: consequences.ParameterValue{Value: 100.0}, FoundHt: consequences.ParameterValue{Value: 0.0}, BaseStructure: BaseStructure{DamCat: "category"}}
+	for _, c := range []struct {
+		duration           float64
+		structure, content float64
+	}{
+		{1, 5, 33},
+		{5, 15, 45},
+		{20, 15, 60},
+	} {
+		e := hazards.HazardDataToMultiParameter(hazards.HazardData{Depth: 5, Velocity: -901, Erosion: -901, Duration: c.duration, WaveHeight: -901, DV: -901})
+		r, err := s.Compute(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd, _ := r.Fetch("structure damage")
+		cd, _ := r.Fetch("content damage")
+		if math.Abs(sd.(float64)-c.structure) > 1e-9 || math.Abs(cd.(float64)-c.content) > 1e-9 {
+			t.Errorf("expected %v structure and %v content damage after %v days, got %v and %v", c.structure, c.content, c.duration, sd, cd)
+		}
+	}
+}
+func TestDurationMultiplier_Validate(t *testing.T) {
+	for _, invalid := range []string{
+		`{"damagefunctions": {"default": {"source": "test", "damagedriver": "depth", "durationmultiplier": {"xvalues": [0, 0], "yvalues": [1, 2]}}}}`,
+		`{"damagefunctions": {"default": {"source": "test", "damagedriver": "depth", "durationmultiplier": {"xvalues": [0, 1], "yvalues": [1]}}}}`,
+		`{"damagefunctions": {"default": {"source": "test", "damagedriver": "depth", "durationmultiplier": {"xvalues": [0], "yvalues": [-1]}}}}`,
+		`{"damagefunctions": {"default": {"source": "test", "damagedriver": "depth", "durationmultiplier": {"xvalues": [1, 10], "yvalues": [1, 2]}}}}`,
+	} {
+		var dff DamageFunctionFamily
+		if json.Unmarshal([]byte(invalid), &dff) == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+}
@@ -59,3 +59,31 @@ func (jotp JsonOccupancyTypeProvider) Write(path string) error {
 	}
 	return nil
 }
+
+// OverrideOccupancyTypesFromFile reads json occupancy types from path. The damage functions they list replace those of the occupancy types already in m, and occupancy types m does not have are added. m is modified in place.
+func OverrideOccupancyTypesFromFile(m map[string]OccupancyTypeStochastic, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c := OccupancyTypesContainer{}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return fmt.Errorf("structures: unable to parse json occupancy type overrides at path %v: %w", path, err)
+	}
+	overrides := make(map[string]OccupancyTypeStochastic)
+	additions := make(map[string]OccupancyTypeStochastic)
+	for key, value := range c.OccupancyTypes {
+		if _, exists := m[key]; exists {
+			overrides[key] = value
+		} else {
+			additions[key] = value
+		}
+	}
+	existing := OccupancyTypesContainer{OccupancyTypes: m}
+	err = existing.OverrideMap(overrides)
+	if err != nil {
+		return err
+	}
+	return existing.ExtendMap(additions)
+}
@@ -0,0 +1,228 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MitigationRecord describes the measures applied to a deterministic structure, the zero value is an unmitigated structure.
+type MitigationRecord struct {
+	TimesRaised            int32
+	OriginalFoundHt        float64 //foundation height before the structure was first raised
+	WetFloodproofHeight    float64 //depth above ground up to which content damage is reduced
+	ContentDamageReduction float64 //fraction of content damage avoided at or below WetFloodproofHeight
+	FloodproofFailure      float64 //probability dry floodproofing fails in an event, damage at or below FloodproofHeight is weighted by it
+}
+
+// MitigationMeasure modifies a structure before it is computed and estimates what that costs.
+type MitigationMeasure interface {
+	//Apply returns the mitigated structure, it is not ok if the measure removed the structure from the inventory. seed draws any uncertainty in the measure's performance.
+	Apply(s StructureDeterministic, seed int64) (StructureDeterministic, bool)
+	//Cost is the cost of applying the measure to s.
+	Cost(s StructureDeterministic) float64
+	//Validate checks the measure can be applied to s.
+	Validate(s StructureDeterministic) error
+}
+
+// CostModel estimates the cost of a measure as a fixed cost, plus a fraction of the structure value, plus a cost for each foot the measure raises or protects the structure.
+type CostModel struct {
+	FixedCost                float64 `json:"fixed_cost,omitempty"`
+	FractionOfStructureValue float64 `json:"fraction_of_structure_value,omitempty"`
+	CostPerFoot              float64 `json:"cost_per_foot,omitempty"`
+}
+
+// Cost is the cost of a measure of height feet on a structure of structureValue.
+func (c CostModel) Cost(structureValue float64, feet float64) float64 {
+	return c.FixedCost + c.FractionOfStructureValue*structureValue + c.CostPerFoot*feet
+}
+
+// Elevation raises the first floor to a target elevation, in the same datum as GroundElevation. Structures already at or above the target are unchanged and cost nothing.
+type Elevation struct {
+	TargetElevation float64
+	CostModel
+}
+
+func (e Elevation) raise(s StructureDeterministic) float64 {
+	raise := e.TargetElevation - (s.GroundElevation + s.FoundHt)
+	if raise < 0 {
+		return 0
+	}
+	return raise
+}
+
+// Validate implements MitigationMeasure, the structure must have a ground elevation. A first floor already at or above the target is left as it is.
+func (e Elevation) Validate(s StructureDeterministic) error {
+	if !s.HasGroundElevation {
+		return fmt.Errorf("structures: elevating structure %v requires a ground elevation", s.Name)
+	}
+	return nil
+}
+
+// Apply implements MitigationMeasure.
+func (e Elevation) Apply(s StructureDeterministic, seed int64) (StructureDeterministic, bool) {
+	raise := e.raise(s)
+	if raise == 0 {
+		return s, true
+	}
+	if s.Mitigation.TimesRaised == 0 {
+		s.Mitigation.OriginalFoundHt = s.FoundHt
+	}
+	s.Mitigation.TimesRaised++
+	s.FoundHt += raise
+	return s, true
+}
+
+// Cost implements MitigationMeasure.
+func (e Elevation) Cost(s StructureDeterministic) float64 {
+	raise := e.raise(s)
+	if raise == 0 {
+		return 0
+	}
+	return e.CostModel.Cost(s.StructVal, raise)
+}
+
+// DryFloodproofing seals the structure against water up to a design depth above ground. The seal fails with FailureProbability in each event, e.g. closures that are not installed in time, so damage from water at or below the design depth is the expectation over failure, FailureProbability times the damage the structure would take without floodproofing.
+type DryFloodproofing struct {
+	DesignDepth        float64
+	FailureProbability float64
+	CostModel
+}
+
+// Apply implements MitigationMeasure.
+func (d DryFloodproofing) Apply(s StructureDeterministic, seed int64) (StructureDeterministic, bool) {
+	if d.DesignDepth > s.FloodproofHeight {
+		s.FloodproofHeight = d.DesignDepth
+		s.Mitigation.FloodproofFailure = d.FailureProbability
+	}
+	return s, true
+}
+
+// Validate implements MitigationMeasure.
+func (d DryFloodproofing) Validate(s StructureDeterministic) error {
+	return nil
+}
+
+// Cost implements MitigationMeasure.
+func (d DryFloodproofing) Cost(s StructureDeterministic) float64 {
+	return d.CostModel.Cost(s.StructVal, d.DesignDepth)
+}
+
+// WetFloodproofing lets water into the structure but uses flood resistant materials and relocates contents, so content damage is reduced by ContentDamageReduction for water up to a design depth above ground.
+type WetFloodproofing struct {
+	DesignDepth            float64
+	ContentDamageReduction float64
+	CostModel
+}
+
+// Apply implements MitigationMeasure.
+func (w WetFloodproofing) Apply(s StructureDeterministic, seed int64) (StructureDeterministic, bool) {
+	s.Mitigation.WetFloodproofHeight = w.DesignDepth
+	s.Mitigation.ContentDamageReduction = w.ContentDamageReduction
+	return s, true
+}
+
+// Validate implements MitigationMeasure.
+func (w WetFloodproofing) Validate(s StructureDeterministic) error {
+	return nil
+}
+
+// Cost implements MitigationMeasure.
+func (w WetFloodproofing) Cost(s StructureDeterministic) float64 {
+	return w.CostModel.Cost(s.StructVal, w.DesignDepth)
+}
+
+// Acquisition buys out and removes the structure, the cost model usually includes the full structure value.
+type Acquisition struct {
+	CostModel
+}
+
+// Apply implements MitigationMeasure.
+func (a Acquisition) Apply(s StructureDeterministic, seed int64) (StructureDeterministic, bool) {
+	return s, false
+}
+
+// Validate implements MitigationMeasure.
+func (a Acquisition) Validate(s StructureDeterministic) error {
+	return nil
+}
+
+// Cost implements MitigationMeasure.
+func (a Acquisition) Cost(s StructureDeterministic) float64 {
+	return a.CostModel.Cost(s.StructVal, 0)
+}
+
+// ApplyMitigation applies each measure in order and sums their costs, it is not ok if a measure removed the structure. Each measure draws from its own stream derived from seed. It is an error if a measure cannot be applied to the structure as it is when the measure is reached.
+func ApplyMitigation(s StructureDeterministic, measures []MitigationMeasure, seed int64) (StructureDeterministic, float64, bool, error) {
+	cost := 0.0
+	for i, m := range measures {
+		if err := m.Validate(s); err != nil {
+			return s, cost, false, err
+		}
+		cost += m.Cost(s)
+		var ok bool
+		s, ok = m.Apply(s, DeriveSeed(seed, int64(i)))
+		if !ok {
+			return s, cost, false, nil
+		}
+	}
+	return s, cost, true, nil
+}
+
+// wetFloodproofed is true when content damage at depth is reduced by wet floodproofing.
+func (s StructureDeterministic) wetFloodproofed(depth float64) bool {
+	return s.Mitigation.ContentDamageReduction > 0 && depth <= s.Mitigation.WetFloodproofHeight
+}
+
+// originalFFE is the first floor elevation before the structure was raised.
+func (s StructureDeterministic) originalFFE() float64 {
+	if s.Mitigation.TimesRaised > 0 {
+		return s.GroundElevation + s.Mitigation.OriginalFoundHt
+	}
+	return s.GroundElevation + s.FoundHt
+}
+
+type MitigationMeasureType string
+
+const (
+	ElevationMeasure        MitigationMeasureType = "elevation"
+	DryFloodproofingMeasure MitigationMeasureType = "dry_floodproofing"
+	WetFloodproofingMeasure MitigationMeasureType = "wet_floodproofing"
+	AcquisitionMeasure      MitigationMeasureType = "acquisition"
+)
+
+// MitigationMeasureInfo describes a measure in a configuration, only the parameters of its type are used.
+type MitigationMeasureInfo struct {
+	Type                   MitigationMeasureType `json:"measure_type"`
+	TargetElevation        float64               `json:"target_elevation,omitempty"`
+	DesignDepth            float64               `json:"design_depth,omitempty"`
+	FailureProbability     float64               `json:"failure_probability,omitempty"`
+	ContentDamageReduction float64               `json:"content_damage_reduction,omitempty"`
+	CostModel              CostModel             `json:"cost_model"`
+}
+
+func (info MitigationMeasureInfo) CreateMitigationMeasure() (MitigationMeasure, error) {
+	switch info.Type {
+	case ElevationMeasure:
+		return Elevation{TargetElevation: info.TargetElevation, CostModel: info.CostModel}, nil
+	case DryFloodproofingMeasure:
+		if info.DesignDepth <= 0 {
+			return nil, errors.New("structures: dry floodproofing requires a positive design_depth")
+		}
+		if info.FailureProbability < 0 || info.FailureProbability > 1 {
+			return nil, errors.New("structures: failure_probability must be between 0 and 1")
+		}
+		return DryFloodproofing{DesignDepth: info.DesignDepth, FailureProbability: info.FailureProbability, CostModel: info.CostModel}, nil
+	case WetFloodproofingMeasure:
+		if info.DesignDepth <= 0 {
+			return nil, errors.New("structures: wet floodproofing requires a positive design_depth")
+		}
+		if info.ContentDamageReduction <= 0 || info.ContentDamageReduction > 1 {
+			return nil, errors.New("structures: content_damage_reduction must be greater than 0 and at most 1")
+		}
+		return WetFloodproofing{DesignDepth: info.DesignDepth, ContentDamageReduction: info.ContentDamageReduction, CostModel: info.CostModel}, nil
+	case AcquisitionMeasure:
+		return Acquisition{CostModel: info.CostModel}, nil
+	default:
+		return nil, fmt.Errorf("structures: could not create a mitigation measure of type %v", info.Type)
+	}
+}
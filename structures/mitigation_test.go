@@ -0,0 +1,141 @@
+package structures
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func mitigationTestStructure() StructureDeterministic {
+	otp := JsonOccupancyTypeProvider{}
+	otp.InitDefault()
+	s := overrideTestStructure()
+	s.OccType = otp.OccupancyTypeMap()["RES1-1SNB"]
+	s.UseUncertainty = false
+	s.FoundHt.Value = 1.0
+	s.StructVal.Value = 100000.0
+	s.HasGroundElevation = true
+	return s.SampleStructure(s.Seed)
+}
+func TestElevation(t *testing.T) {
+	s := mitigationTestStructure()
+	e := Elevation{TargetElevation: 15, CostModel: CostModel{FixedCost: 10000, CostPerFoot: 5000}}
+	if c := e.Cost(s); c != 30000 {
+		t.Errorf("raising 4 feet cost %f; expected %f", c, 30000.0)
+	}
+	raised, ok := e.Apply(s, 0)
+	if !ok {
+		t.Fatal("elevation removed the structure")
+	}
+	if raised.FoundHt != 5 || raised.Mitigation.TimesRaised != 1 || raised.Mitigation.OriginalFoundHt != 1 {
+		t.Errorf("raised structure had foundation height %f raised %v times from %f", raised.FoundHt, raised.Mitigation.TimesRaised, raised.Mitigation.OriginalFoundHt)
+	}
+	if c := e.Cost(raised); c != 0 {
+		t.Errorf("raising a structure already at the target cost %f", c)
+	}
+	if err := e.Validate(s); err != nil {
+		t.Error(err)
+	}
+	below := Elevation{TargetElevation: 10.5, CostModel: CostModel{FixedCost: 10000}}
+	unchanged, c, ok, err := ApplyMitigation(s, []MitigationMeasure{below}, 0)
+	if err != nil || !ok || c != 0 || unchanged.FoundHt != s.FoundHt || unchanged.Mitigation.TimesRaised != 0 {
+		t.Errorf("a target below the first floor raised the structure to %f for %f: %v %v", unchanged.FoundHt, c, ok, err)
+	}
+	s.HasGroundElevation = false
+	if e.Validate(s) == nil {
+		t.Error("expected an error elevating a structure without a ground elevation")
+	}
+	if _, _, _, err := ApplyMitigation(s, []MitigationMeasure{e}, 0); err == nil {
+		t.Error("expected ApplyMitigation to reject a structure without a ground elevation")
+	}
+}
+func TestWetFloodproofing(t *testing.T) {
+	s := mitigationTestStructure()
+	d := hazards.DepthEvent{}
+	d.SetDepth(3)
+	r, _ := s.Compute(d)
+	before, _ := r.Fetch("content damage")
+	wet, _ := WetFloodproofing{DesignDepth: 4, ContentDamageReduction: .5}.Apply(s, 0)
+	r, _ = wet.Compute(d)
+	after, _ := r.Fetch("content damage")
+	if after.(float64) != before.(float64)*.5 {
+		t.Errorf("wet floodproofing left %f content damage; expected %f", after, before.(float64)*.5)
+	}
+	sd, _ := r.Fetch("structure damage")
+	if sd.(float64) == 0 {
+		t.Error("wet floodproofing removed structure damage")
+	}
+}
+func TestApplyMitigation(t *testing.T) {
+	s := mitigationTestStructure()
+	measures := []MitigationMeasure{
+		DryFloodproofing{DesignDepth: 3, FailureProbability: 0, CostModel: CostModel{CostPerFoot: 1000}},
+		Acquisition{CostModel: CostModel{FractionOfStructureValue: 1}},
+	}
+	_, cost, ok, err := ApplyMitigation(s, measures, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("acquisition did not remove the structure")
+	}
+	if cost != 103000 {
+		t.Errorf("measures cost %f; expected %f", cost, 103000.0)
+	}
+}
+func TestDryFloodproofing_ExpectedOverFailure(t *testing.T) {
+	s := mitigationTestStructure()
+	damage := func(s StructureDeterministic, depth float64) float64 {
+		d := hazards.DepthEvent{}
+		d.SetDepth(depth)
+		r, _ := s.Compute(d)
+		sd, _ := r.Fetch("structure damage")
+		return sd.(float64)
+	}
+	for seed := int64(0); seed < 3; seed++ {
+		fp, _, _, err := ApplyMitigation(s, []MitigationMeasure{DryFloodproofing{DesignDepth: 3, FailureProbability: .2}}, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, expected := damage(fp, 2.5), .2*damage(s, 2.5); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("seed %v: floodproofed damage was %v; expected 0.2 of the unprotected %v", seed, got, expected)
+		}
+		if got, expected := damage(fp, 4), damage(s, 4); got != expected {
+			t.Errorf("seed %v: water over the floodproofing caused %v; expected the unprotected %v", seed, got, expected)
+		}
+	}
+	perfect, _ := DryFloodproofing{DesignDepth: 3}.Apply(s, 0)
+	if d := damage(perfect, 2.5); d != 0 {
+		t.Errorf("floodproofing that cannot fail left %v damage", d)
+	}
+}
+func TestFloodproofing_Multi(t *testing.T) {
+	df := DamageFunction{Source: "fabricated", DamageDriver: hazards.Depth, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 10}, Yvals: []float64{0, 100}}}
+	family := DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: df}}
+	o := OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: map[string]DamageFunctionFamily{"structure": family, "contents": family, "reconstruction": family}}
+	s := StructureDeterministic{OccType: o, StructVal: 100, ContVal: 100, BaseStructure: BaseStructure{Name: "1"}}
+	e := hazards.ArrivalDepthandDurationEvent{}
+	e.SetDepth(2)
+	e.SetDuration(0)
+	e.SetArrivalTime(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC))
+	damage := func(s StructureDeterministic) (float64, float64) {
+		r, err := computeConsequencesMulti([]hazards.HazardEvent{e}, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd, _ := r[0].Fetch("structure damage")
+		cd, _ := r[0].Fetch("content damage")
+		return sd.(float64), cd.(float64)
+	}
+	dry, _ := DryFloodproofing{DesignDepth: 3, FailureProbability: .25}.Apply(s, 0)
+	if sd, cd := damage(dry); math.Abs(sd-5) > 1e-9 || math.Abs(cd-5) > 1e-9 {
+		t.Errorf("dry floodproofing left %v structure and %v content damage; expected 5 and 5", sd, cd)
+	}
+	wet, _ := WetFloodproofing{DesignDepth: 3, ContentDamageReduction: .5}.Apply(s, 0)
+	if sd, cd := damage(wet); math.Abs(sd-20) > 1e-9 || math.Abs(cd-10) > 1e-9 {
+		t.Errorf("wet floodproofing left %v structure and %v content damage; expected 20 and 10", sd, cd)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/bits"
 	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
@@ -61,6 +63,9 @@ func (dff *DamageFunctionFamily) UnmarshalJSON(b []byte) error {
 		if err != nil {
 			return errors.New("structures: could not unmarshal parameter key " + key)
 		}
+		if err = errors.Join(validateDepthVelocity(value.DamageDriver, value.DepthVelocity), validateDurationMultiplier(value.DurationMultiplier)); err != nil {
+			return err
+		}
 		damgfunctions[p] = value
 	}
 	dff.DamageFunctions = damgfunctions
@@ -112,6 +117,9 @@ func (dffs *DamageFunctionFamilyStochastic) UnmarshalJSON(b []byte) error {
 		if err != nil {
 			return errors.New("structures: could not unmarshal parameter key " + key)
 		}
+		if err = errors.Join(validateDepthVelocity(value.DamageDriver, value.DepthVelocity), validateDurationMultiplier(value.DurationMultiplier)); err != nil {
+			return err
+		}
 		damgfunctions[p] = value
 	}
 	dffs.DamageFunctions = damgfunctions
@@ -119,14 +127,18 @@ func (dffs *DamageFunctionFamilyStochastic) UnmarshalJSON(b []byte) error {
 }
 
 type DamageFunction struct {
-	Source         string                `json:"source"`
-	DamageDriver   hazards.Parameter     `json:"damagedriver"`
-	DamageFunction paireddata.PairedData `json:"damagefunction"`
+	Source             string                 `json:"source"`
+	DamageDriver       hazards.Parameter      `json:"damagedriver"`
+	DamageFunction     paireddata.PairedData  `json:"damagefunction"`
+	DepthVelocity      *DepthVelocitySurface  `json:"depthvelocity,omitempty"`      //damage by depth and velocity together, for a depth, velocity damage driver
+	DurationMultiplier *paireddata.PairedData `json:"durationmultiplier,omitempty"` //multiplier on damage by duration in days
 }
 type DamageFunctionStochastic struct {
-	Source         string                           `json:"source"`
-	DamageDriver   hazards.Parameter                `json:"damagedriver"`
-	DamageFunction paireddata.UncertaintyPairedData `json:"damagefunction"`
+	Source             string                           `json:"source"`
+	DamageDriver       hazards.Parameter                `json:"damagedriver"`
+	DamageFunction     paireddata.UncertaintyPairedData `json:"damagefunction"`
+	DepthVelocity      *DepthVelocitySurface            `json:"depthvelocity,omitempty"`      //damage by depth and velocity together, for a depth, velocity damage driver, it has no uncertainty
+	DurationMultiplier *paireddata.PairedData           `json:"durationmultiplier,omitempty"` //multiplier on damage by duration in days, it has no uncertainty
 }
 
 //OccupancyTypeStochastic is used to describe an occupancy type with uncertainty in the damage relationships it produces an OccupancyTypeDeterministic through the UncertaintyOccupancyTypeSampler interface
@@ -225,16 +237,29 @@ func (otc OccupancyTypesContainer) OcctypeReport() ([]byte, error) {
 func (o OccupancyTypeDeterministic) GetComponentDamageFunctionForHazard(component string, h hazards.HazardEvent) (DamageFunction, error) {
 	c, cok := o.ComponentDamageFunctions[component]
 	if cok {
-		r, rok := c.DamageFunctions[h.Parameters()]
-		if rok {
-			return r, nil
-		} else {
-			return c.DamageFunctions[hazards.Default], nil //errors.New("using default damage function")
-		}
+		return c.DamageFunctions[familyKey(c.DamageFunctions, h.Parameters())], nil
 	}
 	return DamageFunction{}, errors.New("component does not exist for this occupancy type")
 }
 
+// familyKey finds the damage function family entry for a hazard's parameters. An entry for exactly the parameters is used if there is one, otherwise the entry for the most of the parameters, such as depth, waveheight, mediumwaveheight, salinity for an event that also has a velocity, otherwise the default. Ties go to the entry with more classes, so depth, longduration is chosen over depth, duration for long flooding, and then to the lower bitflag so the choice does not depend on map order.
+func familyKey[T any](functions map[hazards.Parameter]T, p hazards.Parameter) hazards.Parameter {
+	if _, ok := functions[p]; ok {
+		return p
+	}
+	best, count, classes := hazards.Default, 0, 0
+	for k := range functions {
+		if k == hazards.Default || k&^p != 0 {
+			continue
+		}
+		n, c := bits.OnesCount(uint(k)), bits.OnesCount(uint(k&hazards.ClassParameters))
+		if n > count || (n == count && (c > classes || (c == classes && k < best))) {
+			best, count, classes = k, n, c
+		}
+	}
+	return best
+}
+
 //UncertaintyOccupancyTypeSampler provides the pattern for an OccupancyTypeStochastic to produce an OccupancyTypeDeterministic
 type UncertaintyOccupancyTypeSampler interface {
 	SampleOccupancyType(rand int64) OccupancyTypeDeterministic
@@ -244,16 +269,30 @@ type UncertaintyOccupancyTypeSampler interface {
 //SampleOccupancyType implements the UncertaintyOccupancyTypeSampler on the OccupancyTypeStochastic interface.
 func (o OccupancyTypeStochastic) SampleOccupancyType(seed int64) OccupancyTypeDeterministic {
 	r := rand.New(rand.NewSource(seed))
-	//iterate through damage function family
+	//iterate through damage function family in sorted order, map iteration order is random and would change the draws for a given seed.
 	cm := make(map[string]DamageFunctionFamily)
-	for ck, cv := range o.ComponentDamageFunctions { //components
+	components := make([]string, 0, len(o.ComponentDamageFunctions))
+	for ck := range o.ComponentDamageFunctions {
+		components = append(components, ck)
+	}
+	sort.Strings(components)
+	for _, ck := range components { //components
+		cv := o.ComponentDamageFunctions[ck]
 		hm := make(map[hazards.Parameter]DamageFunction)
 		var cdf = DamageFunctionFamily{DamageFunctions: hm}
-		for k, v := range cv.DamageFunctions { //hazards
+		parameters := make([]hazards.Parameter, 0, len(cv.DamageFunctions))
+		for k := range cv.DamageFunctions {
+			parameters = append(parameters, k)
+		}
+		sort.Slice(parameters, func(i, j int) bool { return parameters[i] < parameters[j] })
+		for _, k := range parameters { //hazards
+			v := cv.DamageFunctions[k]
 			df := DamageFunction{}
 			df.DamageDriver = v.DamageDriver
 			df.Source = v.Source
 			df.DamageFunction = samplePairedDataValueSampler(r, v.DamageFunction)
+			df.DepthVelocity = v.DepthVelocity
+			df.DurationMultiplier = v.DurationMultiplier
 			cdf.DamageFunctions[k] = df
 		}
 		cm[ck] = cdf
@@ -308,6 +347,8 @@ func (o OccupancyTypeStochastic) CentralTendency() OccupancyTypeDeterministic {
 			df.DamageDriver = v.DamageDriver
 			df.Source = v.Source
 			df.DamageFunction = centralTendencyPairedDataValueSampler(v.DamageFunction)
+			df.DepthVelocity = v.DepthVelocity
+			df.DurationMultiplier = v.DurationMultiplier
 			cdf.DamageFunctions[k] = df
 		}
 		cm[ck] = cdf
@@ -3,6 +3,8 @@ package structures
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
@@ -76,6 +78,65 @@ func TestDamageFunctionFamily(t *testing.T) {
 		t.Errorf("Expected 6 %v", c2v)
 	}
 }
+func TestFamilyKey(t *testing.T) {
+	functions := map[hazards.Parameter]bool{
+		hazards.Default:                  true,
+		hazards.Depth:                    true,
+		hazards.Erosion:                  true,
+		hazards.Depth | hazards.Salinity: true,
+		hazards.Depth | hazards.WaveHeight | hazards.HighWaveHeight | hazards.Salinity: true,
+	}
+	for _, c := range []struct {
+		p, expected hazards.Parameter
+	}{
+		{hazards.Depth | hazards.Salinity, hazards.Depth | hazards.Salinity},
+		{hazards.Depth | hazards.Velocity | hazards.WaveHeight | hazards.HighWaveHeight | hazards.Salinity, hazards.Depth | hazards.WaveHeight | hazards.HighWaveHeight | hazards.Salinity},
+		{hazards.Depth | hazards.WaveHeight | hazards.MediumWaveHeight | hazards.Salinity, hazards.Depth | hazards.Salinity},
+		{hazards.Depth | hazards.Erosion, hazards.Depth},
+		{hazards.Velocity, hazards.Default},
+	} {
+		if got := familyKey(functions, c.p); got != c.expected {
+			t.Errorf("expected %v for %v, got %v", c.expected, c.p, got)
+		}
+	}
+}
+func TestFamilyKey_DefaultOccupancyTypes(t *testing.T) {
+	jotp := JsonOccupancyTypeProvider{}
+	jotp.InitDefault()
+	functions := jotp.occupancyTypesContainer.OccupancyTypes["RES1-2SNB"].ComponentDamageFunctions["structure"].DamageFunctions
+	coastal := func(depth float64, waveHeight float64, salinity bool) hazards.CoastalEvent {
+		e := hazards.CoastalEvent{}
+		e.SetDepth(depth)
+		e.SetWaveHeight(waveHeight)
+		e.SetSalinity(salinity)
+		return e
+	}
+	de := hazards.DepthEvent{}
+	de.SetDepth(2)
+	ade := hazards.ArrivalDepthandDurationEvent{}
+	ade.SetDepth(2)
+	ade.SetDuration(3)
+	dve := hazards.DepthandDVEvent{}
+	dve.SetDepth(2)
+	dve.SetDV(4)
+	for _, c := range []struct {
+		name     string
+		e        hazards.HazardEvent
+		expected hazards.Parameter
+	}{
+		{"depth", de, hazards.Depth},
+		{"arrival depth and duration", ade, hazards.Depth},
+		{"depth and dv", dve, hazards.Depth},
+		{"high waves and salt water", coastal(2, 3.4, true), hazards.Depth | hazards.WaveHeight | hazards.HighWaveHeight | hazards.Salinity},
+		{"medium waves and salt water", coastal(2, 1, true), hazards.Depth | hazards.WaveHeight | hazards.MediumWaveHeight | hazards.Salinity},
+		{"salt water", coastal(2, 0, true), hazards.Depth | hazards.Salinity},
+		{"medium waves", coastal(2, 1, false), hazards.Depth},
+	} {
+		if got := familyKey(functions, c.e.Parameters()); got != c.expected {
+			t.Errorf("expected %v for %v, got %v", c.expected, c.name, got)
+		}
+	}
+}
 func Test_occupancyCentralTendency(t *testing.T) {
 	//a map of occupancy types
 	jotp := JsonOccupancyTypeProvider{}
@@ -244,6 +305,7 @@ func Test_OccupancyType_Report(t *testing.T) {
 	b, _ := jotp.occupancyTypesContainer.OcctypeReport()
 	fmt.Println(string(b))
 }
+
 /*
 func Test_OccupancyType_Report_Inland_damageFunctions(t *testing.T) {
 	jotp := JsonOccupancyTypeProvider{}
@@ -274,3 +336,46 @@ func Test_JsonOcctypes_toFile(t *testing.T) {
 	}
 }
 */
+
+func Test_OverrideOccupancyTypesFromFile(t *testing.T) {
+	jotp := JsonOccupancyTypeProvider{}
+	jotp.InitDefault()
+	m := jotp.OccupancyTypeMap()
+	replaced := make(map[hazards.Parameter]DamageFunctionStochastic)
+	for p, df := range m["RES1-1SNB"].ComponentDamageFunctions["structure"].DamageFunctions {
+		df.Source = "override"
+		replaced[p] = df
+	}
+	custom := m["COM1"]
+	custom.Name = "CUSTOM"
+	overrides := OccupancyTypesContainer{OccupancyTypes: map[string]OccupancyTypeStochastic{
+		"RES1-1SNB": {Name: "RES1-1SNB", ComponentDamageFunctions: map[string]DamageFunctionFamilyStochastic{"structure": {DamageFunctions: replaced}}},
+		"CUSTOM":    custom,
+	}}
+	b, err := json.Marshal(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	err = os.WriteFile(path, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = OverrideOccupancyTypesFromFile(m, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, df := range m["RES1-1SNB"].ComponentDamageFunctions["structure"].DamageFunctions {
+		if df.Source != "override" {
+			t.Errorf("expected the %v structure damage function to be overridden", p)
+		}
+	}
+	for p, df := range m["RES1-1SNB"].ComponentDamageFunctions["contents"].DamageFunctions {
+		if df.Source == "override" {
+			t.Errorf("expected the %v contents damage function to be unchanged", p)
+		}
+	}
+	if _, ok := m["CUSTOM"]; !ok {
+		t.Error("expected the CUSTOM occupancy type to be added")
+	}
+}
@@ -0,0 +1,161 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/statistics"
+	"github.com/USACE/go-consequences/consequences"
+)
+
+// InventoryOverride modifies, or removes, the stochastic structures it selects, e.g. to describe a nonstructural alternative. Elevations are in the same datum as the structure's GroundElevation.
+type InventoryOverride struct {
+	FdIds               []string `json:"fd_ids,omitempty"`                //structures selected, every structure if empty
+	DamageCategory      string   `json:"damage_category,omitempty"`       //further limits the selection to one damage category if set
+	RaiseFoundationBy   float64  `json:"raise_foundation_by,omitempty"`   //raises the foundation height by a fixed amount
+	FirstFloorElevation *float64 `json:"first_floor_elevation,omitempty"` //raises the first floor to this elevation, first floors already above it are unchanged
+	FloodproofElevation *float64 `json:"floodproof_elevation,omitempty"`  //floodproofs the structure to this elevation
+	Buyout              bool     `json:"buyout,omitempty"`                //removes the structure from the inventory
+}
+
+// Validate checks the override describes a modification.
+func (o InventoryOverride) Validate() error {
+	if o.RaiseFoundationBy < 0 {
+		return errors.New("structures: raise_foundation_by must not be negative")
+	}
+	if !o.Buyout && o.RaiseFoundationBy == 0 && o.FirstFloorElevation == nil && o.FloodproofElevation == nil {
+		return errors.New("structures: an inventory override must raise, floodproof or buy out the structures it selects")
+	}
+	return nil
+}
+
+// Selects is true if the override applies to s.
+func (o InventoryOverride) Selects(s BaseStructure) bool {
+	if o.DamageCategory != "" && o.DamageCategory != s.DamCat {
+		return false
+	}
+	if len(o.FdIds) == 0 {
+		return true
+	}
+	for _, id := range o.FdIds {
+		if id == s.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns s with the override applied, it is not ok if the structure was bought out. The structure keeps its seed and the foundation height keeps its draw from the seed, so the modified structure samples the same values as the original. It is an error to override a structure to an elevation if it has no ground elevation.
+func (o InventoryOverride) Apply(s StructureStochastic) (StructureStochastic, bool, error) {
+	if !o.Selects(s.BaseStructure) {
+		return s, true, nil
+	}
+	if o.Buyout {
+		return s, false, nil
+	}
+	minimum, floodproof, err := o.heights(s.BaseStructure)
+	if err != nil {
+		return s, true, err
+	}
+	if o.RaiseFoundationBy > 0 || o.FirstFloorElevation != nil {
+		s.FoundHt = raiseFoundation(s.FoundHt, o.RaiseFoundationBy, minimum)
+	}
+	s.FloodproofHeight = math.Max(s.FloodproofHeight, floodproof)
+	return s, true, nil
+}
+
+// ApplyDeterministic is Apply for a structure without uncertainty.
+func (o InventoryOverride) ApplyDeterministic(s StructureDeterministic) (StructureDeterministic, bool, error) {
+	if !o.Selects(s.BaseStructure) {
+		return s, true, nil
+	}
+	if o.Buyout {
+		return s, false, nil
+	}
+	minimum, floodproof, err := o.heights(s.BaseStructure)
+	if err != nil {
+		return s, true, err
+	}
+	s.FoundHt = math.Max(s.FoundHt+o.RaiseFoundationBy, minimum)
+	s.FloodproofHeight = math.Max(s.FloodproofHeight, floodproof)
+	return s, true, nil
+}
+
+// heights are the minimum foundation height and the floodproofed height above ground of the override's elevations for s, negative infinity for an elevation that is not set.
+func (o InventoryOverride) heights(s BaseStructure) (float64, float64, error) {
+	minimum, floodproof := math.Inf(-1), math.Inf(-1)
+	if o.FirstFloorElevation == nil && o.FloodproofElevation == nil {
+		return minimum, floodproof, nil
+	}
+	if !s.HasGroundElevation {
+		return minimum, floodproof, fmt.Errorf("structures: overriding structure %v to an elevation requires a ground elevation", s.Name)
+	}
+	if o.FirstFloorElevation != nil {
+		minimum = *o.FirstFloorElevation - s.GroundElevation
+	}
+	if o.FloodproofElevation != nil {
+		floodproof = *o.FloodproofElevation - s.GroundElevation
+	}
+	return minimum, floodproof, nil
+}
+
+// ApplyOverrides applies each override in order, it is not ok if any override bought the structure out.
+func ApplyOverrides(overrides []InventoryOverride, s StructureStochastic) (StructureStochastic, bool, error) {
+	for _, o := range overrides {
+		var ok bool
+		var err error
+		s, ok, err = o.Apply(s)
+		if !ok || err != nil {
+			return s, ok, err
+		}
+	}
+	return s, true, nil
+}
+
+// ApplyOverridesDeterministic is ApplyOverrides for a structure without uncertainty.
+func ApplyOverridesDeterministic(overrides []InventoryOverride, s StructureDeterministic) (StructureDeterministic, bool, error) {
+	for _, o := range overrides {
+		var ok bool
+		var err error
+		s, ok, err = o.ApplyDeterministic(s)
+		if !ok || err != nil {
+			return s, ok, err
+		}
+	}
+	return s, true, nil
+}
+
+// raiseFoundation adds raise to a foundation height and holds it at or above minimum.
+func raiseFoundation(fh consequences.ParameterValue, raise float64, minimum float64) consequences.ParameterValue {
+	if d, ok := fh.Value.(statistics.ContinuousDistribution); ok {
+		return consequences.ParameterValue{Value: raisedDistribution{base: d, raise: raise, minimum: minimum}}
+	}
+	return consequences.ParameterValue{Value: math.Max(fh.CentralTendency()+raise, minimum)}
+}
+
+// raisedDistribution is a foundation height distribution shifted up by raise and held at or above minimum, a sample at a given probability is the raised sample of the base distribution at that probability.
+type raisedDistribution struct {
+	base    statistics.ContinuousDistribution
+	raise   float64
+	minimum float64
+}
+
+func (r raisedDistribution) InvCDF(probability float64) float64 {
+	return math.Max(r.base.InvCDF(probability)+r.raise, r.minimum)
+}
+func (r raisedDistribution) CDF(value float64) float64 {
+	if value < r.minimum {
+		return 0
+	}
+	return r.base.CDF(value - r.raise)
+}
+func (r raisedDistribution) PDF(value float64) float64 {
+	if value < r.minimum {
+		return 0
+	}
+	return r.base.PDF(value - r.raise)
+}
+func (r raisedDistribution) CentralTendency() float64 {
+	return math.Max(r.base.CentralTendency()+r.raise, r.minimum)
+}
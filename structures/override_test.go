@@ -0,0 +1,113 @@
+package structures
+
+import (
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/statistics"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func overrideTestStructure() StructureStochastic {
+	otp := JsonOccupancyTypeProvider{}
+	otp.InitDefault()
+	s := StructureStochastic{
+		OccType:        otp.OccupancyTypeMap()["RES1-1SNB"],
+		UseUncertainty: true,
+		BaseStructure:  BaseStructure{Name: "100", DamCat: "RES", GroundElevation: 10, HasGroundElevation: true},
+		Seed:           StructureSeed(1234, "100"),
+	}
+	s.StructVal.Value = statistics.NormalDistribution{Mean: 100000, StandardDeviation: 10000}
+	s.ContVal.Value = 50000.0
+	s.FoundHt.Value = statistics.NormalDistribution{Mean: 1, StandardDeviation: .5}
+	return s
+}
+func TestInventoryOverride_RaiseKeepsSample(t *testing.T) {
+	s := overrideTestStructure()
+	ffe := 14.0
+	raised, ok, err := InventoryOverride{FdIds: []string{"100"}, FirstFloorElevation: &ffe}.Apply(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("raising a structure removed it")
+	}
+	before := s.SampleStructure(s.Seed)
+	after := raised.SampleStructure(raised.Seed)
+	if after.StructVal != before.StructVal {
+		t.Errorf("raising changed the structure value from %f to %f", before.StructVal, after.StructVal)
+	}
+	if after.FoundHt != 4 {
+		t.Errorf("raised foundation height was %f; expected %f", after.FoundHt, 4.0)
+	}
+	by, _, _ := InventoryOverride{RaiseFoundationBy: 2}.Apply(s)
+	if fh := by.SampleStructure(s.Seed).FoundHt; fh != before.FoundHt+2 {
+		t.Errorf("foundation raised by 2 was %f; expected %f", fh, before.FoundHt+2)
+	}
+}
+func TestInventoryOverride_Selection(t *testing.T) {
+	s := overrideTestStructure()
+	_, ok, _ := InventoryOverride{FdIds: []string{"101"}, Buyout: true}.Apply(s)
+	if !ok {
+		t.Error("a buyout of another structure removed this one")
+	}
+	_, ok, _ = InventoryOverride{DamageCategory: "COM", Buyout: true}.Apply(s)
+	if !ok {
+		t.Error("a buyout of another damage category removed this one")
+	}
+	_, ok, _ = ApplyOverrides([]InventoryOverride{{RaiseFoundationBy: 1}, {DamageCategory: "RES", Buyout: true}}, s)
+	if ok {
+		t.Error("the buyout did not remove the structure")
+	}
+}
+func TestInventoryOverride_Floodproofing(t *testing.T) {
+	s := overrideTestStructure()
+	fpe := 13.0
+	fp, _, _ := InventoryOverride{FloodproofElevation: &fpe}.Apply(s)
+	d := hazards.DepthEvent{}
+	d.SetDepth(3)
+	r, err := fp.Compute(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, _ := r.Fetch("structure damage")
+	if sd.(float64) != 0 {
+		t.Errorf("floodproofed structure had %f damage below the floodproof elevation", sd)
+	}
+	d.SetDepth(3.5)
+	r, err = fp.Compute(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, _ = r.Fetch("structure damage")
+	if sd.(float64) == 0 {
+		t.Error("floodproofed structure had no damage above the floodproof elevation")
+	}
+}
+func TestInventoryOverride_RequiresGroundElevation(t *testing.T) {
+	s := overrideTestStructure()
+	s.HasGroundElevation = false
+	ffe := 14.0
+	if _, _, err := (InventoryOverride{FirstFloorElevation: &ffe}).Apply(s); err == nil {
+		t.Error("expected raising a structure without a ground elevation to a first floor elevation to be an error")
+	}
+	if _, _, err := (InventoryOverride{FloodproofElevation: &ffe}).ApplyDeterministic(s.SampleStructure(s.Seed)); err == nil {
+		t.Error("expected floodproofing a structure without a ground elevation to an elevation to be an error")
+	}
+	if _, _, err := (InventoryOverride{RaiseFoundationBy: 1}).Apply(s); err != nil {
+		t.Errorf("raising a structure without a ground elevation by a fixed amount failed: %v", err)
+	}
+}
+func TestInventoryOverride_Deterministic(t *testing.T) {
+	sd := overrideTestStructure().SampleStructure(1)
+	ffe, fpe := 14.0, 13.0
+	raised, ok, err := ApplyOverridesDeterministic([]InventoryOverride{{FirstFloorElevation: &ffe}, {FloodproofElevation: &fpe}}, sd)
+	if err != nil || !ok {
+		t.Fatalf("raising and floodproofing failed: %v %v", ok, err)
+	}
+	if raised.FoundHt != 4 || raised.FloodproofHeight != 3 {
+		t.Errorf("raised foundation height and floodproof height were %f and %f; expected 4 and 3", raised.FoundHt, raised.FloodproofHeight)
+	}
+	if _, ok, _ = ApplyOverridesDeterministic([]InventoryOverride{{Buyout: true}}, sd); ok {
+		t.Error("the buyout did not remove the structure")
+	}
+}
@@ -0,0 +1,83 @@
+package structures
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+// TotalLossAction is what happens to a structure once its cumulative damage reaches the total loss threshold.
+type TotalLossAction string
+
+const (
+	RebuildTotalLoss TotalLossAction = "rebuild" //the structure is rebuilt as it was
+	AbandonTotalLoss TotalLossAction = "abandon" //the structure is not rebuilt and takes no further damage
+	ElevateTotalLoss TotalLossAction = "elevate" //the structure is rebuilt with its first floor raised by ElevationHeight
+)
+
+// RebuildRules decide how a structure is rebuilt between the events of a multi hazard event, following the rebuild behavior of G2CRM described in notes.md.
+type RebuildRules struct {
+	RebuildFactor      float64         `json:"rebuild_factor"`             //fraction of the damage that is rebuilt, 1 rebuilds all of it
+	TotalLossThreshold float64         `json:"total_loss_threshold"`       //cumulative structure damage factor at or above which the structure is a total loss, zero never triggers
+	TotalLossAction    TotalLossAction `json:"total_loss_action"`          //what happens to a total loss
+	ElevationHeight    float64         `json:"elevation_height,omitempty"` //feet a total loss is raised when it is rebuilt with the elevate action
+}
+
+// DefaultRebuildRules rebuild all damage and never treat a structure as a total loss.
+func DefaultRebuildRules() RebuildRules {
+	return RebuildRules{RebuildFactor: 1, TotalLossAction: RebuildTotalLoss}
+}
+
+// UnmarshalJSON starts from DefaultRebuildRules so a configuration only needs to specify the rules it changes.
+func (r *RebuildRules) UnmarshalJSON(b []byte) error {
+	type rules RebuildRules
+	d := rules(DefaultRebuildRules())
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return err
+	}
+	*r = RebuildRules(d)
+	return nil
+}
+
+// Validate checks the rebuild factor, threshold and action.
+func (r RebuildRules) Validate() error {
+	if r.RebuildFactor <= 0 || r.RebuildFactor > 1 {
+		return errors.New("structures: rebuild_factor must be greater than 0 and at most 1")
+	}
+	if r.TotalLossThreshold < 0 || r.TotalLossThreshold > 1 {
+		return errors.New("structures: total_loss_threshold must be between 0 and 1")
+	}
+	switch r.TotalLossAction {
+	case RebuildTotalLoss, AbandonTotalLoss:
+	case ElevateTotalLoss:
+		if r.ElevationHeight <= 0 {
+			return errors.New("structures: the elevate total loss action requires a positive elevation_height")
+		}
+	default:
+		return fmt.Errorf("structures: unknown total_loss_action %v", r.TotalLossAction)
+	}
+	return nil
+}
+
+// totalLoss is true when a cumulative structure damage factor triggers the total loss action.
+func (r RebuildRules) totalLoss(damageFactor float64) bool {
+	return r.TotalLossThreshold > 0 && damageFactor >= r.TotalLossThreshold
+}
+
+// ComputeWithReconstruction computes consequences and the days needed to reconstruct the structure. A MultiHazardEvent is computed as a sequence of events, rebuilding between them according to rules.
+func (s StructureDeterministic) ComputeWithReconstruction(d hazards.HazardEvent, rules RebuildRules) (consequences.Result, error) {
+	addMulti, ok := d.(hazards.MultiHazardEvent)
+	if ok {
+		return computeConsequencesMultiHazard(addMulti, s, rules)
+	}
+	return computeConsequencesWithReconstruction(d, s)
+}
+
+// ComputeWithReconstruction samples the structure with its own Seed, or one derived from its fd_id, and computes it with reconstruction.
+func (s StructureStochastic) ComputeWithReconstruction(d hazards.HazardEvent, rules RebuildRules) (consequences.Result, error) {
+	return s.SampleStructure(s.SampleSeed()).ComputeWithReconstruction(d, rules)
+}
@@ -0,0 +1,156 @@
+package structures
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/go-statistics/paireddata"
+	"github.com/USACE/go-consequences/consequences"
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func reconstructionTestStructure() StructureDeterministic {
+	depthDamage := DamageFunction{DamageFunction: paireddata.PairedData{Xvals: []float64{1, 2, 3, 4}, Yvals: []float64{10, 20, 30, 40}}, DamageDriver: hazards.Depth}
+	rebuildDays := DamageFunction{DamageFunction: paireddata.PairedData{Xvals: []float64{0, 1}, Yvals: []float64{0, 100}}, DamageDriver: hazards.Depth}
+	family := func(df DamageFunction) DamageFunctionFamily {
+		return DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: df}}
+	}
+	components := map[string]DamageFunctionFamily{"structure": family(depthDamage), "contents": family(depthDamage), "reconstruction": family(rebuildDays)}
+	return StructureDeterministic{
+		OccType:       OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: components},
+		StructVal:     100,
+		ContVal:       50,
+		FoundHt:       1,
+		BaseStructure: BaseStructure{Name: "1", DamCat: "RES", GroundElevation: 10},
+	}
+}
+
+// yearlyEvents are events a year apart, so every event is fully rebuilt before the next.
+func yearlyEvents(depths ...float64) *hazards.ArrivalDepthandDurationEventMulti {
+	events := make([]hazards.ArrivalDepthandDurationEvent, len(depths))
+	for i, d := range depths {
+		events[i].SetDepth(d)
+		events[i].SetDuration(0)
+		events[i].SetArrivalTime(time.Date(2000+i, time.January, 1, 0, 0, 0, 0, time.UTC))
+	}
+	return &hazards.ArrivalDepthandDurationEventMulti{Events: events}
+}
+func fetchFloat(t *testing.T, r consequences.Result, header string) float64 {
+	v, err := r.Fetch(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.(float64)
+}
+func TestComputeWithReconstruction_Totals(t *testing.T) {
+	s := reconstructionTestStructure()
+	r, err := s.ComputeWithReconstruction(yearlyEvents(2, 3), DefaultRebuildRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	//depths of 1 and 2 above the first floor damage 10% and 20%.
+	if v := fetchFloat(t, r, "StructureTotalLoss"); math.Abs(v-30) > 1e-9 {
+		t.Errorf("structure total loss was %f; expected %f", v, 30.0)
+	}
+	if v := fetchFloat(t, r, "ContentsTotalLoss"); math.Abs(v-15) > 1e-9 {
+		t.Errorf("contents total loss was %f; expected %f", v, 15.0)
+	}
+	if v, _ := r.Fetch("times rebuilt"); v != int32(2) {
+		t.Errorf("rebuilt %v times; expected 2", v)
+	}
+	hr, _ := r.Fetch("hazard results")
+	first, _ := hr.(consequences.Result).Fetch("0")
+	if v := fetchFloat(t, first.(consequences.Result), "c_dam_per"); v != .1 {
+		t.Errorf("c_dam_per was %f; expected %f", v, .1)
+	}
+	if v := fetchFloat(t, first.(consequences.Result), "reconstruction_days"); v != 10 {
+		t.Errorf("reconstruction_days was %f; expected %f", v, 10.0)
+	}
+}
+func TestComputeWithReconstruction_PartialRebuild(t *testing.T) {
+	s := reconstructionTestStructure()
+	rules := DefaultRebuildRules()
+	rules.RebuildFactor = .5
+	r, err := s.ComputeWithReconstruction(yearlyEvents(2, 2), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//half of the first 10% is never rebuilt, so the second event damages 10% of 95.
+	hr, _ := r.Fetch("hazard results")
+	second, _ := hr.(consequences.Result).Fetch("1")
+	if v := fetchFloat(t, second.(consequences.Result), "structure damage"); math.Abs(v-9.5) > 1e-9 {
+		t.Errorf("second event structure damage was %f; expected %f", v, 9.5)
+	}
+}
+func TestComputeWithReconstruction_TotalLoss(t *testing.T) {
+	s := reconstructionTestStructure()
+	abandon := RebuildRules{RebuildFactor: 1, TotalLossThreshold: .3, TotalLossAction: AbandonTotalLoss}
+	r, err := s.ComputeWithReconstruction(yearlyEvents(2, 5, 5), abandon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Fetch("abandoned"); v != true {
+		t.Error("the structure was not abandoned after a total loss")
+	}
+	if v := fetchFloat(t, r, "StructureTotalLoss"); math.Abs(v-50) > 1e-9 {
+		t.Errorf("structure total loss was %f; expected %f after abandonment", v, 50.0)
+	}
+	elevate := RebuildRules{RebuildFactor: 1, TotalLossThreshold: .3, TotalLossAction: ElevateTotalLoss, ElevationHeight: 4}
+	r, err = s.ComputeWithReconstruction(yearlyEvents(5, 5), elevate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Fetch("times raised"); v != int32(1) {
+		t.Errorf("raised %v times; expected 1", v)
+	}
+	if v := fetchFloat(t, r, "final ffe"); v != 15 {
+		t.Errorf("final ffe was %f; expected %f", v, 15.0)
+	}
+	if v := fetchFloat(t, r, "original ffe"); v != 11 {
+		t.Errorf("original ffe was %f; expected %f", v, 11.0)
+	}
+	//raised 4 feet, the second event is 0 feet above the first floor.
+	if v := fetchFloat(t, r, "StructureTotalLoss"); math.Abs(v-40) > 1e-9 {
+		t.Errorf("structure total loss was %f; expected %f", v, 40.0)
+	}
+}
+func TestComputeWithReconstruction_RaisedOnce(t *testing.T) {
+	s := reconstructionTestStructure()
+	//a quarter of the first event's 40% is rebuilt each year, without a reset 30% would remain at the second event and raise the structure again.
+	elevate := RebuildRules{RebuildFactor: .25, TotalLossThreshold: .3, TotalLossAction: ElevateTotalLoss, ElevationHeight: 4}
+	r, err := s.ComputeWithReconstruction(yearlyEvents(5, 5, 5), elevate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Fetch("times raised"); v != int32(1) {
+		t.Errorf("raised %v times; expected 1", v)
+	}
+	if v, _ := r.Fetch("times rebuilt"); v != int32(1) {
+		t.Errorf("rebuilt %v times; expected 1", v)
+	}
+	if v := fetchFloat(t, r, "final ffe"); v != 15 {
+		t.Errorf("final ffe was %f; expected %f", v, 15.0)
+	}
+	if v := fetchFloat(t, r, "final structure value"); v != 100 {
+		t.Errorf("final structure value was %f; expected the undamaged elevated structure's %f", v, 100.0)
+	}
+	//the second event arrives 10 days into the first event's 20 day rebuild.
+	events := yearlyEvents(3, 3)
+	events.Events[1].SetArrivalTime(events.Events[0].ArrivalTime().AddDate(0, 0, 10))
+	r, err = s.ComputeWithReconstruction(events, DefaultRebuildRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Fetch("times rebuilt"); v != int32(1) {
+		t.Errorf("rebuilt %v times; expected an event during a rebuild to extend it", v)
+	}
+}
+func TestRebuildRules_Validate(t *testing.T) {
+	if DefaultRebuildRules().Validate() != nil {
+		t.Error("the default rebuild rules are not valid")
+	}
+	if (RebuildRules{RebuildFactor: 1, TotalLossAction: ElevateTotalLoss}).Validate() == nil {
+		t.Error("expected an error for elevation without a height")
+	}
+}
@@ -0,0 +1,31 @@
+package structures
+
+import "hash/fnv"
+
+// StructureSeed derives the seed for a structure's random stream from a master seed and the structure's fd_id. Because the seed depends only on those two values, a structure samples the same way regardless of the order structures are streamed in or which other structures are filtered out.
+func StructureSeed(masterSeed int64, fdid string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(fdid))
+	return mix(uint64(masterSeed) ^ h.Sum64())
+}
+
+// DeriveSeed derives an independent seed from a parent seed and a stream number, e.g. a realization index or a separate purpose such as lifeloss.
+func DeriveSeed(seed int64, stream int64) int64 {
+	return mix(uint64(seed) ^ uint64(mix(uint64(stream))))
+}
+
+// mix is the splitmix64 finalizer, it spreads nearby inputs across the full range of seeds.
+func mix(z uint64) int64 {
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// SampleSeed is the seed Compute samples the structure with: its Seed, or for a structure that was never given one, the seed StructureSeed derives from its fd_id under master seed 0, so unseeded structures do not all share one random stream.
+func (s StructureStochastic) SampleSeed() int64 {
+	if s.Seed != 0 {
+		return s.Seed
+	}
+	return StructureSeed(0, s.Name)
+}
@@ -0,0 +1,59 @@
+package structures
+
+import (
+	"testing"
+
+	"github.com/USACE/go-consequences/hazards"
+)
+
+func TestStructureSeed(t *testing.T) {
+	a := StructureSeed(1234, "100")
+	if a != StructureSeed(1234, "100") {
+		t.Error("StructureSeed is not deterministic")
+	}
+	if a == StructureSeed(1234, "101") {
+		t.Error("neighboring fd_ids produced the same seed")
+	}
+	if a == StructureSeed(1235, "100") {
+		t.Error("neighboring master seeds produced the same seed")
+	}
+	if DeriveSeed(a, 0) == DeriveSeed(a, 1) {
+		t.Error("neighboring streams produced the same seed")
+	}
+}
+func TestStructureStochasticCompute_Reproducible(t *testing.T) {
+	otp := JsonOccupancyTypeProvider{}
+	otp.InitDefault()
+	s := StructureStochastic{
+		OccType:        otp.OccupancyTypeMap()["RES1-1SNB"],
+		UseUncertainty: true,
+		BaseStructure:  BaseStructure{Name: "100", DamCat: "RES"},
+		Seed:           StructureSeed(1234, "100"),
+	}
+	s.StructVal.Value = 100000.0
+	s.ContVal.Value = 50000.0
+	s.FoundHt.Value = 1.0
+	d := hazards.DepthEvent{}
+	d.SetDepth(4)
+	r1, err := s.Compute(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, _ := s.Compute(d)
+	sd1, _ := r1.Fetch("structure damage")
+	sd2, _ := r2.Fetch("structure damage")
+	if sd1 != sd2 {
+		t.Errorf("computing the same structure twice gave %v and %v", sd1, sd2)
+	}
+}
+func TestStructureStochasticSeed_Unseeded(t *testing.T) {
+	a, b := StructureStochastic{}, StructureStochastic{}
+	a.Name, b.Name = "100", "101"
+	if a.SampleSeed() == b.SampleSeed() || a.SampleSeed() != StructureSeed(0, "100") {
+		t.Errorf("expected unseeded structures to derive distinct seeds from their fd_ids, got %v and %v", a.SampleSeed(), b.SampleSeed())
+	}
+	a.Seed = 42
+	if a.SampleSeed() != 42 {
+		t.Errorf("expected a seeded structure to keep its seed, got %v", a.SampleSeed())
+	}
+}
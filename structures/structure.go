@@ -19,6 +19,9 @@ type BaseStructure struct {
 	DamCat                string
 	CBFips                string
 	X, Y, GroundElevation float64
+	HasGroundElevation    bool                 //the inventory provided GroundElevation, so it can be used for depth above water surface elevations
+	SRID                  string               //spatial reference system of X and Y, stamped by the structure provider
+	Footprint             []geography.Location //exterior ring of the building footprint in the reference system of X and Y, empty if the inventory has points
 }
 type PopulationSet struct {
 	Pop2pmo65, Pop2pmu65, Pop2amo65, Pop2amu65 int32
@@ -33,6 +36,8 @@ type StructureStochastic struct {
 	StructVal, ContVal, FoundHt           consequences.ParameterValue
 	NumStories                            int32
 	PopulationSet
+	Seed             int64   //seed for this structure's random stream, see StructureSeed
+	FloodproofHeight float64 //depth above ground the structure is floodproofed to, zero if it is not floodproofed
 }
 
 func (f *StructureStochastic) ApplyFoundationHeightUncertanty(fu *FoundationUncertainty) {
@@ -79,11 +84,13 @@ type StructureDeterministic struct {
 	StructVal, ContVal, FoundHt           float64
 	NumStories                            int32
 	PopulationSet
+	FloodproofHeight float64          //depth above ground the structure is floodproofed to, water at or below it causes no damage unless the floodproofing fails, see MitigationRecord.FloodproofFailure
+	Mitigation       MitigationRecord //measures applied with ApplyMitigation
 }
 
 // GetX implements consequences.Locatable
 func (s BaseStructure) Location() geography.Location {
-	return geography.Location{X: s.X, Y: s.Y}
+	return geography.Location{X: s.X, Y: s.Y, SRID: s.SRID, Footprint: s.Footprint, Z: s.GroundElevation, HasZ: s.HasGroundElevation}
 }
 
 // SampleStructure converts a structureStochastic into a structure deterministic based on an input seed
@@ -116,21 +123,22 @@ func (s StructureStochastic) SampleStructure(seed int64) StructureDeterministic
 		ConstructionType: s.ConstructionType,
 		FirmZone:         s.FirmZone,
 		FoundHt:          fh,
+		FloodproofHeight: s.FloodproofHeight,
 		PopulationSet:    PopulationSet{s.Pop2amo65, s.Pop2pmu65, s.Pop2amo65, s.Pop2amu65},
 		NumStories:       s.NumStories,
-		BaseStructure:    BaseStructure{Name: s.Name, CBFips: s.CBFips, X: s.X, Y: s.Y, DamCat: s.DamCat, GroundElevation: s.GroundElevation}}
+		BaseStructure:    BaseStructure{Name: s.Name, CBFips: s.CBFips, X: s.X, Y: s.Y, DamCat: s.DamCat, GroundElevation: s.GroundElevation, HasGroundElevation: s.HasGroundElevation, SRID: s.SRID, Footprint: s.Footprint}}
 }
 
-// Compute implements the consequences.Receptor interface on StrucutreStochastic
+// Compute implements the consequences.Receptor interface on StrucutreStochastic, the structure is sampled with its own Seed, or one derived from its fd_id if it has none, so repeated computes are reproducible.
 func (s StructureStochastic) Compute(d hazards.HazardEvent) (consequences.Result, error) {
-	return s.SampleStructure(rand.Int63()).Compute(d) //this needs work so seeds can be controlled.
+	return s.SampleStructure(s.SampleSeed()).Compute(d)
 }
 
 // Compute implements the consequences.Receptor interface on StrucutreDeterminstic
 func (s StructureDeterministic) Compute(d hazards.HazardEvent) (consequences.Result, error) {
 	addMulti, ok := d.(hazards.MultiHazardEvent)
 	if ok {
-		return computeConsequencesMultiHazard(addMulti, s)
+		return computeConsequencesMultiHazard(addMulti, s, DefaultRebuildRules())
 	}
 	return computeConsequences(d, s)
 }
@@ -145,9 +153,84 @@ func (s StructureDeterministic) Clone() StructureDeterministic {
 		ConstructionType: s.ConstructionType,
 		FirmZone:         s.FirmZone,
 		FoundHt:          s.FoundHt,
+		FloodproofHeight: s.FloodproofHeight,
+		Mitigation:       s.Mitigation,
 		PopulationSet:    PopulationSet{s.Pop2amo65, s.Pop2pmu65, s.Pop2amo65, s.Pop2amu65},
 		NumStories:       s.NumStories,
-		BaseStructure:    BaseStructure{Name: s.Name, CBFips: s.CBFips, X: s.X, Y: s.Y, DamCat: s.DamCat, GroundElevation: s.GroundElevation}}
+		BaseStructure:    BaseStructure{Name: s.Name, CBFips: s.CBFips, X: s.X, Y: s.Y, DamCat: s.DamCat, GroundElevation: s.GroundElevation, HasGroundElevation: s.HasGroundElevation, SRID: s.SRID, Footprint: s.Footprint}}
+}
+
+// floodproofFactor weights damage from water at depth by dry floodproofing, one if the water is above the floodproofing or there is none, otherwise the probability the floodproofing fails.
+func (s StructureDeterministic) floodproofFactor(depth float64) float64 {
+	if s.FloodproofHeight > 0 && depth <= s.FloodproofHeight {
+		return s.Mitigation.FloodproofFailure
+	}
+	return 1
+}
+
+// driverValue is the value of the hazard a damage function that is not driven by depth is sampled at, depth times velocity is computed if the event does not provide it. It is an error if the driver is not a single hazard parameter damage can be sampled at.
+func driverValue(e hazards.HazardEvent, driver hazards.Parameter) (float64, error) {
+	switch driver {
+	case hazards.Erosion:
+		return e.Erosion(), nil
+	case hazards.WaveHeight:
+		return e.WaveHeight(), nil
+	case hazards.Velocity:
+		return e.Velocity(), nil
+	case hazards.DV:
+		if e.Has(hazards.DV) {
+			return e.DV(), nil
+		}
+		return e.Depth() * e.Velocity(), nil
+	default:
+		return 0, fmt.Errorf("structures: could not understand the damage driver %v", driver)
+	}
+}
+
+// hasDriver is true if the event has every parameter of a damage driver, depth times velocity can also come from depth and velocity.
+func hasDriver(e hazards.HazardEvent, driver hazards.Parameter) bool {
+	if driver == hazards.DV && e.Has(hazards.Depth) && e.Has(hazards.Velocity) {
+		return true
+	}
+	return driver != hazards.Default && e.Parameters()&driver == driver
+}
+
+// sampleDriver is the percent damage of a function sampled at its own damage driver, adjusted for duration. Depth and a depth velocity surface are sampled at the depth above the first floor.
+func (df DamageFunction) sampleDriver(e hazards.HazardEvent, foundHt float64) (float64, error) {
+	switch {
+	case df.DamageDriver == hazards.Depth:
+		return df.durationAdjusted(df.DamageFunction.SampleValue(e.Depth()-foundHt), e), nil
+	case df.DamageDriver == hazards.Depth|hazards.Velocity && df.DepthVelocity != nil:
+		return df.durationAdjusted(df.DepthVelocity.SampleValue(e.Depth()-foundHt, e.Velocity()), e), nil
+	}
+	v, err := driverValue(e, df.DamageDriver)
+	if err != nil {
+		return 0, err
+	}
+	return df.durationAdjusted(df.DamageFunction.SampleValue(v), e), nil
+}
+
+// damagePercents are the structure and content damage of e as fractions of their values, each sampled at the driver of its own damage function. Depth driven damage is weighted by dry floodproofing, and depth driven content damage is reduced by wet floodproofing.
+func (s StructureDeterministic) damagePercents(sDamFun DamageFunction, cDamFun DamageFunction, e hazards.HazardEvent) (float64, float64, error) {
+	percent := func(df DamageFunction) (float64, error) {
+		v, err := df.sampleDriver(e, s.FoundHt)
+		if df.DamageDriver == hazards.Depth {
+			v *= s.floodproofFactor(e.Depth())
+		}
+		return v / 100, err //assumes what type the damage array is in
+	}
+	sdampercent, err := percent(sDamFun)
+	if err != nil {
+		return 0, 0, err
+	}
+	cdampercent, err := percent(cDamFun)
+	if err != nil {
+		return 0, 0, err
+	}
+	if cDamFun.DamageDriver == hazards.Depth && s.wetFloodproofed(e.Depth()) {
+		cdampercent *= 1 - s.Mitigation.ContentDamageReduction
+	}
+	return sdampercent, cdampercent, nil
 }
 
 func computeConsequences(e hazards.HazardEvent, s StructureDeterministic) (consequences.Result, error) {
@@ -177,20 +260,11 @@ func computeConsequences(e hazards.HazardEvent, s StructureDeterministic) (conse
 			conval *= modifier
 		}
 	} //else dont modify value because damage is not driven by depth
-	if e.Has(sDamFun.DamageDriver) && e.Has(cDamFun.DamageDriver) {
+	if hasDriver(e, sDamFun.DamageDriver) && hasDriver(e, cDamFun.DamageDriver) {
 		//they exist!
-		sdampercent := 0.0
-		cdampercent := 0.0
-		switch sDamFun.DamageDriver {
-		case hazards.Depth:
-			depthAboveFFE := e.Depth() - s.FoundHt
-			sdampercent = sDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100 //assumes what type the damage array is in
-			cdampercent = cDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100
-		case hazards.Erosion:
-			sdampercent = sDamFun.DamageFunction.SampleValue(e.Erosion()) / 100 //assumes what type the damage array is in
-			cdampercent = cDamFun.DamageFunction.SampleValue(e.Erosion()) / 100
-		default:
-			return consequences.Result{}, errors.New("structures: could not understand the damage driver")
+		sdampercent, cdampercent, derr := s.damagePercents(sDamFun, cDamFun, e)
+		if derr != nil {
+			return consequences.Result{}, derr
 		}
 
 		ret.Result[0] = s.BaseStructure.Name
@@ -251,7 +325,7 @@ func computeConsequencesWithReconstruction(e hazards.HazardEvent, s StructureDet
 
 	rDamFun, rderr := s.OccType.GetComponentDamageFunctionForHazard("reconstruction", e)
 	if rderr != nil {
-		return ret, cderr
+		return ret, rderr
 	}
 
 	//TODO: Do we want to return the date that construction will be complete? Only useful if event has arrival time
@@ -266,28 +340,15 @@ func computeConsequencesWithReconstruction(e hazards.HazardEvent, s StructureDet
 			conval *= modifier
 		}
 	} //else dont modify value because damage is not driven by depth
-	if e.Has(sDamFun.DamageDriver) && e.Has(cDamFun.DamageDriver) && e.Has(rDamFun.DamageDriver) {
+	if hasDriver(e, sDamFun.DamageDriver) && hasDriver(e, cDamFun.DamageDriver) && hasDriver(e, rDamFun.DamageDriver) {
 		//they exist!
-		sdampercent := 0.0
-		cdampercent := 0.0
-		reconstruction_days := 0.0
-
-		switch sDamFun.DamageDriver {
-		case hazards.Depth:
-			depthAboveFFE := e.Depth() - s.FoundHt
-			sdampercent = sDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100 //assumes what type the damage array is in
-			cdampercent = cDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100
-			duration := 0.0
-			if e.Duration() > 0.0 { // nodata value for e.Duration == -901.0
-				duration = e.Duration()
-			}
-			reconstruction_days = rDamFun.DamageFunction.SampleValue(sdampercent) + duration
-		case hazards.Erosion:
-			sdampercent = sDamFun.DamageFunction.SampleValue(e.Erosion()) / 100 //assumes what type the damage array is in
-			cdampercent = cDamFun.DamageFunction.SampleValue(e.Erosion()) / 100
-			reconstruction_days = rDamFun.DamageFunction.SampleValue(sdampercent)
-		default:
-			return consequences.Result{}, errors.New("structures: could not understand the damage driver")
+		sdampercent, cdampercent, derr := s.damagePercents(sDamFun, cDamFun, e)
+		if derr != nil {
+			return consequences.Result{}, derr
+		}
+		reconstruction_days := rDamFun.DamageFunction.SampleValue(sdampercent)
+		if sDamFun.DamageDriver == hazards.Depth && e.Duration() > 0.0 { // nodata value for e.Duration == -901.0
+			reconstruction_days += e.Duration()
 		}
 
 		ret.Result[0] = s.BaseStructure.Name
@@ -395,60 +456,34 @@ func computeConsequencesMulti(events []hazards.HazardEvent, s StructureDetermini
 		values := []interface{}{"updateme", 0.0, 0.0, 0.0, 0.0, 0.0, time.Time{}, 0.0, 0.0}
 		result := consequences.Result{Headers: header, Result: values}
 
-		if e.Has(sDamFun.DamageDriver) && e.Has(cDamFun.DamageDriver) && e.Has(rDamFun.DamageDriver) {
+		if hasDriver(e, sDamFun.DamageDriver) && hasDriver(e, cDamFun.DamageDriver) && hasDriver(e, rDamFun.DamageDriver) {
 			//they exist!
-			sdampercent := 0.0
-			sdamage := 0.0
-			cdampercent := 0.0
-			cdamage := 0.0
-
-			reconstruction_days := 0.0
-			completion_date := time.Time{}
-
-			switch sDamFun.DamageDriver {
-			case hazards.Depth:
-				depthAboveFFE := e.Depth() - s.FoundHt
-				sdampercent = sDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100 //assumes what type the damage array is in
-				cdampercent = cDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100
-				sdamage = svalcurr * sdampercent
-				cdamage = convalcurr * cdampercent
-
-				sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
-				cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
-				// total time to complete reconstruction consists of three parts
-				//	1. Time between the start and end of the event. This is e.Duration()
-				//	2. Time between the end of the event and the beginning of reconstruction.
-				//		- In reality, this would depend on a lot but simplest assumption is that reconstruction can begin as soon as event ends.
-				//	3. Time between reconstruction start and reconstruction end. This is the value returned from the damage function
-
-				arrival := e.ArrivalTime() // do we need a check that the ArrivalTime is not just the default time.Time{}?
-
-				duration := 0.0
-				if e.Duration() > 0.0 { // nodata value for e.Duration == -901.0
-					duration = e.Duration()
-				}
+			sdampercent, cdampercent, derr := s.damagePercents(sDamFun, cDamFun, e)
+			if derr != nil {
+				return ret, derr
+			}
+			sdamage := svalcurr * sdampercent
+			cdamage := convalcurr * cdampercent
+
+			sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
+			cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
+			// total time to complete reconstruction consists of three parts
+			//	1. Time between the start and end of the event. This is e.Duration(), only depth driven damage waits for the water to recede
+			//	2. Time between the end of the event and the beginning of reconstruction.
+			//		- In reality, this would depend on a lot but simplest assumption is that reconstruction can begin as soon as event ends.
+			//	3. Time between reconstruction start and reconstruction end. This is the value returned from the damage function
+
+			arrival := e.ArrivalTime() // do we need a check that the ArrivalTime is not just the default time.Time{}?
 
-				// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
-				reconstruction_days = math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor) + duration)
-				completion_date = arrival.AddDate(0, 0, int(reconstruction_days))
-
-			case hazards.Erosion:
-				sdampercent = sDamFun.DamageFunction.SampleValue(e.Erosion()) / 100 //assumes what type the damage array is in
-				cdampercent = cDamFun.DamageFunction.SampleValue(e.Erosion()) / 100
-				sdamage = svalcurr * sdampercent
-				cdamage = convalcurr * cdampercent
-
-				sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
-				cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
-				arrival := e.ArrivalTime()
-				// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
-				reconstruction_days = math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor))
-				completion_date = arrival.AddDate(0, 0, int(reconstruction_days))
-
-			default:
-				return ret, errors.New("structures: could not understand the damage driver")
+			duration := 0.0
+			if sDamFun.DamageDriver == hazards.Depth && e.Duration() > 0.0 { // nodata value for e.Duration == -901.0
+				duration = e.Duration()
 			}
 
+			// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
+			reconstruction_days := math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor) + duration)
+			completion_date := arrival.AddDate(0, 0, int(reconstruction_days))
+
 			svalcurr = svalcurr * (1 - sDamageFactor)
 			convalcurr = convalcurr * (1 - cDamageFactor)
 
@@ -470,13 +505,14 @@ func computeConsequencesMulti(events []hazards.HazardEvent, s StructureDetermini
 	return ret, err
 }
 
-func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureDeterministic) (consequences.Result, error) {
+// computeConsequencesMultiHazard computes a sequence of events. Between events the structure is rebuilt linearly from the end of the previous event to its completion date, and only the rebuild factor of the damage is rebuilt. A structure whose cumulative damage reaches the total loss threshold is rebuilt, abandoned or rebuilt elevated according to rules. Times rebuilt counts the damaging events that start a rebuild, an event that arrives before the previous rebuild is complete extends it instead. The structure and content total losses are the damages summed over every event.
+func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureDeterministic, rules RebuildRules) (consequences.Result, error) {
 
 	mainHeader := []string{
 		"fd_id", "x", "y", "damage category", "occupancy type",
 		"pop2amu65", "pop2amo65", "pop2pmu65", "pop2pmo65", "cbfips",
 		"original structure value", "original content value", "final structure value", "final content value", //
-		"original ffe", "final ffe", "times rebuilt", "times raised", "StructureTotalLoss", "ContentsTotalLoss",
+		"original ffe", "final ffe", "times rebuilt", "times raised", "StructureTotalLoss", "ContentsTotalLoss", "abandoned",
 		"hazard results",
 	}
 	subResultsHeader := make([]string, 0)
@@ -486,7 +522,7 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 		"updateme", 0.0, 0.0, "damcat", "occtype",
 		0.0, 0.0, 0.0, 0.0, "cbfips",
 		0.0, 0.0, 0.0, 0.0,
-		0.0, 0.0, 0.0, 0.0, 0.0, 0.0,
+		0.0, 0.0, int32(0), int32(0), 0.0, 0.0, false,
 		subResult,
 	}
 	var ret = consequences.Result{Headers: mainHeader, Result: mainResults}
@@ -503,7 +539,7 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 	}
 	rDamFun, rderr := s.OccType.GetComponentDamageFunctionForHazard("reconstruction", event)
 	if rderr != nil {
-		return ret, cderr
+		return ret, rderr
 	}
 
 	// variables for tracking value and damage across hazards
@@ -513,6 +549,12 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 	conval := s.ContVal
 	convalcurr := conval
 	cDamageFactor := 0.0 // this is the current pct_damage to the contents
+	originalFFE := s.originalFFE()
+	timesRebuilt := int32(0)
+	rebuildingUntil := time.Time{} // completion date of the rebuild in progress
+	structureTotalLoss := 0.0
+	contentsTotalLoss := 0.0
+	abandoned := false
 
 	// adjust value for tall structures
 	if sDamFun.DamageDriver == hazards.Depth {
@@ -528,8 +570,8 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 	} //else dont modify value because damage is not driven by depth
 
 	for {
-		// Calculate reconstruction from previous hazard if we aren't on the first one
-		if event.HasPrevious() {
+		// Calculate reconstruction from previous hazard if we aren't on the first one, an abandoned structure is not rebuilt
+		if event.HasPrevious() && !abandoned {
 
 			pr, err := subResult.Fetch(fmt.Sprintf("%d", event.Index()-1))
 			// pr, err := ret.Fetch(fmt.Sprintf("%d", event.Index()-1))
@@ -556,8 +598,9 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 				pct_complete = 1.0
 			}
 
-			sPctloss_rebuilt := sDamageFactor * pct_complete
-			cPctloss_rebuilt := cDamageFactor * pct_complete
+			// only the rebuild factor of the damage is ever rebuilt
+			sPctloss_rebuilt := sDamageFactor * pct_complete * rules.RebuildFactor
+			cPctloss_rebuilt := cDamageFactor * pct_complete * rules.RebuildFactor
 
 			// update structure damage factor to reflect completed construction
 			sDamageFactor = sDamageFactor - sPctloss_rebuilt
@@ -579,67 +622,72 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 		values := []interface{}{event.This(), 0.0, 0.0, 0.0, 0.0, 0.0, time.Time{}, 0.0, 0.0}
 		result := consequences.Result{Headers: header, Result: values}
 
-		if event.Has(sDamFun.DamageDriver) && event.Has(cDamFun.DamageDriver) && event.Has(rDamFun.DamageDriver) {
+		if abandoned {
+			// an abandoned structure takes no further damage
+		} else if hasDriver(event, sDamFun.DamageDriver) && hasDriver(event, cDamFun.DamageDriver) && hasDriver(event, rDamFun.DamageDriver) {
 			//they exist!
-			sdampercent := 0.0
-			sdamage := 0.0
-			cdampercent := 0.0
-			cdamage := 0.0
-
-			reconstruction_days := 0.0
-			completion_date := time.Time{}
-
-			switch sDamFun.DamageDriver {
-			case hazards.Depth:
-				depthAboveFFE := event.Depth() - s.FoundHt
-				sdampercent = sDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100 //assumes what type the damage array is in
-				cdampercent = cDamFun.DamageFunction.SampleValue(depthAboveFFE) / 100
-				sdamage = svalcurr * sdampercent
-				cdamage = convalcurr * cdampercent
-
-				sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
-				cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
-				// total time to complete reconstruction consists of three parts
-				//	1. Time between the start and end of the event. This is e.Duration()
-				//	2. Time between the end of the event and the beginning of reconstruction.
-				//		- In reality, this would depend on a lot but simplest assumption is that reconstruction can begin as soon as event ends.
-				//	3. Time between reconstruction start and reconstruction end. This is the value returned from the damage function
-
-				arrival := event.ArrivalTime() // do we need a check that the ArrivalTime is not just the default time.Time{}?
-
-				duration := 0.0
-				if event.Duration() > 0.0 { // nodata value for e.Duration == -901.0
-					duration = event.Duration()
-				}
+			sdampercent, cdampercent, derr := s.damagePercents(sDamFun, cDamFun, event)
+			if derr != nil {
+				return ret, derr
+			}
+			priorDamageFactor := sDamageFactor
+			sdamage := svalcurr * sdampercent
+			cdamage := convalcurr * cdampercent
+
+			sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
+			cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
+			// total time to complete reconstruction consists of three parts
+			//	1. Time between the start and end of the event. This is e.Duration(), only depth driven damage waits for the water to recede
+			//	2. Time between the end of the event and the beginning of reconstruction.
+			//		- In reality, this would depend on a lot but simplest assumption is that reconstruction can begin as soon as event ends.
+			//	3. Time between reconstruction start and reconstruction end. This is the value returned from the damage function
 
-				// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
-				reconstruction_days = math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor) + duration)
-				completion_date = arrival.AddDate(0, 0, int(reconstruction_days))
-
-			case hazards.Erosion:
-				sdampercent = sDamFun.DamageFunction.SampleValue(event.Erosion()) / 100 //assumes what type the damage array is in
-				cdampercent = cDamFun.DamageFunction.SampleValue(event.Erosion()) / 100
-				sdamage = svalcurr * sdampercent
-				cdamage = convalcurr * cdampercent
-
-				sDamageFactor = 1 - (1-sDamageFactor)*(1-sdampercent)
-				cDamageFactor = 1 - (1-cDamageFactor)*(1-cdampercent)
-				arrival := event.ArrivalTime()
-				// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
-				reconstruction_days = math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor))
-				completion_date = arrival.AddDate(0, 0, int(reconstruction_days))
-
-			default:
-				return ret, errors.New("structures: could not understand the damage driver")
+			arrival := event.ArrivalTime() // do we need a check that the ArrivalTime is not just the default time.Time{}?
+
+			duration := 0.0
+			if sDamFun.DamageDriver == hazards.Depth && event.Duration() > 0.0 { // nodata value for e.Duration == -901.0
+				duration = event.Duration()
 			}
 
-			svalcurr = svalcurr * (1 - sDamageFactor)
-			convalcurr = convalcurr * (1 - cDamageFactor)
+			// calculate reconstruction_days based on damageFactor to account for potential remaining damage from previous events
+			reconstruction_days := math.Ceil(rDamFun.DamageFunction.SampleValue(sDamageFactor) + duration)
+			completion_date := arrival.AddDate(0, 0, int(reconstruction_days))
+
+			structureTotalLoss += sdamage
+			contentsTotalLoss += cdamage
+			// only the event that makes the structure a total loss triggers the action, not later events while it is still being rebuilt
+			if rules.totalLoss(sDamageFactor) && !rules.totalLoss(priorDamageFactor) {
+				switch rules.TotalLossAction {
+				case AbandonTotalLoss:
+					abandoned = true
+					reconstruction_days = 0
+					completion_date = time.Time{}
+				case ElevateTotalLoss:
+					s, _ = Elevation{TargetElevation: s.GroundElevation + s.FoundHt + rules.ElevationHeight}.Apply(s, 0)
+					// the elevated structure is new construction, none of the damage remains
+					sDamageFactor = 0
+					cDamageFactor = 0
+				}
+			}
+			if !abandoned && (sdamage > 0 || cdamage > 0) {
+				// an event during an unfinished rebuild extends it rather than starting another
+				if !event.ArrivalTime().Before(rebuildingUntil) {
+					timesRebuilt++
+				}
+				rebuildingUntil = completion_date
+			}
+
+			svalcurr = sval * (1 - sDamageFactor)
+			convalcurr = conval * (1 - cDamageFactor)
+			if abandoned {
+				svalcurr = 0
+				convalcurr = 0
+			}
 
 			result.Result[1] = sdamage
 			result.Result[2] = cdamage
 			result.Result[3] = sdampercent
-			result.Result[3] = cdampercent
+			result.Result[4] = cdampercent
 			result.Result[5] = math.Ceil(reconstruction_days)
 			result.Result[6] = completion_date
 			result.Result[7] = svalcurr
@@ -665,7 +713,14 @@ func computeConsequencesMultiHazard(event hazards.MultiHazardEvent, s StructureD
 		ret.Result[11] = conval
 		ret.Result[12] = svalcurr
 		ret.Result[13] = convalcurr
-		ret.Result[20] = subResult
+		ret.Result[14] = originalFFE
+		ret.Result[15] = s.GroundElevation + s.FoundHt
+		ret.Result[16] = timesRebuilt
+		ret.Result[17] = s.Mitigation.TimesRaised
+		ret.Result[18] = structureTotalLoss
+		ret.Result[19] = contentsTotalLoss
+		ret.Result[20] = abandoned
+		ret.Result[21] = subResult
 
 		if event.HasNext() {
 			event.Increment() // go to the next event and restart loop
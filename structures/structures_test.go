@@ -185,6 +185,65 @@ func TestComputeConsequences_erosion(t *testing.T) {
 	}
 }
 
+func TestComputeConsequences_waveHeight(t *testing.T) {
+	//depth damage by default and wave height damage for waves with salt water.
+	depth := DamageFunction{Source: "fabricated", DamageDriver: hazards.Depth, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 10}, Yvals: []float64{0, 10}}}
+	wave := DamageFunction{Source: "fabricated", DamageDriver: hazards.WaveHeight, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 4}, Yvals: []float64{0, 80}}}
+	family := DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]DamageFunction{
+		hazards.Default: depth,
+		hazards.Depth | hazards.WaveHeight | hazards.MediumWaveHeight | hazards.Salinity: wave,
+	}}
+	o := OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: map[string]DamageFunctionFamily{"structure": family, "contents": family}}
+	s := StructureDeterministic{OccType: o, StructVal: 100.0, ContVal: 100.0, BaseStructure: BaseStructure{DamCat: "category"}}
+	for _, c := range []struct {
+		hd       hazards.HazardData
+		expected float64
+	}{
+		//the velocity has no damage function of its own, so the wave function for the rest of the parameters is used.
+		{hazards.HazardData{Depth: 2, Velocity: 1, Erosion: -901, Duration: -901, WaveHeight: 2, Salinity: true, DV: -901}, 40},
+		{hazards.HazardData{Depth: 2, Velocity: -901, Erosion: -901, Duration: -901, WaveHeight: -901, DV: -901}, 2},
+	} {
+		r, err := s.Compute(hazards.HazardDataToMultiParameter(c.hd))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dr, err := r.Fetch("structure damage")
+		if err != nil || dr.(float64) != c.expected {
+			t.Errorf("expected %v structure damage for %+v, got %v %v", c.expected, c.hd, dr, err)
+		}
+	}
+}
+
+func TestComputeConsequences_mixedDrivers(t *testing.T) {
+	//structure damage from wave height and content damage from depth above the first floor for the same event.
+	depth := DamageFunction{Source: "fabricated", DamageDriver: hazards.Depth, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 10}, Yvals: []float64{0, 10}}}
+	wave := DamageFunction{Source: "fabricated", DamageDriver: hazards.WaveHeight, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 4}, Yvals: []float64{0, 80}}}
+	coastal := hazards.Depth | hazards.WaveHeight | hazards.MediumWaveHeight | hazards.Salinity
+	structure := DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: depth, coastal: wave}}
+	contents := DamageFunctionFamily{DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: depth, coastal: depth}}
+	o := OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: map[string]DamageFunctionFamily{"structure": structure, "contents": contents}}
+	s := StructureDeterministic{OccType: o, StructVal: 100.0, ContVal: 100.0, FoundHt: 1, BaseStructure: BaseStructure{DamCat: "category"}}
+	e := hazards.HazardDataToMultiParameter(hazards.HazardData{Depth: 3, Velocity: -901, Erosion: -901, Duration: -901, WaveHeight: 2, Salinity: true, DV: -901})
+	r, err := s.Compute(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := r.Fetch("structure damage")
+	if err != nil || sd.(float64) != 40 {
+		t.Errorf("expected 40 structure damage from the wave height, got %v %v", sd, err)
+	}
+	cd, err := r.Fetch("content damage")
+	if err != nil || cd.(float64) != 2 {
+		t.Errorf("expected 2 content damage from the depth above the first floor, got %v %v", cd, err)
+	}
+	//a driver damage cannot be sampled at is an error rather than erosion damage.
+	salinity := DamageFunction{Source: "fabricated", DamageDriver: hazards.Salinity, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 1}, Yvals: []float64{0, 100}}}
+	contents.DamageFunctions[coastal] = salinity
+	if _, err = s.Compute(e); err == nil {
+		t.Error("expected a salinity damage driver to be an error")
+	}
+}
+
 func TestComputeConsequencesWithReconstruction(t *testing.T) {
 
 	//build a basic structure with a defined depth damage relationship.
@@ -373,6 +432,32 @@ func TestComputeConsequencesMulti(t *testing.T) {
 
 }
 
+func TestComputeConsequencesMulti_mixedDrivers(t *testing.T) {
+	//structure damage from depth above the first floor and content damage from velocity for the same event.
+	depth := DamageFunction{Source: "fabricated", DamageDriver: hazards.Depth, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 10}, Yvals: []float64{0, 100}}}
+	velocity := DamageFunction{Source: "fabricated", DamageDriver: hazards.Velocity, DamageFunction: paireddata.PairedData{Xvals: []float64{0, 10}, Yvals: []float64{0, 25}}}
+	components := map[string]DamageFunctionFamily{
+		"structure":      {DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: depth}},
+		"contents":       {DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: velocity}},
+		"reconstruction": {DamageFunctions: map[hazards.Parameter]DamageFunction{hazards.Default: depth}},
+	}
+	o := OccupancyTypeDeterministic{Name: "test", ComponentDamageFunctions: components}
+	s := StructureDeterministic{OccType: o, StructVal: 100.0, ContVal: 100.0, FoundHt: 1, BaseStructure: BaseStructure{DamCat: "category"}}
+	e := hazards.HazardDataToMultiParameter(hazards.HazardData{Depth: 3, Velocity: 4, ArrivalTime: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), Erosion: -901, Duration: -901, WaveHeight: -901, DV: -901})
+	results, err := computeConsequencesMulti([]hazards.HazardEvent{e}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := results[0].Fetch("structure damage")
+	if err != nil || sd.(float64) != 20 {
+		t.Errorf("expected 20 structure damage from the depth above the first floor, got %v %v", sd, err)
+	}
+	cd, err := results[0].Fetch("content damage")
+	if err != nil || cd.(float64) != 10 {
+		t.Errorf("expected 10 content damage from the velocity, got %v %v", cd, err)
+	}
+}
+
 func TestComputeConsequencesMultiHazard(t *testing.T) {
 	//build a basic structure with a defined depth damage relationship.
 	x := []float64{1.0, 2.0, 3.0, 4.0}
@@ -0,0 +1,61 @@
+// Package vectorlayer opens ogr vector layers and reads the points and polygon rings of their features, for the providers that read hazards or filter polygons from vector files.
+package vectorlayer
+
+import (
+	"errors"
+
+	"github.com/dewberry/gdal"
+)
+
+// Open opens the layer named layerName in the file at path with an ogr driver, or the first layer if layerName is not set. The data source must be destroyed once the layer is read.
+func Open(driver string, path string, layerName string) (gdal.DataSource, gdal.Layer, error) {
+	ds, ok := gdal.OGRDriverByName(driver).Open(path, int(gdal.ReadOnly))
+	if !ok {
+		return ds, gdal.Layer{}, errors.New("vectorlayer: error opening " + path + " with driver " + driver)
+	}
+	if ds.LayerCount() == 0 {
+		ds.Destroy()
+		return ds, gdal.Layer{}, errors.New("vectorlayer: " + path + " has no layers")
+	}
+	if layerName == "" {
+		return ds, ds.LayerByIndex(0), nil
+	}
+	for i := 0; i < ds.LayerCount(); i++ {
+		if ds.LayerByIndex(i).Name() == layerName {
+			return ds, ds.LayerByIndex(i), nil
+		}
+	}
+	ds.Destroy()
+	return ds, gdal.Layer{}, errors.New("vectorlayer: " + path + " does not have a layer titled " + layerName)
+}
+
+// SpatialReference is the wkt of a layer's reference system, empty if it has none.
+func SpatialReference(l gdal.Layer) string {
+	srid, err := l.SpatialReference().ToWKT()
+	if err != nil {
+		return ""
+	}
+	return srid
+}
+
+// ReadGeometry appends the points and polygon rings of a geometry and its parts, other geometry types are skipped.
+func ReadGeometry(g gdal.Geometry, points [][2]float64, rings [][][2]float64) ([][2]float64, [][][2]float64) {
+	switch g.Name() {
+	case "POINT":
+		points = append(points, [2]float64{g.X(0), g.Y(0)})
+	case "POLYGON":
+		for i := 0; i < g.GeometryCount(); i++ {
+			r := g.Geometry(i)
+			ring := make([][2]float64, r.PointCount())
+			for j := range ring {
+				ring[j] = [2]float64{r.X(j), r.Y(j)}
+			}
+			rings = append(rings, ring)
+		}
+	case "MULTIPOINT", "MULTIPOLYGON", "GEOMETRYCOLLECTION":
+		for i := 0; i < g.GeometryCount(); i++ {
+			points, rings = ReadGeometry(g.Geometry(i), points, rings)
+		}
+	}
+	return points, rings
+}
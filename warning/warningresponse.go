@@ -13,6 +13,12 @@ type PopulationReductionFunction func(s structures.StructureDeterministic, hazar
 type WarningResponseSystem interface {
 	WarningFunction() PopulationReductionFunction
 }
+
+// SeedableWarningResponseSystem is a warning response system that can be reseeded so each structure draws from its own random stream.
+type SeedableWarningResponseSystem interface {
+	WarningResponseSystem
+	WithSeed(seed int64) WarningResponseSystem
+}
 type ComplianceBasedWarningSystem struct {
 	rng            *rand.Rand
 	ComplianceRate float64
@@ -21,6 +27,11 @@ type ComplianceBasedWarningSystem struct {
 func InitComplianceBasedWarningSystem(seed int64, complianceRate float64) ComplianceBasedWarningSystem {
 	return ComplianceBasedWarningSystem{rng: rand.New(rand.NewSource(seed)), ComplianceRate: complianceRate}
 }
+
+// WithSeed implements SeedableWarningResponseSystem
+func (c ComplianceBasedWarningSystem) WithSeed(seed int64) WarningResponseSystem {
+	return InitComplianceBasedWarningSystem(seed, c.ComplianceRate)
+}
 func (c ComplianceBasedWarningSystem) WarningFunction() PopulationReductionFunction { //@TODO:Consider housing groups instead of simply assuming each individual is independent
 	return func(s structures.StructureDeterministic, hazard hazards.HazardEvent) (structures.PopulationSet, consequences.Result) {
 		var remainingpop2amo65 int32 = 0